@@ -119,6 +119,16 @@ type Args struct {
 
 	DeployCmd *DeployArgs `arg:"subcommand:deploy" help:"deploy code into a cluster"`
 
+	LintCmd *LintArgs `arg:"subcommand:lint" help:"check mcl code for common mistakes"`
+
+	LspCmd *LspArgs `arg:"subcommand:lsp" help:"run the mcl language server over stdio"`
+
+	TestCmd *TestArgs `arg:"subcommand:test" help:"run the mcl unit tests in *_test.mcl files"`
+
+	ModCmd *ModArgs `arg:"subcommand:mod" help:"manage the modules that mcl code imports"`
+
+	SecretCmd *SecretArgs `arg:"subcommand:secret" help:"manage the secrets that hosts can decrypt"`
+
 	// This never runs, it gets preempted in the real main() function.
 	// XXX: Can we do it nicely with the new arg parser? can it ignore all args?
 	EtcdCmd *EtcdArgs `arg:"subcommand:etcd" help:"run standalone etcd"`
@@ -155,6 +165,26 @@ func (obj *Args) Run(ctx context.Context, data *cliUtil.Data) (bool, error) {
 		return cmd.Run(ctx, data)
 	}
 
+	if cmd := obj.LintCmd; cmd != nil {
+		return cmd.Run(ctx, data)
+	}
+
+	if cmd := obj.LspCmd; cmd != nil {
+		return cmd.Run(ctx, data)
+	}
+
+	if cmd := obj.TestCmd; cmd != nil {
+		return cmd.Run(ctx, data)
+	}
+
+	if cmd := obj.ModCmd; cmd != nil {
+		return cmd.Run(ctx, data)
+	}
+
+	if cmd := obj.SecretCmd; cmd != nil {
+		return cmd.Run(ctx, data)
+	}
+
 	// NOTE: we could return true, fmt.Errorf("...") if more than one did
 	return false, nil // nobody activated
 }
@@ -0,0 +1,198 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	cliUtil "github.com/purpleidea/mgmt/cli/util"
+	"github.com/purpleidea/mgmt/lang/ast"
+	"github.com/purpleidea/mgmt/lang/funcs/vars"
+	"github.com/purpleidea/mgmt/lang/inputs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/interpolate"
+	"github.com/purpleidea/mgmt/lang/lint"
+	"github.com/purpleidea/mgmt/lang/parser"
+	"github.com/purpleidea/mgmt/pgraph"
+	"github.com/purpleidea/mgmt/util"
+	"github.com/purpleidea/mgmt/util/errwrap"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	// LintOutputText is the human readable output format for `lint`.
+	LintOutputText = "text"
+
+	// LintOutputJSON is the machine readable output format for `lint`.
+	LintOutputJSON = "json"
+)
+
+// LintArgs is the CLI parsing structure and type of the parsed result. This
+// particular one contains all the flags for the `lint` subcommand.
+type LintArgs struct {
+	// Input is the input mcl code or file path or any input specification.
+	Input string `arg:"positional,required"`
+
+	Output string `arg:"--output" default:"text" help:"output format, either text or json"`
+
+	ModulePath string `arg:"--module-path,env:MGMT_MODULE_PATH" help:"choose the modules path (absolute)"`
+}
+
+// Run executes the correct subcommand. It errors if there's ever an error. It
+// returns true if we did activate one of the subcommands. It returns false if
+// we did not. This information is used so that the top-level parser can return
+// usage or help information if no subcommand activates. This particular Run is
+// the run for the main `lint` subcommand. It parses the mcl input, and then it
+// runs the static checks from the lint package, which scope-check the code the
+// same way that `run` does, but without unifying or running any of it. The
+// problems are printed to stdout, and an error is returned if there were any,
+// so that this can be used in scripts.
+func (obj *LintArgs) Run(ctx context.Context, data *cliUtil.Data) (bool, error) {
+	if obj.Output != LintOutputText && obj.Output != LintOutputJSON {
+		return false, fmt.Errorf("unknown output format: %s", obj.Output)
+	}
+	modules := obj.ModulePath
+	if modules != "" && (!strings.HasPrefix(modules, "/") || !strings.HasSuffix(modules, "/")) {
+		return false, fmt.Errorf("module path is not an absolute directory")
+	}
+	debug := data.Flags.Debug
+	logf := func(format string, v ...interface{}) {
+		data.Flags.Logf("lint: "+format, v...)
+	}
+
+	osFs := afero.NewReadOnlyFs(afero.NewOsFs())
+	afs := &afero.Afero{Fs: osFs} // wrap so that we're implementing ioutil
+	localFs := &util.AferoFs{Afero: afs}
+
+	output, err := inputs.ParseInput(obj.Input, localFs)
+	if err != nil {
+		return false, errwrap.Wrapf(err, "could not activate an input parser")
+	}
+
+	xast, err := parser.LexParse(bytes.NewReader(output.Main))
+	if err != nil {
+		return false, errwrap.Wrapf(err, "could not generate AST")
+	}
+
+	importGraph, err := pgraph.NewGraph("importGraph")
+	if err != nil {
+		return false, err
+	}
+	importVertex := &pgraph.SelfVertex{
+		Name:  "",          // first node is the empty string
+		Graph: importGraph, // store a reference to ourself
+	}
+	importGraph.AddVertex(importVertex)
+
+	iface := &interfaces.Data{
+		Fs:       output.FS,
+		FsURI:    output.FS.URI(),
+		Base:     output.Base, // base dir (absolute path) that this is rooted in
+		Files:    output.Files,
+		Imports:  importVertex,
+		Metadata: output.Metadata,
+		Modules:  modules,
+
+		LexParser:       parser.LexParse,
+		Downloader:      nil, // we never download when linting
+		StrInterpolater: interpolate.StrInterpolate,
+
+		Debug: debug,
+		Logf: func(format string, v ...interface{}) {
+			logf("ast: "+format, v...)
+		},
+	}
+	if err := xast.Init(iface); err != nil {
+		return false, errwrap.Wrapf(err, "could not init and validate AST")
+	}
+
+	iast, err := xast.Interpolate()
+	if err != nil {
+		return false, errwrap.Wrapf(err, "could not interpolate AST")
+	}
+
+	variables := map[string]interfaces.Expr{
+		"purpleidea": &ast.ExprStr{V: "hello world!"}, // james says hi
+		"hostname":   &ast.ExprStr{V: ""},             // NOTE: empty b/c not used
+	}
+	consts := ast.VarPrefixToVariablesScope(vars.ConstNamespace) // strips prefix!
+	addback := vars.ConstNamespace + interfaces.ModuleSep        // add it back...
+	variables, err = ast.MergeExprMaps(variables, consts, addback)
+	if err != nil {
+		return false, errwrap.Wrapf(err, "couldn't merge in consts")
+	}
+	// top-level, built-in, initial global scope
+	scope := &interfaces.Scope{
+		Variables: variables,
+		// all the built-in top-level, core functions enter here...
+		Functions: ast.FuncPrefixToFunctionsScope(""), // runs funcs.LookupPrefix
+	}
+
+	filename := "" // raw code and stdin don't come from a file
+	if output.Metadata != nil && len(output.Files) > 0 {
+		filename = output.Base + output.Metadata.Main
+	}
+	linter := &lint.Linter{
+		AST:      iast,
+		Scope:    scope,
+		Filename: filename,
+		Debug:    debug,
+		Logf:     logf,
+	}
+	problems, err := linter.Lint()
+	if err != nil {
+		return false, errwrap.Wrapf(err, "could not lint")
+	}
+
+	switch obj.Output {
+	case LintOutputJSON:
+		b, err := json.MarshalIndent(problems, "", "\t")
+		if err != nil {
+			return false, errwrap.Wrapf(err, "could not encode problems")
+		}
+		fmt.Fprintf(os.Stdout, "%s\n", b)
+
+	case LintOutputText:
+		for _, p := range problems {
+			fmt.Fprintf(os.Stdout, "%s (%s)\n", p, p.Node)
+		}
+	}
+
+	if len(problems) > 0 {
+		return false, fmt.Errorf("found %d problem(s)", len(problems))
+	}
+	return true, nil
+}
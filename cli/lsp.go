@@ -0,0 +1,83 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	cliUtil "github.com/purpleidea/mgmt/cli/util"
+	"github.com/purpleidea/mgmt/lang/lsp"
+)
+
+// LspArgs is the CLI parsing structure and type of the parsed result. This
+// particular one contains all the flags for the `lsp` subcommand.
+type LspArgs struct {
+	// Stdio is accepted because most editors pass it, but it is the only
+	// transport that we support at the moment.
+	Stdio bool `arg:"--stdio" help:"use stdin and stdout to communicate (the default)"`
+
+	ModulePath string `arg:"--module-path,env:MGMT_MODULE_PATH" help:"choose the modules path (absolute)"`
+}
+
+// Run executes the correct subcommand. It errors if there's ever an error. It
+// returns true if we did activate one of the subcommands. It returns false if
+// we did not. This information is used so that the top-level parser can return
+// usage or help information if no subcommand activates. This particular Run is
+// the run for the main `lsp` subcommand. It runs a language server for mcl over
+// stdin and stdout until the editor asks it to exit. Since stdout is used for
+// the protocol, all log messages go to stderr.
+func (obj *LspArgs) Run(ctx context.Context, data *cliUtil.Data) (bool, error) {
+	modules := obj.ModulePath
+	if modules != "" && (!strings.HasPrefix(modules, "/") || !strings.HasSuffix(modules, "/")) {
+		return false, fmt.Errorf("module path is not an absolute directory")
+	}
+
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+
+	server := &lsp.Server{
+		In:      os.Stdin,
+		Out:     os.Stdout,
+		Version: data.Version,
+		Modules: modules,
+		Debug:   data.Flags.Debug,
+		Logf: func(format string, v ...interface{}) {
+			data.Flags.Logf("lsp: "+format, v...)
+		},
+	}
+	if err := server.Run(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
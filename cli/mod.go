@@ -0,0 +1,128 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	cliUtil "github.com/purpleidea/mgmt/cli/util"
+	"github.com/purpleidea/mgmt/gapi"
+	langGAPI "github.com/purpleidea/mgmt/lang/gapi"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+// ModArgs is the CLI parsing structure and type of the parsed result. This
+// particular one contains all the subcommands of the `mod` subcommand which is
+// used to manage the modules that mcl code imports.
+type ModArgs struct {
+	ModVendor *ModVendorArgs `arg:"subcommand:vendor" help:"copy all imported modules into the vendor directory"`
+}
+
+// Run executes the correct subcommand. It errors if there's ever an error. It
+// returns true if we did activate one of the subcommands. It returns false if
+// we did not. This information is used so that the top-level parser can return
+// usage or help information if no subcommand activates. This particular Run is
+// the run for the main `mod` subcommand.
+func (obj *ModArgs) Run(ctx context.Context, data *cliUtil.Data) (bool, error) {
+	if cmd := obj.ModVendor; cmd != nil {
+		return cmd.Run(ctx, data)
+	}
+
+	return false, nil // nobody activated
+}
+
+// ModVendorArgs is the CLI parsing structure and type of the parsed result.
+// This particular one contains all the flags for the `mod vendor` subcommand.
+type ModVendorArgs struct {
+	// Input is the input mcl code or file path or any input specification.
+	Input string `arg:"positional,required"`
+
+	Update bool `arg:"--update" help:"update all dependencies to the latest allowed versions, ignoring the lock file"`
+
+	// The default of 0 means any error is a failure by default.
+	Retry int `arg:"--retry" help:"max number of retries (-1 is unlimited)"`
+
+	ModulePath  string `arg:"--module-path,env:MGMT_MODULE_PATH" help:"choose the modules path (absolute)"`
+	ModuleCache string `arg:"--module-cache,env:MGMT_MODULE_CACHE" help:"choose the shared module cache path (absolute)"`
+}
+
+// Run executes the correct subcommand. It errors if there's ever an error. It
+// returns true if we did activate one of the subcommands. It returns false if
+// we did not. This information is used so that the top-level parser can return
+// usage or help information if no subcommand activates. This particular Run is
+// the run for the `mod vendor` subcommand. It downloads every transitive import
+// of the input, and copies them into the vendor/ directory beside the top-level
+// metadata file, where the import resolver finds them when not downloading. It
+// also writes the lock file. If no modules path is given, then the downloads go
+// into a temporary directory which is removed when we're done.
+func (obj *ModVendorArgs) Run(ctx context.Context, data *cliUtil.Data) (bool, error) {
+	modules := obj.ModulePath
+	if modules == "" {
+		dir, err := os.MkdirTemp("", "mgmt-mod-vendor-")
+		if err != nil {
+			return false, errwrap.Wrapf(err, "could not make temporary modules path")
+		}
+		defer os.RemoveAll(dir) // clean up
+		modules = dir + "/"
+	}
+
+	args := &cliUtil.LangArgs{
+		Input:        obj.Input,
+		Download:     true,
+		OnlyDownload: true,
+		Update:       obj.Update,
+		Depth:        -1, // infinite
+		Retry:        obj.Retry,
+		ModulePath:   modules,
+		ModuleCache:  obj.ModuleCache,
+		Vendor:       true,
+	}
+
+	info := &gapi.Info{
+		Args:  args,
+		Flags: &gapi.Flags{},
+		Fs:    nil, // we stop after the download, so we never deploy
+		Debug: data.Flags.Debug,
+		Logf: func(format string, v ...interface{}) {
+			data.Flags.Logf("mod: "+format, v...)
+		},
+	}
+
+	deploy, err := (&langGAPI.GAPI{}).Cli(info)
+	if err != nil {
+		return false, cliUtil.CliParseError(err) // consistent errors
+	}
+	if deploy != nil { // programming error
+		return false, fmt.Errorf("unexpected deploy")
+	}
+	return true, nil
+}
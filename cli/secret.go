@@ -0,0 +1,158 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	cliUtil "github.com/purpleidea/mgmt/cli/util"
+	"github.com/purpleidea/mgmt/etcd/client"
+	"github.com/purpleidea/mgmt/etcd/client/strmap"
+	"github.com/purpleidea/mgmt/lib"
+	"github.com/purpleidea/mgmt/pgp"
+	"github.com/purpleidea/mgmt/util/errwrap"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// SecretArgs is the CLI parsing structure and type of the parsed result. This
+// particular one contains all the subcommands of the `secret` subcommand which
+// is used to work with the secrets that the secret.decrypt function reads.
+type SecretArgs struct {
+	SecretEncrypt *SecretEncryptArgs `arg:"subcommand:encrypt" help:"encrypt a secret from stdin to the pgp keys of some hosts"`
+}
+
+// Run executes the correct subcommand. It errors if there's ever an error. It
+// returns true if we did activate one of the subcommands. It returns false if
+// we did not. This information is used so that the top-level parser can return
+// usage or help information if no subcommand activates. This particular Run is
+// the run for the main `secret` subcommand.
+func (obj *SecretArgs) Run(ctx context.Context, data *cliUtil.Data) (bool, error) {
+	if cmd := obj.SecretEncrypt; cmd != nil {
+		return cmd.Run(ctx, data)
+	}
+
+	return false, nil // nobody activated
+}
+
+// SecretEncryptArgs is the CLI parsing structure and type of the parsed result.
+// This particular one contains all the flags for the `secret encrypt`
+// subcommand.
+type SecretEncryptArgs struct {
+	// Hosts are the names of the hosts which will be able to decrypt the
+	// secret. Their public keys are looked up in the cluster.
+	Hosts []string `arg:"positional" help:"hostnames which can decrypt the secret"`
+
+	Seeds []string `arg:"--seeds,env:MGMT_SEEDS" help:"default etc client endpoint"`
+
+	// KeyFiles are armored public keys which can decrypt the secret. This
+	// can be used instead of, or in addition to the hosts.
+	KeyFiles []string `arg:"--key-file,separate" help:"armored public key file which can decrypt the secret"`
+}
+
+// Run executes the correct subcommand. It errors if there's ever an error. It
+// returns true if we did activate one of the subcommands. It returns false if
+// we did not. This information is used so that the top-level parser can return
+// usage or help information if no subcommand activates. This particular Run is
+// the run for the `secret encrypt` subcommand. It reads the secret from stdin so
+// that it doesn't show up in the list of processes, and prints the ciphertext.
+// A single trailing newline is removed, since `echo` adds one. Each of the hosts
+// must have published its public key, which happens when it runs with pgp on.
+func (obj *SecretEncryptArgs) Run(ctx context.Context, data *cliUtil.Data) (bool, error) {
+	if len(obj.Hosts) == 0 && len(obj.KeyFiles) == 0 {
+		return false, cliUtil.CliParseError(fmt.Errorf("no hosts or key files to encrypt to"))
+	}
+
+	recipients := []*openpgp.Entity{}
+	for _, keyFile := range obj.KeyFiles {
+		b, err := os.ReadFile(keyFile)
+		if err != nil {
+			return false, errwrap.Wrapf(err, "can't read key file")
+		}
+		entity, err := pgp.ParsePublicKey(string(b))
+		if err != nil {
+			return false, errwrap.Wrapf(err, "can't parse key file: %s", keyFile)
+		}
+		recipients = append(recipients, entity)
+	}
+
+	if len(obj.Hosts) > 0 {
+		keys, err := publicKeys(ctx, obj.Seeds, obj.Hosts)
+		if err != nil {
+			return false, err
+		}
+		for _, host := range obj.Hosts {
+			entity, err := pgp.ParsePublicKey(keys[host])
+			if err != nil {
+				return false, errwrap.Wrapf(err, "can't parse the public key of host: %s", host)
+			}
+			recipients = append(recipients, entity)
+		}
+	}
+
+	b, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return false, errwrap.Wrapf(err, "can't read the secret from stdin")
+	}
+	ciphertext, err := pgp.EncryptTo(recipients, strings.TrimSuffix(string(b), "\n"))
+	if err != nil {
+		return false, err
+	}
+	fmt.Println(ciphertext)
+	return true, nil
+}
+
+// publicKeys returns the public keys that these hosts published in the cluster.
+// It errors if any of them are missing.
+func publicKeys(ctx context.Context, seeds, hosts []string) (map[string]string, error) {
+	etcdClient := client.NewClientFromSeedsNamespace(
+		seeds, // endpoints
+		lib.NS,
+	)
+	if err := etcdClient.Init(); err != nil {
+		return nil, errwrap.Wrapf(err, "client Init failed")
+	}
+	defer etcdClient.Close() // nothing useful to do with the error
+
+	keys, err := strmap.GetStrMap(ctx, etcdClient, hosts, pgp.PublicKeyNamespace)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "can't get the public keys")
+	}
+	for _, host := range hosts {
+		if _, exists := keys[host]; !exists {
+			return nil, fmt.Errorf("host %s has not published a public key", host)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,101 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	cliUtil "github.com/purpleidea/mgmt/cli/util"
+	"github.com/purpleidea/mgmt/lang/tester"
+)
+
+// TestArgs is the CLI parsing structure and type of the parsed result. This
+// particular one contains all the flags for the `test` subcommand.
+type TestArgs struct {
+	// Path is a single test file or a directory to search for them in.
+	Path string `arg:"positional" default:"." help:"test file or directory to search for *_test.mcl files"`
+
+	Hostname string `arg:"--hostname" default:"localhost" help:"hostname that the code under test will see"`
+
+	Timeout int `arg:"--timeout" default:"60" help:"seconds to wait for each test to produce a graph"`
+}
+
+// Run executes the correct subcommand. It errors if there's ever an error. It
+// returns true if we did activate one of the subcommands. It returns false if
+// we did not. This information is used so that the top-level parser can return
+// usage or help information if no subcommand activates. This particular Run is
+// the run for the main `test` subcommand. It finds all the mcl test files, runs
+// each one until it produces a resource graph, and checks the assertions in it.
+// The results are printed to stdout, and an error is returned if any failed.
+func (obj *TestArgs) Run(ctx context.Context, data *cliUtil.Data) (bool, error) {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+
+	t := &tester.Tester{
+		Path:     obj.Path,
+		Hostname: obj.Hostname,
+		Timeout:  obj.Timeout,
+		Debug:    data.Flags.Debug,
+		Logf: func(format string, v ...interface{}) {
+			data.Flags.Logf("test: "+format, v...)
+		},
+	}
+	results, err := t.Run(ctx)
+	if err != nil {
+		return false, err
+	}
+	if len(results) == 0 {
+		return false, fmt.Errorf("no test files found")
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Passed() {
+			fmt.Fprintf(os.Stdout, "PASS: %s (%s)\n", result.File, result.Duration)
+			continue
+		}
+		failed++
+		fmt.Fprintf(os.Stdout, "FAIL: %s (%s)\n", result.File, result.Duration)
+		if result.Err != nil {
+			fmt.Fprintf(os.Stdout, "\terror: %s\n", result.Err)
+		}
+		for _, x := range result.Failures {
+			fmt.Fprintf(os.Stdout, "\t%s\n", x)
+		}
+	}
+
+	if failed > 0 {
+		return false, fmt.Errorf("%d of %d test(s) failed", failed, len(results))
+	}
+	return true, nil
+}
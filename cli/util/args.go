@@ -82,7 +82,7 @@ type LangArgs struct {
 
 	Download     bool `arg:"--download" help:"download any missing imports"`
 	OnlyDownload bool `arg:"--only-download" help:"stop after downloading any missing imports"`
-	Update       bool `arg:"--update" help:"update all dependencies to the latest versions"`
+	Update       bool `arg:"--update" help:"update all dependencies to the latest allowed versions, ignoring the lock file"`
 
 	OnlyUnify          bool     `arg:"--only-unify" help:"stop after type unification"`
 	SkipUnify          bool     `arg:"--skip-unify" help:"skip type unification"`
@@ -92,9 +92,23 @@ type LangArgs struct {
 	Depth int `arg:"--depth" default:"-1" help:"max recursion depth limit (-1 is unlimited)"`
 
 	// The default of 0 means any error is a failure by default.
-	Retry int `arg:"--depth" help:"max number of retries (-1 is unlimited)"`
+	Retry int `arg:"--retry" help:"max number of retries (-1 is unlimited)"`
 
-	ModulePath string `arg:"--module-path,env:MGMT_MODULE_PATH" help:"choose the modules path (absolute)"`
+	ModulePath  string `arg:"--module-path,env:MGMT_MODULE_PATH" help:"choose the modules path (absolute)"`
+	ModuleCache string `arg:"--module-cache,env:MGMT_MODULE_CACHE" help:"choose the shared module cache path (absolute)"`
+
+	FuncProfile string `arg:"--func-profile" help:"write a function engine profile to this file on exit (.pprof or .pb.gz for pprof, otherwise json)"`
+
+	FuncGraphviz       string `arg:"--func-graphviz" help:"output file for graphviz data of the function graph"`
+	FuncGraphvizFilter string `arg:"--func-graphviz-filter" help:"graphviz filter to use for the function graph"`
+	FuncInspect        string `arg:"--func-inspect" help:"listen address for an http server which shows the latest values of the running program"`
+	FuncRecord         string `arg:"--func-record" help:"record the values of the functions which aren't pure to this file"`
+	FuncReplay         string `arg:"--func-replay" help:"replay the function values that were recorded in this file"`
+
+	// Vendor is set by the `mod vendor` command, and is not a flag. When it
+	// is true, every downloaded module is copied into the vendor directory
+	// of the top-level module, once all of the downloads are done.
+	Vendor bool `arg:"-"`
 }
 
 // YamlArgs is the yaml CLI parsing structure and type of the parsed result.
@@ -135,7 +149,7 @@ type LangPuppetArgs struct {
 
 	Download     bool `arg:"--download" help:"download any missing imports"`
 	OnlyDownload bool `arg:"--only-download" help:"stop after downloading any missing imports"`
-	Update       bool `arg:"--update" help:"update all dependencies to the latest versions"`
+	Update       bool `arg:"--update" help:"update all dependencies to the latest allowed versions, ignoring the lock file"`
 
 	OnlyUnify bool `arg:"--only-unify" help:"stop after type unification"`
 	SkipUnify bool `arg:"--skip-unify" help:"skip type unification"`
@@ -143,9 +157,18 @@ type LangPuppetArgs struct {
 	Depth int `arg:"--depth" default:"-1" help:"max recursion depth limit (-1 is unlimited)"`
 
 	// The default of 0 means any error is a failure by default.
-	Retry int `arg:"--depth" help:"max number of retries (-1 is unlimited)"`
+	Retry int `arg:"--retry" help:"max number of retries (-1 is unlimited)"`
+
+	ModulePath  string `arg:"--module-path,env:MGMT_MODULE_PATH" help:"choose the modules path (absolute)"`
+	ModuleCache string `arg:"--module-cache,env:MGMT_MODULE_CACHE" help:"choose the shared module cache path (absolute)"`
+
+	FuncProfile string `arg:"--func-profile" help:"write a function engine profile to this file on exit (.pprof or .pb.gz for pprof, otherwise json)"`
 
-	ModulePath string `arg:"--module-path,env:MGMT_MODULE_PATH" help:"choose the modules path (absolute)"`
+	FuncGraphviz       string `arg:"--func-graphviz" help:"output file for graphviz data of the function graph"`
+	FuncGraphvizFilter string `arg:"--func-graphviz-filter" help:"graphviz filter to use for the function graph"`
+	FuncInspect        string `arg:"--func-inspect" help:"listen address for an http server which shows the latest values of the running program"`
+	FuncRecord         string `arg:"--func-record" help:"record the values of the functions which aren't pure to this file"`
+	FuncReplay         string `arg:"--func-replay" help:"replay the function values that were recorded in this file"`
 
 	// end LangArgs
 }
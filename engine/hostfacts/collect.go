@@ -0,0 +1,151 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package hostfacts
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/purpleidea/mgmt/util/errwrap"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// MeminfoFile is where we read the memory values from.
+	MeminfoFile = "/proc/meminfo"
+)
+
+// Collect gathers the current facts about the local host. The facts which can't
+// be determined on this platform are left empty.
+func Collect(hostname string) (*Facts, error) {
+	addresses, err := addresses()
+	if err != nil {
+		return nil, err
+	}
+
+	kernelVersion := ""
+	uts := &unix.Utsname{}
+	if err := unix.Uname(uts); err == nil {
+		kernelVersion = unix.ByteSliceToString(uts.Release[:])
+	}
+
+	return &Facts{
+		Hostname:       hostname,
+		Addresses:      addresses,
+		CPUCount:       int64(runtime.NumCPU()),
+		Memory:         memory(MeminfoFile),
+		KernelVersion:  kernelVersion,
+		Virtualization: Virtualization("/"),
+		Vendor:         ReadString(path.Join(DMIDir, "sys_vendor")),
+		Product:        ReadString(path.Join(DMIDir, "product_name")),
+	}, nil
+}
+
+// addresses returns the sorted list of global unicast addresses on this host.
+func addresses() ([]string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	result := []string{}
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || !ipnet.IP.IsGlobalUnicast() {
+			continue
+		}
+		result = append(result, ipnet.IP.String())
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// memory returns the total memory in bytes from this meminfo file, or zero if
+// it can't be read.
+func memory(p string) int64 {
+	f, err := os.Open(p)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	m, err := ParseMeminfo(f)
+	if err != nil {
+		return 0
+	}
+	return m["MemTotal"]
+}
+
+// ParseMeminfo parses the contents of /proc/meminfo and returns each of the
+// values in bytes.
+func ParseMeminfo(r io.Reader) (map[string]int64, error) {
+	m := make(map[string]int64)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// lines look like: `MemTotal:       16318908 kB`
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		i, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "invalid value for %s", key)
+		}
+		if len(fields) > 1 {
+			if fields[1] != "kB" {
+				return nil, fmt.Errorf("unknown unit for %s: %s", key, fields[1])
+			}
+			i *= 1024
+		}
+		m[key] = i
+	}
+	return m, scanner.Err()
+}
+
+// ReadString returns the trimmed contents of a file, or an empty string if it
+// can't be read.
+func ReadString(p string) string {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
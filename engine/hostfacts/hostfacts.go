@@ -0,0 +1,168 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+// Package hostfacts collects facts about the local host, such as the type of
+// virtualization that we are running in. It also publishes a curated set of
+// them into the cluster through the World API, so that the other hosts can read
+// them back without any hand-rolled exchange plumbing. Each host stores its
+// facts as a json encoded string in a single, reserved string map namespace.
+package hostfacts
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/purpleidea/mgmt/engine"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// Namespace is the World string map namespace that each host publishes
+	// its facts into. It is reserved and shouldn't be used with exchange.
+	Namespace = "_facts"
+
+	// DefaultInterval is how often the facts are collected again if no
+	// other interval is specified.
+	DefaultInterval = 60 * time.Second
+
+	// removeTimeout is how long we wait to remove our facts on shutdown.
+	removeTimeout = 5 * time.Second
+)
+
+// Facts is the curated set of facts that each host publishes. The struct tags
+// are used for both the json encoding and for the names of the struct fields
+// in the language.
+type Facts struct {
+	// Hostname is the name of the host that published these facts.
+	Hostname string `json:"hostname" lang:"hostname"`
+
+	// Addresses is the sorted list of global unicast ip addresses that are
+	// configured on the host. Loopback and link local ones are skipped.
+	Addresses []string `json:"addresses" lang:"addresses"`
+
+	// CPUCount is the number of logical cpus that are usable.
+	CPUCount int64 `json:"cpu_count" lang:"cpu_count"`
+
+	// Memory is the total memory in bytes.
+	Memory int64 `json:"memory" lang:"memory"`
+
+	// KernelVersion is the release of the running kernel.
+	KernelVersion string `json:"kernel_version" lang:"kernel_version"`
+
+	// Virtualization is the type of virtualization that the host is running
+	// in, or `none`. See the Virtualization function for more details.
+	Virtualization string `json:"virtualization" lang:"virtualization"`
+
+	// Vendor is the DMI system vendor, if it's available.
+	Vendor string `json:"vendor" lang:"vendor"`
+
+	// Product is the DMI product name, if it's available.
+	Product string `json:"product" lang:"product"`
+}
+
+// Decode parses the facts that a host published.
+func Decode(data string) (*Facts, error) {
+	facts := &Facts{}
+	if err := json.Unmarshal([]byte(data), facts); err != nil {
+		return nil, err
+	}
+	if facts.Addresses == nil {
+		facts.Addresses = []string{} // be consistent
+	}
+	return facts, nil
+}
+
+// Publisher collects the local facts and keeps them up to date in the World. It
+// removes them again when it shuts down, so that a host which is gone no longer
+// has any facts. A host which crashes will leave its last facts behind.
+type Publisher struct {
+	// Hostname is the name which we publish our facts under.
+	Hostname string
+
+	// World is the World API that we publish into.
+	World engine.World
+
+	// Interval is how often we collect the facts again. If this is zero,
+	// then the DefaultInterval is used.
+	Interval time.Duration
+
+	Debug bool
+	Logf  func(format string, v ...interface{})
+}
+
+// Run publishes the facts until the context is cancelled. Errors talking to the
+// World are logged and retried at the next interval, since they're often only a
+// temporary problem with the cluster.
+func (obj *Publisher) Run(ctx context.Context) error {
+	interval := obj.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	defer func() {
+		// the main context is already cancelled when we get here
+		ctx, cancel := context.WithTimeout(context.Background(), removeTimeout)
+		defer cancel()
+		if err := obj.World.StrMapDel(ctx, Namespace); err != nil {
+			obj.Logf("could not remove facts: %+v", err)
+		}
+	}()
+
+	last := ""
+	for {
+		facts, err := Collect(obj.Hostname)
+		if err != nil {
+			return errwrap.Wrapf(err, "could not collect facts")
+		}
+		b, err := json.Marshal(facts)
+		if err != nil {
+			return errwrap.Wrapf(err, "could not encode facts")
+		}
+
+		if s := string(b); s != last {
+			if err := obj.World.StrMapSet(ctx, Namespace, s); err != nil {
+				obj.Logf("could not publish facts: %+v", err)
+			} else {
+				last = s
+				if obj.Debug {
+					obj.Logf("published: %s", s)
+				}
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
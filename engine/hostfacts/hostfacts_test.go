@@ -0,0 +1,125 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package hostfacts
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	facts := &Facts{
+		Hostname:       "h1",
+		Addresses:      []string{"192.0.2.1", "2001:db8::1"},
+		CPUCount:       4,
+		Memory:         8 * 1024 * 1024 * 1024,
+		KernelVersion:  "6.1.0",
+		Virtualization: VirtualizationNone,
+	}
+	b, err := json.Marshal(facts)
+	if err != nil {
+		t.Errorf("could not encode: %+v", err)
+		return
+	}
+	out, err := Decode(string(b))
+	if err != nil {
+		t.Errorf("could not decode: %+v", err)
+		return
+	}
+	if !reflect.DeepEqual(facts, out) {
+		t.Errorf("expected %+v, got %+v", facts, out)
+	}
+
+	out, err = Decode(`{"hostname": "h2"}`)
+	if err != nil {
+		t.Errorf("could not decode: %+v", err)
+		return
+	}
+	if out.Addresses == nil {
+		t.Errorf("expected an empty list of addresses")
+	}
+
+	if _, err := Decode(`{"hostname": 42}`); err == nil {
+		t.Errorf("expected an error for invalid facts")
+	}
+}
+
+func TestMemory(t *testing.T) {
+	p := path.Join(t.TempDir(), "meminfo")
+	data := "MemTotal:       16318908 kB\nMemFree:         1130208 kB\n"
+	if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+		t.Errorf("could not write file: %+v", err)
+		return
+	}
+	if m := memory(p); m != 16318908*1024 {
+		t.Errorf("unexpected memory: %d", m)
+	}
+	if m := memory(path.Join(t.TempDir(), "missing")); m != 0 {
+		t.Errorf("expected no memory for a missing file, got: %d", m)
+	}
+}
+
+func TestParseMeminfo(t *testing.T) {
+	data := `MemTotal:       16318908 kB
+MemFree:         1130208 kB
+MemAvailable:    9262792 kB
+HugePages_Total:       0
+SwapTotal:       8388604 kB
+SwapFree:        8388604 kB
+`
+	m, err := ParseMeminfo(strings.NewReader(data))
+	if err != nil {
+		t.Errorf("could not ParseMeminfo: %+v", err)
+		return
+	}
+	expected := map[string]int64{
+		"MemTotal":        16318908 * 1024,
+		"MemFree":         1130208 * 1024,
+		"MemAvailable":    9262792 * 1024,
+		"HugePages_Total": 0,
+		"SwapTotal":       8388604 * 1024,
+		"SwapFree":        8388604 * 1024,
+	}
+	for k, v := range expected {
+		if m[k] != v {
+			t.Errorf("expected %s to be %d, got %d", k, v, m[k])
+		}
+	}
+
+	if _, err := ParseMeminfo(strings.NewReader("MemTotal: 42 MB\n")); err == nil {
+		t.Errorf("expected an error for an unknown unit")
+	}
+}
@@ -0,0 +1,120 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package hostfacts
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	// DMIDir is where the kernel exposes the DMI (SMBIOS) information.
+	DMIDir = "/sys/class/dmi/id/"
+
+	// VirtualizationNone is what Virtualization returns on bare metal.
+	VirtualizationNone = "none"
+
+	// VirtualizationOther is what Virtualization returns if it knows that
+	// we are virtualized, but not by what.
+	VirtualizationOther = "other"
+)
+
+// Virtualization works out the type of virtualization that we are running in by
+// looking at the files under root, which is usually `/`. Containers are checked
+// first, so a container inside of a virtual machine returns the container type.
+// The names are similar to what systemd-detect-virt uses, such as `kvm`,
+// `vmware`, `docker` or `lxc`.
+func Virtualization(root string) string {
+	exists := func(p string) bool {
+		_, err := os.Stat(path.Join(root, p))
+		return err == nil
+	}
+	read := func(p string) []byte {
+		b, _ := os.ReadFile(path.Join(root, p)) // errors mean we don't know
+		return b
+	}
+
+	// containers...
+	if exists("/.dockerenv") {
+		return "docker"
+	}
+	if exists("/run/.containerenv") {
+		return "podman"
+	}
+	// this usually needs root, but systemd sets it in some containers
+	for _, env := range bytes.Split(read("/proc/1/environ"), []byte{0}) {
+		if s, ok := strings.CutPrefix(string(env), "container="); ok && s != "" {
+			return s
+		}
+	}
+
+	// virtual machines...
+	vendor := strings.TrimSpace(string(read(path.Join(DMIDir, "sys_vendor"))))
+	product := strings.TrimSpace(string(read(path.Join(DMIDir, "product_name"))))
+	if s := DMIVirtualization(vendor, product); s != "" {
+		return s
+	}
+	if exists("/proc/xen") {
+		return "xen"
+	}
+	for _, line := range strings.Split(string(read("/proc/cpuinfo")), "\n") {
+		if strings.HasPrefix(line, "flags") && strings.Contains(line, " hypervisor") {
+			return VirtualizationOther
+		}
+	}
+
+	return VirtualizationNone
+}
+
+// DMIVirtualization returns the type of virtual machine that has this DMI
+// vendor and product name, or an empty string if it isn't a known one.
+func DMIVirtualization(vendor, product string) string {
+	switch {
+	case strings.Contains(product, "KVM"):
+		return "kvm"
+	case strings.Contains(vendor, "QEMU") || strings.Contains(product, "QEMU"):
+		return "qemu"
+	case strings.Contains(vendor, "VMware"):
+		return "vmware"
+	case strings.Contains(vendor, "innotek") || strings.Contains(product, "VirtualBox"):
+		return "oracle"
+	case strings.Contains(vendor, "Xen"):
+		return "xen"
+	case vendor == "Microsoft Corporation" && product == "Virtual Machine":
+		return "microsoft"
+	case strings.Contains(vendor, "Amazon EC2"):
+		return "amazon"
+	case vendor == "Google" && product == "Google Compute Engine":
+		return "google"
+	}
+	return ""
+}
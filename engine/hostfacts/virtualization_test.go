@@ -0,0 +1,115 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package hostfacts
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestVirtualization(t *testing.T) {
+	values := []struct {
+		desc     string
+		files    map[string]string
+		expected string
+	}{
+		{
+			desc:     "bare metal",
+			files:    map[string]string{"/proc/cpuinfo": "flags\t\t: fpu vme de pse\n"},
+			expected: VirtualizationNone,
+		},
+		{
+			desc:     "docker",
+			files:    map[string]string{"/.dockerenv": ""},
+			expected: "docker",
+		},
+		{
+			desc:     "systemd-nspawn",
+			files:    map[string]string{"/proc/1/environ": "PATH=/bin\x00container=systemd-nspawn\x00"},
+			expected: "systemd-nspawn",
+		},
+		{
+			desc: "qemu",
+			files: map[string]string{
+				DMIDir + "sys_vendor":   "QEMU\n",
+				DMIDir + "product_name": "Standard PC (Q35 + ICH9, 2009)\n",
+			},
+			expected: "qemu",
+		},
+		{
+			desc:     "unknown hypervisor",
+			files:    map[string]string{"/proc/cpuinfo": "flags\t\t: fpu vme de pse hypervisor lahf_lm\n"},
+			expected: VirtualizationOther,
+		},
+	}
+
+	for _, tt := range values {
+		t.Run(tt.desc, func(t *testing.T) {
+			root := t.TempDir()
+			for p, data := range tt.files {
+				f := path.Join(root, p)
+				if err := os.MkdirAll(path.Dir(f), 0755); err != nil {
+					t.Errorf("could not make dir: %+v", err)
+					return
+				}
+				if err := os.WriteFile(f, []byte(data), 0644); err != nil {
+					t.Errorf("could not write file: %+v", err)
+					return
+				}
+			}
+			if s := Virtualization(root); s != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, s)
+			}
+		})
+	}
+}
+
+func TestDMIVirtualization(t *testing.T) {
+	values := []struct {
+		vendor   string
+		product  string
+		expected string
+	}{
+		{"QEMU", "KVM", "kvm"},
+		{"VMware, Inc.", "VMware Virtual Platform", "vmware"},
+		{"innotek GmbH", "VirtualBox", "oracle"},
+		{"Microsoft Corporation", "Virtual Machine", "microsoft"},
+		{"Microsoft Corporation", "Surface Laptop", ""},
+		{"Dell Inc.", "PowerEdge R640", ""},
+	}
+	for _, x := range values {
+		if s := DMIVirtualization(x.vendor, x.product); s != x.expected {
+			t.Errorf("expected %q for %s/%s, got %q", x.expected, x.vendor, x.product, s)
+		}
+	}
+}
@@ -44,6 +44,12 @@ import (
 	"github.com/purpleidea/mgmt/util"
 )
 
+// Decrypter is the interface of something which can decrypt messages. This is
+// usually the pgp key pair of the host.
+type Decrypter interface {
+	Decrypt(string) (string, error)
+}
+
 // API implements the base handle for all the methods in this package. If we
 // were going to have more than one implementation for all of these, then this
 // would be an interface instead, and different packages would implement it.
@@ -53,6 +59,10 @@ type API struct {
 	Debug  bool
 	Logf   func(format string, v ...interface{})
 
+	// Decrypter decrypts the secrets which were encrypted to the pgp key
+	// of this host. It is nil if pgp is disabled.
+	Decrypter Decrypter
+
 	// Each piece of the API can take a handle here.
 	*Value
 }
@@ -33,6 +33,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"os/user"
 	"sort"
@@ -42,6 +43,7 @@ import (
 
 	"github.com/purpleidea/mgmt/engine"
 	"github.com/purpleidea/mgmt/engine/traits"
+	"github.com/purpleidea/mgmt/lang/types"
 	"github.com/purpleidea/mgmt/util/errwrap"
 	"github.com/purpleidea/mgmt/util/recwatch"
 )
@@ -50,7 +52,10 @@ func init() {
 	engine.RegisterResource("user", func() engine.Res { return &UserRes{} })
 }
 
-const passwdFile = "/etc/passwd"
+const (
+	passwdFile = "/etc/passwd"
+	shadowFile = "/etc/shadow"
+)
 
 // UserRes is a user account resource.
 type UserRes struct {
@@ -78,6 +83,12 @@ type UserRes struct {
 	// HomeDir is the path to the user's home directory.
 	HomeDir *string `lang:"homedir" yaml:"homedir"`
 
+	// Password is the hashed password of the user, in the format used by
+	// the shadow file, such as what the crypto.sha512_crypt function
+	// returns. It is a secret, so it doesn't get printed. If it is not
+	// specified, then the password is not managed.
+	Password *types.Secret `lang:"password" yaml:"password"`
+
 	// AllowDuplicateUID is needed for a UID to be non-unique. This is rare
 	// but happens if you want more than one username to access the
 	// resources of the same UID. See the --non-unique flag in `useradd`.
@@ -111,6 +122,9 @@ func (obj *UserRes) Validate() error {
 			}
 		}
 	}
+	if obj.Password != nil && obj.Password.ContainsAny(":\n") {
+		return fmt.Errorf("password contains invalid character(s)")
+	}
 	if obj.Groups != nil {
 		for _, group := range obj.Groups {
 			if group == "" {
@@ -147,6 +161,16 @@ func (obj *UserRes) Watch(ctx context.Context) error {
 	}
 	defer obj.recWatcher.Close()
 
+	var shadowEvents chan recwatch.Event // nil unless we need to watch it
+	if obj.Password != nil {
+		shadowWatcher, err := recwatch.NewRecWatcher(shadowFile, false)
+		if err != nil {
+			return err
+		}
+		defer shadowWatcher.Close()
+		shadowEvents = shadowWatcher.Events()
+	}
+
 	obj.init.Running() // when started, notify engine that we're running
 
 	var send = false // send event?
@@ -168,6 +192,18 @@ func (obj *UserRes) Watch(ctx context.Context) error {
 			}
 			send = true
 
+		case event, ok := <-shadowEvents:
+			if !ok { // channel shutdown
+				return nil
+			}
+			if err := event.Error; err != nil {
+				return errwrap.Wrapf(err, "Unknown %s watcher error", obj)
+			}
+			if obj.init.Debug { // don't access event.Body if event.Error isn't nil
+				obj.init.Logf("Event(%s): %v", event.Body.Name, event.Body.Op)
+			}
+			send = true
+
 		case <-ctx.Done(): // closed by the engine to signal shutdown
 			return nil
 		}
@@ -208,7 +244,17 @@ func (obj *UserRes) CheckApply(ctx context.Context, apply bool) (bool, error) {
 		return true, nil
 	}
 
-	if usercheck := true; exists && obj.State == "exists" {
+	passcheck := true // is the password correct?
+	if exists && obj.State == "exists" && obj.Password != nil {
+		current, err := shadowPassword(obj.Name())
+		if err != nil {
+			return false, err
+		}
+		passcheck = current == obj.Password.Reveal()
+	}
+
+	usercheck := true // are the other fields correct?
+	if exists && obj.State == "exists" {
 		intUID, err := strconv.Atoi(usr.Uid)
 		if err != nil {
 			return false, errwrap.Wrapf(err, "error casting UID to int")
@@ -226,7 +272,7 @@ func (obj *UserRes) CheckApply(ctx context.Context, apply bool) (bool, error) {
 		if obj.HomeDir != nil && *obj.HomeDir != usr.HomeDir {
 			usercheck = false
 		}
-		if usercheck {
+		if usercheck && passcheck {
 			return true, nil
 		}
 	}
@@ -235,6 +281,11 @@ func (obj *UserRes) CheckApply(ctx context.Context, apply bool) (bool, error) {
 		return false, nil
 	}
 
+	if exists && obj.State == "exists" && usercheck { // only the password
+		obj.init.Logf("Setting password of user: %s", obj.Name())
+		return false, obj.setPassword()
+	}
+
 	var cmdName string
 	var args []string
 	if obj.State == "exists" {
@@ -271,33 +322,73 @@ func (obj *UserRes) CheckApply(ctx context.Context, apply bool) (bool, error) {
 
 	args = append(args, obj.Name())
 
+	if err := userCmd(nil, cmdName, args...); err != nil {
+		return false, err
+	}
+
+	if obj.State == "exists" && obj.Password != nil && !(exists && passcheck) {
+		obj.init.Logf("Setting password of user: %s", obj.Name())
+		if err := obj.setPassword(); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// setPassword sets the hashed password of the user. It's passed on stdin so
+// that it doesn't appear in the list of processes.
+func (obj *UserRes) setPassword() error {
+	stdin := strings.NewReader(obj.Name() + ":" + obj.Password.Reveal() + "\n")
+	return userCmd(stdin, "chpasswd", "--encrypted")
+}
+
+// userCmd runs one of the commands which manage users. If it fails, then the
+// error contains what it printed to stderr.
+func userCmd(stdin io.Reader, cmdName string, args ...string) error {
 	cmd := exec.Command(cmdName, args...)
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Setpgid: true,
 		Pgid:    0,
 	}
+	cmd.Stdin = stdin
 
 	// open a pipe to get error messages from os/exec
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return false, errwrap.Wrapf(err, "failed to initialize stderr pipe")
+		return errwrap.Wrapf(err, "failed to initialize stderr pipe")
 	}
 
 	// start the command
 	if err := cmd.Start(); err != nil {
-		return false, errwrap.Wrapf(err, "cmd failed to start")
+		return errwrap.Wrapf(err, "cmd failed to start")
 	}
 	// capture any error messages
 	slurp, err := io.ReadAll(stderr)
 	if err != nil {
-		return false, errwrap.Wrapf(err, "error slurping error message")
+		return errwrap.Wrapf(err, "error slurping error message")
 	}
 	// wait until cmd exits and return error message if any
 	if err := cmd.Wait(); err != nil {
-		return false, errwrap.Wrapf(err, "%s", slurp)
+		return errwrap.Wrapf(err, "%s", slurp)
 	}
+	return nil
+}
 
-	return false, nil
+// shadowPassword returns the hashed password of a user from the shadow file. It
+// is empty if the user isn't in there.
+func shadowPassword(name string) (string, error) {
+	b, err := os.ReadFile(shadowFile)
+	if err != nil {
+		return "", errwrap.Wrapf(err, "error reading the shadow file")
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) >= 2 && fields[0] == name {
+			return fields[1], nil
+		}
+	}
+	return "", nil
 }
 
 // Cmp compares two resources and returns an error if they are not equivalent.
@@ -352,6 +443,14 @@ func (obj *UserRes) Cmp(r engine.Res) error {
 			return fmt.Errorf("the HomeDir differs")
 		}
 	}
+	if (obj.Password == nil) != (res.Password == nil) {
+		return fmt.Errorf("the Password differs")
+	}
+	if obj.Password != nil && res.Password != nil {
+		if *obj.Password != *res.Password {
+			return fmt.Errorf("the Password differs")
+		}
+	}
 	if obj.AllowDuplicateUID != res.AllowDuplicateUID {
 		return fmt.Errorf("the AllowDuplicateUID differs")
 	}
@@ -43,6 +43,7 @@ import (
 	"github.com/purpleidea/mgmt/engine"
 	"github.com/purpleidea/mgmt/engine/traits"
 	engineUtil "github.com/purpleidea/mgmt/engine/util"
+	"github.com/purpleidea/mgmt/lang/types"
 	"github.com/purpleidea/mgmt/util"
 	"github.com/purpleidea/mgmt/util/errwrap"
 
@@ -138,10 +139,11 @@ type VirtRes struct {
 	guestAgentConnected bool // our tracking of if guest agent is running
 }
 
-// VirtAuth is used to pass credentials to libvirt.
+// VirtAuth is used to pass credentials to libvirt. The password is a secret, so
+// it doesn't get printed.
 type VirtAuth struct {
-	Username string `lang:"username" yaml:"username"`
-	Password string `lang:"password" yaml:"password"`
+	Username string       `lang:"username" yaml:"username"`
+	Password types.Secret `lang:"password" yaml:"password"`
 }
 
 // Cmp compares two VirtAuth structs. It errors if they are not identical.
@@ -277,7 +279,7 @@ func (obj *VirtRes) connect() (conn *libvirt.Connect, err error) {
 					cred.Result = obj.Auth.Username
 					cred.ResultLen = len(cred.Result)
 				} else if cred.Type == libvirt.CRED_PASSPHRASE {
-					cred.Result = obj.Auth.Password
+					cred.Result = obj.Auth.Password.Reveal()
 					cred.ResultLen = len(cred.Result)
 				}
 			}
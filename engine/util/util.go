@@ -40,6 +40,7 @@ import (
 	"os"
 	"os/user"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -352,6 +353,47 @@ func ResToParamValues(res engine.Res) (map[string]types.Value, error) {
 	return ret, nil
 }
 
+// ResToFieldsString is a helper function to store the fields of a resource as a
+// text format for test comparisons. It adds one line for each non-zero field. If
+// the resource contains grouped resources, then these get listed too, and each
+// of those also adds a line for the vertex itself.
+func ResToFieldsString(res engine.Res) (string, error) {
+	m, err := ResToParamValues(res)
+	if err != nil {
+		return "", errwrap.Wrapf(err, "can't read resource %s", res)
+	}
+	str := ""
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // sort for determinism
+	for _, field := range keys {
+		v := m[field]
+		str += fmt.Sprintf("Field: %s[%s].%s = %s\n", res.Kind(), res.Name(), field, v)
+	}
+
+	groupableRes, ok := res.(engine.GroupableRes)
+	if !ok {
+		return str, nil
+	}
+	for _, x := range groupableRes.GetGroup() { // grouped elements
+		s, err := ResToFieldsString(x) // recurse
+		if err != nil {
+			return "", err
+		}
+		s += fmt.Sprintf("Vertex: %s\n", x) // add one for the res itself!
+
+		// add a prefix to each line?
+		s = strings.Trim(s, "\n") // trim trailing newlines
+		for _, f := range strings.Split(s, "\n") {
+			str += fmt.Sprintf("Group: %s: ", res) + f + "\n"
+		}
+		//str += s
+	}
+	return str, nil
+}
+
 // GetUID returns the UID of an user. It supports an UID or an username. Caller
 // should first check user is not empty. It will return an error if it can't
 // lookup the UID or username.
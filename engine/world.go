@@ -49,6 +49,8 @@ type World interface { // TODO: is there a better name for this interface?
 	IdealClusterSizeGet(context.Context) (uint16, error)
 	IdealClusterSizeSet(context.Context, uint16) (bool, error)
 
+	// The values of the str methods are stored in plaintext, so a caller
+	// must refuse to pass in anything which holds a secret.
 	StrWatch(ctx context.Context, namespace string) (chan error, error)
 	StrIsNotExist(error) bool
 	StrGet(ctx context.Context, namespace string) (string, error)
@@ -61,6 +63,16 @@ type World interface { // TODO: is there a better name for this interface?
 	StrMapSet(ctx context.Context, namespace, value string) error
 	StrMapDel(ctx context.Context, namespace string) error
 
+	// The typed variants store a type string beside each value, so that a
+	// value of any type can be encoded into the string. They use the same
+	// namespaces as the above, so the same watch and delete methods apply.
+	// The type is empty for a value that was set by an untyped method, and
+	// the untyped methods remove any stored type when they set a value.
+	TypedStrGet(ctx context.Context, namespace string) (typ, value string, err error)
+	TypedStrSet(ctx context.Context, namespace, typ, value string) error
+	TypedStrMapGet(ctx context.Context, namespace string) (values, types map[string]string, err error)
+	TypedStrMapSet(ctx context.Context, namespace, typ, value string) error
+
 	Scheduler(namespace string, opts ...scheduler.Option) (*scheduler.Result, error)
 
 	// Fs takes a URI and returns the filesystem that corresponds to that.
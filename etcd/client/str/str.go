@@ -34,6 +34,7 @@ import (
 	"fmt"
 
 	"github.com/purpleidea/mgmt/etcd/interfaces"
+	etcdUtil "github.com/purpleidea/mgmt/etcd/util"
 	"github.com/purpleidea/mgmt/util/errwrap"
 
 	etcd "go.etcd.io/etcd/client/v3"
@@ -44,7 +45,8 @@ const (
 	ns = "" // in case we want to add one back in
 )
 
-// WatchStr returns a channel which spits out events on key activity.
+// WatchStr returns a channel which spits out events on key activity. This
+// includes changes to the type which is stored beside the value.
 // FIXME: It should close the channel when it's done, and spit out errors when
 // something goes wrong.
 // XXX: since the caller of this (via the World API) has no way to tell it it's
@@ -54,8 +56,21 @@ const (
 // shutdown?
 func WatchStr(ctx context.Context, client interfaces.Client, key string) (chan error, error) {
 	// new key structure is $NS/strings/$key = $data
+	// type structure is $NS/strtypes/$key = $type
 	path := fmt.Sprintf("%s/strings/%s", ns, key)
-	return client.Watcher(ctx, path)
+	typePath := fmt.Sprintf("%s/strtypes/%s", ns, key)
+	ctx, cancel := context.WithCancel(ctx)
+	ch, err := client.Watcher(ctx, path)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	typeCh, err := client.Watcher(ctx, typePath)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return etcdUtil.MergeWatchers(ctx, cancel, ch, typeCh), nil
 }
 
 // GetStr collects the string which matches a global namespace in etcd.
@@ -83,27 +98,77 @@ func GetStr(ctx context.Context, client interfaces.Client, key string) (string,
 	return val, nil
 }
 
+// GetTypedStr collects the string which matches a global namespace in etcd,
+// along with the type that was stored beside it. The type is empty if the
+// string was set without one. Both are read at the same revision.
+func GetTypedStr(ctx context.Context, client interfaces.Client, key string) (string, string, error) {
+	// key structure is $NS/strings/$key = $data
+	// type structure is $NS/strtypes/$key = $type
+	path := fmt.Sprintf("%s/strings/%s", ns, key)
+	typePath := fmt.Sprintf("%s/strtypes/%s", ns, key)
+	ops := []etcd.Op{
+		etcd.OpGet(path),
+		etcd.OpGet(typePath),
+	}
+	resp, err := client.Txn(ctx, nil, ops, nil)
+	if err != nil {
+		return "", "", errwrap.Wrapf(err, "could not get strings in: %s", key)
+	}
+	if count := len(resp.Responses); count != len(ops) {
+		return "", "", fmt.Errorf("returned %d responses", count)
+	}
+
+	kvs := resp.Responses[0].GetResponseRange().GetKvs()
+	if len(kvs) == 0 {
+		return "", "", interfaces.ErrNotExist
+	}
+	val := string(kvs[0].Value)
+
+	typ := ""
+	if kvs := resp.Responses[1].GetResponseRange().GetKvs(); len(kvs) > 0 {
+		typ = string(kvs[0].Value)
+	}
+
+	return typ, val, nil
+}
+
 // SetStr sets a key and hostname pair to a certain value. If the value is nil,
-// then it deletes the key. Otherwise the value should point to a string.
+// then it deletes the key. Otherwise the value should point to a string. Any
+// type that was stored beside a previous value is removed.
 // TODO: TTL or delete disconnect?
 func SetStr(ctx context.Context, client interfaces.Client, key string, data *string) error {
+	return SetTypedStr(ctx, client, key, "", data)
+}
+
+// SetTypedStr is like SetStr, but it also stores a type beside the value. If the
+// type is empty, then the value is stored without one.
+func SetTypedStr(ctx context.Context, client interfaces.Client, key, typ string, data *string) error {
 	// key structure is $NS/strings/$key = $data
+	// type structure is $NS/strtypes/$key = $type
 	path := fmt.Sprintf("%s/strings/%s", ns, key)
+	typePath := fmt.Sprintf("%s/strtypes/%s", ns, key)
 	ifs := []etcd.Cmp{} // list matching the desired state
 	ops := []etcd.Op{}  // list of ops in this transaction (then)
 	els := []etcd.Op{}  // list of ops in this transaction (else)
 	if data == nil {    // perform a delete
 		ifs = append(ifs, etcdutil.KeyExists(path))
 		//ifs = append(ifs, etcd.Compare(etcd.Version(path), ">", 0))
-		ops = append(ops, etcd.OpDelete(path))
+		ops = append(ops, etcd.OpDelete(path), etcd.OpDelete(typePath))
 	} else {
 		data := *data                                                // get the real value
 		ifs = append(ifs, etcd.Compare(etcd.Value(path), "=", data)) // desired state
 		els = append(els, etcd.OpPut(path, data))
+		if typ == "" {
+			ifs = append(ifs, etcdutil.KeyMissing(typePath))
+			els = append(els, etcd.OpDelete(typePath))
+		} else {
+			ifs = append(ifs, etcd.Compare(etcd.Value(typePath), "=", typ))
+			els = append(els, etcd.OpPut(typePath, typ))
+		}
 	}
 
 	// it's important to do this in one transaction, and atomically, because
-	// this way, we only generate one watch event, and only when it's needed
+	// this way, we only generate one watch event per key, and only when needed
 	_, err := client.Txn(ctx, ifs, ops, els) // TODO: do we need to look at response?
 	return errwrap.Wrapf(err, "could not set strings in: %s", key)
 }
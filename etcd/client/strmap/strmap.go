@@ -35,6 +35,7 @@ import (
 	"strings"
 
 	"github.com/purpleidea/mgmt/etcd/interfaces"
+	etcdUtil "github.com/purpleidea/mgmt/etcd/util"
 	"github.com/purpleidea/mgmt/util"
 	"github.com/purpleidea/mgmt/util/errwrap"
 
@@ -46,13 +47,27 @@ const (
 	ns = "" // in case we want to add one back in
 )
 
-// WatchStrMap returns a channel which spits out events on key activity.
+// WatchStrMap returns a channel which spits out events on key activity. This
+// includes changes to the type which is stored beside the value.
 // FIXME: It should close the channel when it's done, and spit out errors when
 // something goes wrong.
 func WatchStrMap(ctx context.Context, client interfaces.Client, key string) (chan error, error) {
 	// new key structure is $NS/strings/$key/$hostname = $data
+	// type structure is $NS/strmaptypes/$key/$hostname = $type
 	path := fmt.Sprintf("%s/strings/%s", ns, key)
-	return client.Watcher(ctx, path, etcd.WithPrefix())
+	typePath := fmt.Sprintf("%s/strmaptypes/%s", ns, key)
+	ctx, cancel := context.WithCancel(ctx)
+	ch, err := client.Watcher(ctx, path, etcd.WithPrefix())
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	typeCh, err := client.Watcher(ctx, typePath, etcd.WithPrefix())
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return etcdUtil.MergeWatchers(ctx, cancel, ch, typeCh), nil
 }
 
 // GetStrMap collects all of the strings which match a namespace in etcd.
@@ -67,6 +82,53 @@ func GetStrMap(ctx context.Context, client interfaces.Client, hostnameFilter []s
 	if err != nil {
 		return nil, errwrap.Wrapf(err, "could not get strings in: %s", key)
 	}
+	return parseStrMap(path, keyMap, hostnameFilter)
+}
+
+// GetTypedStrMap collects all of the strings which match a namespace in etcd,
+// along with the types that were stored beside them. Both maps are keyed by
+// hostname. The type is missing for any string that was set without one. They
+// are all read at the same revision.
+func GetTypedStrMap(ctx context.Context, client interfaces.Client, hostnameFilter []string, key string) (map[string]string, map[string]string, error) {
+	// key structure is $NS/strings/$key/$hostname = $data
+	// type structure is $NS/strmaptypes/$key/$hostname = $type
+	path := fmt.Sprintf("%s/strings/%s", ns, key)
+	typePath := fmt.Sprintf("%s/strmaptypes/%s", ns, key)
+	ops := []etcd.Op{
+		etcd.OpGet(path, etcd.WithPrefix()),
+		etcd.OpGet(typePath, etcd.WithPrefix()),
+	}
+	resp, err := client.Txn(ctx, nil, ops, nil)
+	if err != nil {
+		return nil, nil, errwrap.Wrapf(err, "could not get strings in: %s", key)
+	}
+	if count := len(resp.Responses); count != len(ops) {
+		return nil, nil, fmt.Errorf("returned %d responses", count)
+	}
+
+	keyMaps := []map[string]string{}
+	for _, x := range resp.Responses {
+		keyMap := make(map[string]string)
+		for _, kv := range x.GetResponseRange().GetKvs() {
+			keyMap[string(kv.Key)] = string(kv.Value)
+		}
+		keyMaps = append(keyMaps, keyMap)
+	}
+
+	values, err := parseStrMap(path, keyMaps[0], hostnameFilter)
+	if err != nil {
+		return nil, nil, err
+	}
+	types, err := parseStrMap(typePath, keyMaps[1], hostnameFilter)
+	if err != nil {
+		return nil, nil, err
+	}
+	return values, types, nil
+}
+
+// parseStrMap turns the keys and values that were found under a path into a
+// map of hostnames to values.
+func parseStrMap(path string, keyMap map[string]string, hostnameFilter []string) (map[string]string, error) {
 	result := make(map[string]string)
 	for key, val := range keyMap {
 		if !strings.HasPrefix(key, path) { // sanity check
@@ -95,25 +157,41 @@ func GetStrMap(ctx context.Context, client interfaces.Client, hostnameFilter []s
 
 // SetStrMap sets a key and hostname pair to a certain value. If the value is
 // nil, then it deletes the key. Otherwise the value should point to a string.
+// Any type that was stored beside a previous value is removed.
 // TODO: TTL or delete disconnect?
 func SetStrMap(ctx context.Context, client interfaces.Client, hostname, key string, data *string) error {
+	return SetTypedStrMap(ctx, client, hostname, key, "", data)
+}
+
+// SetTypedStrMap is like SetStrMap, but it also stores a type beside the value.
+// If the type is empty, then the value is stored without one.
+func SetTypedStrMap(ctx context.Context, client interfaces.Client, hostname, key, typ string, data *string) error {
 	// key structure is $NS/strings/$key/$hostname = $data
+	// type structure is $NS/strmaptypes/$key/$hostname = $type
 	path := fmt.Sprintf("%s/strings/%s/%s", ns, key, hostname)
+	typePath := fmt.Sprintf("%s/strmaptypes/%s/%s", ns, key, hostname)
 	ifs := []etcd.Cmp{} // list matching the desired state
 	ops := []etcd.Op{}  // list of ops in this transaction (then)
 	els := []etcd.Op{}  // list of ops in this transaction (else)
 	if data == nil {    // perform a delete
 		ifs = append(ifs, etcdutil.KeyExists(path))
 		//ifs = append(ifs, etcd.Compare(etcd.Version(path), ">", 0))
-		ops = append(ops, etcd.OpDelete(path))
+		ops = append(ops, etcd.OpDelete(path), etcd.OpDelete(typePath))
 	} else {
 		data := *data                                                // get the real value
 		ifs = append(ifs, etcd.Compare(etcd.Value(path), "=", data)) // desired state
 		els = append(els, etcd.OpPut(path, data))
+		if typ == "" {
+			ifs = append(ifs, etcdutil.KeyMissing(typePath))
+			els = append(els, etcd.OpDelete(typePath))
+		} else {
+			ifs = append(ifs, etcd.Compare(etcd.Value(typePath), "=", typ))
+			els = append(els, etcd.OpPut(typePath, typ))
+		}
 	}
 
 	// it's important to do this in one transaction, and atomically, because
-	// this way, we only generate one watch event, and only when it's needed
+	// this way, we only generate one watch event per key, and only when needed
 	_, err := client.Txn(ctx, ifs, ops, els) // TODO: do we need to look at response?
 	return errwrap.Wrapf(err, "could not set strings in: %s", key)
 }
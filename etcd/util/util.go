@@ -30,9 +30,11 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/purpleidea/mgmt/util/errwrap"
 
@@ -198,3 +200,31 @@ func LocalhostURLs(urls etcdtypes.URLs) etcdtypes.URLs {
 //	}
 //	return strs
 //}
+
+// MergeWatchers returns a channel which spits out the events of all of the
+// watcher channels that it is given. It closes once they have all closed, and
+// then it runs the cancel function. The watchers should be cancelled by the same
+// context that is passed in here, which that cancel function should belong to.
+func MergeWatchers(ctx context.Context, cancel context.CancelFunc, chans ...chan error) chan error {
+	ch := make(chan error)
+	wg := &sync.WaitGroup{}
+	for _, c := range chans {
+		wg.Add(1)
+		go func(c chan error) {
+			defer wg.Done()
+			for err := range c {
+				select {
+				case ch <- err: // send (might be nil!)
+				case <-ctx.Done():
+					// wait for c closure, but don't block
+				}
+			}
+		}(c)
+	}
+	go func() {
+		defer cancel()
+		wg.Wait()
+		close(ch)
+	}()
+	return ch
+}
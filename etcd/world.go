@@ -174,6 +174,31 @@ func (obj *World) StrMapDel(ctx context.Context, namespace string) error {
 	return strmap.SetStrMap(ctx, obj.Client, obj.Hostname, namespace, nil)
 }
 
+// TypedStrGet returns the value for the given namespace, along with the type
+// that was stored beside it. The type is empty if it was set without one.
+func (obj *World) TypedStrGet(ctx context.Context, namespace string) (string, string, error) {
+	return str.GetTypedStr(ctx, obj.Client, namespace)
+}
+
+// TypedStrSet sets the namespace value to a particular string, and stores the
+// type beside it. Both are stored in plaintext, so this must never be a secret.
+func (obj *World) TypedStrSet(ctx context.Context, namespace, typ, value string) error {
+	return str.SetTypedStr(ctx, obj.Client, namespace, typ, &value)
+}
+
+// TypedStrMapGet returns a map of hostnames to values in the given namespace,
+// and a map of hostnames to the types that were stored beside those values.
+func (obj *World) TypedStrMapGet(ctx context.Context, namespace string) (map[string]string, map[string]string, error) {
+	return strmap.GetTypedStrMap(ctx, obj.Client, []string{}, namespace)
+}
+
+// TypedStrMapSet sets the namespace value to a particular string under the
+// identity of its own hostname, and stores the type beside it. Both are stored
+// in plaintext, so this must never be a secret.
+func (obj *World) TypedStrMapSet(ctx context.Context, namespace, typ, value string) error {
+	return strmap.SetTypedStrMap(ctx, obj.Client, obj.Hostname, namespace, typ, &value)
+}
+
 // Scheduler returns a scheduling result of hosts in a particular namespace.
 // XXX: Add a context.Context here
 func (obj *World) Scheduler(namespace string, opts ...scheduler.Option) (*scheduler.Result, error) {
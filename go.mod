@@ -3,6 +3,7 @@ module github.com/purpleidea/mgmt
 go 1.20
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	github.com/alexflint/go-arg v1.4.3
 	github.com/aws/aws-sdk-go v1.51.2
 	github.com/coredhcp/coredhcp v0.0.0-20240314075632-dfd0594edf16
@@ -38,6 +39,7 @@ require (
 	go.etcd.io/etcd/client/v3 v3.5.12
 	go.etcd.io/etcd/server/v3 v3.5.12
 	golang.org/x/crypto v0.21.0
+	golang.org/x/mod v0.16.0
 	golang.org/x/sys v0.18.0
 	golang.org/x/time v0.5.0
 	golang.org/x/tools v0.19.0
@@ -149,7 +151,6 @@ require (
 	go.uber.org/zap v1.23.0 // indirect
 	go4.org/netipx v0.0.0-20231129151722-fdeea329fbba // indirect
 	golang.org/x/exp v0.0.0-20240112132812-db7319d0e0e3 // indirect
-	golang.org/x/mod v0.16.0 // indirect
 	golang.org/x/net v0.22.0 // indirect
 	golang.org/x/sync v0.6.0 // indirect
 	golang.org/x/term v0.18.0 // indirect
@@ -0,0 +1,170 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package ast
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/funcs/structs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+const (
+	// foldMemoLimit is the max number of folded results that we remember.
+	// When it is reached, the memo is emptied and starts over again.
+	foldMemoLimit = 4096
+)
+
+var (
+	// AllowConstantFolding specifies if calls to pure functions with only
+	// constant args get evaluated when the function graph is built. Each
+	// of those calls is then replaced by a single constant vertex, instead
+	// of a subgraph which would stream a value that never changes. It is a
+	// variable so that the two can be compared in tests and benchmarks.
+	AllowConstantFolding = true
+
+	// foldMemo stores the results of the pure function calls that were
+	// folded, so that identical calls are only ever evaluated once. Since
+	// these functions are pure, it's safe to share this across programs.
+	foldMemo = make(map[string]types.Value)
+
+	// foldMutex guards the foldMemo map.
+	foldMutex = &sync.Mutex{}
+)
+
+// fold tries to evaluate this call at compile time. This is only possible if
+// the callee is a builtin pure function which isn't slow, and if all of the
+// args are constants. If it worked, then it returns the value that the call
+// would produce, otherwise it returns nil. A function which errors with these
+// args is not folded, so that the error still happens when the code runs.
+func (obj *ExprCall) fold(argFuncs []interfaces.Func) (types.Value, error) {
+	if !AllowConstantFolding {
+		return nil, nil
+	}
+	fn, ok := trueCallee(obj.expr).(*ExprFunc)
+	if !ok || fn.Function == nil {
+		return nil, nil // lambdas and parameters can't be folded here
+	}
+	if obj.typ == nil || containsFunc(obj.typ) {
+		return nil, nil
+	}
+
+	args := []types.Value{}
+	for _, x := range argFuncs {
+		constFunc, ok := x.(*structs.ConstFunc)
+		if !ok || constFunc.Value == nil {
+			return nil, nil // not a constant
+		}
+		if containsFunc(constFunc.Value.Type()) {
+			return nil, nil // we don't build graphs here
+		}
+		args = append(args, constFunc.Value)
+	}
+
+	// Copy so that we get a new, built, function for this call.
+	exprCopy, err := fn.Copy()
+	if err != nil {
+		return nil, err
+	}
+	funcExprCopy, ok := exprCopy.(*ExprFunc)
+	if !ok || funcExprCopy.function == nil {
+		return nil, nil
+	}
+	handle := funcExprCopy.function
+	info := handle.Info()
+	if !info.Pure || info.Slow || info.Sig == nil {
+		return nil, nil
+	}
+
+	// The result of a function which reads its data could depend on the
+	// module that it's called from, so we only memoize the others.
+	_, isDataFunc := handle.(interfaces.DataFunc)
+	key := ""
+	if !isDataFunc {
+		values := []string{}
+		for _, x := range args {
+			values = append(values, x.String())
+		}
+		key = fmt.Sprintf("%s: %s(%s)", handle, info.Sig, strings.Join(values, ", "))
+
+		foldMutex.Lock()
+		value, exists := foldMemo[key]
+		foldMutex.Unlock()
+		if exists {
+			return value, nil
+		}
+	}
+
+	value, err := funcs.PureFuncExec(handle, args)
+	if err != nil {
+		if obj.data != nil && obj.data.Debug {
+			obj.data.Logf("fold: not folding `%s`: %v", obj.Name, err)
+		}
+		return nil, nil // let it error at runtime instead
+	}
+	if err := obj.typ.Cmp(value.Type()); err != nil {
+		return nil, nil // eg: an empty list might not have a full type
+	}
+
+	if key != "" {
+		foldMutex.Lock()
+		if len(foldMemo) >= foldMemoLimit {
+			foldMemo = make(map[string]types.Value) // start over
+		}
+		foldMemo[key] = value
+		foldMutex.Unlock()
+	}
+	return value, nil
+}
+
+// containsFunc returns true if the type is or contains a function type. Values
+// of these types are graphs that get built at runtime, and so they're never
+// folded.
+func containsFunc(typ *types.Type) bool {
+	if typ == nil {
+		return false
+	}
+	if typ.Kind == types.KindFunc {
+		return true
+	}
+	if containsFunc(typ.Val) || containsFunc(typ.Key) || containsFunc(typ.Var) {
+		return true
+	}
+	for _, t := range typ.Map {
+		if containsFunc(t) {
+			return true
+		}
+	}
+	return false
+}
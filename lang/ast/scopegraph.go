@@ -99,6 +99,11 @@ func (obj *StmtClass) ScopeGraph(g *pgraph.Graph) {
 // ScopeGraph adds nodes and vertices to the supplied graph.
 func (obj *StmtInclude) ScopeGraph(g *pgraph.Graph) {
 	g.AddVertex(obj)
+	if obj.class == nil { // SetScope has not run yet
+		return
+	}
+	obj.class.ScopeGraph(g)
+	g.AddEdge(obj, obj.class, &pgraph.SimpleEdge{Name: "class"})
 }
 
 // ScopeGraph adds nodes and vertices to the supplied graph.
@@ -106,6 +111,11 @@ func (obj *StmtImport) ScopeGraph(g *pgraph.Graph) {
 	g.AddVertex(obj)
 }
 
+// ScopeGraph adds nodes and vertices to the supplied graph.
+func (obj *StmtType) ScopeGraph(g *pgraph.Graph) {
+	g.AddVertex(obj)
+}
+
 // ScopeGraph adds nodes and vertices to the supplied graph.
 func (obj *StmtComment) ScopeGraph(g *pgraph.Graph) {
 	g.AddVertex(obj)
@@ -179,8 +179,15 @@ var (
 // StmtBind is a representation of an assignment, which binds a variable to an
 // expression.
 type StmtBind struct {
+	position
+
 	Ident string
 	Value interfaces.Expr
+
+	// Type is the declared type of the bind if it references a type alias.
+	// These can't be set on the value until SetScope can resolve them. Any
+	// other declared type is set on the value directly by the parser.
+	Type *types.Type
 }
 
 // String returns a short representation of this statement.
@@ -219,8 +226,11 @@ func (obj *StmtBind) Interpolate() (interfaces.Stmt, error) {
 		return nil, err
 	}
 	return &StmtBind{
+		position: obj.position,
+
 		Ident: obj.Ident,
 		Value: interpolated,
+		Type:  obj.Type,
 	}, nil
 }
 
@@ -239,8 +249,11 @@ func (obj *StmtBind) Copy() (interfaces.Stmt, error) {
 		return obj, nil
 	}
 	return &StmtBind{
+		position: obj.position,
+
 		Ident: obj.Ident,
 		Value: value,
+		Type:  obj.Type,
 	}, nil
 }
 
@@ -291,6 +304,16 @@ func (obj *StmtBind) Ordering(produces map[string]interfaces.Node) (*pgraph.Grap
 // SetScope stores the scope for later use in this resource and its children,
 // which it propagates this downwards to.
 func (obj *StmtBind) SetScope(scope *interfaces.Scope) error {
+	if obj.Type != nil {
+		typ, err := resolveType(obj.Type, scope)
+		if err != nil {
+			return errwrap.Wrapf(err, "could not resolve type of var `%s`", obj.Ident)
+		}
+		if err := obj.Value.SetType(typ); err != nil {
+			return errwrap.Wrapf(err, "could not set type of var `%s`", obj.Ident)
+		}
+	}
+
 	emptyContext := map[string]interfaces.Expr{}
 	return obj.Value.SetScope(scope, emptyContext)
 }
@@ -329,6 +352,8 @@ func (obj *StmtBind) Output(map[interfaces.Func]types.Value) (*interfaces.Output
 // TODO: Consider expanding Name to have this return a list of Res's in the
 // Output function if it is a map[name]struct{}, or even a map[[]name]struct{}.
 type StmtRes struct {
+	position
+
 	data *interfaces.Data
 
 	Kind     string            // kind of resource, eg: pkg, file, svc, etc...
@@ -427,6 +452,8 @@ func (obj *StmtRes) Interpolate() (interfaces.Stmt, error) {
 	}
 
 	return &StmtRes{
+		position: obj.position,
+
 		data:     obj.data,
 		Kind:     obj.Kind,
 		Name:     name,
@@ -467,6 +494,8 @@ func (obj *StmtRes) Copy() (interfaces.Stmt, error) {
 		return obj, nil
 	}
 	return &StmtRes{
+		position: obj.position,
+
 		data:     obj.data,
 		Kind:     obj.Kind,
 		Name:     name,
@@ -700,6 +729,9 @@ func (obj *StmtRes) Output(table map[interfaces.Func]types.Value) (*interfaces.O
 	if !exists {
 		return nil, ErrTableNoValue
 	}
+	if types.IsSecret(nameValue) { // names get printed everywhere
+		return nil, fmt.Errorf("the name of a resource can't be a secret")
+	}
 
 	names := []string{} // list of names to build
 	switch {
@@ -1180,6 +1212,8 @@ type StmtResContents interface {
 // StmtResField represents a single field in the parsed resource representation.
 // This does not satisfy the Stmt interface.
 type StmtResField struct {
+	position
+
 	Field        string
 	Value        interfaces.Expr
 	valuePtr     interfaces.Func // ptr for table lookup
@@ -1243,6 +1277,8 @@ func (obj *StmtResField) Interpolate() (StmtResContents, error) {
 		}
 	}
 	return &StmtResField{
+		position: obj.position,
+
 		Field:     obj.Field,
 		Value:     interpolated,
 		Condition: condition,
@@ -1276,6 +1312,8 @@ func (obj *StmtResField) Copy() (StmtResContents, error) {
 		return obj, nil
 	}
 	return &StmtResField{
+		position: obj.position,
+
 		Field:     obj.Field,
 		Value:     value,
 		Condition: condition,
@@ -2381,6 +2419,8 @@ func (obj *StmtEdge) Output(table map[interfaces.Func]types.Value) (*interfaces.
 // StmtEdgeHalf represents half of an edge in the parsed edge representation.
 // This does not satisfy the Stmt interface.
 type StmtEdgeHalf struct {
+	position
+
 	Kind     string          // kind of resource, eg: pkg, file, svc, etc...
 	Name     interfaces.Expr // unique name for the res of this kind
 	namePtr  interfaces.Func // ptr for table lookup
@@ -2430,6 +2470,8 @@ func (obj *StmtEdgeHalf) Interpolate() (*StmtEdgeHalf, error) {
 	}
 
 	return &StmtEdgeHalf{
+		position: obj.position,
+
 		Kind:     obj.Kind,
 		Name:     name,
 		SendRecv: obj.SendRecv,
@@ -2451,6 +2493,8 @@ func (obj *StmtEdgeHalf) Copy() (*StmtEdgeHalf, error) {
 		return obj, nil
 	}
 	return &StmtEdgeHalf{
+		position: obj.position,
+
 		Kind:     obj.Kind,
 		Name:     name,
 		SendRecv: obj.SendRecv,
@@ -2551,6 +2595,8 @@ func (obj *StmtEdgeHalf) Graph() (*pgraph.Graph, error) {
 // optional, it is the else branch, although this struct allows either to be
 // optional, even if it is not commonly used.
 type StmtIf struct {
+	position
+
 	Condition    interfaces.Expr
 	conditionPtr interfaces.Func // ptr for table lookup
 	ThenBranch   interfaces.Stmt // optional, but usually present
@@ -2637,6 +2683,8 @@ func (obj *StmtIf) Interpolate() (interfaces.Stmt, error) {
 		}
 	}
 	return &StmtIf{
+		position: obj.position,
+
 		Condition:  condition,
 		ThenBranch: thenBranch,
 		ElseBranch: elseBranch,
@@ -2679,6 +2727,8 @@ func (obj *StmtIf) Copy() (interfaces.Stmt, error) {
 		return obj, nil
 	}
 	return &StmtIf{
+		position: obj.position,
+
 		Condition:  condition,
 		ThenBranch: thenBranch,
 		ElseBranch: elseBranch,
@@ -3355,6 +3405,28 @@ func (obj *StmtProg) importScope(info *interfaces.ImportData, scope *interfaces.
 
 	// Now, info.IsLocal is false... we're dealing with a remote import!
 
+	// If the top-level module has vendored this import, then we use it as
+	// is. This lets us run without any network access at all. When we are
+	// downloading, we skip this, so that the vendor dir can be refreshed.
+	if vendorPath := interfaces.FindVendorPath(obj.data.Metadata); vendorPath != "" && obj.data.Downloader == nil {
+		importFilePath := vendorPath + info.Path + interfaces.MetadataFilename
+		if _, err := obj.data.Fs.Stat(importFilePath); err == nil {
+			if obj.data.Debug {
+				obj.data.Logf("import: vendored file: %s", importFilePath)
+			}
+			if fn := obj.data.VerifyModule; fn != nil {
+				if err := fn(obj.data.Fs, info.Path, vendorPath+info.Path); err != nil {
+					return nil, errwrap.Wrapf(err, "vendored import of `%s` failed", info.Name)
+				}
+			}
+			vendorScope, err := obj.importScopeWithInputs(importFilePath, scope, nextVertex)
+			if err != nil {
+				return nil, errwrap.Wrapf(err, "vendored import of `%s` failed", info.Name)
+			}
+			return vendorScope, nil
+		}
+	}
+
 	// This takes the current metadata as input so it can use the Path
 	// directory to search upwards if we wanted to look in parent paths.
 	// Since this is an fqdn import, it must contain a metadata file...
@@ -3397,10 +3469,18 @@ func (obj *StmtProg) importScope(info *interfaces.ImportData, scope *interfaces.
 	// we need to invoke the recursive checker before we run this download!
 	// this should cleverly deal with skipping modules that are up-to-date!
 	if obj.data.Downloader != nil {
+		// the importing module decides which version it wants to use
+		if md := obj.data.Metadata; md != nil {
+			info.Version = md.Require[info.Name]
+		}
 		// run downloader stuff first
 		if err := obj.data.Downloader.Get(info, modulesPath); err != nil {
 			return nil, errwrap.Wrapf(err, "download of `%s` failed", info.Name)
 		}
+	} else if fn := obj.data.VerifyModule; fn != nil { // downloads verify
+		if err := fn(obj.data.Fs, info.Path, modulesPath+info.Path); err != nil {
+			return nil, errwrap.Wrapf(err, "remote import of `%s` failed", info.Name)
+		}
 	}
 
 	// takes the full absolute path to the metadata.yaml file
@@ -3630,6 +3710,7 @@ func (obj *StmtProg) importScopeWithParsedInputs(input *inputs.ParsedInput, scop
 
 		LexParser:       obj.data.LexParser,
 		Downloader:      obj.data.Downloader,
+		VerifyModule:    obj.data.VerifyModule,
 		StrInterpolater: obj.data.StrInterpolater,
 		//World: obj.data.World, // TODO: do we need this?
 
@@ -3727,6 +3808,7 @@ func (obj *StmtProg) SetScope(scope *interfaces.Scope) error {
 	newVariables := make(map[string]string)
 	newFunctions := make(map[string]string)
 	newClasses := make(map[string]string)
+	newTypes := make(map[string]string)
 	// TODO: If we added .Ordering() for *StmtImport, we could combine this
 	// loop with the main nodeOrder sorted topological ordering loop below!
 	for _, x := range obj.Body {
@@ -3808,12 +3890,44 @@ func (obj *StmtProg) SetScope(scope *interfaces.Scope) error {
 			newClasses[newName] = imp.Name
 			newScope.Classes[newName] = x
 		}
+		for name, x := range importedScope.Types {
+			newName := alias + interfaces.ModuleSep + name
+			if alias == interfaces.BareSymbol {
+				if !AllowBareImports {
+					return fmt.Errorf("bare imports disabled at compile time for import of `%s`", imp.Name)
+				}
+				newName = name
+			}
+			if previous, exists := newTypes[newName]; exists {
+				// don't overwrite in same scope
+				return fmt.Errorf("can't squash type `%s` from `%s` by import of `%s`", newName, previous, imp.Name)
+			}
+			newTypes[newName] = imp.Name
+			newScope.Types[newName] = x
+		}
 
 		// everything has been merged, move on to next import...
 		imports[imp.Name] = struct{}{} // mark as found in scope
 		aliases[alias] = struct{}{}
 	}
 
+	// Type aliases only exist at compile time and may be declared in any
+	// order, so we resolve all of them before any of the other statements
+	// run, since those might need them in their own SetScope.
+	stmtTypes := []*StmtType{}
+	for _, x := range obj.Body {
+		if stmt, ok := x.(*StmtType); ok {
+			stmtTypes = append(stmtTypes, stmt)
+		}
+	}
+	localTypes, err := resolveTypeAliases(stmtTypes, newScope.Types)
+	if err != nil {
+		return err
+	}
+	for name, typ := range localTypes {
+		newScope.Types[name] = typ // add to scope, (shadowing is ok)
+	}
+
 	// TODO: this could be called once at the top-level, and then cached...
 	// TODO: it currently gets called inside child programs, which is slow!
 	orderingGraph, _, err := obj.Ordering(nil) // XXX: pass in globals from scope?
@@ -4273,13 +4387,14 @@ func (obj *StmtProg) Output(table map[interfaces.Func]types.Value) (*interfaces.
 // TODO: technically this could be a method on Stmt, possibly using Apply...
 func (obj *StmtProg) IsModuleUnsafe() error { // TODO: rename this function?
 	for _, x := range obj.Body {
-		// stmt's allowed: import, bind, func, class
+		// stmt's allowed: import, bind, func, class, type
 		// stmt's not-allowed: if, include, res, edge
 		switch x.(type) {
 		case *StmtImport:
 		case *StmtBind:
 		case *StmtFunc:
 		case *StmtClass:
+		case *StmtType:
 		case *StmtComment: // possibly not even parsed
 			// all of these are safe
 		default:
@@ -4294,6 +4409,8 @@ func (obj *StmtProg) IsModuleUnsafe() error { // TODO: rename this function?
 // the supplied function in the current scope and irrespective of the order of
 // definition.
 type StmtFunc struct {
+	position
+
 	Name string
 	//Func *ExprFunc // TODO: should it be this instead?
 	Func interfaces.Expr // TODO: is this correct?
@@ -4341,6 +4458,8 @@ func (obj *StmtFunc) Interpolate() (interfaces.Stmt, error) {
 	}
 
 	return &StmtFunc{
+		position: obj.position,
+
 		Name: obj.Name,
 		Func: interpolated,
 	}, nil
@@ -4361,6 +4480,8 @@ func (obj *StmtFunc) Copy() (interfaces.Stmt, error) {
 		return obj, nil
 	}
 	return &StmtFunc{
+		position: obj.position,
+
 		Name: obj.Name,
 		Func: fn,
 	}, nil
@@ -4466,6 +4587,8 @@ func (obj *StmtFunc) Output(map[interfaces.Func]types.Value) (*interfaces.Output
 // TODO: We don't currently support defining polymorphic classes (eg: different
 // signatures for the same class name) but it might be something to consider.
 type StmtClass struct {
+	position
+
 	scope *interfaces.Scope // store for referencing this later
 
 	Name string
@@ -4484,6 +4607,9 @@ func (obj *StmtClass) String() string {
 // Nevertheless, it is a useful facility for operations that might only apply to
 // a select number of node types, since they won't need extra noop iterators...
 func (obj *StmtClass) Apply(fn func(interfaces.Node) error) error {
+	if err := applyArgs(obj.Args, fn); err != nil {
+		return err
+	}
 	if err := obj.Body.Apply(fn); err != nil {
 		return err
 	}
@@ -4497,6 +4623,10 @@ func (obj *StmtClass) Init(data *interfaces.Data) error {
 		return fmt.Errorf("class name is empty")
 	}
 
+	if err := initArgs(obj.Args, data); err != nil {
+		return errwrap.Wrapf(err, "class `%s`", obj.Name)
+	}
+
 	return obj.Body.Init(data)
 }
 
@@ -4509,12 +4639,14 @@ func (obj *StmtClass) Interpolate() (interfaces.Stmt, error) {
 		return nil, err
 	}
 
-	args := obj.Args
-	if obj.Args == nil {
-		args = []*interfaces.Arg{}
+	args, err := interpolateArgs(obj.Args)
+	if err != nil {
+		return nil, err
 	}
 
 	return &StmtClass{
+		position: obj.position,
+
 		scope: obj.scope,
 		Name:  obj.Name,
 		Args:  args, // ensure this has length == 0 instead of nil
@@ -4542,6 +4674,8 @@ func (obj *StmtClass) Copy() (interfaces.Stmt, error) {
 		return obj, nil
 	}
 	return &StmtClass{
+		position: obj.position,
+
 		scope: obj.scope,
 		Name:  obj.Name,
 		Args:  args, // ensure this has length == 0 instead of nil
@@ -4605,6 +4739,17 @@ func (obj *StmtClass) Ordering(produces map[string]interfaces.Node) (*pgraph.Gra
 		newCons[k] = v // "remaining" values from cons
 	}
 
+	// The default args can't see the other args, so they get the produces
+	// of the parent, and their consumes are passed through unchanged.
+	g, c, err := orderingArgs(obj.Args, obj, produces)
+	if err != nil {
+		return nil, nil, err
+	}
+	graph.AddGraph(g) // add in the child graph
+	for k, v := range c {
+		newCons[k] = v
+	}
+
 	return graph, newCons, nil
 }
 
@@ -4622,6 +4767,19 @@ func (obj *StmtClass) SetScope(scope *interfaces.Scope) error {
 	// site and not the variables which were in scope at the include site.
 	obj.scope = scope // store for later
 
+	// The arg types might reference type aliases that are in scope here.
+	for i, arg := range obj.Args {
+		typ, err := resolveType(arg.Type, scope)
+		if err != nil {
+			return errwrap.Wrapf(err, "could not resolve type of class `%s` arg `%s`", obj.Name, arg.Name)
+		}
+		if typ != arg.Type {
+			a := *arg // keep everything else, such as the position
+			a.Type = typ
+			obj.Args[i] = &a
+		}
+	}
+
 	return nil
 }
 
@@ -4660,11 +4818,17 @@ func (obj *StmtClass) Output(table map[interfaces.Func]types.Value) (*interfaces
 // to call a class except that it produces output instead of a value. Most of
 // the interesting logic for classes happens here or in StmtProg.
 type StmtInclude struct {
+	position
+
+	data  *interfaces.Data
 	class *StmtClass   // copy of class that we're using
 	orig  *StmtInclude // original pointer to this
 
-	Name  string
-	Args  []interfaces.Expr
+	Name string
+	Args []interfaces.Expr
+	// Named are the args which were passed in by name. They get merged
+	// into Args in SetScope, along with any omitted default args.
+	Named []*ExprNamedArg
 	Alias string
 }
 
@@ -4693,12 +4857,18 @@ func (obj *StmtInclude) Apply(fn func(interfaces.Node) error) error {
 			return err
 		}
 	}
+	for _, x := range obj.Named {
+		if err := x.Value.Apply(fn); err != nil {
+			return err
+		}
+	}
 	return fn(obj)
 }
 
 // Init initializes this branch of the AST, and returns an error if it fails to
 // validate.
 func (obj *StmtInclude) Init(data *interfaces.Data) error {
+	obj.data = data
 	if obj.Name == "" {
 		return fmt.Errorf("include name is empty")
 	}
@@ -4708,6 +4878,14 @@ func (obj *StmtInclude) Init(data *interfaces.Data) error {
 			return err
 		}
 	}
+	if err := validateNamedArgs(obj.Named); err != nil {
+		return err
+	}
+	for _, x := range obj.Named {
+		if err := x.Value.Init(data); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -4723,16 +4901,24 @@ func (obj *StmtInclude) Interpolate() (interfaces.Stmt, error) {
 		}
 		args = append(args, interpolated)
 	}
+	named, err := interpolateNamedArgs(obj.Named)
+	if err != nil {
+		return nil, err
+	}
 
 	orig := obj
 	if obj.orig != nil { // preserve the original pointer (the identifier!)
 		orig = obj.orig
 	}
 	return &StmtInclude{
+		position: obj.position,
+
+		data: obj.data,
 		//class: obj.class, // TODO: is this necessary?
 		orig:  orig,
 		Name:  obj.Name,
 		Args:  args,
+		Named: named,
 		Alias: obj.Alias,
 	}, nil
 }
@@ -4751,6 +4937,13 @@ func (obj *StmtInclude) Copy() (interfaces.Stmt, error) {
 		}
 		args = append(args, cp)
 	}
+	named, copiedNamed, err := copyNamedArgs(obj.Named)
+	if err != nil {
+		return nil, err
+	}
+	if copiedNamed {
+		copied = true
+	}
 
 	// TODO: is this necessary? (I doubt it even gets used.)
 	orig := obj
@@ -4763,10 +4956,14 @@ func (obj *StmtInclude) Copy() (interfaces.Stmt, error) {
 		return obj, nil
 	}
 	return &StmtInclude{
+		position: obj.position,
+
+		data: obj.data,
 		//class: obj.class, // TODO: is this necessary?
 		orig:  orig,
 		Name:  obj.Name,
 		Args:  args,
+		Named: named,
 		Alias: obj.Alias,
 	}, nil
 }
@@ -4812,7 +5009,12 @@ func (obj *StmtInclude) Ordering(produces map[string]interfaces.Node) (*pgraph.G
 	cons := make(map[interfaces.Node]string)
 	cons[obj] = uid
 
-	for _, node := range obj.Args {
+	exprs := []interfaces.Expr{}
+	exprs = append(exprs, obj.Args...)
+	for _, x := range obj.Named {
+		exprs = append(exprs, x.Value)
+	}
+	for _, node := range exprs {
 		g, c, err := node.Ordering(produces)
 		if err != nil {
 			return nil, nil, err
@@ -4852,18 +5054,14 @@ func (obj *StmtInclude) SetScope(scope *interfaces.Scope) error {
 
 	stmt, exists := scope.Classes[obj.Name]
 	if !exists {
-		return fmt.Errorf("class `%s` does not exist in this scope", obj.Name)
+		err := fmt.Errorf("class `%s` does not exist in this scope", obj.Name)
+		return interfaces.WrapPos(err, obj.Pos())
 	}
 	class, ok := stmt.(*StmtClass)
 	if !ok {
 		return fmt.Errorf("class scope of `%s` does not contain a class", obj.Name)
 	}
 
-	// Is it even possible for the signatures to not match?
-	if len(class.Args) != len(obj.Args) {
-		return fmt.Errorf("class `%s` expected %d args but got %d", obj.Name, len(class.Args), len(obj.Args))
-	}
-
 	if obj.class != nil {
 		// possible programming error
 		return fmt.Errorf("include already contains a class pointer")
@@ -4875,6 +5073,31 @@ func (obj *StmtInclude) SetScope(scope *interfaces.Scope) error {
 			return err
 		}
 	}
+	for _, x := range obj.Named {
+		if err := x.Value.SetScope(scope, map[string]interfaces.Expr{}); err != nil {
+			return err
+		}
+	}
+
+	// Fill in the named and the omitted default args, so that from here on
+	// we only have to deal with the full list of positional args.
+	args, added, err := completeArgs(obj.data, class.Args, class.scope, obj.Args, obj.Named)
+	if err != nil {
+		return errwrap.Wrapf(err, "class `%s`", obj.Name)
+	}
+	for _, x := range added {
+		// each of these captured the scope of the class definition
+		if err := x.SetScope(class.scope, map[string]interfaces.Expr{}); err != nil {
+			return errwrap.Wrapf(err, "could not set scope of default arg")
+		}
+	}
+	obj.Args = args
+	obj.Named = nil // we've used them up
+
+	// Is it even possible for the signatures to not match?
+	if len(class.Args) != len(obj.Args) {
+		return fmt.Errorf("class `%s` expected %d args but got %d", obj.Name, len(class.Args), len(obj.Args))
+	}
 
 	for i := len(scope.Chain) - 1; i >= 0; i-- { // reverse order
 		x, ok := scope.Chain[i].(*StmtInclude)
@@ -5028,6 +5251,8 @@ func (obj *StmtInclude) Output(table map[interfaces.Func]types.Value) (*interfac
 // file. As with any statement, it produces output, but that output is empty. To
 // benefit from its inclusion, reference the scope definitions you want.
 type StmtImport struct {
+	position
+
 	Name  string
 	Alias string
 }
@@ -5058,6 +5283,8 @@ func (obj *StmtImport) Init(*interfaces.Data) error {
 // on any child elements and builds the new node with those new node contents.
 func (obj *StmtImport) Interpolate() (interfaces.Stmt, error) {
 	return &StmtImport{
+		position: obj.position,
+
 		Name:  obj.Name,
 		Alias: obj.Alias,
 	}, nil
@@ -5123,6 +5350,102 @@ func (obj *StmtImport) Output(map[interfaces.Func]types.Value) (*interfaces.Outp
 	return interfaces.EmptyOutput(), nil
 }
 
+// StmtType binds a name to a type, so that it can be used as an alias in the
+// places where a type can be specified, such as in the args of a function or a
+// class. These aliases only exist at compile time, and they are exported from a
+// module like any other scope definition. Like binds, they can be declared out
+// of order, and they can refer to each other as long as there is no cycle.
+type StmtType struct {
+	Name string
+	Type *types.Type
+}
+
+// String returns a short representation of this statement.
+func (obj *StmtType) String() string {
+	return fmt.Sprintf("type(%s)", obj.Name)
+}
+
+// Apply is a general purpose iterator method that operates on any AST node. It
+// is not used as the primary AST traversal function because it is less readable
+// and easy to reason about than manually implementing traversal for each node.
+// Nevertheless, it is a useful facility for operations that might only apply to
+// a select number of node types, since they won't need extra noop iterators...
+func (obj *StmtType) Apply(fn func(interfaces.Node) error) error { return fn(obj) }
+
+// Init initializes this branch of the AST, and returns an error if it fails to
+// validate.
+func (obj *StmtType) Init(*interfaces.Data) error {
+	if obj.Name == "" {
+		return fmt.Errorf("type name is empty")
+	}
+	if obj.Type == nil {
+		return fmt.Errorf("type `%s` is empty", obj.Name)
+	}
+	return nil
+}
+
+// Interpolate returns a new node (aka a copy) once it has been expanded. This
+// generally increases the size of the AST when it is used. It calls Interpolate
+// on any child elements and builds the new node with those new node contents.
+func (obj *StmtType) Interpolate() (interfaces.Stmt, error) {
+	return &StmtType{
+		Name: obj.Name,
+		Type: obj.Type,
+	}, nil
+}
+
+// Copy returns a light copy of this struct. Anything static will not be copied.
+func (obj *StmtType) Copy() (interfaces.Stmt, error) {
+	return obj, nil // always static
+}
+
+// Ordering returns a graph of the scope ordering that represents the data flow.
+// This can be used in SetScope so that it knows the correct order to run it in.
+// Nothing special happens in this method, the aliases are all resolved by the
+// StmtProg before any of the other statements are run.
+func (obj *StmtType) Ordering(produces map[string]interfaces.Node) (*pgraph.Graph, map[interfaces.Node]string, error) {
+	graph, err := pgraph.NewGraph("ordering")
+	if err != nil {
+		return nil, nil, err
+	}
+	graph.AddVertex(obj)
+
+	cons := make(map[interfaces.Node]string)
+	return graph, cons, nil
+}
+
+// SetScope stores the scope for later use in this resource and its children,
+// which it propagates this downwards to. The alias is added to the scope by the
+// parent StmtProg, so there is nothing to do here.
+func (obj *StmtType) SetScope(*interfaces.Scope) error { return nil }
+
+// Unify returns the list of invariants that this node produces. It recursively
+// calls Unify on any children elements that exist in the AST, and returns the
+// collection to the caller.
+func (obj *StmtType) Unify() ([]interfaces.Invariant, error) {
+	if obj.Name == "" {
+		return nil, fmt.Errorf("missing type name")
+	}
+
+	return []interfaces.Invariant{}, nil
+}
+
+// Graph returns the reactive function graph which is expressed by this node. It
+// includes any vertices produced by this node, and the appropriate edges to any
+// vertices that are produced by its children. Nodes which fulfill the Expr
+// interface directly produce vertices (and possible children) where as nodes
+// that fulfill the Stmt interface do not produces vertices, where as their
+// children might. This particular statement just returns an empty graph.
+func (obj *StmtType) Graph() (*pgraph.Graph, error) {
+	return pgraph.NewGraph("type") // empty graph
+}
+
+// Output for the type statement produces no output. The alias is only used at
+// compile time by the other statements and expressions which reference it.
+func (obj *StmtType) Output(map[interfaces.Func]types.Value) (*interfaces.Output, error) {
+	return interfaces.EmptyOutput(), nil
+}
+
 // StmtComment is a representation of a comment. It is currently unused. It
 // probably makes sense to make a third kind of Node (not a Stmt or an Expr) so
 // that comments can still be part of the AST (for eventual automatic code
@@ -5386,6 +5709,7 @@ func (obj *ExprStr) Interpolate() (interfaces.Expr, error) {
 
 		LexParser:       obj.data.LexParser,
 		Downloader:      obj.data.Downloader,
+		VerifyModule:    obj.data.VerifyModule,
 		StrInterpolater: obj.data.StrInterpolater,
 		//World: obj.data.World, // TODO: do we need this?
 
@@ -7048,6 +7372,8 @@ type ExprStructField struct {
 // 4. A pure built-in function (set Values to a singleton)
 // 5. A pure polymorphic built-in function (set Values to a list)
 type ExprFunc struct {
+	position
+
 	data  *interfaces.Data
 	scope *interfaces.Scope // store for referencing this later
 	typ   *types.Type
@@ -7131,6 +7457,9 @@ func (obj *ExprFunc) String() string {
 // Nevertheless, it is a useful facility for operations that might only apply to
 // a select number of node types, since they won't need extra noop iterators...
 func (obj *ExprFunc) Apply(fn func(interfaces.Node) error) error {
+	if err := applyArgs(obj.Args, fn); err != nil {
+		return err
+	}
 	if obj.Body != nil {
 		if err := obj.Body.Apply(fn); err != nil {
 			return err
@@ -7152,6 +7481,10 @@ func (obj *ExprFunc) Init(data *interfaces.Data) error {
 		return fmt.Errorf("function expression was not built correctly")
 	}
 
+	if err := initArgs(obj.Args, data); err != nil {
+		return err
+	}
+
 	if obj.Body != nil {
 		if err := obj.Body.Init(data); err != nil {
 			return err
@@ -7205,12 +7538,14 @@ func (obj *ExprFunc) Interpolate() (interfaces.Expr, error) {
 		}
 	}
 
-	args := obj.Args
-	if obj.Args == nil {
-		args = []*interfaces.Arg{}
+	args, err := interpolateArgs(obj.Args)
+	if err != nil {
+		return nil, err
 	}
 
 	return &ExprFunc{
+		position: obj.position,
+
 		data:     obj.data,
 		scope:    obj.scope,
 		typ:      obj.typ,
@@ -7289,6 +7624,8 @@ func (obj *ExprFunc) Copy() (interfaces.Expr, error) {
 		return obj, nil
 	}
 	return &ExprFunc{
+		position: obj.position,
+
 		data:     obj.data,
 		scope:    obj.scope, // TODO: copy?
 		typ:      obj.typ,
@@ -7358,6 +7695,17 @@ func (obj *ExprFunc) Ordering(produces map[string]interfaces.Node) (*pgraph.Grap
 		newCons[k] = v // "remaining" values from cons
 	}
 
+	// The default args can't see the other args, so they get the produces
+	// of the parent, and their consumes are passed through unchanged.
+	g, c, err := orderingArgs(obj.Args, obj, produces)
+	if err != nil {
+		return nil, nil, err
+	}
+	graph.AddGraph(g) // add in the child graph
+	for k, v := range c {
+		newCons[k] = v
+	}
+
 	return graph, newCons, nil
 }
 
@@ -7369,6 +7717,10 @@ func (obj *ExprFunc) SetScope(scope *interfaces.Scope, sctx map[string]interface
 	}
 	obj.scope = scope // store for later
 
+	if err := obj.resolveTypes(scope); err != nil {
+		return err
+	}
+
 	if obj.Body != nil {
 		sctxBody := make(map[string]interfaces.Expr)
 		for k, v := range sctx {
@@ -7379,7 +7731,12 @@ func (obj *ExprFunc) SetScope(scope *interfaces.Scope, sctx map[string]interface
 		// make a list as long as obj.Args
 		obj.params = make([]*ExprParam, len(obj.Args))
 		for i, arg := range obj.Args {
-			param := &ExprParam{Name: arg.Name, Typ: arg.Type}
+			param := &ExprParam{
+				position: position{pos: arg.Pos()},
+
+				Name: arg.Name,
+				Typ:  arg.Type,
+			}
 			obj.params[i] = param
 			sctxBody[arg.Name] = param
 		}
@@ -7399,6 +7756,88 @@ func (obj *ExprFunc) SetScope(scope *interfaces.Scope, sctx map[string]interface
 	return nil
 }
 
+// resolveTypes replaces any type alias references in the args or in the return
+// type with the real types from the scope. The parser can't set the type of the
+// function when it contains these references, so if it's now fully typed, then
+// we do that here instead.
+func (obj *ExprFunc) resolveTypes(scope *interfaces.Scope) error {
+	resolved := false
+	for i, arg := range obj.Args {
+		typ, err := resolveType(arg.Type, scope)
+		if err != nil {
+			return errwrap.Wrapf(err, "could not resolve type of arg `%s`", arg.Name)
+		}
+		if typ != arg.Type {
+			a := *arg // keep everything else, such as the position
+			a.Type = typ
+			obj.Args[i] = &a
+			resolved = true
+		}
+	}
+	typ, err := resolveType(obj.Return, scope)
+	if err != nil {
+		return errwrap.Wrapf(err, "could not resolve return type")
+	}
+	if typ != obj.Return {
+		obj.Return = typ
+		resolved = true
+	}
+	if !resolved || obj.typ != nil || obj.Return == nil {
+		return nil
+	}
+
+	m := make(map[string]*types.Type)
+	ord := []string{}
+	for _, arg := range obj.Args {
+		if arg.Type == nil {
+			return nil // at least one is unknown, can't run SetType...
+		}
+		m[arg.Name] = arg.Type
+		ord = append(ord, arg.Name)
+	}
+	return obj.SetType(&types.Type{
+		Kind: types.KindFunc,
+		Map:  m,
+		Ord:  ord,
+		Out:  obj.Return,
+	})
+}
+
+// argsDecl returns the list of args that this function was declared with. For
+// the built-in functions, these are built from the names in the signature, and
+// as a result, they never have any default values. If the arg names can't be
+// determined, eg: for a polymorphic function without a static signature, then
+// this returns nil.
+func (obj *ExprFunc) argsDecl() ([]*interfaces.Arg, error) {
+	if obj.Body != nil {
+		return obj.Args, nil
+	}
+
+	var typ *types.Type
+	if obj.Function != nil {
+		if obj.function == nil {
+			// possible programming error
+			return nil, fmt.Errorf("func has not been built")
+		}
+		typ = obj.function.Info().Sig
+	}
+	for _, x := range obj.Values { // all the signatures must agree on names
+		if typ != nil && !reflect.DeepEqual(typ.Ord, x.T.Ord) {
+			return nil, nil
+		}
+		typ = x.T
+	}
+	if typ == nil || typ.Kind != types.KindFunc {
+		return nil, nil
+	}
+
+	args := []*interfaces.Arg{}
+	for _, name := range typ.Ord {
+		args = append(args, &interfaces.Arg{Name: name})
+	}
+	return args, nil
+}
+
 // SetType is used to set the type of this expression once it is known. This
 // usually happens during type unification, but it can also happen during
 // parsing if a type is specified explicitly. Since types are static and don't
@@ -7818,10 +8257,20 @@ func (obj *ExprFunc) Value() (types.Value, error) {
 	//}, nil
 }
 
+// ExprNamedArg represents a name value pair which is passed as an arg to a
+// function call or to an include of a class. For example: `port => 8080`. This
+// does not satisfy the Expr interface.
+type ExprNamedArg struct {
+	Name  string
+	Value interfaces.Expr
+}
+
 // ExprCall is a representation of a function call. This does not represent the
 // declaration or implementation of a new function value. This struct has an
 // analogous symmetry with ExprVar.
 type ExprCall struct {
+	position
+
 	data  *interfaces.Data
 	scope *interfaces.Scope // store for referencing this later
 	typ   *types.Type
@@ -7835,6 +8284,10 @@ type ExprCall struct {
 	Name string
 	// Args are the list of inputs to this function.
 	Args []interfaces.Expr // list of args in parsed order
+	// Named are the list of inputs to this function which were passed in
+	// by name. They get merged into Args in SetScope, once we know what
+	// function is being called, along with any omitted default args.
+	Named []*ExprNamedArg
 	// Var specifies whether the function being called is a lambda in a var.
 	Var bool
 }
@@ -7845,6 +8298,9 @@ func (obj *ExprCall) String() string {
 	for _, x := range obj.Args {
 		s = append(s, fmt.Sprintf("%s", x.String()))
 	}
+	for _, x := range obj.Named {
+		s = append(s, fmt.Sprintf("%s => %s", x.Name, x.Value.String()))
+	}
 	return fmt.Sprintf("call:%s(%s)", obj.Name, strings.Join(s, ", "))
 }
 
@@ -7859,6 +8315,11 @@ func (obj *ExprCall) Apply(fn func(interfaces.Node) error) error {
 			return err
 		}
 	}
+	for _, x := range obj.Named {
+		if err := x.Value.Apply(fn); err != nil {
+			return err
+		}
+	}
 	return fn(obj)
 }
 
@@ -7871,6 +8332,14 @@ func (obj *ExprCall) Init(data *interfaces.Data) error {
 			return err
 		}
 	}
+	if err := validateNamedArgs(obj.Named); err != nil {
+		return err
+	}
+	for _, x := range obj.Named {
+		if err := x.Value.Init(data); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -7886,6 +8355,10 @@ func (obj *ExprCall) Interpolate() (interfaces.Expr, error) {
 		}
 		args = append(args, interpolated)
 	}
+	named, err := interpolateNamedArgs(obj.Named)
+	if err != nil {
+		return nil, err
+	}
 
 	orig := obj
 	if obj.orig != nil { // preserve the original pointer (the identifier!)
@@ -7893,17 +8366,20 @@ func (obj *ExprCall) Interpolate() (interfaces.Expr, error) {
 	}
 
 	return &ExprCall{
+		position: obj.position,
+
 		data:  obj.data,
 		scope: obj.scope,
 		typ:   obj.typ,
 		// XXX: Copy copies this, do we want to here as well? (or maybe
 		// we want to do it here, but not in Copy?)
-		expr: obj.expr,
-		orig: orig,
-		V:    obj.V,
-		Name: obj.Name,
-		Args: args,
-		Var:  obj.Var,
+		expr:  obj.expr,
+		orig:  orig,
+		V:     obj.V,
+		Name:  obj.Name,
+		Args:  args,
+		Named: named,
+		Var:   obj.Var,
 	}, nil
 }
 
@@ -7928,7 +8404,14 @@ func (obj *ExprCall) Copy() (interfaces.Expr, error) {
 		args = obj.Args // don't re-package it unnecessarily!
 	}
 
-	var err error
+	named, copiedNamed, err := copyNamedArgs(obj.Named)
+	if err != nil {
+		return nil, err
+	}
+	if copiedNamed {
+		copied = true
+	}
+
 	var expr interfaces.Expr
 	if obj.expr != nil {
 		expr, err = obj.expr.Copy()
@@ -7952,6 +8435,8 @@ func (obj *ExprCall) Copy() (interfaces.Expr, error) {
 		return obj, nil
 	}
 	return &ExprCall{
+		position: obj.position,
+
 		data:  obj.data,
 		scope: obj.scope,
 		typ:   obj.typ,
@@ -7960,6 +8445,7 @@ func (obj *ExprCall) Copy() (interfaces.Expr, error) {
 		V:     obj.V,
 		Name:  obj.Name,
 		Args:  args,
+		Named: named,
 		Var:   obj.Var,
 	}, nil
 }
@@ -8006,7 +8492,12 @@ func (obj *ExprCall) Ordering(produces map[string]interfaces.Node) (*pgraph.Grap
 	cons := make(map[interfaces.Node]string)
 	cons[obj] = uid
 
-	for _, node := range obj.Args {
+	exprs := []interfaces.Expr{}
+	exprs = append(exprs, obj.Args...)
+	for _, x := range obj.Named {
+		exprs = append(exprs, x.Value)
+	}
+	for _, node := range exprs {
 		g, c, err := node.Ordering(produces)
 		if err != nil {
 			return nil, nil, err
@@ -8056,6 +8547,11 @@ func (obj *ExprCall) SetScope(scope *interfaces.Scope, sctx map[string]interface
 			return err
 		}
 	}
+	for _, x := range obj.Named {
+		if err := x.Value.SetScope(scope, sctx); err != nil {
+			return err
+		}
+	}
 
 	var prefixedName string
 	var target interfaces.Expr
@@ -8068,7 +8564,8 @@ func (obj *ExprCall) SetScope(scope *interfaces.Scope, sctx map[string]interface
 		} else {
 			f, exists := obj.scope.Variables[obj.Name]
 			if !exists {
-				return fmt.Errorf("func `%s` does not exist in this scope", prefixedName)
+				err := fmt.Errorf("func `%s` does not exist in this scope", prefixedName)
+				return interfaces.WrapPos(err, obj.Pos())
 			}
 			target = f
 		}
@@ -8077,7 +8574,8 @@ func (obj *ExprCall) SetScope(scope *interfaces.Scope, sctx map[string]interface
 		prefixedName = obj.Name
 		f, exists := obj.scope.Functions[obj.Name]
 		if !exists {
-			return fmt.Errorf("func `%s` does not exist in this scope", prefixedName)
+			err := fmt.Errorf("func `%s` does not exist in this scope", prefixedName)
+			return interfaces.WrapPos(err, obj.Pos())
 		}
 		target = f
 	}
@@ -8115,6 +8613,55 @@ func (obj *ExprCall) SetScope(scope *interfaces.Scope, sctx map[string]interface
 		obj.expr = target
 	}
 
+	// Now that we know what we're calling, we can look at its signature,
+	// and fill in any named or omitted args so that the rest of the
+	// compiler only ever has to deal with the full list of positional args.
+	if err := obj.completeArgs(); err != nil {
+		return errwrap.Wrapf(err, "func `%s`", prefixedName)
+	}
+
+	return nil
+}
+
+// completeArgs merges the named args into the list of positional args, and adds
+// a copy of the default value for each omitted arg that has one. This can only
+// be done once we know what we're calling, so it must run after the target is
+// found in SetScope. Afterwards, the list of named args is empty.
+func (obj *ExprCall) completeArgs() error {
+	fn, ok := trueCallee(obj.expr).(*ExprFunc)
+	if !ok {
+		// we can't see the signature, eg: this is a function param
+		if len(obj.Named) > 0 {
+			return fmt.Errorf("named args are not supported here")
+		}
+		return nil
+	}
+	if fn.Body == nil && len(obj.Named) == 0 {
+		return nil // built-in functions don't have default args
+	}
+	decl, err := fn.argsDecl()
+	if err != nil {
+		return err
+	}
+	if decl == nil { // unknown signature, so there can't be defaults
+		if len(obj.Named) > 0 {
+			return fmt.Errorf("named args are not supported by this func")
+		}
+		return nil
+	}
+
+	args, added, err := completeArgs(obj.data, decl, fn.scope, obj.Args, obj.Named)
+	if err != nil {
+		return err
+	}
+	for _, x := range added {
+		// each of these captured the scope of the func definition
+		if err := x.SetScope(fn.scope, map[string]interfaces.Expr{}); err != nil {
+			return errwrap.Wrapf(err, "could not set scope of default arg")
+		}
+	}
+	obj.Args = args
+	obj.Named = nil // we've used them up
 	return nil
 }
 
@@ -8637,6 +9184,38 @@ func (obj *ExprCall) Graph(env map[string]interfaces.Func) (*pgraph.Graph, inter
 		return nil, nil, errwrap.Wrapf(err, "could not get the type of the function")
 	}
 
+	// Loop over the arguments, add them to the graph, but do _not_ connect them
+	// to the function vertex. Instead, each time the call vertex (which we
+	// create below) receives a FuncValue from the function node, it creates the
+	// corresponding subgraph and connects these arguments to it.
+	var argGraphs []*pgraph.Graph
+	var argFuncs []interfaces.Func
+	for i, arg := range obj.Args {
+		argGraph, argFunc, err := arg.Graph(env)
+		if err != nil {
+			return nil, nil, errwrap.Wrapf(err, "could not get graph for arg %d", i)
+		}
+		argGraphs = append(argGraphs, argGraph)
+		argFuncs = append(argFuncs, argFunc)
+	}
+
+	// If we can compute the value right now, we only need a constant.
+	value, err := obj.fold(argFuncs)
+	if err != nil {
+		return nil, nil, errwrap.Wrapf(err, "could not fold call to `%s`", obj.Name)
+	}
+	if value != nil {
+		constFunc := &structs.ConstFunc{
+			Value:    value,
+			NameHint: fmt.Sprintf("folded: %s", obj.Name),
+		}
+		graph.AddVertex(constFunc)
+		return graph, constFunc, nil
+	}
+	for _, argGraph := range argGraphs {
+		graph.AddGraph(argGraph)
+	}
+
 	// Find the vertex which produces the FuncValue.
 	var funcValueFunc interfaces.Func
 	if _, isParam := obj.expr.(*ExprParam); isParam {
@@ -8661,20 +9240,6 @@ func (obj *ExprCall) Graph(env map[string]interfaces.Func) (*pgraph.Graph, inter
 		funcValueFunc = topLevelFunc
 	}
 
-	// Loop over the arguments, add them to the graph, but do _not_ connect them
-	// to the function vertex. Instead, each time the call vertex (which we
-	// create below) receives a FuncValue from the function node, it creates the
-	// corresponding subgraph and connects these arguments to it.
-	var argFuncs []interfaces.Func
-	for i, arg := range obj.Args {
-		argGraph, argFunc, err := arg.Graph(env)
-		if err != nil {
-			return nil, nil, errwrap.Wrapf(err, "could not get graph for arg %d", i)
-		}
-		graph.AddGraph(argGraph)
-		argFuncs = append(argFuncs, argFunc)
-	}
-
 	// Add a vertex for the call itself.
 	edgeName := structs.CallFuncArgNameFunction
 	callFunc := &structs.CallFunc{
@@ -8718,6 +9283,8 @@ func (obj *ExprCall) Value() (types.Value, error) {
 // ExprVar is a representation of a variable lookup. It returns the expression
 // that that variable refers to.
 type ExprVar struct {
+	position
+
 	scope *interfaces.Scope // store for referencing this later
 	typ   *types.Type
 
@@ -8737,7 +9304,7 @@ func (obj *ExprVar) Apply(fn func(interfaces.Node) error) error { return fn(obj)
 // Init initializes this branch of the AST, and returns an error if it fails to
 // validate.
 func (obj *ExprVar) Init(*interfaces.Data) error {
-	return langUtil.ValidateVarName(obj.Name)
+	return interfaces.WrapPos(langUtil.ValidateVarName(obj.Name), obj.Pos())
 }
 
 // Interpolate returns a new node (aka a copy) once it has been expanded. This
@@ -8747,6 +9314,8 @@ func (obj *ExprVar) Init(*interfaces.Data) error {
 // support variable, variables or anything crazy like that.
 func (obj *ExprVar) Interpolate() (interfaces.Expr, error) {
 	return &ExprVar{
+		position: obj.position,
+
 		scope: obj.scope,
 		typ:   obj.typ,
 		Name:  obj.Name,
@@ -8760,6 +9329,8 @@ func (obj *ExprVar) Interpolate() (interfaces.Expr, error) {
 // and they won't be able to have different values.
 func (obj *ExprVar) Copy() (interfaces.Expr, error) {
 	return &ExprVar{
+		position: obj.position,
+
 		scope: obj.scope,
 		typ:   obj.typ,
 		Name:  obj.Name,
@@ -8827,7 +9398,8 @@ func (obj *ExprVar) SetScope(scope *interfaces.Scope, sctx map[string]interfaces
 
 	target, exists := obj.scope.Variables[obj.Name]
 	if !exists {
-		return fmt.Errorf("variable %s not in scope", obj.Name)
+		err := fmt.Errorf("variable %s not in scope", obj.Name)
+		return interfaces.WrapPos(err, obj.Pos())
 	}
 
 	obj.scope.Variables[obj.Name] = target
@@ -8972,6 +9544,8 @@ func (obj *ExprVar) Value() (types.Value, error) {
 
 // ExprParam represents a parameter to a function.
 type ExprParam struct {
+	position
+
 	Name string // name of the parameter
 	Typ  *types.Type
 }
@@ -8999,6 +9573,8 @@ func (obj *ExprParam) Init(*interfaces.Data) error {
 // on any child elements and builds the new node with those new node contents.
 func (obj *ExprParam) Interpolate() (interfaces.Expr, error) {
 	return &ExprParam{
+		position: obj.position,
+
 		Name: obj.Name,
 		Typ:  obj.Typ,
 	}, nil
@@ -9011,6 +9587,8 @@ func (obj *ExprParam) Interpolate() (interfaces.Expr, error) {
 // and they won't be able to have different values.
 func (obj *ExprParam) Copy() (interfaces.Expr, error) {
 	return &ExprParam{
+		position: obj.position,
+
 		Name: obj.Name,
 		Typ:  obj.Typ,
 	}, nil
@@ -31,6 +31,7 @@ package ast
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 
@@ -40,6 +41,7 @@ import (
 	"github.com/purpleidea/mgmt/lang/funcs/vars"
 	"github.com/purpleidea/mgmt/lang/interfaces"
 	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/pgraph"
 	"github.com/purpleidea/mgmt/util/errwrap"
 )
 
@@ -280,6 +282,46 @@ func CollectFiles(ast interfaces.Stmt) ([]string, error) {
 	return fileList, nil
 }
 
+// BindFuncs returns the function graph vertices which produce the values of
+// the variables that are used in the AST, indexed by variable name. Variables
+// that have not been added to the function graph yet are not included. If the
+// same name refers to different variables in different scopes, then each one
+// is included in the list for that name.
+func BindFuncs(ast interfaces.Stmt) (map[string][]interfaces.Func, error) {
+	result := make(map[string][]interfaces.Func)
+	found := make(map[interfaces.Func]struct{})
+	fn := func(node interfaces.Node) error {
+		expr, ok := node.(*ExprVar)
+		if !ok || expr.scope == nil {
+			return nil
+		}
+		target := expr.scope.Variables[expr.Name]
+		if topLevel, ok := target.(*ExprTopLevel); ok {
+			target = topLevel.Definition
+		}
+		singleton, ok := target.(*ExprSingleton)
+		if !ok { // params and other vars don't have a single func
+			return nil
+		}
+		singleton.mutex.Lock()
+		f := singleton.singletonExpr
+		singleton.mutex.Unlock()
+		if f == nil { // not graphed (yet)
+			return nil
+		}
+		if _, exists := found[f]; exists {
+			return nil
+		}
+		found[f] = struct{}{}
+		result[expr.Name] = append(result[expr.Name], f)
+		return nil
+	}
+	if err := ast.Apply(fn); err != nil {
+		return nil, errwrap.Wrapf(err, "can't collect binds")
+	}
+	return result, nil
+}
+
 // CopyNodeMapping copies the map of string to node and is used in Ordering.
 func CopyNodeMapping(in map[string]interfaces.Node) map[string]interfaces.Node {
 	out := make(map[string]interfaces.Node)
@@ -288,3 +330,346 @@ func CopyNodeMapping(in map[string]interfaces.Node) map[string]interfaces.Node {
 	}
 	return out
 }
+
+// resolveTypeAliases resolves the list of type alias statements against each
+// other and against the aliases which are already in scope. The local aliases
+// shadow the ones from the scope. It errors on duplicate names and on cycles.
+// It returns the resolved types of the local aliases, indexed by their names.
+func resolveTypeAliases(stmts []*StmtType, scope map[string]*types.Type) (map[string]*types.Type, error) {
+	local := make(map[string]*StmtType)
+	names := []string{}
+	for _, x := range stmts {
+		if _, exists := local[x.Name]; exists {
+			return nil, fmt.Errorf("type `%s` already exists in this scope", x.Name)
+		}
+		local[x.Name] = x
+		names = append(names, x.Name)
+	}
+	sort.Strings(names) // deterministic error messages
+
+	resolved := make(map[string]*types.Type)
+	visiting := make(map[string]struct{})
+	var lookup func(string) (*types.Type, error)
+	lookup = func(name string) (*types.Type, error) {
+		if typ, exists := resolved[name]; exists {
+			return typ, nil
+		}
+		stmt, exists := local[name]
+		if !exists {
+			if typ, exists := scope[name]; exists {
+				return typ, nil
+			}
+			return nil, fmt.Errorf("type `%s` does not exist in this scope", name)
+		}
+		if _, exists := visiting[name]; exists {
+			return nil, fmt.Errorf("type `%s` is recursive", name)
+		}
+		visiting[name] = struct{}{}
+		typ, err := stmt.Type.Resolve(lookup)
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "could not resolve type `%s`", name)
+		}
+		delete(visiting, name)
+		resolved[name] = typ
+		return typ, nil
+	}
+
+	for _, name := range names {
+		if _, err := lookup(name); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// resolveType resolves any type alias references in the type with the aliases
+// that are in the scope. It returns the same pointer if there was nothing to do.
+func resolveType(typ *types.Type, scope *interfaces.Scope) (*types.Type, error) {
+	return typ.Resolve(func(name string) (*types.Type, error) {
+		if scope != nil {
+			if t, exists := scope.Types[name]; exists {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("type `%s` does not exist in this scope", name)
+	})
+}
+
+// applyArgs runs Apply on the default values of a list of declared args.
+func applyArgs(args []*interfaces.Arg, fn func(interfaces.Node) error) error {
+	for _, arg := range args {
+		if arg.Default == nil {
+			continue
+		}
+		if err := arg.Default.Apply(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// initArgs runs Init on the default values of a list of declared args. It also
+// validates that there are no duplicate names, and that a required arg doesn't
+// follow one which has a default, since that would be confusing to read.
+func initArgs(args []*interfaces.Arg, data *interfaces.Data) error {
+	names := make(map[string]struct{})
+	var last *interfaces.Arg // the last arg with a default
+	for _, arg := range args {
+		if _, exists := names[arg.Name]; exists {
+			return fmt.Errorf("duplicate arg `%s`", arg.Name)
+		}
+		names[arg.Name] = struct{}{}
+
+		if arg.Default == nil {
+			if last != nil {
+				return fmt.Errorf("required arg `%s` follows default arg `%s`", arg.Name, last.Name)
+			}
+			continue
+		}
+		last = arg
+		if err := arg.Default.Init(data); err != nil {
+			return errwrap.Wrapf(err, "default of arg `%s`", arg.Name)
+		}
+	}
+	return nil
+}
+
+// interpolateArgs returns a new list of declared args where the default values
+// have been interpolated. If none of the args have a default value, then the
+// same list is returned. The returned list is never nil.
+func interpolateArgs(args []*interfaces.Arg) ([]*interfaces.Arg, error) {
+	if args == nil {
+		return []*interfaces.Arg{}, nil // ensure this has length == 0
+	}
+	result := []*interfaces.Arg{}
+	interpolated := false
+	for _, arg := range args {
+		if arg.Default == nil {
+			result = append(result, arg)
+			continue
+		}
+		def, err := arg.Default.Interpolate()
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "could not interpolate default of arg `%s`", arg.Name)
+		}
+		a := *arg // keep everything else, such as the position
+		a.Default = def
+		result = append(result, &a)
+		interpolated = true
+	}
+	if !interpolated {
+		return args, nil
+	}
+	return result, nil
+}
+
+// orderingArgs returns the ordering graph and the consumes of the default
+// values of a list of declared args. The default values are evaluated in the
+// scope of the definition, so they get the produces of the parent, which is the
+// node that declares them.
+func orderingArgs(args []*interfaces.Arg, parent interfaces.Node, produces map[string]interfaces.Node) (*pgraph.Graph, map[interfaces.Node]string, error) {
+	graph, err := pgraph.NewGraph("ordering")
+	if err != nil {
+		return nil, nil, err
+	}
+	cons := make(map[interfaces.Node]string)
+
+	for _, arg := range args {
+		if arg.Default == nil {
+			continue
+		}
+		g, c, err := arg.Default.Ordering(produces)
+		if err != nil {
+			return nil, nil, err
+		}
+		graph.AddGraph(g) // add in the child graph
+
+		// additional constraint...
+		edge := &pgraph.SimpleEdge{Name: "argdefault1"}
+		graph.AddEdge(arg.Default, parent, edge) // prod -> cons
+
+		for k, v := range c { // c is consumes
+			cons[k] = v // add to map
+
+			n, exists := produces[v]
+			if !exists {
+				continue
+			}
+			edge := &pgraph.SimpleEdge{Name: "argdefault2"}
+			graph.AddEdge(n, k, edge)
+		}
+	}
+
+	return graph, cons, nil
+}
+
+// validateNamedArgs checks that a list of named args is valid. It is used by
+// the Init method of the nodes which accept them.
+func validateNamedArgs(named []*ExprNamedArg) error {
+	names := make(map[string]struct{})
+	for _, x := range named {
+		if x.Name == "" {
+			return fmt.Errorf("named arg is missing a name")
+		}
+		if x.Value == nil {
+			return fmt.Errorf("named arg `%s` is missing a value", x.Name)
+		}
+		if _, exists := names[x.Name]; exists {
+			return fmt.Errorf("duplicate named arg `%s`", x.Name)
+		}
+		names[x.Name] = struct{}{}
+	}
+	return nil
+}
+
+// interpolateNamedArgs returns a new list of named args where each value has
+// been interpolated.
+func interpolateNamedArgs(named []*ExprNamedArg) ([]*ExprNamedArg, error) {
+	if named == nil {
+		return nil, nil
+	}
+	result := []*ExprNamedArg{}
+	for _, x := range named {
+		interpolated, err := x.Value.Interpolate()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &ExprNamedArg{
+			Name:  x.Name,
+			Value: interpolated,
+		})
+	}
+	return result, nil
+}
+
+// copyNamedArgs returns a light copy of a list of named args. If none of the
+// values needed copying, then the same list is returned, and the boolean is
+// false.
+func copyNamedArgs(named []*ExprNamedArg) ([]*ExprNamedArg, bool, error) {
+	copied := false
+	result := []*ExprNamedArg{}
+	for _, x := range named {
+		cp, err := x.Value.Copy()
+		if err != nil {
+			return nil, false, err
+		}
+		if cp != x.Value { // must have been copied, or pointer would be same
+			copied = true
+		}
+		result = append(result, &ExprNamedArg{
+			Name:  x.Name,
+			Value: cp,
+		})
+	}
+	if !copied {
+		return named, false, nil
+	}
+	return result, true, nil
+}
+
+// completeArgs builds the full list of positional args for a call to something
+// that was declared with the list of args in decl. The positional args come
+// first, then the named args are placed by name, and any remaining args get a
+// fresh copy of their default value. Those copies are wrapped so that they are
+// evaluated in the scope that is passed in, which should be the scope of the
+// definition. The new default expressions are also returned, since they still
+// need to have SetScope run on them. If there are no named args, and a missing
+// arg has no default, then the args are returned unchanged, so that the caller
+// can report the mismatch in the usual way.
+func completeArgs(data *interfaces.Data, decl []*interfaces.Arg, scope *interfaces.Scope, args []interfaces.Expr, named []*ExprNamedArg) ([]interfaces.Expr, []interfaces.Expr, error) {
+	if len(named) == 0 && len(args) >= len(decl) {
+		return args, nil, nil // nothing to do
+	}
+	if len(args) > len(decl) {
+		return nil, nil, fmt.Errorf("expected at most %d args but got %d", len(decl), len(args)+len(named))
+	}
+
+	result := make([]interfaces.Expr, len(decl))
+	copy(result, args)
+	for _, x := range named {
+		found := false
+		for i, arg := range decl {
+			if arg.Name != x.Name {
+				continue
+			}
+			if result[i] != nil {
+				return nil, nil, fmt.Errorf("arg `%s` was passed more than once", x.Name)
+			}
+			result[i] = x.Value
+			found = true
+			break
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("unknown arg `%s`", x.Name)
+		}
+	}
+
+	added := []interfaces.Expr{}
+	for i, arg := range decl {
+		if result[i] != nil {
+			continue
+		}
+		if arg.Default == nil {
+			if len(named) == 0 {
+				return args, nil, nil // let the caller report this
+			}
+			return nil, nil, fmt.Errorf("missing arg `%s`", arg.Name)
+		}
+
+		// Each use of the default gets its own copy, so that it can be
+		// unified separately, just like an arg that was passed in.
+		def, err := arg.Default.Interpolate()
+		if err != nil {
+			return nil, nil, errwrap.Wrapf(err, "could not copy default of arg `%s`", arg.Name)
+		}
+		if err := def.Init(data); err != nil {
+			return nil, nil, errwrap.Wrapf(err, "could not init default of arg `%s`", arg.Name)
+		}
+		expr := &ExprTopLevel{
+			Definition:    def,
+			CapturedScope: scope,
+		}
+		result[i] = expr
+		added = append(added, expr)
+	}
+
+	return result, added, nil
+}
+
+// position stores where a node was defined in the code. It is embedded in the
+// nodes which the parser locates, and every copy of the node keeps the same one.
+type position struct {
+	pos *interfaces.Pos
+}
+
+// Locate stores the position of this node. It is called by the parser.
+func (obj *position) Locate(line, column int) {
+	obj.pos = &interfaces.Pos{Line: line, Column: column}
+}
+
+// Pos returns the position of this node, or nil if it is not known.
+func (obj *position) Pos() *interfaces.Pos { return obj.pos }
+
+// ScopeTarget returns the node that a variable, a call or an include statement
+// was resolved to by SetScope. It returns nil for any other kind of node, or if
+// the node was never scope-checked, such as in a class that is never included.
+func ScopeTarget(node interfaces.Node) interfaces.Node {
+	switch x := node.(type) {
+	case *ExprVar:
+		if x.scope == nil {
+			return nil
+		}
+		if target, exists := x.scope.Variables[x.Name]; exists {
+			return target
+		}
+	case *ExprCall:
+		if x.expr != nil {
+			return x.expr
+		}
+	case *StmtInclude:
+		if x.class != nil {
+			return x.class
+		}
+	}
+	return nil
+}
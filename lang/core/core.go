@@ -37,17 +37,22 @@ import (
 
 	// import so the funcs register
 	_ "github.com/purpleidea/mgmt/lang/core/convert"
+	_ "github.com/purpleidea/mgmt/lang/core/crypto"
 	_ "github.com/purpleidea/mgmt/lang/core/datetime"
 	_ "github.com/purpleidea/mgmt/lang/core/deploy"
 	_ "github.com/purpleidea/mgmt/lang/core/embedded"
+	_ "github.com/purpleidea/mgmt/lang/core/encoding"
 	_ "github.com/purpleidea/mgmt/lang/core/example"
 	_ "github.com/purpleidea/mgmt/lang/core/example/nested"
 	_ "github.com/purpleidea/mgmt/lang/core/fmt"
 	_ "github.com/purpleidea/mgmt/lang/core/iter"
+	_ "github.com/purpleidea/mgmt/lang/core/list"
+	_ "github.com/purpleidea/mgmt/lang/core/maps"
 	_ "github.com/purpleidea/mgmt/lang/core/math"
 	_ "github.com/purpleidea/mgmt/lang/core/net"
 	_ "github.com/purpleidea/mgmt/lang/core/os"
 	_ "github.com/purpleidea/mgmt/lang/core/regexp"
+	_ "github.com/purpleidea/mgmt/lang/core/secret"
 	_ "github.com/purpleidea/mgmt/lang/core/strings"
 	_ "github.com/purpleidea/mgmt/lang/core/sys"
 	_ "github.com/purpleidea/mgmt/lang/core/test"
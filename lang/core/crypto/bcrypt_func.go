@@ -0,0 +1,144 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package corecrypto
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// BcryptFuncName is the name this function is registered as.
+	BcryptFuncName = "bcrypt"
+
+	// arg names...
+	bcryptArgNamePassword = "password"
+)
+
+func init() {
+	funcs.ModuleRegister(ModuleName, BcryptFuncName, func() interfaces.Func { return &BcryptFunc{} }) // must register the func and name
+}
+
+// BcryptFunc returns the bcrypt hash of a password, with the default cost. The
+// salt is random, so it isn't pure, and it only hashes again when the password
+// changes. Since the hash is different each time that it runs, a resource which
+// compares it, such as the user password, is changed each time, so sha512_crypt
+// is usually a better choice. Passwords can't be longer than 72 bytes.
+type BcryptFunc struct {
+	init *interfaces.Init
+	last types.Value // last value received to use for diff
+}
+
+// String returns a simple name for this function. This is needed so this struct
+// can satisfy the pgraph.Vertex interface.
+func (obj *BcryptFunc) String() string {
+	return BcryptFuncName
+}
+
+// ArgGen returns the Nth arg name for this function.
+func (obj *BcryptFunc) ArgGen(index int) (string, error) {
+	seq := []string{bcryptArgNamePassword}
+	if l := len(seq); index >= l {
+		return "", fmt.Errorf("index %d exceeds arg length of %d", index, l)
+	}
+	return seq[index], nil
+}
+
+// Validate makes sure we've built our struct properly. It is usually unused for
+// normal functions that users can use directly.
+func (obj *BcryptFunc) Validate() error {
+	return nil
+}
+
+// Info returns some static info about itself.
+func (obj *BcryptFunc) Info() *interfaces.Info {
+	return &interfaces.Info{
+		Pure: false, // the salt is random
+		Memo: false,
+		Sig:  types.NewType(fmt.Sprintf("func(%s str) str", bcryptArgNamePassword)),
+		Err:  obj.Validate(),
+	}
+}
+
+// Init runs some startup code for this function.
+func (obj *BcryptFunc) Init(init *interfaces.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the changing values that this func has over time.
+func (obj *BcryptFunc) Stream(ctx context.Context) error {
+	defer close(obj.init.Output) // the sender closes
+	for {
+		select {
+		case input, ok := <-obj.init.Input:
+			if !ok {
+				return nil // can't output any more
+			}
+
+			if obj.last != nil && input.Cmp(obj.last) == nil {
+				continue // value didn't change, skip it
+			}
+			obj.last = input // store for next
+
+			result, err := bcryptHash(input.Struct()[bcryptArgNamePassword].Str())
+			if err != nil {
+				return err
+			}
+
+			select {
+			case obj.init.Output <- result:
+			case <-ctx.Done():
+				return nil
+			}
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// bcryptHash returns the bcrypt hash of a password with a random salt.
+func bcryptHash(password string) (types.Value, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "bcrypt failed")
+	}
+	return &types.StrValue{
+		V: string(b),
+	}, nil
+}
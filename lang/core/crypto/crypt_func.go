@@ -0,0 +1,70 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package corecrypto
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/purpleidea/mgmt/lang/funcs/simple"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+
+	sha512Crypt "github.com/tredoe/osutil/v2/userutil/crypt/sha512_crypt"
+)
+
+func init() {
+	simple.ModuleRegister(ModuleName, "sha512_crypt", &types.FuncValue{
+		T: types.NewType("func(password str, salt str) str"),
+		V: SHA512Crypt,
+	})
+}
+
+// SHA512Crypt returns the crypt(3) style sha512-crypt hash of a password, such
+// as what is used in /etc/shadow. The salt is given so that the same password
+// always hashes the same way, which keeps resources from changing every time.
+// Only the first sixteen characters of the salt are used.
+func SHA512Crypt(input []types.Value) (types.Value, error) {
+	password, salt := input[0].Str(), input[1].Str()
+	if salt == "" || strings.ContainsAny(salt, "$:\n") {
+		return nil, fmt.Errorf("invalid salt: %q", salt)
+	}
+	if len(salt) > sha512Crypt.SaltLenMax {
+		salt = salt[:sha512Crypt.SaltLenMax]
+	}
+	// With the default rounds, they aren't in the salt or the output.
+	hash, err := sha512Crypt.New().Generate([]byte(password), []byte(sha512Crypt.MagicPrefix+salt))
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "sha512-crypt failed")
+	}
+	return &types.StrValue{
+		V: hash,
+	}, nil
+}
@@ -0,0 +1,135 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package corecrypto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/purpleidea/mgmt/lang/types"
+
+	xbcrypt "golang.org/x/crypto/bcrypt"
+)
+
+func TestHashes0(t *testing.T) {
+	tests := []struct {
+		fn  func([]types.Value) (types.Value, error)
+		in  []string
+		exp string
+	}{
+		{SHA256, []string{"hello"}, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		{MD5, []string{"hello"}, "5d41402abc4b2a76b9719d911017c592"},
+		{HMACSHA256, []string{"key", "The quick brown fox jumps over the lazy dog"}, "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{Base64Encode, []string{"hello"}, "aGVsbG8="},
+		{Base64Decode, []string{"aGVsbG8="}, "hello"},
+		{HexEncode, []string{"hi"}, "6869"},
+		{HexDecode, []string{"6869"}, "hi"},
+	}
+	for i, x := range tests {
+		input := []types.Value{}
+		for _, s := range x.in {
+			input = append(input, &types.StrValue{V: s})
+		}
+		out, err := x.fn(input)
+		if err != nil {
+			t.Errorf("test #%d: failed with: %+v", i, err)
+			continue
+		}
+		if s := out.Str(); s != x.exp {
+			t.Errorf("test #%d: expected %s, got %s", i, x.exp, s)
+		}
+	}
+
+	if _, err := Base64Decode([]types.Value{&types.StrValue{V: "!!"}}); err == nil {
+		t.Errorf("expected invalid base64 to error")
+	}
+}
+
+func TestSHA512Crypt0(t *testing.T) {
+	tests := []struct {
+		password, salt, exp string
+	}{
+		{"Hello world!", "saltstring", "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1"},
+		{"", "ab", "$6$ab$xnh5Qsr2NdbFw1PgdZie7nLON3gv.S.23iQDBqAzkdoXPtDnVSpludXkM5UWybQO3OI7hBj9wHg9Ow6sUWD60/"},
+		{strings.Repeat("a", 100), "0123456789abcdefXYZ", "$6$0123456789abcdef$tGaTckP72q7jpF52bMmzwGpchsg55T/p77YUV80zNoRM/7d5eq5NiZJA1xo.7xLIcQ9oCdiE2XE1z1QfF5CNt1"},
+	}
+	for i, x := range tests {
+		out, err := SHA512Crypt([]types.Value{&types.StrValue{V: x.password}, &types.StrValue{V: x.salt}})
+		if err != nil {
+			t.Errorf("test #%d: failed with: %+v", i, err)
+			continue
+		}
+		if s := out.Str(); s != x.exp {
+			t.Errorf("test #%d: expected %s, got %s", i, x.exp, s)
+		}
+	}
+
+	if _, err := SHA512Crypt([]types.Value{&types.StrValue{V: "x"}, &types.StrValue{V: "a$b"}}); err == nil {
+		t.Errorf("expected invalid salt to error")
+	}
+}
+
+func TestBcrypt0(t *testing.T) {
+	out, err := bcryptHash("hunter2")
+	if err != nil {
+		t.Errorf("bcrypt failed with: %+v", err)
+		return
+	}
+	if err := xbcrypt.CompareHashAndPassword([]byte(out.Str()), []byte("hunter2")); err != nil {
+		t.Errorf("hash %s did not verify: %+v", out.Str(), err)
+	}
+	if _, err := bcryptHash(strings.Repeat("x", 73)); err == nil {
+		t.Errorf("expected an error for a password which is too long")
+	}
+}
+
+func TestStableRandom0(t *testing.T) {
+	a := stableRandom("secret", "h1", 100)
+	if len(a) != 100 {
+		t.Errorf("unexpected length: %d", len(a))
+	}
+	if strings.Trim(a, stableRandomAlphabet) != "" {
+		t.Errorf("unexpected characters in: %s", a)
+	}
+	if b := stableRandom("secret", "h1", 100); a != b {
+		t.Errorf("string is not stable: %s != %s", a, b)
+	}
+	if b := stableRandom("secret", "h1", 10); b != a[:10] {
+		t.Errorf("shorter string is not a prefix: %s", b)
+	}
+	if b := stableRandom("secret", "h2", 100); a == b {
+		t.Errorf("different hosts gave the same string")
+	}
+	if b := stableRandom("other", "h1", 100); a == b {
+		t.Errorf("different secrets gave the same string")
+	}
+}
@@ -0,0 +1,111 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package corecrypto
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+
+	"github.com/purpleidea/mgmt/lang/funcs/simple"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func init() {
+	simple.ModuleRegister(ModuleName, "md5", &types.FuncValue{
+		T: types.NewType("func(s str) str"),
+		V: MD5,
+	})
+	simple.ModuleRegister(ModuleName, "sha1", &types.FuncValue{
+		T: types.NewType("func(s str) str"),
+		V: SHA1,
+	})
+	simple.ModuleRegister(ModuleName, "sha256", &types.FuncValue{
+		T: types.NewType("func(s str) str"),
+		V: SHA256,
+	})
+	simple.ModuleRegister(ModuleName, "sha512", &types.FuncValue{
+		T: types.NewType("func(s str) str"),
+		V: SHA512,
+	})
+	simple.ModuleRegister(ModuleName, "hmac_sha256", &types.FuncValue{
+		T: types.NewType("func(key str, message str) str"),
+		V: HMACSHA256,
+	})
+	simple.ModuleRegister(ModuleName, "hmac_sha512", &types.FuncValue{
+		T: types.NewType("func(key str, message str) str"),
+		V: HMACSHA512,
+	})
+}
+
+// digest returns the hex encoded hash of a string.
+func digest(h hash.Hash, s string) types.Value {
+	h.Write([]byte(s)) // never errors
+	return &types.StrValue{
+		V: hex.EncodeToString(h.Sum(nil)),
+	}
+}
+
+// MD5 returns the hex encoded md5 hash of a string. It's not secure, but it's
+// still a common checksum.
+func MD5(input []types.Value) (types.Value, error) {
+	return digest(md5.New(), input[0].Str()), nil
+}
+
+// SHA1 returns the hex encoded sha1 hash of a string.
+func SHA1(input []types.Value) (types.Value, error) {
+	return digest(sha1.New(), input[0].Str()), nil
+}
+
+// SHA256 returns the hex encoded sha256 hash of a string.
+func SHA256(input []types.Value) (types.Value, error) {
+	return digest(sha256.New(), input[0].Str()), nil
+}
+
+// SHA512 returns the hex encoded sha512 hash of a string.
+func SHA512(input []types.Value) (types.Value, error) {
+	return digest(sha512.New(), input[0].Str()), nil
+}
+
+// HMACSHA256 returns the hex encoded HMAC of a message, using sha256 and the
+// key.
+func HMACSHA256(input []types.Value) (types.Value, error) {
+	return digest(hmac.New(sha256.New, []byte(input[0].Str())), input[1].Str()), nil
+}
+
+// HMACSHA512 returns the hex encoded HMAC of a message, using sha512 and the
+// key.
+func HMACSHA512(input []types.Value) (types.Value, error) {
+	return digest(hmac.New(sha512.New, []byte(input[0].Str())), input[1].Str()), nil
+}
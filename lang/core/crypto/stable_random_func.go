@@ -0,0 +1,174 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package corecrypto
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+const (
+	// StableRandomFuncName is the name this function is registered as.
+	StableRandomFuncName = "stable_random"
+
+	// arg names...
+	stableRandomArgNameSecret = "secret"
+	stableRandomArgNameLength = "length"
+
+	// stableRandomAlphabet is the alphabet that the strings are made from.
+	stableRandomAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+func init() {
+	funcs.ModuleRegister(ModuleName, StableRandomFuncName, func() interfaces.Func { return &StableRandomFunc{} })
+}
+
+// StableRandomFunc returns a random looking string which is derived from a
+// secret and the hostname. The same secret on the same host always gives the
+// same string, so it can be used for things like generated passwords that need
+// to stay the same across runs, without having to store them anywhere. Anyone
+// who knows the secret and the hostname can work out the string.
+type StableRandomFunc struct {
+	init *interfaces.Init
+
+	last   types.Value // last value received to use for diff
+	result types.Value // last calculated output
+}
+
+// String returns a simple name for this function. This is needed so this struct
+// can satisfy the pgraph.Vertex interface.
+func (obj *StableRandomFunc) String() string {
+	return StableRandomFuncName
+}
+
+// ArgGen returns the Nth arg name for this function.
+func (obj *StableRandomFunc) ArgGen(index int) (string, error) {
+	seq := []string{stableRandomArgNameSecret, stableRandomArgNameLength}
+	if l := len(seq); index >= l {
+		return "", fmt.Errorf("index %d exceeds arg length of %d", index, l)
+	}
+	return seq[index], nil
+}
+
+// Validate makes sure we've built our struct properly. It is usually unused for
+// normal functions that users can use directly.
+func (obj *StableRandomFunc) Validate() error {
+	return nil
+}
+
+// Info returns some static info about itself.
+func (obj *StableRandomFunc) Info() *interfaces.Info {
+	return &interfaces.Info{
+		Pure: false, // it depends on the hostname
+		Memo: false,
+		Sig:  types.NewType(fmt.Sprintf("func(%s str, %s int) str", stableRandomArgNameSecret, stableRandomArgNameLength)),
+		Err:  obj.Validate(),
+	}
+}
+
+// Init runs some startup code for this function.
+func (obj *StableRandomFunc) Init(init *interfaces.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the changing values that this func has over time.
+func (obj *StableRandomFunc) Stream(ctx context.Context) error {
+	defer close(obj.init.Output) // the sender closes
+	for {
+		select {
+		case input, ok := <-obj.init.Input:
+			if !ok {
+				return nil // can't output any more
+			}
+			if obj.last != nil && input.Cmp(obj.last) == nil {
+				continue // value didn't change, skip it
+			}
+			obj.last = input // store for next
+
+			secret := input.Struct()[stableRandomArgNameSecret].Str()
+			length := input.Struct()[stableRandomArgNameLength].Int()
+			if secret == "" {
+				return fmt.Errorf("the secret can't be empty")
+			}
+			if length < 0 {
+				return fmt.Errorf("can't generate a negative length")
+			}
+
+			result := &types.StrValue{
+				V: stableRandom(secret, obj.init.Hostname, int(length)),
+			}
+			if obj.result != nil && result.Cmp(obj.result) == nil {
+				continue // result didn't change
+			}
+			obj.result = result // store new result
+
+		case <-ctx.Done():
+			return nil
+		}
+
+		select {
+		case obj.init.Output <- obj.result: // send
+			// pass
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// stableRandom generates a string from the HMAC of the hostname and a counter,
+// keyed with the secret. Bytes which would bias the choice of character are
+// skipped, so each character is equally likely.
+func stableRandom(secret, hostname string, length int) string {
+	limit := 256 - 256%len(stableRandomAlphabet)
+	out := []byte{}
+	for counter := uint64(0); len(out) < length; counter++ {
+		h := hmac.New(sha256.New, []byte(secret))
+		h.Write([]byte(hostname))
+		binary.Write(h, binary.BigEndian, counter) // never errors
+		for _, b := range h.Sum(nil) {
+			if int(b) >= limit {
+				continue
+			}
+			if len(out) == length {
+				break
+			}
+			out = append(out, stableRandomAlphabet[int(b)%len(stableRandomAlphabet)])
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,92 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coredatetime
+
+import (
+	"time"
+
+	"github.com/purpleidea/mgmt/lang/funcs/simple"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+func init() {
+	simple.ModuleRegister(ModuleName, "add", &types.FuncValue{
+		T: types.NewType("func(a int, duration str) int"),
+		V: Add,
+	})
+	simple.ModuleRegister(ModuleName, "sub", &types.FuncValue{
+		T: types.NewType("func(a int, duration str) int"),
+		V: Sub,
+	})
+	simple.ModuleRegister(ModuleName, "add_date", &types.FuncValue{
+		T: types.NewType("func(a int, years int, months int, days int, zone str) int"),
+		V: AddDate,
+	})
+}
+
+// Add adds a golang duration, such as `1h30m`, to a unix timestamp. This is an
+// exact amount of time, so a day is not always 24h when the clocks change. Use
+// add_date for that.
+func Add(input []types.Value) (types.Value, error) {
+	d, err := time.ParseDuration(input[1].Str())
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "invalid duration")
+	}
+	return &types.IntValue{
+		V: time.Unix(input[0].Int(), 0).Add(d).Unix(),
+	}, nil
+}
+
+// Sub subtracts a golang duration, such as `1h30m`, from a unix timestamp.
+func Sub(input []types.Value) (types.Value, error) {
+	d, err := time.ParseDuration(input[1].Str())
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "invalid duration")
+	}
+	return &types.IntValue{
+		V: time.Unix(input[0].Int(), 0).Add(-d).Unix(),
+	}, nil
+}
+
+// AddDate adds a number of years, months and days to a unix timestamp, keeping
+// the same wall clock time in the given time zone. The empty zone is the local
+// one. Adding one day across a daylight saving time change keeps the hour. As
+// with golang, the 31st of october plus one month is the 1st of december.
+func AddDate(input []types.Value) (types.Value, error) {
+	loc, err := loadLocation(input[4].Str())
+	if err != nil {
+		return nil, err
+	}
+	t := time.Unix(input[0].Int(), 0).In(loc)
+	return &types.IntValue{
+		V: t.AddDate(int(input[1].Int()), int(input[2].Int()), int(input[3].Int())).Unix(),
+	}, nil
+}
@@ -0,0 +1,235 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coredatetime
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// cronZonePrefix is the prefix of an optional time zone at the start of
+	// a cron expression, such as `TZ=Europe/Paris 0 9 * * *`.
+	cronZonePrefix = "TZ="
+
+	// cronSearchYears is how far into the future we look for the next time
+	// that a cron expression matches. Some of them never match, such as the
+	// 30th of February, and leap days can be eight years apart.
+	cronSearchYears = 10
+)
+
+var (
+	cronMacros = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+
+	cronMonths = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	cronDays   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// cronField is the set of values that one field of a cron expression matches.
+type cronField struct {
+	min, max int
+	names    []string // names of the values, starting from min
+	values   map[int]bool
+	star     bool // true if the field started with a star
+}
+
+// parse parses one comma separated field. Each element is a star, a number, a
+// name, or a range, which may be followed by a step such as `*/15` or `1-5/2`.
+func (obj *cronField) parse(field string) error {
+	obj.values = make(map[int]bool)
+	obj.star = strings.HasPrefix(field, "*")
+	for _, elem := range strings.Split(field, ",") {
+		rng, step := elem, 1
+		if i := strings.Index(elem, "/"); i >= 0 {
+			s, err := strconv.Atoi(elem[i+1:])
+			if err != nil || s <= 0 {
+				return fmt.Errorf("invalid step in: %s", elem)
+			}
+			rng, step = elem[:i], s
+		}
+
+		lo, hi := obj.min, obj.max
+		if rng != "*" {
+			var err error
+			bounds := strings.SplitN(rng, "-", 2)
+			if lo, err = obj.value(bounds[0]); err != nil {
+				return err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = obj.value(bounds[1]); err != nil {
+					return err
+				}
+			} else if step > 1 { // as in `5/15`, which means `5-59/15`
+				hi = obj.max
+			}
+		}
+		if lo > hi {
+			return fmt.Errorf("invalid range: %s", rng)
+		}
+		for i := lo; i <= hi; i += step {
+			obj.values[i] = true
+		}
+	}
+	return nil
+}
+
+// value parses a single number or name.
+func (obj *cronField) value(s string) (int, error) {
+	for i, name := range obj.names {
+		if strings.ToLower(s) == name {
+			return obj.min + i, nil
+		}
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value: %s", s)
+	}
+	if i < obj.min || i > obj.max {
+		return 0, fmt.Errorf("value %d is out of range %d-%d", i, obj.min, obj.max)
+	}
+	return i, nil
+}
+
+// cronSchedule is a parsed cron expression.
+type cronSchedule struct {
+	minute, hour, dom, month, dow *cronField
+	loc                           *time.Location
+}
+
+// parseCron parses a standard cron expression with the five fields of minute,
+// hour, day of month, month and day of week. The months and days can be names,
+// such as `jan` or `mon`, and a day of week of 7 is also sunday. The macros such
+// as `@daily` and `@hourly` are supported too. It is evaluated in the local time
+// zone unless it starts with one such as `TZ=Europe/Paris`. As with the classic
+// cron, if both days fields are restricted, then a day matching either is fine.
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	loc := time.Local
+	if len(fields) > 0 && strings.HasPrefix(fields[0], cronZonePrefix) {
+		var err error
+		if loc, err = loadLocation(strings.TrimPrefix(fields[0], cronZonePrefix)); err != nil {
+			return nil, err
+		}
+		fields = fields[1:]
+	}
+	if len(fields) == 1 {
+		macro, exists := cronMacros[fields[0]]
+		if !exists {
+			return nil, fmt.Errorf("unknown cron macro: %s", fields[0])
+		}
+		fields = strings.Fields(macro)
+	}
+	if l := len(fields); l != 5 {
+		return nil, fmt.Errorf("expected 5 fields in cron expression, got %d", l)
+	}
+
+	obj := &cronSchedule{
+		minute: &cronField{min: 0, max: 59},
+		hour:   &cronField{min: 0, max: 23},
+		dom:    &cronField{min: 1, max: 31},
+		month:  &cronField{min: 1, max: 12, names: cronMonths},
+		dow:    &cronField{min: 0, max: 7, names: cronDays},
+		loc:    loc,
+	}
+	for i, field := range []*cronField{obj.minute, obj.hour, obj.dom, obj.month, obj.dow} {
+		if err := field.parse(fields[i]); err != nil {
+			return nil, fmt.Errorf("invalid cron field `%s`: %v", fields[i], err)
+		}
+	}
+	if obj.dow.values[7] { // sunday is both 0 and 7
+		obj.dow.values[0] = true
+	}
+	return obj, nil
+}
+
+// dayMatches returns true if this day matches the two days fields.
+func (obj *cronSchedule) dayMatches(t time.Time) bool {
+	dom := obj.dom.values[t.Day()]
+	dow := obj.dow.values[int(t.Weekday())]
+	if !obj.dom.star && !obj.dow.star {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// Matches returns true if the minute of this time matches the expression. It is
+// checked with the wall clock time of the time zone of the expression.
+func (obj *cronSchedule) Matches(t time.Time) bool {
+	t = t.In(obj.loc)
+	return obj.month.values[int(t.Month())] && obj.dayMatches(t) && obj.hour.values[t.Hour()] && obj.minute.values[t.Minute()]
+}
+
+// Next returns the start of the first minute after this time which matches the
+// expression. It returns the zero time if there isn't one in the next few
+// years. The wall clock times which are skipped by a daylight saving time change
+// never match, and the ones which are repeated only match the first time.
+func (obj *cronSchedule) Next(t time.Time) time.Time {
+	// This must not go through the wall clock time, since a repeated one
+	// would be moved to the first time that it happens, which is earlier.
+	t = t.In(obj.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + cronSearchYears
+	for t.Year() <= limit {
+		var next time.Time
+		y, m, d, h := t.Year(), t.Month(), t.Day(), t.Hour()
+		switch {
+		case !obj.month.values[int(m)]:
+			next = time.Date(y, m+1, 1, 0, 0, 0, 0, obj.loc)
+		case !obj.dayMatches(t):
+			next = time.Date(y, m, d+1, 0, 0, 0, 0, obj.loc)
+		case !obj.hour.values[h]:
+			next = time.Date(y, m, d, h+1, 0, 0, 0, obj.loc)
+		case !obj.minute.values[t.Minute()]:
+			next = t.Add(time.Minute)
+		default:
+			if prev := t.Add(-time.Hour); prev.Hour() == h && prev.Minute() == t.Minute() && prev.Day() == d {
+				// this wall clock time is repeated
+				next = t.Add(time.Minute)
+				break
+			}
+			return t
+		}
+		if !next.After(t) { // always make progress around dst changes
+			next = t.Add(time.Minute)
+		}
+		t = next
+	}
+	return time.Time{}
+}
@@ -0,0 +1,210 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package coredatetime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func TestParseCron0(t *testing.T) {
+	invalid := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"@bogus",
+		"TZ=Nowhere/Zone * * * * *",
+	}
+	for _, expr := range invalid {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("expected an error for: %s", expr)
+		}
+	}
+}
+
+func TestCronNext0(t *testing.T) {
+	utc := func(y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, time.UTC)
+	}
+	values := []struct {
+		expr  string
+		after time.Time
+		next  time.Time
+	}{
+		{"TZ=UTC 30 2 * * *", utc(2024, 1, 1, 0, 0), utc(2024, 1, 1, 2, 30)},
+		{"TZ=UTC */15 * * * *", utc(2024, 1, 1, 10, 7), utc(2024, 1, 1, 10, 15)},
+		{"TZ=UTC */15 * * * *", utc(2024, 1, 1, 10, 15), utc(2024, 1, 1, 10, 30)}, // strictly after
+		{"TZ=UTC 5/20 * * * *", utc(2024, 1, 1, 10, 30), utc(2024, 1, 1, 10, 45)},
+		{"TZ=UTC @hourly", utc(2024, 1, 1, 10, 59), utc(2024, 1, 1, 11, 0)},
+		{"TZ=UTC 0 0 29 feb *", utc(2024, 3, 1, 0, 0), utc(2028, 2, 29, 0, 0)},
+		{"TZ=UTC 0 9 * * mon-fri", utc(2024, 1, 5, 10, 0), utc(2024, 1, 8, 9, 0)},
+		{"TZ=UTC 0 0 * * 7", utc(2024, 1, 1, 0, 0), utc(2024, 1, 7, 0, 0)},    // sunday
+		{"TZ=UTC 0 0 13 * fri", utc(2024, 1, 1, 0, 0), utc(2024, 1, 5, 0, 0)}, // either day
+		{"TZ=UTC 0 0 13 * *", utc(2024, 1, 1, 0, 0), utc(2024, 1, 13, 0, 0)},
+		{"TZ=UTC 0 12 1,15 jan,jul *", utc(2024, 1, 20, 0, 0), utc(2024, 7, 1, 12, 0)},
+		{"TZ=UTC 0 0 30 2 *", utc(2024, 1, 1, 0, 0), time.Time{}}, // never
+	}
+	for i, x := range values {
+		sched, err := parseCron(x.expr)
+		if err != nil {
+			t.Errorf("test index %d failed to parse: %+v", i, err)
+			continue
+		}
+		if next := sched.Next(x.after); !next.Equal(x.next) {
+			t.Errorf("test index %d (%s) expected %s, got %s", i, x.expr, x.next, next)
+		}
+	}
+}
+
+func TestCronNextDST0(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone data: %+v", err)
+	}
+	local := func(y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, loc)
+	}
+
+	// 02:30 doesn't exist on the day that the clocks go forward
+	sched, err := parseCron("TZ=America/New_York 30 2 * * *")
+	if err != nil {
+		t.Fatalf("could not parse: %+v", err)
+	}
+	if next, exp := sched.Next(local(2024, 3, 10, 0, 0)), local(2024, 3, 11, 2, 30); !next.Equal(exp) {
+		t.Errorf("expected %s, got %s", exp, next)
+	}
+
+	// 01:30 happens twice on the day that the clocks go back
+	sched, err = parseCron("TZ=America/New_York 30 1 * * *")
+	if err != nil {
+		t.Fatalf("could not parse: %+v", err)
+	}
+	first := sched.Next(local(2024, 11, 3, 0, 0))
+	if exp := time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC); !first.Equal(exp) {
+		t.Errorf("expected %s, got %s", exp, first)
+	}
+	if next, exp := sched.Next(first), local(2024, 11, 4, 1, 30); !next.Equal(exp) {
+		t.Errorf("expected %s, got %s", exp, next)
+	}
+
+	// every minute of the repeated hour is after the one before it
+	sched, err = parseCron("TZ=America/New_York * * * * *")
+	if err != nil {
+		t.Fatalf("could not parse: %+v", err)
+	}
+	for _, utc := range []int{5, 6} { // 01:30 EDT and then 01:30 EST
+		after := time.Date(2024, 11, 3, utc, 30, 0, 0, time.UTC)
+		if next := sched.Next(after); !next.After(after) {
+			t.Errorf("expected a time after %s, got %s", after, next)
+		}
+	}
+	// the repeated ones only match the first time, so the next is 02:00
+	second := time.Date(2024, 11, 3, 6, 30, 0, 0, time.UTC) // 01:30 EST
+	if next, exp := sched.Next(second), local(2024, 11, 3, 2, 0); !next.Equal(exp) {
+		t.Errorf("expected %s, got %s", exp, next)
+	}
+
+	// a window opens at the same wall clock time on either side of a change
+	sched, err = parseCron("TZ=America/New_York * 9-16 * * *")
+	if err != nil {
+		t.Fatalf("could not parse: %+v", err)
+	}
+	for _, d := range []int{9, 10, 11} {
+		if !sched.Matches(local(2024, 3, d, 9, 0)) || !sched.Matches(local(2024, 3, d, 16, 59)) {
+			t.Errorf("window should be open on day %d", d)
+		}
+		if sched.Matches(local(2024, 3, d, 8, 59)) || sched.Matches(local(2024, 3, d, 17, 0)) {
+			t.Errorf("window should be closed on day %d", d)
+		}
+	}
+}
+
+func TestInWindowStream0(t *testing.T) {
+	input := make(chan types.Value)
+	output := make(chan types.Value)
+	fn := &InWindowFunc{}
+	if err := fn.Init(&interfaces.Init{
+		Input:  input,
+		Output: output,
+		Logf:   t.Logf,
+	}); err != nil {
+		t.Fatalf("could not init: %+v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errch := make(chan error)
+	go func() {
+		errch <- fn.Stream(ctx)
+	}()
+
+	for _, x := range []struct {
+		expr string
+		exp  bool
+	}{
+		{"* * * * *", true},
+		{"0 0 30 2 *", false}, // never
+	} {
+		st := types.NewStruct(types.NewType("struct{expr str}"))
+		if err := st.Set(cronArgNameExpr, &types.StrValue{V: x.expr}); err != nil {
+			t.Fatalf("could not set: %+v", err)
+		}
+		input <- st
+		if v := <-output; v.Bool() != x.exp {
+			t.Errorf("expected %t for: %s", x.exp, x.expr)
+		}
+	}
+
+	close(input) // it keeps running, since the time changes
+	select {
+	case <-output:
+		t.Errorf("unexpected output")
+	case <-time.After(100 * time.Millisecond):
+	}
+	cancel()
+	if err := <-errch; err != nil {
+		t.Errorf("stream failed: %+v", err)
+	}
+	if _, ok := <-output; ok {
+		t.Errorf("output was not closed")
+	}
+}
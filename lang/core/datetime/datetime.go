@@ -29,7 +29,27 @@
 
 package coredatetime
 
+import (
+	"time"
+
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
 const (
 	// ModuleName is the prefix given to all the functions in this module.
 	ModuleName = "datetime"
 )
+
+// loadLocation returns the time zone with this name, such as `Europe/Paris`.
+// The empty string and `Local` are the local time zone of the host, so that a
+// time zone arg can be left empty.
+func loadLocation(zone string) (*time.Location, error) {
+	if zone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "invalid time zone: %s", zone)
+	}
+	return loc, nil
+}
@@ -0,0 +1,103 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coredatetime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+const (
+	// InWindowFuncName is the name this function is registered as.
+	InWindowFuncName = "in_window"
+)
+
+func init() {
+	funcs.ModuleRegister(ModuleName, InWindowFuncName, func() interfaces.Func { return &InWindowFunc{} }) // must register the func and name
+}
+
+// InWindowFunc is true during every minute which matches a cron expression, and
+// false otherwise. It changes at the start of the minute when the window opens
+// or closes. For example, `* 9-17 * * mon-fri` is a window during office hours.
+// Since it uses the wall clock time, it opens and closes at the same time of day
+// even when the clocks change. See next_cron for the format of the expression.
+type InWindowFunc struct {
+	init *interfaces.Init
+}
+
+// String returns a simple name for this function. This is needed so this struct
+// can satisfy the pgraph.Vertex interface.
+func (obj *InWindowFunc) String() string {
+	return InWindowFuncName
+}
+
+// ArgGen returns the Nth arg name for this function.
+func (obj *InWindowFunc) ArgGen(index int) (string, error) {
+	seq := []string{cronArgNameExpr}
+	if l := len(seq); index >= l {
+		return "", fmt.Errorf("index %d exceeds arg length of %d", index, l)
+	}
+	return seq[index], nil
+}
+
+// Validate makes sure we've built our struct properly. It is usually unused for
+// normal functions that users can use directly.
+func (obj *InWindowFunc) Validate() error {
+	return nil
+}
+
+// Info returns some static info about itself.
+func (obj *InWindowFunc) Info() *interfaces.Info {
+	return &interfaces.Info{
+		Pure: false, // it changes over time
+		Memo: false,
+		Sig:  types.NewType(fmt.Sprintf("func(%s str) bool", cronArgNameExpr)),
+	}
+}
+
+// Init runs some startup code for this function.
+func (obj *InWindowFunc) Init(init *interfaces.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the changing values that this func has over time.
+func (obj *InWindowFunc) Stream(ctx context.Context) error {
+	return minuteStream(ctx, obj.init, cronArgNameExpr, func(sched *cronSchedule, t time.Time) (types.Value, error) {
+		return &types.BoolValue{
+			V: sched.Matches(t),
+		}, nil
+	})
+}
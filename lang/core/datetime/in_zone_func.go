@@ -0,0 +1,113 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coredatetime
+
+import (
+	"strings"
+	"time"
+
+	"github.com/purpleidea/mgmt/lang/funcs/simple"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+// inZoneOutType is the type of the values that the in_zone function produces.
+var inZoneOutType = types.NewType("struct{year int; month int; day int; hour int; minute int; second int; weekday str; yearday int; zone str; offset int}")
+
+func init() {
+	simple.ModuleRegister(ModuleName, "in_zone", &types.FuncValue{
+		T: types.NewType("func(a int, zone str) " + inZoneOutType.String()),
+		V: InZone,
+	})
+	simple.ModuleRegister(ModuleName, "unix", &types.FuncValue{
+		T: types.NewType("func(year int, month int, day int, hour int, minute int, second int, zone str) int"),
+		V: Unix,
+	})
+}
+
+// InZone converts a unix timestamp into the wall clock time in the given time
+// zone, such as `Europe/Paris`. The empty zone is the local one. The zone field
+// of the result is the abbreviated name, such as `CEST`, and the offset is the
+// number of seconds east of UTC.
+func InZone(input []types.Value) (types.Value, error) {
+	loc, err := loadLocation(input[1].Str())
+	if err != nil {
+		return nil, err
+	}
+	t := time.Unix(input[0].Int(), 0).In(loc)
+	name, offset := t.Zone()
+
+	fields := map[string]types.Value{
+		"year":    &types.IntValue{V: int64(t.Year())},
+		"month":   &types.IntValue{V: int64(t.Month())},
+		"day":     &types.IntValue{V: int64(t.Day())},
+		"hour":    &types.IntValue{V: int64(t.Hour())},
+		"minute":  &types.IntValue{V: int64(t.Minute())},
+		"second":  &types.IntValue{V: int64(t.Second())},
+		"weekday": &types.StrValue{V: strings.ToLower(t.Weekday().String())},
+		"yearday": &types.IntValue{V: int64(t.YearDay())},
+		"zone":    &types.StrValue{V: name},
+		"offset":  &types.IntValue{V: int64(offset)},
+	}
+	result := types.NewStruct(inZoneOutType)
+	for k, v := range fields {
+		if err := result.Set(k, v); err != nil {
+			return nil, errwrap.Wrapf(err, "struct could not set field `%s`", k)
+		}
+	}
+	return result, nil
+}
+
+// Unix converts a wall clock time in the given time zone into a unix timestamp.
+// The empty zone is the local one. Values out of range are normalized, so the
+// 32nd of january is the 1st of february. A time which is skipped when the
+// clocks go forward is moved forward by the size of the change, so 02:30 is
+// 03:30 if the clocks skip from 02:00 to 03:00.
+func Unix(input []types.Value) (types.Value, error) {
+	loc, err := loadLocation(input[6].Str())
+	if err != nil {
+		return nil, err
+	}
+	ints := []int{}
+	for _, x := range input[:6] {
+		ints = append(ints, int(x.Int()))
+	}
+	t := time.Date(ints[0], time.Month(ints[1]), ints[2], ints[3], ints[4], ints[5], 0, loc)
+
+	// golang doesn't say which way it moves a skipped time, so check it
+	want := time.Date(ints[0], time.Month(ints[1]), ints[2], ints[3], ints[4], ints[5], 0, time.UTC)
+	got := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	if got.Before(want) {
+		t = t.Add(want.Sub(got))
+	}
+	return &types.IntValue{
+		V: t.Unix(),
+	}, nil
+}
@@ -0,0 +1,190 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coredatetime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/funcs/simple"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+const (
+	// NextCronFuncName is the name this function is registered as.
+	NextCronFuncName = "next_cron"
+
+	// arg names...
+	cronArgNameExpr = "expr"
+)
+
+func init() {
+	funcs.ModuleRegister(ModuleName, NextCronFuncName, func() interfaces.Func { return &NextCronFunc{} }) // must register the func and name
+	simple.ModuleRegister(ModuleName, "next_cron_after", &types.FuncValue{
+		T: types.NewType("func(expr str, a int) int"),
+		V: NextCronAfter,
+	})
+}
+
+// NextCronAfter returns the unix timestamp of the first minute after the given
+// one which matches the cron expression. See next_cron for the format.
+func NextCronAfter(input []types.Value) (types.Value, error) {
+	sched, err := parseCron(input[0].Str())
+	if err != nil {
+		return nil, err
+	}
+	return nextCron(sched, time.Unix(input[1].Int(), 0))
+}
+
+// nextCron returns the next time that matches as an int value.
+func nextCron(sched *cronSchedule, t time.Time) (types.Value, error) {
+	next := sched.Next(t)
+	if next.IsZero() {
+		return nil, fmt.Errorf("cron expression doesn't match in the next %d years", cronSearchYears)
+	}
+	return &types.IntValue{
+		V: next.Unix(),
+	}, nil
+}
+
+// NextCronFunc returns the unix timestamp of the next time that a cron
+// expression matches. It changes each time that it passes. The expression has
+// the five fields of minute, hour, day of month, month and day of week, as in
+// `30 2 * * mon-fri`, or it is a macro such as `@daily`. It uses the local time
+// zone unless it starts with one, such as `TZ=Europe/Paris 0 9 * * *`.
+type NextCronFunc struct {
+	init *interfaces.Init
+}
+
+// String returns a simple name for this function. This is needed so this struct
+// can satisfy the pgraph.Vertex interface.
+func (obj *NextCronFunc) String() string {
+	return NextCronFuncName
+}
+
+// ArgGen returns the Nth arg name for this function.
+func (obj *NextCronFunc) ArgGen(index int) (string, error) {
+	seq := []string{cronArgNameExpr}
+	if l := len(seq); index >= l {
+		return "", fmt.Errorf("index %d exceeds arg length of %d", index, l)
+	}
+	return seq[index], nil
+}
+
+// Validate makes sure we've built our struct properly. It is usually unused for
+// normal functions that users can use directly.
+func (obj *NextCronFunc) Validate() error {
+	return nil
+}
+
+// Info returns some static info about itself.
+func (obj *NextCronFunc) Info() *interfaces.Info {
+	return &interfaces.Info{
+		Pure: false, // it changes over time
+		Memo: false,
+		Sig:  types.NewType(fmt.Sprintf("func(%s str) int", cronArgNameExpr)),
+	}
+}
+
+// Init runs some startup code for this function.
+func (obj *NextCronFunc) Init(init *interfaces.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the changing values that this func has over time.
+func (obj *NextCronFunc) Stream(ctx context.Context) error {
+	return minuteStream(ctx, obj.init, cronArgNameExpr, nextCron)
+}
+
+// minuteStream parses the cron expression in the input, and runs the function
+// with it at the start of every minute. It sends the result whenever it changes.
+// This is simpler than working out when the result will change next, and it
+// copes with the clock jumping, such as after the host was suspended.
+func minuteStream(ctx context.Context, init *interfaces.Init, argName string, fn func(*cronSchedule, time.Time) (types.Value, error)) error {
+	defer close(init.Output) // the sender closes
+
+	timer := time.NewTimer(0)
+	<-timer.C // start it drained, we run on the first input
+	defer timer.Stop()
+
+	input := init.Input
+	var sched *cronSchedule
+	var last types.Value
+	for {
+		select {
+		case in, ok := <-input:
+			if !ok {
+				input = nil // the time keeps changing, so don't exit
+				if sched == nil {
+					return nil // we never got an input
+				}
+				continue
+			}
+			s, err := parseCron(in.Struct()[argName].Str())
+			if err != nil {
+				return err
+			}
+			sched = s
+
+		case <-timer.C:
+
+		case <-ctx.Done():
+			return nil
+		}
+
+		now := time.Now()
+		if !timer.Stop() {
+			select { // drain it if it fired
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+
+		result, err := fn(sched, now)
+		if err != nil {
+			return err
+		}
+		if last != nil && result.Cmp(last) == nil {
+			continue // value didn't change, skip it
+		}
+		last = result
+
+		select {
+		case init.Output <- result:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
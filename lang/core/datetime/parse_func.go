@@ -0,0 +1,94 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coredatetime
+
+import (
+	"time"
+
+	"github.com/purpleidea/mgmt/lang/funcs/simple"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+func init() {
+	simple.ModuleRegister(ModuleName, "parse", &types.FuncValue{
+		T: types.NewType("func(layout str, value str) int"),
+		V: Parse,
+	})
+	simple.ModuleRegister(ModuleName, "parse_in", &types.FuncValue{
+		T: types.NewType("func(layout str, value str, zone str) int"),
+		V: ParseIn,
+	})
+	simple.ModuleRegister(ModuleName, "format_in", &types.FuncValue{
+		T: types.NewType("func(a int, layout str, zone str) str"),
+		V: FormatIn,
+	})
+}
+
+// Parse parses a time with the golang layout, such as `2006-01-02 15:04`, and
+// returns it as a unix timestamp. If the value doesn't contain a time zone,
+// then it is in UTC.
+func Parse(input []types.Value) (types.Value, error) {
+	t, err := time.Parse(input[0].Str(), input[1].Str())
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "could not parse time")
+	}
+	return &types.IntValue{
+		V: t.Unix(),
+	}, nil
+}
+
+// ParseIn is like Parse, except that a value without a time zone is in the
+// given zone, such as `Europe/Paris`. The empty zone is the local one.
+func ParseIn(input []types.Value) (types.Value, error) {
+	loc, err := loadLocation(input[2].Str())
+	if err != nil {
+		return nil, err
+	}
+	t, err := time.ParseInLocation(input[0].Str(), input[1].Str(), loc)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "could not parse time")
+	}
+	return &types.IntValue{
+		V: t.Unix(),
+	}, nil
+}
+
+// FormatIn is like format, except that the unix timestamp is shown in the given
+// time zone instead of the local one.
+func FormatIn(input []types.Value) (types.Value, error) {
+	loc, err := loadLocation(input[2].Str())
+	if err != nil {
+		return nil, err
+	}
+	return &types.StrValue{
+		V: time.Unix(input[0].Int(), 0).In(loc).Format(input[1].Str()),
+	}, nil
+}
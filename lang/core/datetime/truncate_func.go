@@ -0,0 +1,84 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coredatetime
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/purpleidea/mgmt/lang/funcs/simple"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func init() {
+	simple.ModuleRegister(ModuleName, "truncate", &types.FuncValue{
+		T: types.NewType("func(a int, unit str, zone str) int"),
+		V: Truncate,
+	})
+}
+
+// Truncate rounds a unix timestamp down to the start of the unit that it is in.
+// The unit is one of `minute`, `hour`, `day`, `week`, `month` or `year`, and it
+// uses the calendar of the given time zone, so that a day starts at midnight
+// even when the clocks change, and an hour which is repeated when they go back
+// stays the one that the timestamp is in. The empty zone is the local one. Weeks
+// start on monday.
+func Truncate(input []types.Value) (types.Value, error) {
+	loc, err := loadLocation(input[2].Str())
+	if err != nil {
+		return nil, err
+	}
+	t := time.Unix(input[0].Int(), 0).In(loc)
+	y, m, d := t.Date()
+
+	switch unit := input[1].Str(); unit {
+	// These subtract from the time, since when the clocks go back, the
+	// same wall clock hour happens twice, and we must stay in the one that
+	// we're in.
+	case "minute":
+		t = t.Add(-time.Duration(t.Second()) * time.Second)
+	case "hour":
+		t = t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second)
+	case "day":
+		t = time.Date(y, m, d, 0, 0, 0, 0, loc)
+	case "week":
+		days := (int(t.Weekday()) + 6) % 7 // days since monday
+		t = time.Date(y, m, d-days, 0, 0, 0, 0, loc)
+	case "month":
+		t = time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	case "year":
+		t = time.Date(y, time.January, 1, 0, 0, 0, 0, loc)
+	default:
+		return nil, fmt.Errorf("invalid unit: %s", unit)
+	}
+	return &types.IntValue{
+		V: t.Unix(),
+	}, nil
+}
@@ -0,0 +1,151 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package coredatetime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func TestZoneFuncs0(t *testing.T) {
+	if _, err := time.LoadLocation("America/New_York"); err != nil {
+		t.Skipf("no time zone data: %+v", err)
+	}
+	str := func(s string) types.Value { return &types.StrValue{V: s} }
+	num := func(i int64) types.Value { return &types.IntValue{V: i} }
+	zone := str("America/New_York")
+
+	// noon on the day before the clocks go forward
+	val, err := ParseIn([]types.Value{str("2006-01-02 15:04"), str("2024-03-09 12:00"), zone})
+	if err != nil {
+		t.Fatalf("could not parse: %+v", err)
+	}
+	noon := val.Int()
+	if exp := time.Date(2024, 3, 9, 17, 0, 0, 0, time.UTC).Unix(); noon != exp {
+		t.Errorf("expected %d, got %d", exp, noon)
+	}
+
+	// a calendar day later is only 23 hours later
+	val, err = AddDate([]types.Value{num(noon), num(0), num(0), num(1), zone})
+	if err != nil {
+		t.Fatalf("could not add date: %+v", err)
+	}
+	if d := val.Int() - noon; d != 23*3600 {
+		t.Errorf("expected 23h, got %ds", d)
+	}
+	val, err = Add([]types.Value{num(noon), str("24h")})
+	if err != nil {
+		t.Fatalf("could not add: %+v", err)
+	}
+	if d := val.Int() - noon; d != 24*3600 {
+		t.Errorf("expected 24h, got %ds", d)
+	}
+	val, err = Sub([]types.Value{num(noon), str("1h30m")})
+	if err != nil {
+		t.Fatalf("could not sub: %+v", err)
+	}
+	if d := noon - val.Int(); d != 5400 {
+		t.Errorf("expected 1h30m, got %ds", d)
+	}
+
+	// the day after the change starts at local midnight
+	after := noon + 13*3600 // 01:00 on the day of the change
+	val, err = Truncate([]types.Value{num(after), str("day"), zone})
+	if err != nil {
+		t.Fatalf("could not truncate: %+v", err)
+	}
+	if exp := time.Date(2024, 3, 10, 5, 0, 0, 0, time.UTC).Unix(); val.Int() != exp {
+		t.Errorf("expected %d, got %d", exp, val.Int())
+	}
+	val, err = Truncate([]types.Value{num(after), str("week"), zone})
+	if err != nil {
+		t.Fatalf("could not truncate: %+v", err)
+	}
+	if exp := time.Date(2024, 3, 4, 5, 0, 0, 0, time.UTC).Unix(); val.Int() != exp { // monday
+		t.Errorf("expected %d, got %d", exp, val.Int())
+	}
+	if _, err := Truncate([]types.Value{num(after), str("fortnight"), zone}); err == nil {
+		t.Errorf("expected an error for an invalid unit")
+	}
+
+	// when the clocks go back, 01:30 happens twice, and each one stays
+	// in its own hour
+	for _, utc := range []int{5, 6} { // 01:30 EDT and then 01:30 EST
+		repeated := time.Date(2024, 11, 3, utc, 30, 0, 0, time.UTC).Unix()
+		val, err = Truncate([]types.Value{num(repeated), str("hour"), zone})
+		if err != nil {
+			t.Fatalf("could not truncate: %+v", err)
+		}
+		if exp := repeated - 1800; val.Int() != exp {
+			t.Errorf("expected %d, got %d", exp, val.Int())
+		}
+		val, err = Truncate([]types.Value{num(repeated + 59), str("minute"), zone})
+		if err != nil {
+			t.Fatalf("could not truncate: %+v", err)
+		}
+		if val.Int() != repeated {
+			t.Errorf("expected %d, got %d", repeated, val.Int())
+		}
+	}
+
+	val, err = InZone([]types.Value{num(after), zone})
+	if err != nil {
+		t.Fatalf("could not convert: %+v", err)
+	}
+	st := val.Struct()
+	if st["hour"].Int() != 1 || st["day"].Int() != 10 || st["zone"].Str() != "EST" || st["offset"].Int() != -5*3600 || st["weekday"].Str() != "sunday" {
+		t.Errorf("unexpected result: %s", val)
+	}
+
+	// this wall clock time is skipped, so it's moved forward
+	val, err = Unix([]types.Value{num(2024), num(3), num(10), num(2), num(30), num(0), zone})
+	if err != nil {
+		t.Fatalf("could not convert: %+v", err)
+	}
+	if exp := time.Date(2024, 3, 10, 7, 30, 0, 0, time.UTC).Unix(); val.Int() != exp {
+		t.Errorf("expected %d, got %d", exp, val.Int())
+	}
+
+	val, err = FormatIn([]types.Value{num(noon), str("15:04 MST"), str("UTC")})
+	if err != nil {
+		t.Fatalf("could not format: %+v", err)
+	}
+	if val.Str() != "17:00 UTC" {
+		t.Errorf("unexpected result: %s", val.Str())
+	}
+
+	if _, err := Parse([]types.Value{str("2006-01-02"), str("not a date")}); err == nil {
+		t.Errorf("expected a parse error")
+	}
+}
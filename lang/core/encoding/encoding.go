@@ -0,0 +1,382 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+// Package coreencoding contains functions for encoding and decoding values in
+// some common structured data formats.
+package coreencoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/funcs/templatepoly"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// ModuleName is the prefix given to all the functions in this module.
+	ModuleName = "encoding"
+
+	// marshalSig is the signature of all the marshal functions.
+	marshalSig = "func(value ?T) str"
+
+	// unmarshalSig is the signature of all the unmarshal functions. The
+	// hint arg is the type to decode into, such as `map{str: int}`.
+	unmarshalSig = "func(hint str, data str) ?T"
+
+	// arg names...
+	argNameHint = "hint"
+)
+
+// registerMarshal registers a function which encodes a value of any type that
+// passes the check into a string.
+func registerMarshal(name string, check func(*types.Type) error, fn func(types.Value) (string, error)) {
+	templatepoly.ModuleRegisterCheck(ModuleName, name, marshalSig, func(vars map[string]*types.Type) error {
+		return check(vars["?T"])
+	}, func(typ *types.Type, args []types.Value) (types.Value, error) {
+		s, err := fn(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return &types.StrValue{V: s}, nil
+	})
+}
+
+// registerUnmarshal registers a function which decodes a string into a value of
+// the type that the hint arg names. That type must pass the check.
+func registerUnmarshal(name string, check func(*types.Type) error, fn func(*types.Type, string) (types.Value, error)) {
+	name = ModuleName + funcs.ModuleSep + name
+	templatepoly.NewTemplate(unmarshalSig) // panic early if it's invalid
+	funcs.Register(name, func() interfaces.Func {
+		tmpl := templatepoly.NewTemplate(unmarshalSig)
+		tmpl.Check = func(vars map[string]*types.Type) error {
+			return check(vars["?T"])
+		}
+		tmpl.Hints = map[string]string{argNameHint: "?T"}
+		return &templatepoly.WrappedFunc{
+			Template: tmpl,
+			Name:     name,
+			Fn: func(typ *types.Type, args []types.Value) (types.Value, error) {
+				return fn(typ.Out, args[1].Str())
+			},
+		}
+	})
+}
+
+// encodable checks that values of this type can be encoded. Functions and
+// variants can't be, and if strKeys is true, then the keys of any maps must be
+// strings, otherwise they can be any of the basic kinds.
+func encodable(typ *types.Type, strKeys bool) error {
+	if typ == nil {
+		return fmt.Errorf("type is not known")
+	}
+	switch typ.Kind {
+	case types.KindBool, types.KindStr, types.KindInt, types.KindFloat:
+		return nil
+	case types.KindList:
+		return encodable(typ.Val, strKeys)
+	case types.KindMap:
+		if strKeys && typ.Key.Kind != types.KindStr {
+			return fmt.Errorf("map keys must be str, got %s", typ.Key)
+		}
+		if !isBasic(typ.Key) {
+			return fmt.Errorf("map keys must be a basic kind, got %s", typ.Key)
+		}
+		return encodable(typ.Val, strKeys)
+	case types.KindStruct:
+		for _, k := range typ.Ord {
+			if err := encodable(typ.Map[k], strKeys); err != nil {
+				return errwrap.Wrapf(err, "field `%s` can't be encoded", k)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("can't encode values of kind %s", typ.Kind)
+}
+
+// isTable returns true if values of this type can be encoded as a table, which
+// is a struct, or a map with string keys.
+func isTable(typ *types.Type) bool {
+	return typ.Kind == types.KindStruct || typ.Kind == types.KindMap && typ.Key.Kind == types.KindStr
+}
+
+// isBasic returns true if this type is a bool, str, int or float.
+func isBasic(typ *types.Type) bool {
+	switch typ.Kind {
+	case types.KindBool, types.KindStr, types.KindInt, types.KindFloat:
+		return true
+	}
+	return false
+}
+
+// fields returns the keys and values of a struct in field order, or of a map
+// with string keys in sorted order.
+func fields(v types.Value) ([]string, []types.Value) {
+	keys := []string{}
+	values := []types.Value{}
+	if st, ok := v.(*types.StructValue); ok {
+		for _, k := range st.T.Ord {
+			keys = append(keys, k)
+			values = append(values, st.V[k])
+		}
+		return keys, values
+	}
+	m := make(map[string]types.Value)
+	for k, x := range v.Map() {
+		m[k.Str()] = x
+		keys = append(keys, k.Str())
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		values = append(values, m[k])
+	}
+	return keys, values
+}
+
+// native converts a value into the plain golang form that the encoders use. If
+// ordered is true, then structs become a yaml.MapSlice so that the fields stay
+// in order, and maps can have keys which aren't strings.
+func native(v types.Value, ordered bool) (interface{}, error) {
+	switch x := v.(type) {
+	case *types.BoolValue:
+		return x.V, nil
+	case *types.StrValue:
+		return x.V, nil
+	case *types.IntValue:
+		return x.V, nil
+	case *types.FloatValue:
+		return x.V, nil
+
+	case *types.ListValue:
+		l := []interface{}{}
+		for _, e := range x.V {
+			n, err := native(e, ordered)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, n)
+		}
+		return l, nil
+
+	case *types.MapValue:
+		if ordered {
+			m := make(map[interface{}]interface{})
+			for k, e := range x.V {
+				n, err := native(e, ordered)
+				if err != nil {
+					return nil, err
+				}
+				m[k.Value()] = n
+			}
+			return m, nil
+		}
+		if k := x.T.Key.Kind; k != types.KindStr {
+			return nil, fmt.Errorf("map keys must be str, got %s", k)
+		}
+		m := make(map[string]interface{})
+		for k, e := range x.V {
+			n, err := native(e, ordered)
+			if err != nil {
+				return nil, err
+			}
+			m[k.Str()] = n
+		}
+		return m, nil
+
+	case *types.StructValue:
+		keys, values := fields(x)
+		if ordered {
+			m := yaml.MapSlice{}
+			for i, k := range keys {
+				n, err := native(values[i], ordered)
+				if err != nil {
+					return nil, err
+				}
+				m = append(m, yaml.MapItem{Key: k, Value: n})
+			}
+			return m, nil
+		}
+		m := make(map[string]interface{})
+		for i, k := range keys {
+			n, err := native(values[i], ordered)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = n
+		}
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("can't encode values of type %s", v.Type())
+}
+
+// decode converts a value from the form that the json, yaml and toml decoders
+// produce into a value of the given type. Struct fields which are missing get
+// their zero value, and any extra ones are ignored.
+func decode(x interface{}, typ *types.Type) (types.Value, error) {
+	switch typ.Kind {
+	case types.KindBool:
+		if b, ok := x.(bool); ok {
+			return &types.BoolValue{V: b}, nil
+		}
+
+	case types.KindStr:
+		switch s := x.(type) {
+		case string:
+			return &types.StrValue{V: s}, nil
+		case time.Time: // toml has dates
+			return &types.StrValue{V: s.Format(time.RFC3339Nano)}, nil
+		}
+
+	case types.KindInt:
+		switch i := x.(type) {
+		case int:
+			return &types.IntValue{V: int64(i)}, nil
+		case int64:
+			return &types.IntValue{V: i}, nil
+		case uint64:
+			if i > math.MaxInt64 {
+				return nil, fmt.Errorf("int %d is too big", i)
+			}
+			return &types.IntValue{V: int64(i)}, nil
+		case json.Number:
+			n, err := i.Int64()
+			if err != nil {
+				return nil, errwrap.Wrapf(err, "can't decode %s as int", i)
+			}
+			return &types.IntValue{V: n}, nil
+		}
+
+	case types.KindFloat:
+		switch f := x.(type) {
+		case float64:
+			return &types.FloatValue{V: f}, nil
+		case int:
+			return &types.FloatValue{V: float64(f)}, nil
+		case int64:
+			return &types.FloatValue{V: float64(f)}, nil
+		case uint64:
+			return &types.FloatValue{V: float64(f)}, nil
+		case json.Number:
+			n, err := f.Float64()
+			if err != nil {
+				return nil, errwrap.Wrapf(err, "can't decode %s as float", f)
+			}
+			return &types.FloatValue{V: n}, nil
+		}
+
+	case types.KindList:
+		rv := reflect.ValueOf(x)
+		if x == nil || rv.Kind() != reflect.Slice {
+			break
+		}
+		l := types.NewList(typ)
+		for i := 0; i < rv.Len(); i++ {
+			v, err := decode(rv.Index(i).Interface(), typ.Val)
+			if err != nil {
+				return nil, errwrap.Wrapf(err, "index %d is invalid", i)
+			}
+			if err := l.Add(v); err != nil {
+				return nil, err
+			}
+		}
+		return l, nil
+
+	case types.KindMap:
+		pairs, ok := mapping(x)
+		if !ok {
+			break
+		}
+		m := types.NewMap(typ)
+		for k, e := range pairs {
+			key, err := decode(k, typ.Key)
+			if err != nil {
+				return nil, errwrap.Wrapf(err, "key %v is invalid", k)
+			}
+			v, err := decode(e, typ.Val)
+			if err != nil {
+				return nil, errwrap.Wrapf(err, "key %v is invalid", k)
+			}
+			if err := m.Add(key, v); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+
+	case types.KindStruct:
+		pairs, ok := mapping(x)
+		if !ok {
+			break
+		}
+		st := types.NewStruct(typ)
+		for _, k := range typ.Ord {
+			e, exists := pairs[k]
+			if !exists {
+				continue // keep the zero value
+			}
+			v, err := decode(e, typ.Map[k])
+			if err != nil {
+				return nil, errwrap.Wrapf(err, "field `%s` is invalid", k)
+			}
+			if err := st.Set(k, v); err != nil {
+				return nil, err
+			}
+		}
+		return st, nil
+
+	default:
+		return nil, fmt.Errorf("can't decode values of kind %s", typ.Kind)
+	}
+
+	return nil, fmt.Errorf("can't decode %T as %s", x, typ)
+}
+
+// mapping returns the pairs in any of the map forms that the decoders produce.
+func mapping(x interface{}) (map[interface{}]interface{}, bool) {
+	switch m := x.(type) {
+	case map[interface{}]interface{}:
+		return m, true
+	case map[string]interface{}:
+		pairs := make(map[interface{}]interface{})
+		for k, v := range m {
+			pairs[k] = v
+		}
+		return pairs, true
+	}
+	return nil, false
+}
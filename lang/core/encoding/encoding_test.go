@@ -0,0 +1,164 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package coreencoding
+
+import (
+	"testing"
+
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+const testType = "struct{name str; port int; ratio float; enabled bool; tags []str; env map{str: str}}"
+
+const testJSON = `{"enabled":true,"env":{"A":"1","B":"2"},"name":"web","port":8080,"ratio":0.5,"tags":["x","y"]}`
+
+func TestJSON0(t *testing.T) {
+	typ := types.NewType(testType)
+	v, err := JSONUnmarshal(typ, testJSON)
+	if err != nil {
+		t.Errorf("unmarshal failed with: %+v", err)
+		return
+	}
+	s, err := JSONMarshal(v)
+	if err != nil {
+		t.Errorf("marshal failed with: %+v", err)
+		return
+	}
+	if s != testJSON {
+		t.Errorf("unexpected json: %s", s)
+	}
+}
+
+func TestRoundTrip0(t *testing.T) {
+	typ := types.NewType(testType)
+	v, err := JSONUnmarshal(typ, testJSON)
+	if err != nil {
+		t.Errorf("unmarshal failed with: %+v", err)
+		return
+	}
+
+	formats := map[string]struct {
+		marshal   func(types.Value) (string, error)
+		unmarshal func(*types.Type, string) (types.Value, error)
+	}{
+		"json": {JSONMarshal, JSONUnmarshal},
+		"yaml": {YAMLMarshal, YAMLUnmarshal},
+		"toml": {TOMLMarshal, TOMLUnmarshal},
+	}
+	for name, f := range formats {
+		s, err := f.marshal(v)
+		if err != nil {
+			t.Errorf("%s: marshal failed with: %+v", name, err)
+			continue
+		}
+		out, err := f.unmarshal(typ, s)
+		if err != nil {
+			t.Errorf("%s: unmarshal failed with: %+v", name, err)
+			continue
+		}
+		// maps don't cmp by value, so compare what they look like
+		if err := out.Type().Cmp(typ); err != nil || out.String() != v.String() {
+			t.Errorf("%s: round trip changed the value to: %s", name, out)
+		}
+	}
+}
+
+func TestYAML0(t *testing.T) {
+	typ := types.NewType("struct{b int; a map{int: str}}")
+	v, err := YAMLUnmarshal(typ, "a:\n  2: two\n  1: one\nb: 3\n")
+	if err != nil {
+		t.Errorf("unmarshal failed with: %+v", err)
+		return
+	}
+	s, err := YAMLMarshal(v)
+	if err != nil {
+		t.Errorf("marshal failed with: %+v", err)
+		return
+	}
+	// struct fields keep their order
+	if exp := "b: 3\na:\n  1: one\n  2: two\n"; s != exp {
+		t.Errorf("unexpected yaml: %s", s)
+	}
+}
+
+func TestUnmarshalErrors0(t *testing.T) {
+	tests := map[string]string{
+		"[]int":         `["a"]`,
+		"int":           `1.5`,
+		"str":           `null`,
+		"map{str: int}": `[1, 2]`,
+		"bool":          `true false`,
+	}
+	for hint, data := range tests {
+		if v, err := JSONUnmarshal(types.NewType(hint), data); err == nil {
+			t.Errorf("expected error decoding %s as %s, got: %s", data, hint, v)
+		}
+	}
+
+	// missing fields get their zero value
+	v, err := JSONUnmarshal(types.NewType("struct{a int; b str}"), `{"a": 1, "c": 2}`)
+	if err != nil {
+		t.Errorf("unmarshal failed with: %+v", err)
+		return
+	}
+	if s := v.Struct()["b"].Str(); s != "" {
+		t.Errorf("unexpected field value: %s", s)
+	}
+}
+
+func TestINI0(t *testing.T) {
+	typ := types.NewType("struct{debug bool; server map{str: str}; client struct{retries int; ratio float}}")
+	if err := iniCheck(typ); err != nil {
+		t.Errorf("check failed with: %+v", err)
+		return
+	}
+	v, err := JSONUnmarshal(typ, `{"debug": true, "server": {"port": "80", "host": "a"}, "client": {"retries": 3, "ratio": 0.5}}`)
+	if err != nil {
+		t.Errorf("unmarshal failed with: %+v", err)
+		return
+	}
+	s, err := INIMarshal(v)
+	if err != nil {
+		t.Errorf("marshal failed with: %+v", err)
+		return
+	}
+	exp := "debug = true\n\n[server]\nhost = a\nport = 80\n\n[client]\nretries = 3\nratio = 0.5\n"
+	if s != exp {
+		t.Errorf("unexpected ini:\n%s", s)
+	}
+
+	for _, x := range []string{"[]str", "map{str: []str}", "struct{a struct{b struct{c int}}}"} {
+		if err := iniCheck(types.NewType(x)); err == nil {
+			t.Errorf("expected check of %s to fail", x)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreencoding
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func init() {
+	registerMarshal("ini_marshal", iniCheck, INIMarshal)
+}
+
+// iniCheck makes sure that values of this type can be used as an INI file. It
+// must be a table, whose entries are either basic values, or tables of basic
+// values which become the sections.
+func iniCheck(typ *types.Type) error {
+	if typ == nil {
+		return fmt.Errorf("type is not known")
+	}
+	if !isTable(typ) {
+		return fmt.Errorf("ini files must be a struct or a map with str keys, got %s", typ)
+	}
+	for _, t := range entries(typ) {
+		if isBasic(t) {
+			continue
+		}
+		if !isTable(t) {
+			return fmt.Errorf("ini entries must be a basic kind or a section, got %s", t)
+		}
+		for _, x := range entries(t) {
+			if !isBasic(x) {
+				return fmt.Errorf("ini section entries must be a basic kind, got %s", x)
+			}
+		}
+	}
+	return nil
+}
+
+// entries returns the types of the entries in a table.
+func entries(typ *types.Type) []*types.Type {
+	if typ.Kind == types.KindMap {
+		return []*types.Type{typ.Val}
+	}
+	l := []*types.Type{}
+	for _, k := range typ.Ord {
+		l = append(l, typ.Map[k])
+	}
+	return l
+}
+
+// INIMarshal returns the INI encoding of a value. The entries which hold basic
+// values come first, and then each of the entries which hold a table becomes a
+// section. Struct fields are kept in order, and map keys are sorted.
+func INIMarshal(v types.Value) (string, error) {
+	var b strings.Builder
+	keys, values := fields(v)
+	sections := []int{}
+	for i, k := range keys {
+		if !isBasic(values[i].Type()) {
+			sections = append(sections, i)
+			continue
+		}
+		if err := iniLine(&b, k, values[i]); err != nil {
+			return "", err
+		}
+	}
+	for _, i := range sections {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		if strings.ContainsAny(keys[i], "[]\n") {
+			return "", fmt.Errorf("invalid ini section name: %q", keys[i])
+		}
+		fmt.Fprintf(&b, "[%s]\n", keys[i])
+		ks, vs := fields(values[i])
+		for j, k := range ks {
+			if err := iniLine(&b, k, vs[j]); err != nil {
+				return "", err
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// iniLine writes a single `key = value` line.
+func iniLine(b *strings.Builder, key string, v types.Value) error {
+	if key == "" || strings.ContainsAny(key, "=[\n") {
+		return fmt.Errorf("invalid ini key: %q", key)
+	}
+	s := v.String()
+	if v.Type().Kind == types.KindStr {
+		s = v.Str() // not quoted
+	}
+	if strings.Contains(s, "\n") {
+		return fmt.Errorf("ini value for key `%s` can't contain a newline", key)
+	}
+	fmt.Fprintf(b, "%s = %s\n", key, s)
+	return nil
+}
@@ -0,0 +1,76 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreencoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+func init() {
+	registerMarshal("json_marshal", func(typ *types.Type) error {
+		return encodable(typ, true)
+	}, JSONMarshal)
+	registerUnmarshal("json_unmarshal", func(typ *types.Type) error {
+		return encodable(typ, true)
+	}, JSONUnmarshal)
+}
+
+// JSONMarshal returns the JSON encoding of a value. Map keys must be strings.
+func JSONMarshal(v types.Value) (string, error) {
+	n, err := native(v, false)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(n)
+	if err != nil {
+		return "", errwrap.Wrapf(err, "can't encode as json")
+	}
+	return string(b), nil
+}
+
+// JSONUnmarshal decodes a JSON document into a value of the given type.
+func JSONUnmarshal(typ *types.Type, data string) (types.Value, error) {
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber() // so that we can tell ints from floats
+	var x interface{}
+	if err := dec.Decode(&x); err != nil {
+		return nil, errwrap.Wrapf(err, "can't decode json")
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("can't decode json: unexpected data after value")
+	}
+	return decode(x, typ)
+}
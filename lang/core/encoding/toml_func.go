@@ -0,0 +1,81 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreencoding
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+
+	"github.com/BurntSushi/toml"
+)
+
+func init() {
+	registerMarshal("toml_marshal", tomlCheck, TOMLMarshal)
+	registerUnmarshal("toml_unmarshal", tomlCheck, TOMLUnmarshal)
+}
+
+// tomlCheck makes sure that values of this type can be used as a TOML
+// document, which must be a table at the top.
+func tomlCheck(typ *types.Type) error {
+	if err := encodable(typ, true); err != nil {
+		return err
+	}
+	if !isTable(typ) {
+		return fmt.Errorf("toml documents must be a struct or a map with str keys, got %s", typ)
+	}
+	return nil
+}
+
+// TOMLMarshal returns the TOML encoding of a value. It must be a struct or a
+// map with string keys.
+func TOMLMarshal(v types.Value) (string, error) {
+	n, err := native(v, false)
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(n); err != nil {
+		return "", errwrap.Wrapf(err, "can't encode as toml")
+	}
+	return buf.String(), nil
+}
+
+// TOMLUnmarshal decodes a TOML document into a value of the given type. Any
+// dates are decoded as strings in RFC 3339 format.
+func TOMLUnmarshal(typ *types.Type, data string) (types.Value, error) {
+	x := make(map[string]interface{})
+	if _, err := toml.Decode(data, &x); err != nil {
+		return nil, errwrap.Wrapf(err, "can't decode toml")
+	}
+	return decode(x, typ)
+}
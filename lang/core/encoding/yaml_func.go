@@ -0,0 +1,69 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreencoding
+
+import (
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+
+	"gopkg.in/yaml.v2"
+)
+
+func init() {
+	registerMarshal("yaml_marshal", func(typ *types.Type) error {
+		return encodable(typ, false)
+	}, YAMLMarshal)
+	registerUnmarshal("yaml_unmarshal", func(typ *types.Type) error {
+		return encodable(typ, false)
+	}, YAMLUnmarshal)
+}
+
+// YAMLMarshal returns the YAML encoding of a value. Struct fields are kept in
+// the order that they were declared in.
+func YAMLMarshal(v types.Value) (string, error) {
+	n, err := native(v, true)
+	if err != nil {
+		return "", err
+	}
+	b, err := yaml.Marshal(n)
+	if err != nil {
+		return "", errwrap.Wrapf(err, "can't encode as yaml")
+	}
+	return string(b), nil
+}
+
+// YAMLUnmarshal decodes a YAML document into a value of the given type.
+func YAMLUnmarshal(typ *types.Type, data string) (types.Value, error) {
+	var x interface{}
+	if err := yaml.Unmarshal([]byte(data), &x); err != nil {
+		return nil, errwrap.Wrapf(err, "can't decode yaml")
+	}
+	return decode(x, typ)
+}
@@ -134,7 +134,7 @@ func (obj *VUMeterFunc) Validate() error {
 // Info returns some static info about itself.
 func (obj *VUMeterFunc) Info() *interfaces.Info {
 	return &interfaces.Info{
-		Pure: true,
+		Pure: false, // it reads from the microphone
 		Memo: false,
 		Sig:  types.NewType(fmt.Sprintf("func(%s str, %s int, %s float) str", vuMeterArgNameSymbol, vuMeterArgNameMultiplier, vuMeterArgNamePeak)),
 	}
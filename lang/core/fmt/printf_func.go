@@ -32,6 +32,8 @@ package corefmt
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/purpleidea/mgmt/lang/funcs"
 	"github.com/purpleidea/mgmt/lang/interfaces"
@@ -75,7 +77,9 @@ var _ interfaces.PolyFunc = &PrintfFunc{} // ensure it meets this expectation
 // is that the format string must be a static string which is known at compile
 // time. This is reasonable, because if it was a reactive, changing string, then
 // we could expect the type signature to change, which is not allowed in our
-// statically typed language.
+// statically typed language. The %t, %s, %d and %f verbs print a bool, str, int
+// and float. Lists and maps use the same letters, such as %[]s for a list of
+// strings and %{s:d} for a map of ints, and %v prints a value of any type.
 type PrintfFunc struct {
 	Type *types.Type // final full type of our function
 
@@ -573,32 +577,48 @@ func (obj *PrintfFunc) Stream(ctx context.Context) error {
 	}
 }
 
-// valueToString prints our values how we expect for printf.
+// valueToString prints our values how we expect for printf. Lists, maps and
+// structs are printed in the same style as the golang %v verb, with the keys of
+// maps in sorted order, and with the names of struct fields.
 // FIXME: if this turns out to be useful, add it to the types package.
 func valueToString(value types.Value) string {
-	switch x := value.Type().Kind; x {
+	switch x := value.(type) {
+	case *types.StrValue:
+		return x.V // use this since otherwise it adds " & "
+
 	// FIXME: floats don't print nicely: https://github.com/golang/go/issues/46118
-	case types.KindFloat:
+	case *types.FloatValue:
 		// TODO: use formatting flags ?
-		// FIXME: Our String() method in FloatValue doesn't print nicely
-		return value.String()
-	}
-
-	// FIXME: this is just an "easy-out" implementation for now...
-	return fmt.Sprintf("%v", value.Value())
-
-	//switch x := value.Type().Kind; x {
-	//case types.KindBool:
-	//	return value.String()
-	//case types.KindStr:
-	//	return value.Str() // use this since otherwise it adds " & "
-	//case types.KindInt:
-	//	return value.String()
-	//case types.KindFloat:
-	//	// TODO: use formatting flags ?
-	//	return value.String()
-	//}
-	//panic("unhandled type") // TODO: not fully implemented yet
+		return x.String()
+
+	case *types.ListValue:
+		l := []string{}
+		for _, v := range x.V {
+			l = append(l, valueToString(v))
+		}
+		return "[" + strings.Join(l, " ") + "]"
+
+	case *types.MapValue:
+		keys := types.ValueSlice{}
+		for k := range x.V {
+			keys = append(keys, k)
+		}
+		sort.Sort(keys)
+		l := []string{}
+		for _, k := range keys {
+			l = append(l, valueToString(k)+":"+valueToString(x.V[k]))
+		}
+		return "map[" + strings.Join(l, " ") + "]"
+
+	case *types.StructValue:
+		l := []string{}
+		for _, k := range x.T.Ord {
+			l = append(l, k+":"+valueToString(x.V[k]))
+		}
+		return "{" + strings.Join(l, " ") + "}"
+	}
+
+	return value.String()
 }
 
 // parseFormatToTypeList takes a format string and returns a list of types that
@@ -628,33 +648,86 @@ func parseFormatToTypeList(format string) ([]*types.Type, error) {
 		}
 
 		// we must be in a type
-		switch format[i] {
-		case 't':
-			typList = append(typList, types.TypeBool)
-		case 's':
-			typList = append(typList, types.TypeStr)
-		case 'd':
-			typList = append(typList, types.TypeInt)
-
-		// TODO: parse fancy formats like %0.2f and stuff
-		case 'f':
-			typList = append(typList, types.TypeFloat)
-
-		// FIXME: add fancy types like: %[]s, %[]f, %{s:f}, etc...
-
-		// special!
-		case 'v':
-			typList = append(typList, types.TypeVariant)
-
-		default:
-			return nil, fmt.Errorf("invalid format string at %d", i)
+		typ, j, err := parseVerb(format, i)
+		if err != nil {
+			return nil, err
 		}
+		typList = append(typList, typ)
+		i = j
 		inType = false // done
 	}
 
 	return typList, nil
 }
 
+// parseVerb parses the part of a format specifier which comes after the % sign
+// and starts at index i. It returns the type that it expects, and the index of
+// the last character that it used. As well as the basic verbs, this can parse
+// lists such as %[]s and maps such as %{s:d}, which can also be nested.
+func parseVerb(format string, i int) (*types.Type, int, error) {
+	if i >= len(format) {
+		return nil, i, fmt.Errorf("format string ends early at %d", i)
+	}
+	switch format[i] {
+	case 't':
+		return types.TypeBool, i, nil
+	case 's':
+		return types.TypeStr, i, nil
+	case 'd':
+		return types.TypeInt, i, nil
+
+	// TODO: parse fancy formats like %0.2f and stuff
+	case 'f':
+		return types.TypeFloat, i, nil
+
+	case '[':
+		if i+1 >= len(format) || format[i+1] != ']' {
+			break
+		}
+		val, j, err := parseVerb(format, i+2)
+		if err != nil {
+			return nil, j, err
+		}
+		if val == types.TypeVariant {
+			return nil, j, fmt.Errorf("can't use %%v inside a list at %d", j)
+		}
+		return &types.Type{
+			Kind: types.KindList,
+			Val:  val,
+		}, j, nil
+
+	case '{':
+		key, j, err := parseVerb(format, i+1)
+		if err != nil {
+			return nil, j, err
+		}
+		if j+1 >= len(format) || format[j+1] != ':' {
+			return nil, j, fmt.Errorf("invalid format string at %d", j+1)
+		}
+		val, k, err := parseVerb(format, j+2)
+		if err != nil {
+			return nil, k, err
+		}
+		if k+1 >= len(format) || format[k+1] != '}' {
+			return nil, k, fmt.Errorf("invalid format string at %d", k+1)
+		}
+		if key == types.TypeVariant || val == types.TypeVariant {
+			return nil, k, fmt.Errorf("can't use %%v inside a map at %d", i)
+		}
+		return &types.Type{
+			Kind: types.KindMap,
+			Key:  key,
+			Val:  val,
+		}, k + 1, nil
+
+	// special!
+	case 'v':
+		return types.TypeVariant, i, nil
+	}
+
+	return nil, i, fmt.Errorf("invalid format string at %d", i)
+}
+
 // compileFormatToString takes a format string and a list of values and returns
 // the compiled/templated output. This can also handle the %v special variant
 // type in the format string. Of course the corresponding value to those %v
@@ -689,27 +762,11 @@ func compileFormatToString(format string, values []types.Value) (string, error)
 		}
 
 		// we must be in a type
-		var typ *types.Type
-		switch format[i] {
-		case 't':
-			typ = types.TypeBool
-		case 's':
-			typ = types.TypeStr
-		case 'd':
-			typ = types.TypeInt
-
-		// TODO: parse fancy formats like %0.2f and stuff
-		case 'f':
-			typ = types.TypeFloat
-
-		// FIXME: add fancy types like: %[]s, %[]f, %{s:f}, etc...
-
-		case 'v':
-			typ = types.TypeVariant
-
-		default:
-			return "", fmt.Errorf("invalid format string at %d", i)
+		typ, j, err := parseVerb(format, i)
+		if err != nil {
+			return "", err
 		}
+		i = j
 		inType = false // done
 
 		if ix >= len(values) {
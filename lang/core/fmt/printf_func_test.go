@@ -0,0 +1,114 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package corefmt
+
+import (
+	"testing"
+
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func TestParseFormatToTypeList0(t *testing.T) {
+	values := map[string][]string{
+		"hello":              {},
+		"%s is %d%%":         {"str", "int"},
+		"%v and %t":          {"variant", "bool"},
+		"%[]s":               {"[]str"},
+		"list: %[][]f.":      {"[][]float"},
+		"%{s:d} %{s:[]t}":    {"map{str: int}", "map{str: []bool}"},
+		"%{d:{s:f}} then %s": {"map{int: map{str: float}}", "str"},
+		"%[]s%[]d":           {"[]str", "[]int"},
+	}
+
+	for format, expected := range values {
+		typList, err := parseFormatToTypeList(format)
+		if err != nil {
+			t.Errorf("format %q failed with: %+v", format, err)
+			continue
+		}
+		if len(typList) != len(expected) {
+			t.Errorf("format %q expected %d types, got %d", format, len(expected), len(typList))
+			continue
+		}
+		for i, x := range expected {
+			if err := types.NewType(x).Cmp(typList[i]); err != nil {
+				t.Errorf("format %q expected %s, got %s", format, x, typList[i])
+			}
+		}
+	}
+
+	for _, format := range []string{"%x", "%[s", "%[]", "%{s}", "%{s:d", "%[]v", "%{s:v}"} {
+		if _, err := parseFormatToTypeList(format); err == nil {
+			t.Errorf("format %q expected an error", format)
+		}
+	}
+}
+
+func TestCompileFormatToString0(t *testing.T) {
+	list := types.NewList(types.NewType("[]str"))
+	list.Add(&types.StrValue{V: "a"})
+	list.Add(&types.StrValue{V: "b"})
+
+	m := types.NewMap(types.NewType("map{str: float}"))
+	m.Add(&types.StrValue{V: "y"}, &types.FloatValue{V: 2.5})
+	m.Add(&types.StrValue{V: "x"}, &types.FloatValue{V: 1})
+
+	st := types.NewStruct(types.NewType("struct{name str; tags []str}"))
+	st.Set("name", &types.StrValue{V: "web"})
+	st.Set("tags", list)
+
+	values := []struct {
+		format   string
+		values   []types.Value
+		expected string
+	}{
+		{"%s=%d", []types.Value{&types.StrValue{V: "a"}, &types.IntValue{V: 42}}, "a=42"},
+		{"list: %[]s", []types.Value{list}, "list: [a b]"},
+		{"map: %{s:f}", []types.Value{m}, "map: map[x:1 y:2.5]"},
+		{"%v", []types.Value{m}, "map[x:1 y:2.5]"},
+		{"%v!", []types.Value{st}, "{name:web tags:[a b]}!"},
+	}
+
+	for i, x := range values {
+		out, err := compileFormatToString(x.format, x.values)
+		if err != nil {
+			t.Errorf("test index %d failed with: %+v", i, err)
+			continue
+		}
+		if out != x.expected {
+			t.Errorf("test index %d expected %q, got %q", i, x.expected, out)
+		}
+	}
+
+	// the type must match the specifier
+	if _, err := compileFormatToString("%[]d", []types.Value{list}); err == nil {
+		t.Errorf("expected a mismatched type to error")
+	}
+}
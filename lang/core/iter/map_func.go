@@ -55,7 +55,8 @@ func init() {
 	funcs.ModuleRegister(ModuleName, MapFuncName, func() interfaces.Func { return &MapFunc{} }) // must register the func and name
 }
 
-var _ interfaces.PolyFunc = &MapFunc{} // ensure it meets this expectation
+var _ interfaces.PolyFunc = &MapFunc{}         // ensure it meets this expectation
+var _ interfaces.UnreplayableFunc = &MapFunc{} // ensure it meets this expectation
 
 // MapFunc is the standard map iterator function that applies a function to each
 // element in a list. It returns a list with the same number of elements as the
@@ -557,6 +558,10 @@ func (obj *MapFunc) Info() *interfaces.Info {
 	}
 }
 
+// Unreplayable tells the engine not to record or replay this function, because
+// it builds a subgraph with the Txn API.
+func (obj *MapFunc) Unreplayable() {}
+
 // helper
 func (obj *MapFunc) sig() *types.Type {
 	// TODO: what do we put if this is unknown?
@@ -0,0 +1,81 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package corelist
+
+import (
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types/full"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// FoldFuncName is the name this function is registered as.
+	FoldFuncName = "fold"
+
+	// arg names...
+	foldArgNameInitial = "initial"
+)
+
+func init() {
+	funcs.ModuleRegister(ModuleName, FoldFuncName, func() interfaces.Func {
+		obj := &FoldFunc{}
+		obj.iterFunc = newIterFunc(FoldFuncName, "func(inputs []?T, initial ?A, function func(?A, ?T) ?A) ?A", obj, obj.replace)
+		return obj
+	}) // must register the func and name
+}
+
+var _ interfaces.PolyFunc = &FoldFunc{}         // ensure it meets this expectation
+var _ interfaces.UnreplayableFunc = &FoldFunc{} // ensure it meets this expectation
+
+// FoldFunc combines the elements of a list into a single value. The function is
+// called with the initial value and the first element, then with that result
+// and the second element, and so on. The last result is returned, or the
+// initial value if the list is empty. This implements the signature:
+// `func(inputs []T, initial A, function func(A, T) A) A` and the function can
+// be an mcl lambda, in the same way as with iter.map.
+type FoldFunc struct {
+	*iterFunc // provides everything except for the subgraph
+}
+
+// replace builds a subgraph which chains the calls of the function, with each
+// one getting the output of the previous call and the next element.
+func (obj *FoldFunc) replace(args interfaces.Func, fn *full.FuncValue, n int) (interfaces.Func, error) {
+	acc := obj.iter.field(args, foldArgNameInitial)
+	for i := 0; i < n; i++ {
+		elem := obj.iter.elem(args, i)
+		call, err := fn.Call(obj.iter.init.Txn, []interfaces.Func{acc, elem})
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "could not call the function")
+		}
+		acc = call
+	}
+	return acc, nil
+}
@@ -0,0 +1,394 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package corelist
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/funcs/structs"
+	"github.com/purpleidea/mgmt/lang/funcs/templatepoly"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/lang/types/full"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// arg names which are shared by the functions that use the iterator...
+	argNameInputs   = "inputs"
+	argNameFunction = "function"
+
+	// argNameArgs is the name of the edge into the nodes which get the
+	// struct of args from the subgraph input.
+	argNameArgs = "args"
+)
+
+// iterFunc is the shared part of the list functions which use an iterator. It
+// provides everything that the function API needs, so each of those functions
+// only has to say how to build its subgraph.
+type iterFunc struct {
+	*templatepoly.Template // provides ArgGen, Unify, Build and Validate
+
+	name    string
+	self    interfaces.Func // the function which embeds this one
+	replace func(args interfaces.Func, fn *full.FuncValue, n int) (interfaces.Func, error)
+
+	iter *iterator
+}
+
+// newIterFunc returns the shared part of a list function. The self arg is the
+// function that embeds it, and the replace arg builds its subgraph, as with the
+// Replace field of the iterator.
+func newIterFunc(name, sig string, self interfaces.Func, replace func(args interfaces.Func, fn *full.FuncValue, n int) (interfaces.Func, error)) *iterFunc {
+	return &iterFunc{
+		Template: templatepoly.NewTemplate(sig),
+		name:     name,
+		self:     self,
+		replace:  replace,
+	}
+}
+
+// String returns a simple name for this function. This is needed so this struct
+// can satisfy the pgraph.Vertex interface.
+func (obj *iterFunc) String() string {
+	return obj.name
+}
+
+// Info returns some static info about itself. Build must be called before this
+// will return correct data.
+func (obj *iterFunc) Info() *interfaces.Info {
+	return &interfaces.Info{
+		Pure: false, // it changes the function graph with the Txn API
+		Memo: false,
+		Sig:  obj.Type(),
+		Err:  obj.Validate(),
+	}
+}
+
+// Unreplayable tells the engine not to record or replay this function, because
+// it builds a subgraph with the Txn API.
+func (obj *iterFunc) Unreplayable() {}
+
+// Init runs some startup code for this function.
+func (obj *iterFunc) Init(init *interfaces.Init) error {
+	obj.iter = &iterator{
+		Name:    ModuleName + funcs.ModuleSep + obj.name,
+		Source:  obj.self,
+		Type:    obj.Type(),
+		Replace: obj.replace,
+	}
+	return obj.iter.Init(init)
+}
+
+// Stream returns the changing values that this func has over time.
+func (obj *iterFunc) Stream(ctx context.Context) error {
+	return obj.iter.Stream(ctx)
+}
+
+// iterator is the shared part of the list functions which call a function value
+// on the elements of a list, the same way that iter.map does. All of the args
+// except the function are sent into a subgraph as a struct. The subgraph is
+// rebuilt each time that the function value or the length of the list changes.
+type iterator struct {
+	// Name is used as the prefix for the names of the subgraph nodes.
+	Name string
+
+	// Source is the function which owns this iterator.
+	Source interfaces.Func
+
+	// Type is the built signature of the function which owns this.
+	Type *types.Type
+
+	// Replace adds a new subgraph for this function value and list length.
+	// It gets the node which outputs the struct of args, and it must return
+	// the node whose output is the result.
+	Replace func(args interfaces.Func, fn *full.FuncValue, n int) (interfaces.Func, error)
+
+	init     *interfaces.Init
+	last     types.Value // last value received to use for diff
+	argsType *types.Type // all the args except the function
+
+	lastFuncValue       *full.FuncValue // remember the last function value
+	lastInputListLength int             // remember the last input list length
+
+	// outputChan is an initially-nil channel from which we receive the
+	// results from the subgraph. This channel is reset when the subgraph is
+	// recreated.
+	outputChan chan types.Value
+}
+
+// Init runs some startup code for the iterator.
+func (obj *iterator) Init(init *interfaces.Init) error {
+	obj.init = init
+	obj.lastFuncValue = nil
+	obj.lastInputListLength = -1
+
+	m := make(map[string]*types.Type)
+	ord := []string{}
+	for _, k := range obj.Type.Ord {
+		if k == argNameFunction {
+			continue
+		}
+		m[k] = obj.Type.Map[k]
+		ord = append(ord, k)
+	}
+	obj.argsType = &types.Type{
+		Kind: types.KindStruct,
+		Map:  m,
+		Ord:  ord,
+	}
+	return nil
+}
+
+// Stream returns the changing values that the function has over time.
+func (obj *iterator) Stream(ctx context.Context) error {
+	defer close(obj.init.Output) // the sender closes
+
+	// A Func to send the args to the subgraph. The Txn.Erase() call ensures
+	// that this Func is not removed when the subgraph is recreated, so that
+	// the function graph can propagate the last args we received to it.
+	inputChan := make(chan types.Value)
+	subgraphInput := &structs.ChannelBasedSourceFunc{
+		Name:   "subgraphInput",
+		Source: obj.Source,
+		Chan:   inputChan,
+		Type:   obj.argsType,
+	}
+	obj.init.Txn.AddVertex(subgraphInput)
+	if err := obj.init.Txn.Commit(); err != nil {
+		return errwrap.Wrapf(err, "commit error in Stream")
+	}
+	obj.init.Txn.Erase() // prevent the next Reverse() from removing subgraphInput
+	defer func() {
+		close(inputChan)
+		obj.init.Txn.Reverse()
+		obj.init.Txn.DeleteVertex(subgraphInput)
+		obj.init.Txn.Commit()
+	}()
+
+	obj.outputChan = nil
+
+	canReceiveMoreFuncValuesOrInputs := true
+	canReceiveMoreOutputs := true
+	for {
+
+		if !canReceiveMoreFuncValuesOrInputs && !canReceiveMoreOutputs {
+			return nil
+		}
+
+		select {
+		case input, ok := <-obj.init.Input:
+			if !ok {
+				obj.init.Input = nil // block looping back here
+				canReceiveMoreFuncValuesOrInputs = false
+				continue
+			}
+
+			if obj.last != nil && input.Cmp(obj.last) == nil {
+				continue // value didn't change, skip it
+			}
+			obj.last = input // store for next
+
+			value, exists := input.Struct()[argNameFunction]
+			if !exists {
+				return fmt.Errorf("programming error, can't find edge")
+			}
+			newFuncValue, ok := value.(*full.FuncValue)
+			if !ok {
+				return fmt.Errorf("programming error, can't convert to *FuncValue")
+			}
+
+			args := types.NewStruct(obj.argsType)
+			for _, k := range obj.argsType.Ord {
+				v, exists := input.Struct()[k]
+				if !exists {
+					return fmt.Errorf("programming error, can't find edge")
+				}
+				if err := args.Set(k, v); err != nil {
+					return errwrap.Wrapf(err, "programming error, can't set arg")
+				}
+			}
+
+			// It's important to only replace the subgraph when it
+			// is needed, for the same reasons as in iter.map.
+			n := len(input.Struct()[argNameInputs].List())
+			if newFuncValue != obj.lastFuncValue || n != obj.lastInputListLength {
+				obj.lastFuncValue = newFuncValue
+				obj.lastInputListLength = n
+				if err := obj.replaceSubGraph(subgraphInput); err != nil {
+					return errwrap.Wrapf(err, "could not replace subgraph")
+				}
+				canReceiveMoreOutputs = true
+			}
+
+			// send the new args to the subgraph
+			select {
+			case inputChan <- args:
+			case <-ctx.Done():
+				return nil
+			}
+
+		case output, ok := <-obj.outputChan:
+			// send the new result downstream
+			if !ok {
+				obj.outputChan = nil
+				canReceiveMoreOutputs = false
+				continue
+			}
+
+			select {
+			case obj.init.Output <- output:
+			case <-ctx.Done():
+				return nil
+			}
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// replaceSubGraph removes the old subgraph and asks for a new one to be built.
+func (obj *iterator) replaceSubGraph(subgraphInput interfaces.Func) error {
+	const channelBasedSinkFuncArgNameEdgeName = structs.ChannelBasedSinkFuncArgName
+
+	// delete the old subgraph
+	if err := obj.init.Txn.Reverse(); err != nil {
+		return errwrap.Wrapf(err, "could not Reverse")
+	}
+
+	obj.outputChan = make(chan types.Value)
+	subgraphOutput := &structs.ChannelBasedSinkFunc{
+		Name:     "subgraphOutput",
+		Target:   obj.Source,
+		EdgeName: channelBasedSinkFuncArgNameEdgeName,
+		Chan:     obj.outputChan,
+		Type:     obj.Type.Out,
+	}
+	obj.init.Txn.AddVertex(subgraphOutput)
+
+	result, err := obj.Replace(subgraphInput, obj.lastFuncValue, obj.lastInputListLength)
+	if err != nil {
+		return err
+	}
+	obj.init.Txn.AddEdge(result, subgraphOutput, &interfaces.FuncEdge{
+		Args: []string{channelBasedSinkFuncArgNameEdgeName},
+	})
+
+	return obj.init.Txn.Commit()
+}
+
+// field adds a node to the subgraph which outputs one of the args.
+func (obj *iterator) field(args interfaces.Func, name string) interfaces.Func {
+	typ := obj.argsType.Map[name]
+	f := structs.SimpleFnToDirectFunc(
+		fmt.Sprintf("%s.%s", obj.Name, name),
+		&types.FuncValue{
+			V: func(input []types.Value) (types.Value, error) {
+				return input[0].Struct()[name], nil
+			},
+			T: types.NewType(fmt.Sprintf("func(%s %s) %s", argNameArgs, obj.argsType, typ)),
+		},
+	)
+	obj.init.Txn.AddVertex(f)
+	obj.init.Txn.AddEdge(args, f, &interfaces.FuncEdge{
+		Args: []string{argNameArgs},
+	})
+	return f
+}
+
+// elem adds a node to the subgraph which outputs one element of the list.
+func (obj *iterator) elem(args interfaces.Func, i int) interfaces.Func {
+	typ := obj.argsType.Map[argNameInputs].Val
+	f := structs.SimpleFnToDirectFunc(
+		fmt.Sprintf("%s.%s[%d]", obj.Name, argNameInputs, i),
+		&types.FuncValue{
+			V: func(input []types.Value) (types.Value, error) {
+				list := input[0].Struct()[argNameInputs].List()
+				if i >= len(list) {
+					return nil, fmt.Errorf("index %d is out of range", i)
+				}
+				return list[i], nil
+			},
+			T: types.NewType(fmt.Sprintf("func(%s %s) %s", argNameArgs, obj.argsType, typ)),
+		},
+	)
+	obj.init.Txn.AddVertex(f)
+	obj.init.Txn.AddEdge(args, f, &interfaces.FuncEdge{
+		Args: []string{argNameArgs},
+	})
+	return f
+}
+
+// combine adds a node to the subgraph which takes the outputs of the elements
+// and of the function calls, and combines them into the result with fn. The
+// values that fn gets are the elements and the call outputs, one pair at a
+// time.
+func (obj *iterator) combine(elems, calls []interfaces.Func, out *types.Type, fn func(elems, calls []types.Value) (types.Value, error)) interfaces.Func {
+	n := len(elems)
+	m := make(map[string]*types.Type)
+	ord := []string{}
+	for i := 0; i < n; i++ {
+		m[fmt.Sprintf("elem%d", i)] = obj.argsType.Map[argNameInputs].Val
+		m[fmt.Sprintf("call%d", i)] = obj.Type.Map[argNameFunction].Out
+		ord = append(ord, fmt.Sprintf("elem%d", i), fmt.Sprintf("call%d", i))
+	}
+	f := structs.SimpleFnToDirectFunc(
+		fmt.Sprintf("%s.combine", obj.Name),
+		&types.FuncValue{
+			V: func(input []types.Value) (types.Value, error) {
+				es, cs := []types.Value{}, []types.Value{}
+				for i := 0; i < len(input); i += 2 {
+					es = append(es, input[i])
+					cs = append(cs, input[i+1])
+				}
+				return fn(es, cs)
+			},
+			T: &types.Type{
+				Kind: types.KindFunc,
+				Map:  m,
+				Ord:  ord,
+				Out:  out,
+			},
+		},
+	)
+	obj.init.Txn.AddVertex(f)
+	for i := 0; i < n; i++ {
+		obj.init.Txn.AddEdge(elems[i], f, &interfaces.FuncEdge{
+			Args: []string{fmt.Sprintf("elem%d", i)},
+		})
+		obj.init.Txn.AddEdge(calls[i], f, &interfaces.FuncEdge{
+			Args: []string{fmt.Sprintf("call%d", i)},
+		})
+	}
+	return f
+}
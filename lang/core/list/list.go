@@ -0,0 +1,70 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+// Package corelist contains functions for working with lists.
+package corelist
+
+import (
+	"fmt"
+
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+const (
+	// ModuleName is the prefix given to all the functions in this module.
+	ModuleName = "list"
+)
+
+// sortable checks that the values of this type can be sorted. Functions can't
+// be compared, so this errors if the type contains one anywhere.
+func sortable(typ *types.Type) error {
+	if typ == nil {
+		return fmt.Errorf("type is not known")
+	}
+	switch typ.Kind {
+	case types.KindFunc:
+		return fmt.Errorf("can't sort functions")
+	case types.KindList:
+		return sortable(typ.Val)
+	case types.KindVariant:
+		return sortable(typ.Var)
+	case types.KindMap:
+		if err := sortable(typ.Key); err != nil {
+			return err
+		}
+		return sortable(typ.Val)
+	case types.KindStruct:
+		for _, k := range typ.Ord {
+			if err := sortable(typ.Map[k]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package corelist
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+// ints is a helper to build an int list value.
+func ints(x ...int64) types.Value {
+	list := types.NewList(types.NewType("[]int"))
+	for _, i := range x {
+		list.V = append(list.V, &types.IntValue{V: i})
+	}
+	return list
+}
+
+func TestListFuncs0(t *testing.T) {
+	type test struct {
+		name string
+		fn   func(*types.Type, []types.Value) (types.Value, error)
+		sig  string
+		args []types.Value
+		exp  types.Value
+	}
+	testCases := []test{
+		{
+			name: "reverse",
+			fn:   Reverse,
+			sig:  "func(inputs []int) []int",
+			args: []types.Value{ints(1, 2, 3)},
+			exp:  ints(3, 2, 1),
+		},
+		{
+			name: "reverse empty",
+			fn:   Reverse,
+			sig:  "func(inputs []int) []int",
+			args: []types.Value{ints()},
+			exp:  ints(),
+		},
+		{
+			name: "uniq",
+			fn:   Uniq,
+			sig:  "func(inputs []int) []int",
+			args: []types.Value{ints(3, 1, 3, 2, 1)},
+			exp:  ints(3, 1, 2),
+		},
+		{
+			name: "sort",
+			fn:   Sort,
+			sig:  "func(inputs []int) []int",
+			args: []types.Value{ints(3, 1, 2)},
+			exp:  ints(1, 2, 3),
+		},
+		{
+			name: "index_of",
+			fn:   IndexOf,
+			sig:  "func(inputs []int, needle int) int",
+			args: []types.Value{ints(4, 5, 6), &types.IntValue{V: 6}},
+			exp:  &types.IntValue{V: 2},
+		},
+		{
+			name: "index_of missing",
+			fn:   IndexOf,
+			sig:  "func(inputs []int, needle int) int",
+			args: []types.Value{ints(4, 5, 6), &types.IntValue{V: 7}},
+			exp:  &types.IntValue{V: -1},
+		},
+		{
+			name: "slice",
+			fn:   Slice,
+			sig:  "func(inputs []int, start int, end int) []int",
+			args: []types.Value{ints(1, 2, 3, 4), &types.IntValue{V: 1}, &types.IntValue{V: 3}},
+			exp:  ints(2, 3),
+		},
+		{
+			name: "slice clamped",
+			fn:   Slice,
+			sig:  "func(inputs []int, start int, end int) []int",
+			args: []types.Value{ints(1, 2, 3), &types.IntValue{V: -5}, &types.IntValue{V: 42}},
+			exp:  ints(1, 2, 3),
+		},
+		{
+			name: "slice backwards",
+			fn:   Slice,
+			sig:  "func(inputs []int, start int, end int) []int",
+			args: []types.Value{ints(1, 2, 3), &types.IntValue{V: 2}, &types.IntValue{V: 1}},
+			exp:  ints(),
+		},
+	}
+
+	flat := types.NewList(types.NewType("[][]int"))
+	flat.V = append(flat.V, ints(1), ints(), ints(2, 3))
+	testCases = append(testCases, test{
+		name: "flatten",
+		fn:   Flatten,
+		sig:  "func(inputs [][]int) []int",
+		args: []types.Value{flat},
+		exp:  ints(1, 2, 3),
+	})
+
+	bools := types.NewList(types.NewType("[]bool"))
+	bools.V = append(bools.V, &types.BoolValue{V: true})
+	zipped := types.NewList(types.NewType("[]struct{a int; b bool}"))
+	st := types.NewStruct(types.NewType("struct{a int; b bool}"))
+	st.Set("a", &types.IntValue{V: 1})
+	st.Set("b", &types.BoolValue{V: true})
+	zipped.V = append(zipped.V, st)
+	testCases = append(testCases, test{
+		name: "zip",
+		fn:   Zip,
+		sig:  "func(a []int, b []bool) []struct{a int; b bool}",
+		args: []types.Value{ints(1, 2), bools},
+		exp:  zipped,
+	})
+
+	for index, tc := range testCases {
+		t.Run(fmt.Sprintf("test #%d (%s)", index, tc.name), func(t *testing.T) {
+			out, err := tc.fn(types.NewType(tc.sig), tc.args)
+			if err != nil {
+				t.Errorf("test #%d: failed with: %+v", index, err)
+				return
+			}
+			if err := out.Cmp(tc.exp); err != nil {
+				t.Errorf("test #%d: got: %s, expected: %s", index, out, tc.exp)
+			}
+		})
+	}
+}
+
+func TestRange0(t *testing.T) {
+	out, err := Range([]types.Value{&types.IntValue{V: 2}, &types.IntValue{V: 5}})
+	if err != nil {
+		t.Errorf("failed with: %+v", err)
+		return
+	}
+	if err := out.Cmp(ints(2, 3, 4)); err != nil {
+		t.Errorf("unexpected range: %s", out)
+	}
+
+	out, err = Range([]types.Value{&types.IntValue{V: 5}, &types.IntValue{V: 2}})
+	if err != nil {
+		t.Errorf("failed with: %+v", err)
+		return
+	}
+	if err := out.Cmp(ints()); err != nil {
+		t.Errorf("unexpected range: %s", out)
+	}
+}
+
+func TestSortable0(t *testing.T) {
+	for _, s := range []string{"int", "[]str", "map{str: float}", "struct{a bool}"} {
+		if err := sortable(types.NewType(s)); err != nil {
+			t.Errorf("expected %s to be sortable: %+v", s, err)
+		}
+	}
+	for _, s := range []string{"func() int", "[]func() int", "struct{a func() int}"} {
+		if err := sortable(types.NewType(s)); err == nil {
+			t.Errorf("expected %s not to be sortable", s)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package corelist
+
+import (
+	"sort"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/lang/types/full"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// SortByFuncName is the name this function is registered as.
+	SortByFuncName = "sort_by"
+)
+
+func init() {
+	funcs.ModuleRegister(ModuleName, SortByFuncName, func() interfaces.Func {
+		obj := &SortByFunc{}
+		obj.iterFunc = newIterFunc(SortByFuncName, "func(inputs []?T, function func(?T) ?K) []?T", obj, obj.replace)
+		obj.Check = func(vars map[string]*types.Type) error {
+			return sortable(vars["?K"])
+		}
+		return obj
+	}) // must register the func and name
+}
+
+var _ interfaces.PolyFunc = &SortByFunc{}         // ensure it meets this expectation
+var _ interfaces.UnreplayableFunc = &SortByFunc{} // ensure it meets this expectation
+
+// SortByFunc returns the elements of a list in ascending order of the keys that
+// the function returns for each of them. Elements with equal keys keep their
+// order. This implements the signature: `func(inputs []T, function func(T) K)
+// []T` and the function can be an mcl lambda, in the same way as with iter.map.
+type SortByFunc struct {
+	*iterFunc // provides everything except for the subgraph
+}
+
+// replace builds a subgraph which calls the function on each element to get its
+// key, and then sorts the elements by those keys.
+func (obj *SortByFunc) replace(args interfaces.Func, fn *full.FuncValue, n int) (interfaces.Func, error) {
+	elems := []interfaces.Func{}
+	calls := []interfaces.Func{}
+	for i := 0; i < n; i++ {
+		elem := obj.iter.elem(args, i)
+		call, err := fn.Call(obj.iter.init.Txn, []interfaces.Func{elem})
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "could not call the function")
+		}
+		elems = append(elems, elem)
+		calls = append(calls, call)
+	}
+
+	out := obj.Type().Out
+	return obj.iter.combine(elems, calls, out, func(elems, calls []types.Value) (types.Value, error) {
+		index := []int{}
+		for i := range elems {
+			index = append(index, i)
+		}
+		sort.SliceStable(index, func(i, j int) bool {
+			return calls[index[i]].Less(calls[index[j]])
+		})
+		list := types.NewList(out)
+		for _, i := range index {
+			list.V = append(list.V, elems[i])
+		}
+		return list, nil
+	}), nil
+}
@@ -0,0 +1,93 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package coremaps
+
+import (
+	"testing"
+
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+// strInt is a helper to build a map value from a golang map.
+func strInt(m map[string]int64) *types.MapValue {
+	out := types.NewMap(types.NewType("map{str: int}"))
+	for k, v := range m {
+		out.Add(&types.StrValue{V: k}, &types.IntValue{V: v})
+	}
+	return out
+}
+
+func TestMapFuncs0(t *testing.T) {
+	m := strInt(map[string]int64{"b": 2, "a": 1, "c": 3})
+
+	keys, err := Keys(types.NewType("func(m map{str: int}) []str"), []types.Value{m})
+	if err != nil {
+		t.Errorf("keys failed with: %+v", err)
+		return
+	}
+	if s := keys.String(); s != `["a", "b", "c"]` {
+		t.Errorf("unexpected keys: %s", s)
+	}
+
+	values, err := Values(types.NewType("func(m map{str: int}) []int"), []types.Value{m})
+	if err != nil {
+		t.Errorf("values failed with: %+v", err)
+		return
+	}
+	if s := values.String(); s != `[1, 2, 3]` {
+		t.Errorf("unexpected values: %s", s)
+	}
+
+	for key, exp := range map[string]bool{"a": true, "z": false} {
+		out, err := HasKey(types.NewType("func(m map{str: int}, key str) bool"), []types.Value{m, &types.StrValue{V: key}})
+		if err != nil {
+			t.Errorf("has_key failed with: %+v", err)
+			return
+		}
+		if out.Bool() != exp {
+			t.Errorf("unexpected has_key of %s: %s", key, out)
+		}
+	}
+
+	b := strInt(map[string]int64{"b": 20, "d": 4})
+	merged, err := Merge(types.NewType("func(a map{str: int}, b map{str: int}) map{str: int}"), []types.Value{m, b})
+	if err != nil {
+		t.Errorf("merge failed with: %+v", err)
+		return
+	}
+	if l := len(merged.Map()); l != 4 {
+		t.Errorf("expected 4 keys after merge, got: %d", l)
+	}
+	if v, exists := merged.(*types.MapValue).Lookup(&types.StrValue{V: "b"}); !exists || v.Int() != 20 {
+		t.Errorf("expected the second map to win, got: %v", v)
+	}
+}
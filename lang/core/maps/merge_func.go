@@ -0,0 +1,59 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coremaps
+
+import (
+	"github.com/purpleidea/mgmt/lang/funcs/templatepoly"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func init() {
+	templatepoly.ModuleRegister(ModuleName, "merge", "func(a map{?K: ?V}, b map{?K: ?V}) map{?K: ?V}", Merge)
+}
+
+// Merge returns a map with the keys of both maps. If a key is in both of them,
+// then the value from the second map is used.
+func Merge(typ *types.Type, input []types.Value) (types.Value, error) {
+	m := types.NewMap(typ.Out)
+	for _, x := range input {
+		for k, v := range x.Map() {
+			// the keys are pointers, so remove an equal one first
+			for old := range m.V {
+				if old.Cmp(k) == nil {
+					delete(m.V, old)
+				}
+			}
+			if err := m.Add(k, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m, nil
+}
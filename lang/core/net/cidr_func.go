@@ -0,0 +1,167 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package corenet
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/purpleidea/mgmt/lang/funcs/simple"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func init() {
+	simple.ModuleRegister(ModuleName, "cidr_contains", &types.FuncValue{
+		T: types.NewType("func(cidr str, ip str) bool"),
+		V: CidrContains,
+	})
+	simple.ModuleRegister(ModuleName, "network_address", &types.FuncValue{
+		T: types.NewType("func(cidr str) str"),
+		V: NetworkAddress,
+	})
+	simple.ModuleRegister(ModuleName, "broadcast_address", &types.FuncValue{
+		T: types.NewType("func(cidr str) str"),
+		V: BroadcastAddress,
+	})
+	simple.ModuleRegister(ModuleName, "netmask", &types.FuncValue{
+		T: types.NewType("func(cidr str) str"),
+		V: Netmask,
+	})
+}
+
+// CidrContains returns true if the ip is inside of the cidr. For example, the
+// ip 192.0.2.42 is inside of 192.0.2.0/24, but not inside of 192.0.2.0/27.
+func CidrContains(input []types.Value) (types.Value, error) {
+	prefix, err := parsePrefix(input[0].Str())
+	if err != nil {
+		return nil, err
+	}
+	addr, err := parseAddr(input[1].Str())
+	if err != nil {
+		return nil, err
+	}
+	return &types.BoolValue{
+		V: prefix.Contains(addr),
+	}, nil
+}
+
+// NetworkAddress returns the first address of the cidr. For example, it is
+// 192.0.2.0 for 192.0.2.42/24.
+func NetworkAddress(input []types.Value) (types.Value, error) {
+	prefix, err := parsePrefix(input[0].Str())
+	if err != nil {
+		return nil, err
+	}
+	return &types.StrValue{
+		V: prefix.Addr().String(),
+	}, nil
+}
+
+// BroadcastAddress returns the last address of an IPv4 cidr. For example, it is
+// 192.0.2.255 for 192.0.2.42/24. There is no broadcast address in IPv6.
+func BroadcastAddress(input []types.Value) (types.Value, error) {
+	prefix, err := parsePrefix(input[0].Str())
+	if err != nil {
+		return nil, err
+	}
+	if !prefix.Addr().Is4() {
+		return nil, fmt.Errorf("there is no broadcast address in IPv6: %s", prefix)
+	}
+	return &types.StrValue{
+		V: lastAddr(prefix).String(),
+	}, nil
+}
+
+// Netmask returns the mask of the cidr in the dotted format. For example, it is
+// 255.255.255.0 for 192.0.2.42/24. This is the format that the `mask` field of
+// the `dhcp:range` resource accepts.
+func Netmask(input []types.Value) (types.Value, error) {
+	prefix, err := parsePrefix(input[0].Str())
+	if err != nil {
+		return nil, err
+	}
+	mask := net.CIDRMask(prefix.Bits(), prefix.Addr().BitLen())
+	return &types.StrValue{
+		V: net.IP(mask).String(),
+	}, nil
+}
+
+// parsePrefix parses a cidr such as 192.0.2.0/24. The host part may be set, as
+// in 192.0.2.42/24, since that's how an address on an interface is written, but
+// the prefix returned is always the network.
+func parsePrefix(s string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(s))
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid CIDR address: %s", s)
+	}
+	return prefix.Masked(), nil
+}
+
+// parseAddr parses an IPv4 or IPv6 address. An IPv4 address which is mapped
+// into IPv6, such as ::ffff:192.0.2.42, is treated as the IPv4 address.
+func parseAddr(s string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid IP address: %s", s)
+	}
+	return addr.Unmap(), nil
+}
+
+// lastAddr returns the last address of the prefix, which is the one with every
+// bit of the host part set.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for i := range b {
+		n := prefix.Bits() - i*8 // network bits in this byte
+		if n <= 0 {
+			b[i] = 0xff
+		} else if n < 8 {
+			b[i] |= 0xff >> n
+		}
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// addAddr returns the address which is n after this one, or before it if n is
+// negative. It returns false if that would go past the first or last address.
+func addAddr(addr netip.Addr, n int64) (netip.Addr, bool) {
+	i := new(big.Int).SetBytes(addr.AsSlice())
+	i.Add(i, big.NewInt(n))
+	size := addr.BitLen() / 8
+	if i.Sign() < 0 || i.BitLen() > size*8 {
+		return netip.Addr{}, false
+	}
+	result, _ := netip.AddrFromSlice(i.FillBytes(make([]byte, size)))
+	return result, true
+}
@@ -0,0 +1,100 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package corenet
+
+import (
+	"testing"
+
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+// calcTest is one case of a table driven test of the network calculations.
+type calcTest struct {
+	name     string
+	fn       func([]types.Value) (types.Value, error)
+	args     []types.Value
+	expected string // the value printed as mcl, or empty for an error
+}
+
+func runCalcTests(t *testing.T, tests []calcTest) {
+	for _, ts := range tests {
+		test := ts
+		t.Run(test.name, func(t *testing.T) {
+			output, err := test.fn(test.args)
+			if test.expected == "" {
+				if err == nil {
+					t.Errorf("expected an error, got: %s", output)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("did not expect error but got: %+v", err)
+				return
+			}
+			if s := output.String(); s != test.expected {
+				t.Errorf("expected: %s, got: %s", test.expected, s)
+			}
+		})
+	}
+}
+
+func str(s string) types.Value { return &types.StrValue{V: s} }
+
+func num(i int64) types.Value { return &types.IntValue{V: i} }
+
+func TestCidrFuncs(t *testing.T) {
+	runCalcTests(t, []calcTest{
+		{"contains", CidrContains, []types.Value{str("192.0.2.0/24"), str("192.0.2.42")}, `true`},
+		{"contains host bits", CidrContains, []types.Value{str(" 192.0.2.7/24 "), str("192.0.2.255")}, `true`},
+		{"not contains", CidrContains, []types.Value{str("192.0.2.0/27"), str("192.0.2.42")}, `false`},
+		{"contains mapped", CidrContains, []types.Value{str("192.0.2.0/24"), str("::ffff:192.0.2.42")}, `true`},
+		{"contains other family", CidrContains, []types.Value{str("2001:db8::/32"), str("192.0.2.42")}, `false`},
+		{"contains IPv6", CidrContains, []types.Value{str("2001:db8::/32"), str("2001:db8:ffff::1")}, `true`},
+		{"contains bad cidr", CidrContains, []types.Value{str("192.0.2.0/33"), str("192.0.2.42")}, ``},
+		{"contains bad ip", CidrContains, []types.Value{str("192.0.2.0/24"), str("192.0.2.256")}, ``},
+
+		{"network", NetworkAddress, []types.Value{str("192.0.2.42/24")}, `"192.0.2.0"`},
+		{"network odd", NetworkAddress, []types.Value{str("10.1.2.3/12")}, `"10.0.0.0"`},
+		{"network IPv6", NetworkAddress, []types.Value{str("2001:db8::42/64")}, `"2001:db8::"`},
+		{"network bad", NetworkAddress, []types.Value{str("192.0.2.42")}, ``},
+
+		{"broadcast", BroadcastAddress, []types.Value{str("192.0.2.42/24")}, `"192.0.2.255"`},
+		{"broadcast odd", BroadcastAddress, []types.Value{str("10.1.2.3/12")}, `"10.15.255.255"`},
+		{"broadcast /32", BroadcastAddress, []types.Value{str("192.0.2.42/32")}, `"192.0.2.42"`},
+		{"broadcast /0", BroadcastAddress, []types.Value{str("0.0.0.0/0")}, `"255.255.255.255"`},
+		{"broadcast IPv6", BroadcastAddress, []types.Value{str("2001:db8::/32")}, ``},
+
+		{"netmask", Netmask, []types.Value{str("192.0.2.42/24")}, `"255.255.255.0"`},
+		{"netmask odd", Netmask, []types.Value{str("192.0.2.42/20")}, `"255.255.240.0"`},
+		{"netmask IPv6", Netmask, []types.Value{str("2001:db8::/48")}, `"ffff:ffff:ffff::"`},
+	})
+}
@@ -0,0 +1,245 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !darwin
+
+package corenet
+
+import (
+	"context"
+	"net"
+	"sort"
+
+	"github.com/purpleidea/mgmt/lang/funcs/facts"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// DefaultRoutesFuncName is the name this fact is registered as. It's
+	// still a Func Name because this is the name space the fact is actually
+	// using.
+	DefaultRoutesFuncName = "default_routes"
+
+	defaultRoutesSignature = "[]struct{family str; gateway str; iface str; metric int}"
+)
+
+func init() {
+	facts.ModuleRegister(ModuleName, DefaultRoutesFuncName, func() facts.Fact { return &DefaultRoutesFact{} }) // must register the fact and name
+}
+
+// DefaultRoutesFact is a fact which returns the list of default routes in the
+// main routing table. The family is either `ipv4` or `ipv6`, the gateway is
+// empty for a device route, and a multipath route is listed once per nexthop.
+// The list is sorted by family and then metric. It receives events from the
+// kernel whenever a route changes.
+type DefaultRoutesFact struct {
+	init *facts.Init
+}
+
+// String returns a simple name for this fact. This is needed so this struct can
+// satisfy the pgraph.Vertex interface.
+func (obj *DefaultRoutesFact) String() string {
+	return DefaultRoutesFuncName
+}
+
+// Info returns some static info about itself.
+func (obj *DefaultRoutesFact) Info() *facts.Info {
+	return &facts.Info{
+		Output: types.NewType(defaultRoutesSignature),
+	}
+}
+
+// Init runs some startup code for this fact.
+func (obj *DefaultRoutesFact) Init(init *facts.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the changing values that this fact has over time.
+func (obj *DefaultRoutesFact) Stream(ctx context.Context) error {
+	defer close(obj.init.Output) // always signal when we're done
+
+	errChan := make(chan error, 1)
+	cberr := func(err error) {
+		select {
+		case errChan <- err:
+		default: // we only need the first one
+		}
+	}
+	done := make(chan struct{})
+	routeChan := make(chan netlink.RouteUpdate)
+
+	if err := netlink.RouteSubscribeWithOptions(routeChan, done, netlink.RouteSubscribeOptions{ErrorCallback: cberr}); err != nil {
+		return errwrap.Wrapf(err, "could not subscribe to route updates")
+	}
+	defer func() {
+		close(done)
+		// The subscription only notices that it's done when it next
+		// receives a message, so drain it in the background so that it
+		// never blocks sending to us.
+		go func() {
+			for range routeChan {
+			}
+		}()
+	}()
+
+	// streams must generate an initial event on startup
+	startChan := make(chan struct{}) // start signal
+	close(startChan)                 // kick it off!
+	var last types.Value
+	for {
+		select {
+		case <-startChan: // kick the loop once at start
+			startChan = nil // disable
+
+		case update, ok := <-routeChan:
+			if !ok {
+				return nil
+			}
+			if update.Dst != nil {
+				continue // not a default route
+			}
+			if obj.init.Debug {
+				obj.init.Logf("default route update: %s", update.Route.String())
+			}
+
+		case err := <-errChan:
+			return errwrap.Wrapf(err, "error receiving netlink update")
+
+		case <-ctx.Done():
+			return nil
+		}
+
+		l, err := defaultRoutes()
+		if err != nil {
+			return errwrap.Wrapf(err, "could not list default routes")
+		}
+		if last != nil && l.Cmp(last) == nil {
+			continue // nothing changed
+		}
+		last = l
+
+		select {
+		case obj.init.Output <- l:
+			// send
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// defaultRoute is one entry of the default routes fact.
+type defaultRoute struct {
+	family  string
+	gateway string
+	iface   string
+	metric  int
+}
+
+// defaultRoutes builds the current list of default routes from the kernel.
+func defaultRoutes() (*types.ListValue, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int]string) // link index -> name
+	for _, link := range links {
+		names[link.Attrs().Index] = link.Attrs().Name
+	}
+	routes, err := netlink.RouteList(nil, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []defaultRoute{}
+	for _, route := range routes {
+		if route.Dst != nil {
+			continue // not a default route
+		}
+		family := "ipv4"
+		if route.Family == netlink.FAMILY_V6 {
+			family = "ipv6"
+		}
+		if len(route.MultiPath) == 0 {
+			result = append(result, defaultRoute{
+				family:  family,
+				gateway: gatewayString(route.Gw),
+				iface:   names[route.LinkIndex],
+				metric:  route.Priority,
+			})
+			continue
+		}
+		for _, nh := range route.MultiPath {
+			result = append(result, defaultRoute{
+				family:  family,
+				gateway: gatewayString(nh.Gw),
+				iface:   names[nh.LinkIndex],
+				metric:  route.Priority,
+			})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.family != b.family {
+			return a.family < b.family
+		}
+		if a.metric != b.metric {
+			return a.metric < b.metric
+		}
+		if a.iface != b.iface {
+			return a.iface < b.iface
+		}
+		return a.gateway < b.gateway
+	})
+
+	typ := types.NewType(defaultRoutesSignature)
+	l := types.NewList(typ)
+	for _, x := range result {
+		st := types.NewStruct(typ.Val)
+		st.Set("family", &types.StrValue{V: x.family})
+		st.Set("gateway", &types.StrValue{V: x.gateway})
+		st.Set("iface", &types.StrValue{V: x.iface})
+		st.Set("metric", &types.IntValue{V: int64(x.metric)})
+		if err := l.Add(st); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// gatewayString returns the gateway address, or empty if there isn't one.
+func gatewayString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
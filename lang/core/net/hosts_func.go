@@ -0,0 +1,188 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package corenet
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/purpleidea/mgmt/lang/funcs/simple"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+const (
+	// maxListLength is the most addresses or subnets that we'll return in a
+	// list, so that an IPv6 cidr can't use up all of the memory.
+	maxListLength = 65536
+)
+
+func init() {
+	simple.ModuleRegister(ModuleName, "hosts", &types.FuncValue{
+		T: types.NewType("func(cidr str) []str"),
+		V: Hosts,
+	})
+	simple.ModuleRegister(ModuleName, "nth_host", &types.FuncValue{
+		T: types.NewType("func(cidr str, n int) str"),
+		V: NthHost,
+	})
+	simple.ModuleRegister(ModuleName, "subnets", &types.FuncValue{
+		T: types.NewType("func(cidr str, prefix int) []str"),
+		V: Subnets,
+	})
+	simple.ModuleRegister(ModuleName, "ip_range", &types.FuncValue{
+		T: types.NewType("func(from str, to str) []str"),
+		V: IPRange,
+	})
+}
+
+// Hosts returns the list of usable host addresses in the cidr. In IPv4 this
+// skips the network and broadcast addresses, except in a /31 or /32 which have
+// no room for them. In IPv6 this skips the first address, which is the subnet
+// router anycast address, except in a /127 or /128. It errors if there would be
+// more than 65536 of them.
+func Hosts(input []types.Value) (types.Value, error) {
+	prefix, err := parsePrefix(input[0].Str())
+	if err != nil {
+		return nil, err
+	}
+	first, last := hostRange(prefix)
+	return addrList(first, last)
+}
+
+// NthHost returns the nth usable host address in the cidr, where the first one
+// is 1. If n is negative, then it counts back from the last one, which is -1.
+// This is useful for giving a host the same address in each of many networks.
+// See hosts for which addresses are usable.
+func NthHost(input []types.Value) (types.Value, error) {
+	prefix, err := parsePrefix(input[0].Str())
+	if err != nil {
+		return nil, err
+	}
+	n := input[1].Int()
+	first, last := hostRange(prefix)
+
+	var addr netip.Addr
+	ok := false
+	switch {
+	case n > 0:
+		addr, ok = addAddr(first, n-1)
+	case n < 0:
+		addr, ok = addAddr(last, n+1)
+	}
+	if !ok || addr.Less(first) || last.Less(addr) {
+		return nil, fmt.Errorf("there is no host %d in %s", n, prefix)
+	}
+	return &types.StrValue{
+		V: addr.String(),
+	}, nil
+}
+
+// Subnets splits the cidr into the list of all of its subnets with the longer
+// prefix length. For example, 192.0.2.0/24 split with 26 gives four subnets. It
+// errors if there would be more than 65536 of them.
+func Subnets(input []types.Value) (types.Value, error) {
+	prefix, err := parsePrefix(input[0].Str())
+	if err != nil {
+		return nil, err
+	}
+	bits := int(input[1].Int())
+	if bits < prefix.Bits() || bits > prefix.Addr().BitLen() {
+		return nil, fmt.Errorf("prefix length %d is not between %d and %d", bits, prefix.Bits(), prefix.Addr().BitLen())
+	}
+	if bits-prefix.Bits() > 16 {
+		return nil, fmt.Errorf("more than %d subnets of /%d in %s", maxListLength, bits, prefix)
+	}
+
+	values := []types.Value{}
+	addr := prefix.Addr()
+	for i := 0; i < 1<<(bits-prefix.Bits()); i++ {
+		subnet := netip.PrefixFrom(addr, bits)
+		values = append(values, &types.StrValue{V: subnet.String()})
+		addr = lastAddr(subnet).Next() // invalid after the last one
+	}
+	return &types.ListValue{
+		T: types.NewType("[]str"),
+		V: values,
+	}, nil
+}
+
+// IPRange returns the list of addresses from the first one to the last one,
+// inclusive. They must both be IPv4 or IPv6 addresses, and it errors if there
+// would be more than 65536 of them.
+func IPRange(input []types.Value) (types.Value, error) {
+	from, err := parseAddr(input[0].Str())
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseAddr(input[1].Str())
+	if err != nil {
+		return nil, err
+	}
+	if from.BitLen() != to.BitLen() {
+		return nil, fmt.Errorf("can't mix IPv4 and IPv6 addresses")
+	}
+	if to.Less(from) {
+		return nil, fmt.Errorf("%s is after %s", from, to)
+	}
+	return addrList(from, to)
+}
+
+// hostRange returns the first and last usable host addresses in the prefix.
+func hostRange(prefix netip.Prefix) (netip.Addr, netip.Addr) {
+	first, last := prefix.Addr(), lastAddr(prefix)
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	if hostBits <= 1 { // point to point links, or a single host
+		return first, last
+	}
+	if prefix.Addr().Is4() {
+		return first.Next(), last.Prev()
+	}
+	return first.Next(), last
+}
+
+// addrList returns the list of addresses from the first one to the last one,
+// inclusive, unless there are too many of them.
+func addrList(first, last netip.Addr) (types.Value, error) {
+	if limit, ok := addAddr(first, maxListLength); ok && !last.Less(limit) {
+		return nil, fmt.Errorf("more than %d addresses from %s to %s", maxListLength, first, last)
+	}
+
+	values := []types.Value{}
+	for addr := first; ; addr = addr.Next() {
+		values = append(values, &types.StrValue{V: addr.String()})
+		if addr == last {
+			break
+		}
+	}
+	return &types.ListValue{
+		T: types.NewType("[]str"),
+		V: values,
+	}, nil
+}
@@ -0,0 +1,78 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package corenet
+
+import (
+	"testing"
+
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func TestHostsFuncs(t *testing.T) {
+	runCalcTests(t, []calcTest{
+		{"hosts /30", Hosts, []types.Value{str("192.0.2.0/30")}, `["192.0.2.1", "192.0.2.2"]`},
+		{"hosts /31", Hosts, []types.Value{str("192.0.2.0/31")}, `["192.0.2.0", "192.0.2.1"]`},
+		{"hosts /32", Hosts, []types.Value{str("192.0.2.7/32")}, `["192.0.2.7"]`},
+		{"hosts IPv6 /126", Hosts, []types.Value{str("2001:db8::/126")}, `["2001:db8::1", "2001:db8::2", "2001:db8::3"]`},
+		{"hosts IPv6 /127", Hosts, []types.Value{str("2001:db8::/127")}, `["2001:db8::", "2001:db8::1"]`},
+		{"hosts too many", Hosts, []types.Value{str("10.0.0.0/8")}, ``},
+		{"hosts IPv6 too many", Hosts, []types.Value{str("2001:db8::/64")}, ``},
+
+		{"nth first", NthHost, []types.Value{str("192.0.2.0/24"), num(1)}, `"192.0.2.1"`},
+		{"nth tenth", NthHost, []types.Value{str("192.0.2.42/24"), num(10)}, `"192.0.2.10"`},
+		{"nth last", NthHost, []types.Value{str("192.0.2.0/24"), num(-1)}, `"192.0.2.254"`},
+		{"nth second last", NthHost, []types.Value{str("192.0.2.0/24"), num(-2)}, `"192.0.2.253"`},
+		{"nth all", NthHost, []types.Value{str("192.0.2.0/24"), num(254)}, `"192.0.2.254"`},
+		{"nth too far", NthHost, []types.Value{str("192.0.2.0/24"), num(255)}, ``},
+		{"nth too far back", NthHost, []types.Value{str("192.0.2.0/24"), num(-255)}, ``},
+		{"nth zero", NthHost, []types.Value{str("192.0.2.0/24"), num(0)}, ``},
+		{"nth across bytes", NthHost, []types.Value{str("10.0.0.0/16"), num(300)}, `"10.0.1.44"`},
+		{"nth IPv6", NthHost, []types.Value{str("2001:db8::/64"), num(65536)}, `"2001:db8::1:0"`},
+		{"nth IPv6 last", NthHost, []types.Value{str("2001:db8::/64"), num(-1)}, `"2001:db8::ffff:ffff:ffff:ffff"`},
+		{"nth top of space", NthHost, []types.Value{str("255.255.255.0/24"), num(255)}, ``},
+
+		{"subnets", Subnets, []types.Value{str("192.0.2.0/24"), num(26)}, `["192.0.2.0/26", "192.0.2.64/26", "192.0.2.128/26", "192.0.2.192/26"]`},
+		{"subnets same", Subnets, []types.Value{str("192.0.2.42/24"), num(24)}, `["192.0.2.0/24"]`},
+		{"subnets top of space", Subnets, []types.Value{str("255.255.255.0/24"), num(25)}, `["255.255.255.0/25", "255.255.255.128/25"]`},
+		{"subnets IPv6", Subnets, []types.Value{str("2001:db8::/47"), num(48)}, `["2001:db8::/48", "2001:db8:1::/48"]`},
+		{"subnets shorter", Subnets, []types.Value{str("192.0.2.0/24"), num(23)}, ``},
+		{"subnets too long", Subnets, []types.Value{str("192.0.2.0/24"), num(33)}, ``},
+		{"subnets too many", Subnets, []types.Value{str("2001:db8::/32"), num(64)}, ``},
+
+		{"range", IPRange, []types.Value{str("192.0.2.254"), str("192.0.3.1")}, `["192.0.2.254", "192.0.2.255", "192.0.3.0", "192.0.3.1"]`},
+		{"range one", IPRange, []types.Value{str("192.0.2.1"), str("192.0.2.1")}, `["192.0.2.1"]`},
+		{"range IPv6", IPRange, []types.Value{str("2001:db8::ffff"), str("2001:db8::1:0")}, `["2001:db8::ffff", "2001:db8::1:0"]`},
+		{"range backwards", IPRange, []types.Value{str("192.0.2.2"), str("192.0.2.1")}, ``},
+		{"range mixed", IPRange, []types.Value{str("192.0.2.1"), str("2001:db8::1")}, ``},
+		{"range too many", IPRange, []types.Value{str("10.0.0.0"), str("10.1.0.0")}, ``},
+	})
+}
@@ -0,0 +1,440 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package corenet
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/funcs/templatepoly"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// HTTPGetFuncName is the name this function is registered as.
+	HTTPGetFuncName = "http_get"
+
+	// DefaultHTTPInterval is the number of seconds to wait between each
+	// request if none has been specified.
+	DefaultHTTPInterval = 60
+
+	// DefaultHTTPTimeout is the number of seconds that each request may
+	// take if none has been specified.
+	DefaultHTTPTimeout = 30
+
+	// DefaultHTTPMaxSize is the largest body in bytes that we accept if
+	// none has been specified.
+	DefaultHTTPMaxSize = 1024 * 1024
+
+	// HTTPMinBackoff is how long we wait before we retry after the first
+	// error. It doubles after each consecutive error.
+	HTTPMinBackoff = 1 * time.Second
+
+	// HTTPMaxBackoff is the longest that we wait before we retry after an
+	// error.
+	HTTPMaxBackoff = 5 * time.Minute
+
+	// arg names...
+	httpGetArgNameOpts = "opts"
+)
+
+// httpGetOutType is the type of the values that the http_get function produces.
+var httpGetOutType = types.NewType("struct{status int; headers map{str: str}; body str; error str}")
+
+func init() {
+	funcs.ModuleRegister(ModuleName, HTTPGetFuncName, func() interfaces.Func {
+		tmpl := templatepoly.NewTemplate(fmt.Sprintf("func(%s ?T) %s", httpGetArgNameOpts, httpGetOutType))
+		tmpl.Check = func(vars map[string]*types.Type) error {
+			return templatepoly.CheckOpts(vars["?T"], httpGetValidOpts(), "url")
+		}
+		return &HTTPGetFunc{
+			Template: tmpl,
+		}
+	})
+}
+
+// httpGetValidOpts returns the available mapping of valid opts fields to types.
+func httpGetValidOpts() map[string]*types.Type {
+	return map[string]*types.Type{
+		"url":      types.TypeStr,
+		"headers":  types.NewType("map{str: str}"),
+		"interval": types.TypeInt,
+		"timeout":  types.TypeInt,
+		"max_size": types.TypeInt,
+	}
+}
+
+var _ interfaces.PolyFunc = &HTTPGetFunc{} // ensure it meets this expectation
+
+// HTTPGetFunc polls a URL with GET requests, and produces a struct with the
+// status code, headers and body of the response. It takes a single opts struct
+// which must contain a `url` field, and may contain any of these: `headers` is
+// a map of request headers to send, `interval` is the number of seconds between
+// requests, `timeout` is the number of seconds that each one may take, and
+// `max_size` is the largest body in bytes that we accept. Repeated headers in
+// the response are joined with commas.
+//
+// It remembers the ETag and Last-Modified headers of the response, and sends
+// them back so that the server can tell us that nothing has changed. A new value
+// is only produced when the response changes. If a request fails, or if the
+// server returns a 5xx status, then it produces the last good response with the
+// `error` field set, and retries with an exponential backoff. Once a request
+// succeeds again, it goes back to polling at the normal interval. Errors never
+// shut down the function graph.
+type HTTPGetFunc struct {
+	*templatepoly.Template // provides ArgGen, Unify, Build and Validate
+
+	// Client is the http client to use. If it is nil, then a default one
+	// is used. This is mostly useful for testing.
+	Client *http.Client
+
+	init   *interfaces.Init
+	cancel context.CancelFunc // kills the current poller
+
+	last types.Value // last input
+}
+
+// String returns a simple name for this function. This is needed so this struct
+// can satisfy the pgraph.Vertex interface.
+func (obj *HTTPGetFunc) String() string {
+	return HTTPGetFuncName
+}
+
+// Info returns some static info about itself. Build must be called before this
+// will return correct data.
+func (obj *HTTPGetFunc) Info() *interfaces.Info {
+	return &interfaces.Info{
+		Pure: false, // definitely false
+		Memo: false,
+		Sig:  obj.Type(),
+		Err:  obj.Validate(),
+	}
+}
+
+// Init runs some startup code for this function.
+func (obj *HTTPGetFunc) Init(init *interfaces.Init) error {
+	obj.init = init
+	if obj.Client == nil {
+		obj.Client = &http.Client{}
+	}
+	return nil
+}
+
+// Stream returns the changing values that this func has over time.
+func (obj *HTTPGetFunc) Stream(ctx context.Context) error {
+	wg := &sync.WaitGroup{}
+	defer close(obj.init.Output) // the sender closes, after the poller exits
+	defer wg.Wait()
+
+	// Kill the current poller, if any. A new cancel function is created
+	// each time a new one is started. We never have more than one.
+	defer func() {
+		if obj.cancel == nil {
+			return
+		}
+		obj.cancel()
+	}()
+
+	for {
+		select {
+		case input, ok := <-obj.init.Input:
+			if !ok {
+				// Keep polling with the last opts.
+				obj.init.Input = nil // don't infinite loop back
+				continue
+			}
+
+			if obj.last != nil && input.Cmp(obj.last) == nil {
+				continue // value didn't change, skip it
+			}
+			obj.last = input // store for next
+
+			opts, err := httpGetOptsFromValue(input.Struct()[httpGetArgNameOpts])
+			if err != nil {
+				return err
+			}
+			if obj.init.Debug {
+				obj.init.Logf("url: %s", opts.URL)
+			}
+
+			if obj.cancel != nil {
+				obj.cancel() // stop the previous poller
+			}
+			wg.Wait()
+
+			var pollCtx context.Context
+			pollCtx, obj.cancel = context.WithCancel(ctx)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				obj.poller(pollCtx, opts)
+			}()
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// poller makes a request each interval, and sends the results that differ from
+// the previous ones until the context closes.
+func (obj *HTTPGetFunc) poller(ctx context.Context, opts *httpGetOpts) {
+	var good *httpResult // last good response
+	var sent string      // last value that was sent
+	var etag, lastModified string
+	backoff := time.Duration(0)
+
+	for {
+		result, err := obj.fetch(ctx, opts, etag, lastModified)
+		if ctx.Err() != nil { // we were cancelled, not the request
+			return
+		}
+
+		wait := opts.Interval
+		if err != nil {
+			if backoff == 0 {
+				backoff = HTTPMinBackoff
+			} else if backoff *= 2; backoff > HTTPMaxBackoff {
+				backoff = HTTPMaxBackoff
+			}
+			wait = backoff
+			if obj.init.Debug {
+				obj.init.Logf("request failed, retrying in %s: %v", wait, err)
+			}
+
+			failed := &httpResult{
+				Headers: map[string]string{},
+				Error:   err.Error(),
+			}
+			if good != nil {
+				failed.Status = good.Status
+				failed.Headers = good.Headers
+				failed.Body = good.Body
+			}
+			result = failed
+
+		} else {
+			backoff = 0
+			if result == nil { // not modified
+				result = good
+			} else {
+				good = result
+				etag = result.etag
+				lastModified = result.lastModified
+			}
+		}
+
+		value, err := result.Value()
+		if err != nil { // programming error
+			obj.init.Logf("could not build the result: %+v", err)
+			return
+		}
+		if s := value.String(); s != sent { // maps cmp by pointer
+			select {
+			case obj.init.Output <- value:
+				sent = s
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// fetch makes one request. It returns a nil result if the server says that it
+// has not been modified since last time. A 5xx status is an error.
+func (obj *HTTPGetFunc) fetch(ctx context.Context, opts *httpGetOpts, etag, lastModified string) (*httpResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, opts.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range opts.Headers {
+		req.Header.Set(k, v)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := obj.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		if etag == "" && lastModified == "" {
+			return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+		}
+		return nil, nil
+	}
+	if resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("server error: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, opts.MaxSize+1))
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "error reading body")
+	}
+	if int64(len(body)) > opts.MaxSize {
+		return nil, fmt.Errorf("body exceeds the max size of %d bytes", opts.MaxSize)
+	}
+
+	headers := make(map[string]string)
+	for k, v := range resp.Header {
+		headers[k] = strings.Join(v, ", ")
+	}
+
+	return &httpResult{
+		Status:       resp.StatusCode,
+		Headers:      headers,
+		Body:         string(body),
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// httpGetOpts is the parsed form of the opts struct.
+type httpGetOpts struct {
+	URL      string
+	Headers  map[string]string
+	Interval time.Duration
+	Timeout  time.Duration
+	MaxSize  int64
+}
+
+// httpGetOptsFromValue parses the opts struct. The fields which are missing get
+// their default values.
+func httpGetOptsFromValue(value types.Value) (*httpGetOpts, error) {
+	opts := &httpGetOpts{
+		Headers:  make(map[string]string),
+		Interval: DefaultHTTPInterval * time.Second,
+		Timeout:  DefaultHTTPTimeout * time.Second,
+		MaxSize:  DefaultHTTPMaxSize,
+	}
+	fields := value.Struct()
+
+	if v, exists := fields["url"]; exists {
+		opts.URL = v.Str()
+	}
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "invalid url: `%s`", opts.URL)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("the url must be http or https, got: `%s`", opts.URL)
+	}
+	if v, exists := fields["headers"]; exists {
+		for k, x := range v.Map() {
+			opts.Headers[k.Str()] = x.Str()
+		}
+	}
+	if v, exists := fields["interval"]; exists {
+		if v.Int() <= 0 {
+			return nil, fmt.Errorf("the interval must be positive")
+		}
+		opts.Interval = time.Duration(v.Int()) * time.Second
+	}
+	if v, exists := fields["timeout"]; exists {
+		if v.Int() <= 0 {
+			return nil, fmt.Errorf("the timeout must be positive")
+		}
+		opts.Timeout = time.Duration(v.Int()) * time.Second
+	}
+	if v, exists := fields["max_size"]; exists {
+		if v.Int() <= 0 {
+			return nil, fmt.Errorf("the max_size must be positive")
+		}
+		opts.MaxSize = v.Int()
+	}
+
+	return opts, nil
+}
+
+// httpResult is what we know about a response, or about the last good one if
+// the request failed.
+type httpResult struct {
+	Status  int
+	Headers map[string]string
+	Body    string
+	Error   string
+
+	etag         string
+	lastModified string
+}
+
+// Value returns the result as a value of the output type.
+func (obj *httpResult) Value() (types.Value, error) {
+	headers := types.NewMap(httpGetOutType.Map["headers"])
+	keys := []string{}
+	for k := range obj.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // deterministic
+	for _, k := range keys {
+		if err := headers.Add(&types.StrValue{V: k}, &types.StrValue{V: obj.Headers[k]}); err != nil {
+			return nil, errwrap.Wrapf(err, "map could not add key `%s`", k)
+		}
+	}
+
+	st := types.NewStruct(httpGetOutType)
+	fields := map[string]types.Value{
+		"status":  &types.IntValue{V: int64(obj.Status)},
+		"headers": headers,
+		"body":    &types.StrValue{V: obj.Body},
+		"error":   &types.StrValue{V: obj.Error},
+	}
+	for k, v := range fields {
+		if err := st.Set(k, v); err != nil {
+			return nil, errwrap.Wrapf(err, "struct could not set field `%s`", k)
+		}
+	}
+	return st, nil
+}
@@ -0,0 +1,211 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package corenet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+// startHTTPGet builds and starts an http_get function with a url and a one
+// second interval. It returns the output channel, and a function which shuts it
+// down and returns how long that took.
+func startHTTPGet(t *testing.T, url string) (chan types.Value, func() time.Duration) {
+	handle, err := funcs.Lookup(ModuleName + funcs.ModuleSep + HTTPGetFuncName)
+	if err != nil {
+		t.Fatalf("func lookup failed with: %+v", err)
+	}
+	optsType := types.NewType("struct{url str; interval int}")
+	sig := types.NewType(fmt.Sprintf("func(%s %s) %s", httpGetArgNameOpts, optsType, httpGetOutType))
+	if _, err := handle.(interfaces.PolyFunc).Build(sig); err != nil {
+		t.Fatalf("build failed with: %+v", err)
+	}
+
+	input := make(chan types.Value)
+	output := make(chan types.Value)
+	init := &interfaces.Init{
+		Input:  input,
+		Output: output,
+		Debug:  testing.Verbose(),
+		Logf:   t.Logf,
+	}
+	if err := handle.Init(init); err != nil {
+		t.Fatalf("init failed with: %+v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := handle.Stream(ctx); err != nil {
+			t.Errorf("stream failed with: %+v", err)
+		}
+	}()
+
+	opts := types.NewStruct(optsType)
+	opts.Set("url", &types.StrValue{V: url})
+	opts.Set("interval", &types.IntValue{V: 1})
+	args := types.NewStruct(types.NewType(fmt.Sprintf("struct{%s %s}", httpGetArgNameOpts, optsType)))
+	args.Set(httpGetArgNameOpts, opts)
+	input <- args
+
+	shutdown := func() time.Duration {
+		start := time.Now()
+		cancel()
+		for range output { // drain until it closes
+		}
+		wg.Wait()
+		return time.Since(start)
+	}
+	return output, shutdown
+}
+
+// recv waits for the next value, or returns nil if there isn't one in time.
+func recv(output chan types.Value, timeout time.Duration) map[string]types.Value {
+	select {
+	case value := <-output:
+		return value.Struct()
+	case <-time.After(timeout):
+		return nil
+	}
+}
+
+func TestHTTPGet0(t *testing.T) {
+	mutex := &sync.Mutex{}
+	body, etag := "hello", `"v1"`
+	conditional := 0 // number of requests with our etag
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if r.Header.Get("If-None-Match") == etag {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("X-Test", "yes")
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	output, shutdown := startHTTPGet(t, server.URL)
+	defer shutdown()
+
+	st := recv(output, 5*time.Second)
+	if st == nil {
+		t.Fatalf("no value was produced")
+	}
+	if st["status"].Int() != 200 || st["body"].Str() != "hello" || st["error"].Str() != "" {
+		t.Errorf("unexpected first value: %+v", st)
+	}
+	if v, exists := st["headers"].(*types.MapValue).Lookup(&types.StrValue{V: "X-Test"}); !exists || v.Str() != "yes" {
+		t.Errorf("missing response header: %+v", st["headers"])
+	}
+
+	// the next poll is not modified, so there is no new value
+	if st := recv(output, 2500*time.Millisecond); st != nil {
+		t.Errorf("unexpected value when not modified: %+v", st)
+	}
+	mutex.Lock()
+	if conditional == 0 {
+		t.Errorf("the etag was not sent back")
+	}
+	body, etag = "world", `"v2"`
+	mutex.Unlock()
+
+	st = recv(output, 5*time.Second)
+	if st == nil || st["body"].Str() != "world" {
+		t.Errorf("expected the changed body, got: %+v", st)
+	}
+}
+
+func TestHTTPGet1(t *testing.T) {
+	mutex := &sync.Mutex{}
+	status := http.StatusOK
+	block := make(chan struct{})
+	blocking := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		s, b := status, blocking
+		mutex.Unlock()
+		if b {
+			select { // hang until the client goes away
+			case <-r.Context().Done():
+			case <-block:
+			}
+			return
+		}
+		w.WriteHeader(s)
+		fmt.Fprint(w, "data")
+	}))
+	defer server.Close()
+	defer close(block)
+
+	output, shutdown := startHTTPGet(t, server.URL)
+
+	if st := recv(output, 5*time.Second); st == nil || st["body"].Str() != "data" {
+		t.Fatalf("unexpected first value: %+v", st)
+	}
+
+	// a server error keeps the last good response
+	mutex.Lock()
+	status = http.StatusServiceUnavailable
+	mutex.Unlock()
+	st := recv(output, 5*time.Second)
+	if st == nil || st["error"].Str() == "" {
+		t.Fatalf("expected an error, got: %+v", st)
+	}
+	if st["status"].Int() != 200 || st["body"].Str() != "data" {
+		t.Errorf("expected the last good response, got: %+v", st)
+	}
+
+	// shutdown doesn't wait for a request which hangs
+	mutex.Lock()
+	blocking = true
+	mutex.Unlock()
+	time.Sleep(3 * time.Second) // let a request start
+	if d := shutdown(); d > 2*time.Second {
+		t.Errorf("shutdown took too long: %s", d)
+	}
+}
@@ -0,0 +1,224 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !darwin
+
+package corenet
+
+import (
+	"context"
+	"net"
+	"sort"
+
+	"github.com/purpleidea/mgmt/lang/funcs/facts"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// InterfacesFuncName is the name this fact is registered as. It's still
+	// a Func Name because this is the name space the fact is actually using.
+	InterfacesFuncName = "interfaces"
+
+	interfacesSignature = "map{str: struct{index int; mac str; mtu int; up bool; state str; addrs []str}}"
+)
+
+func init() {
+	facts.ModuleRegister(ModuleName, InterfacesFuncName, func() facts.Fact { return &InterfacesFact{} }) // must register the fact and name
+}
+
+// InterfacesFact is a fact which returns the network interfaces, keyed by their
+// name. Each one contains the mac address, mtu, administrative up flag, the
+// operational link state (eg: `up`, `down`, `dormant`) and a sorted list of the
+// addresses in cidr notation. It receives events from the kernel whenever a
+// link or an address changes.
+type InterfacesFact struct {
+	init *facts.Init
+}
+
+// String returns a simple name for this fact. This is needed so this struct can
+// satisfy the pgraph.Vertex interface.
+func (obj *InterfacesFact) String() string {
+	return InterfacesFuncName
+}
+
+// Info returns some static info about itself.
+func (obj *InterfacesFact) Info() *facts.Info {
+	return &facts.Info{
+		Output: types.NewType(interfacesSignature),
+	}
+}
+
+// Init runs some startup code for this fact.
+func (obj *InterfacesFact) Init(init *facts.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the changing values that this fact has over time.
+func (obj *InterfacesFact) Stream(ctx context.Context) error {
+	defer close(obj.init.Output) // always signal when we're done
+
+	errChan := make(chan error, 1)
+	cberr := func(err error) {
+		select {
+		case errChan <- err:
+		default: // we only need the first one
+		}
+	}
+	done := make(chan struct{})
+	linkChan := make(chan netlink.LinkUpdate)
+	addrChan := make(chan netlink.AddrUpdate)
+
+	if err := netlink.LinkSubscribeWithOptions(linkChan, done, netlink.LinkSubscribeOptions{ErrorCallback: cberr}); err != nil {
+		return errwrap.Wrapf(err, "could not subscribe to link updates")
+	}
+	if err := netlink.AddrSubscribeWithOptions(addrChan, done, netlink.AddrSubscribeOptions{ErrorCallback: cberr}); err != nil {
+		close(done)
+		go func() {
+			for range linkChan { // drain until it shuts down
+			}
+		}()
+		return errwrap.Wrapf(err, "could not subscribe to address updates")
+	}
+	defer func() {
+		close(done)
+		// The subscriptions only notice that they're done when they
+		// next receive a message, so drain them in the background so
+		// that they never block sending to us.
+		go func() {
+			for range linkChan {
+			}
+		}()
+		go func() {
+			for range addrChan {
+			}
+		}()
+	}()
+
+	// streams must generate an initial event on startup
+	startChan := make(chan struct{}) // start signal
+	close(startChan)                 // kick it off!
+	var last types.Value
+	for {
+		select {
+		case <-startChan: // kick the loop once at start
+			startChan = nil // disable
+
+		case update, ok := <-linkChan:
+			if !ok {
+				return nil
+			}
+			if obj.init.Debug {
+				obj.init.Logf("link update: %s", update.Link.Attrs().Name)
+			}
+
+		case update, ok := <-addrChan:
+			if !ok {
+				return nil
+			}
+			if obj.init.Debug {
+				obj.init.Logf("address update: %s", update.LinkAddress.String())
+			}
+
+		case err := <-errChan:
+			return errwrap.Wrapf(err, "error receiving netlink update")
+
+		case <-ctx.Done():
+			return nil
+		}
+
+		m, err := readInterfaces()
+		if err != nil {
+			return errwrap.Wrapf(err, "could not list interfaces")
+		}
+		if last != nil && m.String() == last.String() { // maps cmp by pointer
+			continue // nothing changed
+		}
+		last = m
+
+		select {
+		case obj.init.Output <- m:
+			// send
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// readInterfaces builds the current map of interfaces from the kernel.
+func readInterfaces() (*types.MapValue, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := netlink.AddrList(nil, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, err
+	}
+	byIndex := make(map[int][]string) // link index -> cidr addresses
+	for _, addr := range addrs {
+		byIndex[addr.LinkIndex] = append(byIndex[addr.LinkIndex], addr.IPNet.String())
+	}
+
+	typ := types.NewType(interfacesSignature)
+	m := types.NewMap(typ)
+	for _, link := range links {
+		attrs := link.Attrs()
+
+		cidrs := byIndex[attrs.Index]
+		sort.Strings(cidrs)
+		list := types.NewList(typ.Val.Map["addrs"])
+		for _, x := range cidrs {
+			if err := list.Add(&types.StrValue{V: x}); err != nil {
+				return nil, err
+			}
+		}
+
+		st := types.NewStruct(typ.Val)
+		for k, v := range map[string]types.Value{
+			"index": &types.IntValue{V: int64(attrs.Index)},
+			"mac":   &types.StrValue{V: attrs.HardwareAddr.String()},
+			"mtu":   &types.IntValue{V: int64(attrs.MTU)},
+			"up":    &types.BoolValue{V: attrs.Flags&net.FlagUp != 0},
+			"state": &types.StrValue{V: attrs.OperState.String()},
+			"addrs": list,
+		} {
+			if err := st.Set(k, v); err != nil {
+				return nil, err
+			}
+		}
+		if err := m.Add(&types.StrValue{V: attrs.Name}, st); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,86 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package corenet
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/purpleidea/mgmt/lang/funcs/simple"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func init() {
+	simple.ModuleRegister(ModuleName, "ipv6_expand", &types.FuncValue{
+		T: types.NewType("func(ip str) str"),
+		V: IPv6Expand,
+	})
+	simple.ModuleRegister(ModuleName, "ipv6_compress", &types.FuncValue{
+		T: types.NewType("func(ip str) str"),
+		V: IPv6Compress,
+	})
+}
+
+// IPv6Expand returns the full form of an IPv6 address, with all eight groups of
+// four hex digits. For example, 2001:db8::1 is expanded to
+// 2001:0db8:0000:0000:0000:0000:0000:0001.
+func IPv6Expand(input []types.Value) (types.Value, error) {
+	addr, err := parseIPv6(input[0].Str())
+	if err != nil {
+		return nil, err
+	}
+	return &types.StrValue{
+		V: addr.StringExpanded(),
+	}, nil
+}
+
+// IPv6Compress returns the canonical short form of an IPv6 address as defined
+// in RFC 5952. For example, 2001:0DB8:0:0:0:0:0:0001 is compressed to
+// 2001:db8::1.
+func IPv6Compress(input []types.Value) (types.Value, error) {
+	addr, err := parseIPv6(input[0].Str())
+	if err != nil {
+		return nil, err
+	}
+	return &types.StrValue{
+		V: addr.String(),
+	}, nil
+}
+
+// parseIPv6 parses an address which must be IPv6. Unlike with parseAddr, an
+// IPv4 address which is mapped into IPv6 stays that way.
+func parseIPv6(s string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil || !addr.Is6() {
+		return netip.Addr{}, fmt.Errorf("invalid IPv6 address: %s", s)
+	}
+	return addr, nil
+}
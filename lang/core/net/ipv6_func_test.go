@@ -0,0 +1,54 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package corenet
+
+import (
+	"testing"
+
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func TestIPv6Funcs(t *testing.T) {
+	runCalcTests(t, []calcTest{
+		{"expand", IPv6Expand, []types.Value{str("2001:db8::1")}, `"2001:0db8:0000:0000:0000:0000:0000:0001"`},
+		{"expand any", IPv6Expand, []types.Value{str("::")}, `"0000:0000:0000:0000:0000:0000:0000:0000"`},
+		{"expand mapped", IPv6Expand, []types.Value{str("::ffff:192.0.2.1")}, `"0000:0000:0000:0000:0000:ffff:c000:0201"`},
+		{"expand IPv4", IPv6Expand, []types.Value{str("192.0.2.1")}, ``},
+		{"expand bad", IPv6Expand, []types.Value{str("2001:db8:::1")}, ``},
+
+		{"compress", IPv6Compress, []types.Value{str("2001:0DB8:0:0:0:0:0:0001")}, `"2001:db8::1"`},
+		{"compress longest run", IPv6Compress, []types.Value{str("2001:db8:0:0:1:0:0:0")}, `"2001:db8:0:0:1::"`},
+		{"compress single zero", IPv6Compress, []types.Value{str("2001:db8:0:1:1:1:1:1")}, `"2001:db8:0:1:1:1:1:1"`},
+		{"compress mapped", IPv6Compress, []types.Value{str("0:0:0:0:0:ffff:c000:0201")}, `"::ffff:192.0.2.1"`},
+		{"compress IPv4", IPv6Compress, []types.Value{str("192.0.2.1")}, ``},
+	})
+}
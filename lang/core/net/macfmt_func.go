@@ -34,6 +34,7 @@ import (
 	"net"
 	"strings"
 
+	"github.com/purpleidea/mgmt/lang/funcs"
 	"github.com/purpleidea/mgmt/lang/funcs/simple"
 	"github.com/purpleidea/mgmt/lang/types"
 )
@@ -47,6 +48,7 @@ func init() {
 		T: types.NewType("func(a str) str"),
 		V: OldMacFmt,
 	})
+	funcs.ModuleDeprecate(ModuleName, "oldmacfmt", "the hyphen style of mac address is deprecated, use the colon style from macfmt instead")
 }
 
 // MacFmt takes a MAC address with hyphens and converts it to a format with
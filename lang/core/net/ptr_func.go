@@ -0,0 +1,97 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package corenet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/purpleidea/mgmt/lang/funcs/simple"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func init() {
+	simple.ModuleRegister(ModuleName, "ptr_name", &types.FuncValue{
+		T: types.NewType("func(a str) str"),
+		V: PtrName,
+	})
+}
+
+// PtrName returns the reverse DNS name that is used to look up the name of an
+// address with a PTR record. For example, it is 42.2.0.192.in-addr.arpa. for
+// 192.0.2.42, and it is in the ip6.arpa. zone for IPv6. If it is given a cidr
+// instead, then it returns the name of the reverse zone for that network, such
+// as 2.0.192.in-addr.arpa. for 192.0.2.0/24. The prefix length of the cidr must
+// be a multiple of 8 for IPv4 or of 4 for IPv6, since the name has one label for
+// each byte or hex digit. The names are fully qualified, so they end in a dot.
+func PtrName(input []types.Value) (types.Value, error) {
+	s := input[0].Str()
+	var b []byte
+	bits := 0
+	if strings.Contains(s, "/") {
+		prefix, err := parsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		b, bits = prefix.Addr().AsSlice(), prefix.Bits()
+	} else {
+		addr, err := parseAddr(s)
+		if err != nil {
+			return nil, err
+		}
+		b, bits = addr.AsSlice(), addr.BitLen()
+	}
+
+	labels := []string{}
+	if len(b) == 4 {
+		if bits%8 != 0 {
+			return nil, fmt.Errorf("the prefix length of an IPv4 zone must be a multiple of 8")
+		}
+		for i := bits/8 - 1; i >= 0; i-- {
+			labels = append(labels, fmt.Sprintf("%d", b[i]))
+		}
+		labels = append(labels, "in-addr.arpa.")
+	} else {
+		if bits%4 != 0 {
+			return nil, fmt.Errorf("the prefix length of an IPv6 zone must be a multiple of 4")
+		}
+		for i := bits/4 - 1; i >= 0; i-- {
+			nibble := b[i/2] >> 4 // the high half comes first
+			if i%2 == 1 {
+				nibble = b[i/2] & 0x0f
+			}
+			labels = append(labels, fmt.Sprintf("%x", nibble))
+		}
+		labels = append(labels, "ip6.arpa.")
+	}
+	return &types.StrValue{
+		V: strings.Join(labels, "."),
+	}, nil
+}
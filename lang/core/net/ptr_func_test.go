@@ -0,0 +1,53 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package corenet
+
+import (
+	"testing"
+
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func TestPtrName(t *testing.T) {
+	runCalcTests(t, []calcTest{
+		{"IPv4", PtrName, []types.Value{str("192.0.2.42")}, `"42.2.0.192.in-addr.arpa."`},
+		{"IPv4 mapped", PtrName, []types.Value{str("::ffff:192.0.2.42")}, `"42.2.0.192.in-addr.arpa."`},
+		{"IPv6", PtrName, []types.Value{str("2001:db8::567:89ab")}, `"b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."`},
+		{"IPv4 zone", PtrName, []types.Value{str("192.0.2.0/24")}, `"2.0.192.in-addr.arpa."`},
+		{"IPv4 zone host bits", PtrName, []types.Value{str("10.1.2.3/8")}, `"10.in-addr.arpa."`},
+		{"IPv4 zone unaligned", PtrName, []types.Value{str("192.0.2.0/25")}, ``},
+		{"IPv6 zone", PtrName, []types.Value{str("2001:db8::/32")}, `"8.b.d.0.1.0.0.2.ip6.arpa."`},
+		{"IPv6 zone nibble", PtrName, []types.Value{str("2001:db8:f000::/36")}, `"f.8.b.d.0.1.0.0.2.ip6.arpa."`},
+		{"IPv6 zone unaligned", PtrName, []types.Value{str("2001:db8::/33")}, ``},
+		{"bad", PtrName, []types.Value{str("example.com")}, ``},
+	})
+}
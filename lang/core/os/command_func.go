@@ -0,0 +1,523 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/funcs/templatepoly"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// CommandFuncName is the name this function is registered as.
+	CommandFuncName = "command"
+
+	// CommandModeOneshot runs the command once each time the opts change,
+	// and produces a single value when it exits. This is the default.
+	CommandModeOneshot = "oneshot"
+
+	// CommandModeStream runs the command once each time the opts change,
+	// and produces a value for each line of stdout and of stderr as they
+	// happen, followed by the same final value as the oneshot mode.
+	CommandModeStream = "stream"
+
+	// CommandModeInterval runs the command over and over again, waiting
+	// for the interval between each run, and produces a value each time it
+	// exits.
+	CommandModeInterval = "interval"
+
+	// DefaultCommandInterval is the number of seconds to wait between each
+	// run in the interval mode if none has been specified.
+	DefaultCommandInterval = 60
+
+	// arg names...
+	commandArgNameOpts = "opts"
+)
+
+// commandOutType is the type of the values that the command function produces.
+var commandOutType = types.NewType("struct{stdout str; stderr str; exit_code int; duration float; error str; done bool}")
+
+func init() {
+	funcs.ModuleRegister(ModuleName, CommandFuncName, func() interfaces.Func {
+		tmpl := templatepoly.NewTemplate(fmt.Sprintf("func(%s ?T) %s", commandArgNameOpts, commandOutType))
+		tmpl.Check = func(vars map[string]*types.Type) error {
+			return checkCommandOpts(vars["?T"])
+		}
+		return &CommandFunc{
+			Template: tmpl,
+		}
+	})
+}
+
+// commandValidOpts returns the available mapping of valid opts fields to types.
+func commandValidOpts() map[string]*types.Type {
+	return map[string]*types.Type{
+		"cmd":      types.TypeStr,
+		"args":     types.NewType("[]str"),
+		"env":      types.NewType("map{str: str}"),
+		"cwd":      types.TypeStr,
+		"timeout":  types.TypeInt,
+		"mode":     types.TypeStr,
+		"interval": types.TypeInt,
+	}
+}
+
+// checkCommandOpts makes sure that the opts struct only has fields which we
+// know about, that they each have the right type, and that cmd is present.
+func checkCommandOpts(typ *types.Type) error {
+	return templatepoly.CheckOpts(typ, commandValidOpts(), "cmd")
+}
+
+var _ interfaces.PolyFunc = &CommandFunc{} // ensure it meets this expectation
+
+// CommandFunc runs a command and produces a struct with what it wrote to stdout
+// and stderr, its exit code, how many seconds it ran for, and an error string.
+// It is a more structured version of the system function. It takes a single
+// opts struct which must contain a `cmd` field, and may contain any of these:
+// `args` is the list of args to pass to it, `env` is a map of variables to add
+// to the environment of mgmt, `cwd` is the directory to run it in, `timeout` is
+// the number of seconds after which it gets killed (zero means never), `mode`
+// is one of "oneshot", "stream" or "interval", and `interval` is the number of
+// seconds between runs in the interval mode. The command is not run by a shell,
+// so if you need one, then use "sh" as the cmd and pass "-c" in the args.
+//
+// Problems running the command do not error the function graph. Instead, they
+// are found in the `error` field, and `exit_code` is -1 if the command did not
+// exit on its own. A non-zero exit also sets the `error` field. The `done` field
+// is only false for the per-line values that the stream mode produces, which
+// only contain the one line in either `stdout` or `stderr`.
+//
+// Note that in the likely case in which the stream mode emits several values
+// one after the other, the downstream resources might not run for every value
+// unless the "Meta:realize" metaparam is set to true.
+type CommandFunc struct {
+	*templatepoly.Template // provides ArgGen, Unify, Build and Validate
+
+	init   *interfaces.Init
+	cancel context.CancelFunc // kills the current runner
+
+	last types.Value // last input
+}
+
+// String returns a simple name for this function. This is needed so this struct
+// can satisfy the pgraph.Vertex interface.
+func (obj *CommandFunc) String() string {
+	return CommandFuncName
+}
+
+// Info returns some static info about itself. Build must be called before this
+// will return correct data.
+func (obj *CommandFunc) Info() *interfaces.Info {
+	return &interfaces.Info{
+		Pure: false, // definitely false
+		Memo: false,
+		Sig:  obj.Type(),
+		Err:  obj.Validate(),
+	}
+}
+
+// Init runs some startup code for this function.
+func (obj *CommandFunc) Init(init *interfaces.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the changing values that this func has over time.
+func (obj *CommandFunc) Stream(ctx context.Context) error {
+	wg := &sync.WaitGroup{}
+	defer close(obj.init.Output) // the sender closes, after the runner exits
+	defer wg.Wait()
+
+	// Kill the current runner, if any. A new cancel function is created
+	// each time a new one is started. We never have more than one.
+	defer func() {
+		if obj.cancel == nil {
+			return
+		}
+		obj.cancel()
+	}()
+
+	for {
+		select {
+		case input, ok := <-obj.init.Input:
+			if !ok {
+				// Let the current runner finish on its own.
+				obj.init.Input = nil // don't infinite loop back
+				continue
+			}
+
+			if obj.last != nil && input.Cmp(obj.last) == nil {
+				continue // value didn't change, skip it
+			}
+			obj.last = input // store for next
+
+			opts, err := commandOptsFromValue(input.Struct()[commandArgNameOpts])
+			if err != nil {
+				return err
+			}
+			if obj.init.Debug {
+				obj.init.Logf("command: %s %v (%s)", opts.Cmd, opts.Args, opts.Mode)
+			}
+
+			if obj.cancel != nil {
+				obj.cancel() // stop the previous runner
+			}
+			wg.Wait()
+
+			var runCtx context.Context
+			runCtx, obj.cancel = context.WithCancel(ctx)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				obj.runner(runCtx, opts)
+			}()
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// runner runs the command as many times as its mode asks for, and sends the
+// results until the context closes.
+func (obj *CommandFunc) runner(ctx context.Context, opts *commandOpts) {
+	send := func(result *commandResult) bool {
+		value, err := result.Value()
+		if err != nil { // programming error
+			obj.init.Logf("could not build the result: %+v", err)
+			return false
+		}
+		select {
+		case obj.init.Output <- value:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	var lines func(stdout bool, line string) bool
+	if opts.Mode == CommandModeStream {
+		lines = func(stdout bool, line string) bool {
+			result := &commandResult{}
+			if stdout {
+				result.Stdout = line
+			} else {
+				result.Stderr = line
+			}
+			return send(result)
+		}
+	}
+
+	for {
+		result := runCommand(ctx, opts, lines)
+		if ctx.Err() != nil { // we were cancelled, not the command
+			return
+		}
+		if result.Error != "" && obj.init.Debug {
+			obj.init.Logf("command: %s: %s", opts.Cmd, result.Error)
+		}
+		if !send(result) {
+			return
+		}
+		if opts.Mode != CommandModeInterval {
+			return
+		}
+
+		select {
+		case <-time.After(opts.Interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// commandOpts is the parsed form of the opts struct.
+type commandOpts struct {
+	Cmd      string
+	Args     []string
+	Env      []string
+	Cwd      string
+	Timeout  time.Duration // zero means no timeout
+	Mode     string
+	Interval time.Duration
+}
+
+// commandOptsFromValue parses the opts struct. The fields which are missing get
+// their default values.
+func commandOptsFromValue(value types.Value) (*commandOpts, error) {
+	opts := &commandOpts{
+		Mode:     CommandModeOneshot,
+		Interval: DefaultCommandInterval * time.Second,
+	}
+	fields := value.Struct()
+
+	if v, exists := fields["cmd"]; exists {
+		opts.Cmd = v.Str()
+	}
+	if opts.Cmd == "" {
+		return nil, fmt.Errorf("the cmd must not be empty")
+	}
+	if v, exists := fields["args"]; exists {
+		for _, x := range v.List() {
+			opts.Args = append(opts.Args, x.Str())
+		}
+	}
+	if v, exists := fields["env"]; exists {
+		keys := []string{}
+		env := make(map[string]string)
+		for k, x := range v.Map() {
+			keys = append(keys, k.Str())
+			env[k.Str()] = x.Str()
+		}
+		sort.Strings(keys) // deterministic
+		opts.Env = os.Environ()
+		for _, k := range keys {
+			if k == "" || strings.Contains(k, "=") {
+				return nil, fmt.Errorf("invalid env var name: `%s`", k)
+			}
+			opts.Env = append(opts.Env, k+"="+env[k]) // the last one wins
+		}
+	}
+	if v, exists := fields["cwd"]; exists {
+		opts.Cwd = v.Str()
+	}
+	if v, exists := fields["timeout"]; exists {
+		if v.Int() < 0 {
+			return nil, fmt.Errorf("the timeout must not be negative")
+		}
+		opts.Timeout = time.Duration(v.Int()) * time.Second
+	}
+	if v, exists := fields["mode"]; exists && v.Str() != "" {
+		opts.Mode = v.Str()
+	}
+	switch opts.Mode {
+	case CommandModeOneshot, CommandModeStream, CommandModeInterval:
+	default:
+		return nil, fmt.Errorf("unknown mode: `%s`", opts.Mode)
+	}
+	if v, exists := fields["interval"]; exists {
+		if v.Int() <= 0 {
+			return nil, fmt.Errorf("the interval must be positive")
+		}
+		opts.Interval = time.Duration(v.Int()) * time.Second
+	}
+
+	return opts, nil
+}
+
+// commandResult is what we know about one run of a command, or about one line
+// of its output in the stream mode.
+type commandResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Duration time.Duration
+	Error    string
+	Done     bool
+}
+
+// Value returns the result as a value of the output type.
+func (obj *commandResult) Value() (types.Value, error) {
+	st := types.NewStruct(commandOutType)
+	fields := map[string]types.Value{
+		"stdout":    &types.StrValue{V: obj.Stdout},
+		"stderr":    &types.StrValue{V: obj.Stderr},
+		"exit_code": &types.IntValue{V: int64(obj.ExitCode)},
+		"duration":  &types.FloatValue{V: obj.Duration.Seconds()},
+		"error":     &types.StrValue{V: obj.Error},
+		"done":      &types.BoolValue{V: obj.Done},
+	}
+	for k, v := range fields {
+		if err := st.Set(k, v); err != nil {
+			return nil, errwrap.Wrapf(err, "struct could not set field `%s`", k)
+		}
+	}
+	return st, nil
+}
+
+// runCommand runs the command once and waits for it to exit. If lines is not
+// nil, then it is called with each line of stdout and of stderr as they are
+// read. If it returns false, then we stop sending lines to it. This never
+// returns nil.
+func runCommand(ctx context.Context, opts *commandOpts, lines func(stdout bool, line string) bool) *commandResult {
+	result := &commandResult{
+		ExitCode: -1,
+		Done:     true,
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, opts.Cmd, opts.Args...)
+	cmd.Env = opts.Env // nil means that of our process
+	cmd.Dir = opts.Cwd
+	// run in our own group, so that a timeout kills any children too
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+		Pgid:    0,
+	}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) // the group
+	}
+	cmd.WaitDelay = time.Second // don't hang if a child keeps the pipes open
+
+	output := &commandOutput{
+		lines: lines,
+	}
+	stdout := &lineWriter{
+		output: output,
+		stdout: true,
+	}
+	stderr := &lineWriter{
+		output: output,
+		stdout: false,
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	err := cmd.Wait() // this waits for the writers too
+	stdout.Flush()
+	stderr.Flush()
+	output.Close() // a child could still write after the WaitDelay
+	result.Duration = time.Since(start)
+
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	if state := cmd.ProcessState; state != nil {
+		result.ExitCode = state.ExitCode() // -1 if it was killed
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.Error = fmt.Sprintf("timed out after %s", opts.Timeout)
+	} else if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// commandOutput is what the stdout and stderr writers of a command share.
+type commandOutput struct {
+	mutex sync.Mutex // only one of them calls lines at a time
+
+	// lines is called with each line of output, until it returns false. It
+	// is nil if nobody is listening.
+	lines func(stdout bool, line string) bool
+}
+
+// Close stops any more lines from being sent.
+func (obj *commandOutput) Close() {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	obj.lines = nil
+}
+
+// lineWriter stores everything that a command writes to stdout or stderr, and
+// passes each line on to the shared lines function as soon as it is complete.
+// Since it isn't a file, exec copies into it, and Wait makes sure that the copy
+// has finished, or gives up after the WaitDelay.
+type lineWriter struct {
+	output *commandOutput
+	stdout bool
+
+	buf     bytes.Buffer // everything that was written
+	partial []byte       // the start of a line that isn't complete yet
+}
+
+// Write stores the data, and sends any lines that it completes.
+func (obj *lineWriter) Write(p []byte) (int, error) {
+	obj.output.mutex.Lock()
+	defer obj.output.mutex.Unlock()
+	obj.buf.Write(p)
+	if obj.output.lines == nil {
+		return len(p), nil
+	}
+	obj.partial = append(obj.partial, p...)
+	for {
+		i := bytes.IndexByte(obj.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(obj.partial[:i])
+		obj.partial = obj.partial[i+1:]
+		obj.send(line)
+	}
+	return len(p), nil
+}
+
+// Flush sends the last line if it didn't end with a newline. It must only be
+// called once the command has exited.
+func (obj *lineWriter) Flush() {
+	obj.output.mutex.Lock()
+	defer obj.output.mutex.Unlock()
+	if len(obj.partial) > 0 {
+		obj.send(string(obj.partial))
+	}
+	obj.partial = nil
+}
+
+// send passes a line on, unless nobody is listening anymore. The mutex must be
+// held when calling this.
+func (obj *lineWriter) send(line string) {
+	if obj.output.lines == nil {
+		return
+	}
+	if !obj.output.lines(obj.stdout, line) {
+		obj.output.lines = nil // nobody is listening anymore
+	}
+}
+
+// String returns everything that was written. It must only be called once the
+// command has exited.
+func (obj *lineWriter) String() string {
+	obj.output.mutex.Lock()
+	defer obj.output.mutex.Unlock()
+	return obj.buf.String()
+}
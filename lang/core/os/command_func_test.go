@@ -0,0 +1,208 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package coreos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func TestCheckCommandOpts0(t *testing.T) {
+	values := []struct {
+		typ string
+		ok  bool
+	}{
+		{"struct{cmd str}", true},
+		{"struct{cmd str; args []str; env map{str: str}; cwd str; timeout int; mode str; interval int}", true},
+		{"struct{args []str}", false},           // cmd is required
+		{"struct{cmd str; timeout str}", false}, // wrong type
+		{"struct{cmd str; shell bool}", false},  // unknown field
+		{"str", false},
+	}
+
+	for i, x := range values {
+		err := checkCommandOpts(types.NewType(x.typ))
+		if x.ok && err != nil {
+			t.Errorf("test index %d failed with: %+v", i, err)
+		}
+		if !x.ok && err == nil {
+			t.Errorf("test index %d expected an error", i)
+		}
+	}
+}
+
+func TestRunCommand0(t *testing.T) {
+	opts := &commandOpts{
+		Cmd:  "sh",
+		Args: []string{"-c", `echo hello; echo "$FOO" >&2; exit 3`},
+		Env:  []string{"FOO=bar"},
+		Mode: CommandModeOneshot,
+	}
+	result := runCommand(context.Background(), opts, nil)
+	if result.Stdout != "hello\n" {
+		t.Errorf("unexpected stdout: %q", result.Stdout)
+	}
+	if result.Stderr != "bar\n" {
+		t.Errorf("unexpected stderr: %q", result.Stderr)
+	}
+	if result.ExitCode != 3 {
+		t.Errorf("unexpected exit code: %d", result.ExitCode)
+	}
+	if result.Error == "" {
+		t.Errorf("expected an error for a non-zero exit")
+	}
+	if !result.Done {
+		t.Errorf("expected the result to be done")
+	}
+}
+
+func TestRunCommand1(t *testing.T) {
+	opts := &commandOpts{
+		Cmd:     "sleep",
+		Args:    []string{"10"},
+		Timeout: 100 * time.Millisecond,
+	}
+	result := runCommand(context.Background(), opts, nil)
+	if result.ExitCode != -1 {
+		t.Errorf("unexpected exit code: %d", result.ExitCode)
+	}
+	if result.Error == "" {
+		t.Errorf("expected a timeout error")
+	}
+	if result.Duration >= 10*time.Second {
+		t.Errorf("the command was not killed")
+	}
+
+	opts = &commandOpts{
+		Cmd: "/this/command/does/not/exist",
+	}
+	result = runCommand(context.Background(), opts, nil)
+	if result.ExitCode != -1 || result.Error == "" {
+		t.Errorf("expected an error, got: %+v", result)
+	}
+}
+
+func TestRunCommand2(t *testing.T) {
+	// the child keeps the pipes open after the shell exits
+	opts := &commandOpts{
+		Cmd:  "sh",
+		Args: []string{"-c", "sleep 5 & echo hi"},
+	}
+	result := runCommand(context.Background(), opts, nil)
+	if result.Stdout != "hi\n" {
+		t.Errorf("unexpected stdout: %q", result.Stdout)
+	}
+	if result.Duration >= 5*time.Second {
+		t.Errorf("waited for the child to close the pipes")
+	}
+
+	// the timeout kills the child too
+	opts = &commandOpts{
+		Cmd:     "sh",
+		Args:    []string{"-c", "sleep 5 & echo hi; sleep 10"},
+		Timeout: time.Second,
+	}
+	lines := []string{}
+	result = runCommand(context.Background(), opts, func(isStdout bool, line string) bool {
+		lines = append(lines, line)
+		return true
+	})
+	if result.Duration >= 5*time.Second {
+		t.Errorf("the timeout didn't stop the command")
+	}
+	if result.Error == "" {
+		t.Errorf("expected a timeout error")
+	}
+	if len(lines) != 1 || lines[0] != "hi" {
+		t.Errorf("unexpected lines: %v", lines)
+	}
+}
+
+func TestRunCommandStream0(t *testing.T) {
+	opts := &commandOpts{
+		Cmd:  "sh",
+		Args: []string{"-c", "echo a; echo b; echo c >&2"},
+		Mode: CommandModeStream,
+	}
+	stdout := []string{}
+	stderr := []string{}
+	lines := func(isStdout bool, line string) bool {
+		if isStdout {
+			stdout = append(stdout, line)
+		} else {
+			stderr = append(stderr, line)
+		}
+		return true
+	}
+	result := runCommand(context.Background(), opts, lines)
+	if len(stdout) != 2 || stdout[0] != "a" || stdout[1] != "b" {
+		t.Errorf("unexpected stdout lines: %v", stdout)
+	}
+	if len(stderr) != 1 || stderr[0] != "c" {
+		t.Errorf("unexpected stderr lines: %v", stderr)
+	}
+	if result.ExitCode != 0 || result.Error != "" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestCommandOptsFromValue0(t *testing.T) {
+	typ := types.NewType("struct{cmd str; env map{str: str}}")
+	st := types.NewStruct(typ)
+	st.Set("cmd", &types.StrValue{V: "true"})
+	env := types.NewMap(types.NewType("map{str: str}"))
+	env.Add(&types.StrValue{V: "A"}, &types.StrValue{V: "1"})
+	st.Set("env", env)
+
+	opts, err := commandOptsFromValue(st)
+	if err != nil {
+		t.Errorf("parsing failed with: %+v", err)
+		return
+	}
+	if opts.Mode != CommandModeOneshot {
+		t.Errorf("unexpected default mode: %s", opts.Mode)
+	}
+	if opts.Timeout != 0 {
+		t.Errorf("unexpected default timeout: %s", opts.Timeout)
+	}
+	if l := len(opts.Env); l == 0 || opts.Env[l-1] != "A=1" {
+		t.Errorf("expected the env var to be added last, got: %v", opts.Env)
+	}
+
+	st.Set("cmd", &types.StrValue{V: ""})
+	if _, err := commandOptsFromValue(st); err == nil {
+		t.Errorf("expected an error for an empty cmd")
+	}
+}
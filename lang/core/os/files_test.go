@@ -0,0 +1,166 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package coreos
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGlobRoot0(t *testing.T) {
+	values := []struct {
+		pattern string
+		root    string
+		recurse bool
+	}{
+		{"/etc/nginx/conf.d/*.conf", "/etc/nginx/conf.d/", false},
+		{"/etc/*/conf.d/*.conf", "/etc/", true},
+		{"/*.conf", "/", false},
+		{"/etc/hosts", "/etc/hosts", false},
+		{"/etc//nginx/", "/etc/nginx", false},
+	}
+
+	for i, x := range values {
+		root, recurse, err := GlobRoot(x.pattern)
+		if err != nil {
+			t.Errorf("test index %d failed with: %+v", i, err)
+			continue
+		}
+		if root != x.root || recurse != x.recurse {
+			t.Errorf("test index %d expected (%s, %t), got (%s, %t)", i, x.root, x.recurse, root, recurse)
+		}
+	}
+
+	if _, _, err := GlobRoot("conf.d/*.conf"); err == nil {
+		t.Errorf("expected an error for a relative pattern")
+	}
+	if _, _, err := GlobRoot("/etc/[*.conf"); err == nil {
+		t.Errorf("expected an error for a bad pattern")
+	}
+}
+
+func TestReadFiles0(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.conf":  "a",
+		"b.conf":  "b",
+		"c.txt":   "c",
+		"d.conf/": "", // a dir which matches
+	} {
+		p := filepath.Join(dir, name)
+		if name[len(name)-1] == '/' {
+			if err := os.Mkdir(p, 0755); err != nil {
+				t.Errorf("mkdir failed: %+v", err)
+				return
+			}
+			continue
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Errorf("write failed: %+v", err)
+			return
+		}
+	}
+
+	files, err := ReadFiles(filepath.Join(dir, "*.conf"))
+	if err != nil {
+		t.Errorf("readfiles failed with: %+v", err)
+		return
+	}
+	m := files.Map()
+	if l := len(m); l != 2 {
+		t.Errorf("expected 2 files, got %d: %s", l, files)
+	}
+
+	list, err := ReadDir(dir)
+	if err != nil {
+		t.Errorf("readdir failed with: %+v", err)
+		return
+	}
+	if s := list.String(); s != `["a.conf", "b.conf", "c.txt", "d.conf/"]` {
+		t.Errorf("unexpected listing: %s", s)
+	}
+
+	list, err = ReadDir(filepath.Join(dir, "missing"))
+	if err != nil {
+		t.Errorf("readdir failed with: %+v", err)
+		return
+	}
+	if l := len(list.List()); l != 0 {
+		t.Errorf("expected a missing dir to be empty, got: %s", list)
+	}
+}
+
+func TestStat0(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "f")
+	if err := os.WriteFile(p, []byte("hello\n"), 0640); err != nil {
+		t.Errorf("write failed: %+v", err)
+		return
+	}
+	if err := os.Chmod(p, 0640); err != nil { // ignore the umask
+		t.Errorf("chmod failed: %+v", err)
+		return
+	}
+
+	value, err := Stat(p)
+	if err != nil {
+		t.Errorf("stat failed with: %+v", err)
+		return
+	}
+	st := value.Struct()
+	if !st["exists"].Bool() || st["is_dir"].Bool() {
+		t.Errorf("unexpected stat: %s", value)
+	}
+	if i := st["size"].Int(); i != 6 {
+		t.Errorf("unexpected size: %d", i)
+	}
+	if s := st["mode"].Str(); s != "0640" {
+		t.Errorf("unexpected mode: %s", s)
+	}
+	if s := st["owner"].Str(); s == "" {
+		t.Errorf("expected an owner")
+	}
+	// echo hello | sha256sum
+	if s := st["sha256"].Str(); s != "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03" {
+		t.Errorf("unexpected sha256: %s", s)
+	}
+
+	value, err = Stat(filepath.Join(dir, "missing"))
+	if err != nil {
+		t.Errorf("stat failed with: %+v", err)
+		return
+	}
+	if st := value.Struct(); st["exists"].Bool() || st["mode"].Str() != "" {
+		t.Errorf("unexpected stat of a missing file: %s", value)
+	}
+}
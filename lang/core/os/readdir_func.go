@@ -0,0 +1,144 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreos
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// ReadDirFuncName is the name this function is registered as.
+	ReadDirFuncName = "readdir"
+
+	// arg names...
+	readDirArgNameDirname = "dirname"
+)
+
+func init() {
+	funcs.ModuleRegister(ModuleName, ReadDirFuncName, func() interfaces.Func { return &ReadDirFunc{} }) // must register the func and name
+}
+
+// ReadDirFunc is a function that lists the contents of a local directory. It
+// produces the sorted names of the entries, and those which are directories
+// have a trailing slash. A directory which doesn't exist is empty. If anything
+// is added to or removed from it, or if the dirname changes, then a new list
+// will be sent.
+type ReadDirFunc struct {
+	init *interfaces.Init
+}
+
+// String returns a simple name for this function. This is needed so this struct
+// can satisfy the pgraph.Vertex interface.
+func (obj *ReadDirFunc) String() string {
+	return ReadDirFuncName
+}
+
+// ArgGen returns the Nth arg name for this function.
+func (obj *ReadDirFunc) ArgGen(index int) (string, error) {
+	seq := []string{readDirArgNameDirname}
+	if l := len(seq); index >= l {
+		return "", fmt.Errorf("index %d exceeds arg length of %d", index, l)
+	}
+	return seq[index], nil
+}
+
+// Validate makes sure we've built our struct properly. It is usually unused for
+// normal functions that users can use directly.
+func (obj *ReadDirFunc) Validate() error {
+	return nil
+}
+
+// Info returns some static info about itself.
+func (obj *ReadDirFunc) Info() *interfaces.Info {
+	return &interfaces.Info{
+		Pure: false, // the directory contents can change
+		Memo: false,
+		Sig:  types.NewType(fmt.Sprintf("func(%s str) []str", readDirArgNameDirname)),
+	}
+}
+
+// Init runs some startup code for this function.
+func (obj *ReadDirFunc) Init(init *interfaces.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the changing values that this func has over time.
+func (obj *ReadDirFunc) Stream(ctx context.Context) error {
+	watch := func(dirname string) (string, bool, error) {
+		if !filepath.IsAbs(dirname) {
+			return "", false, fmt.Errorf("the dirname must be absolute, got: `%s`", dirname)
+		}
+		p, err := dirPath(dirname)
+		return p, false, err
+	}
+	return watchStream(ctx, obj.init, readDirArgNameDirname, watch, ReadDir)
+}
+
+// ReadDir returns the sorted list of entries in a directory, with a trailing
+// slash on those which are directories. A directory which doesn't exist is
+// empty.
+func ReadDir(dirname string) (types.Value, error) {
+	result := types.NewList(types.NewType("[]str"))
+
+	entries, err := os.ReadDir(dirname)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "error reading dir")
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names) // os.ReadDir sorts, but our slashes might not
+
+	for _, name := range names {
+		if err := result.Add(&types.StrValue{V: name}); err != nil {
+			return nil, errwrap.Wrapf(err, "list could not add val: `%s`", name)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,167 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreos
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// ReadFilesFuncName is the name this function is registered as.
+	ReadFilesFuncName = "readfiles"
+
+	// arg names...
+	readFilesArgNamePattern = "pattern"
+)
+
+func init() {
+	funcs.ModuleRegister(ModuleName, ReadFilesFuncName, func() interfaces.Func { return &ReadFilesFunc{} }) // must register the func and name
+}
+
+// ReadFilesFunc is a function that reads the full contents of every local file
+// which matches a glob pattern, such as "/etc/nginx/conf.d/*.conf". It produces
+// a map from each filename to its contents. Anything which isn't a regular file
+// is skipped. If a matching file is added, removed or changed, or if the pattern
+// changes, then a new map will be sent. The pattern syntax is that of the golang
+// filepath.Match function.
+type ReadFilesFunc struct {
+	init *interfaces.Init
+}
+
+// String returns a simple name for this function. This is needed so this struct
+// can satisfy the pgraph.Vertex interface.
+func (obj *ReadFilesFunc) String() string {
+	return ReadFilesFuncName
+}
+
+// ArgGen returns the Nth arg name for this function.
+func (obj *ReadFilesFunc) ArgGen(index int) (string, error) {
+	seq := []string{readFilesArgNamePattern}
+	if l := len(seq); index >= l {
+		return "", fmt.Errorf("index %d exceeds arg length of %d", index, l)
+	}
+	return seq[index], nil
+}
+
+// Validate makes sure we've built our struct properly. It is usually unused for
+// normal functions that users can use directly.
+func (obj *ReadFilesFunc) Validate() error {
+	return nil
+}
+
+// Info returns some static info about itself.
+func (obj *ReadFilesFunc) Info() *interfaces.Info {
+	return &interfaces.Info{
+		Pure: false, // the file contents can change
+		Memo: false,
+		Sig:  types.NewType(fmt.Sprintf("func(%s str) map{str: str}", readFilesArgNamePattern)),
+	}
+}
+
+// Init runs some startup code for this function.
+func (obj *ReadFilesFunc) Init(init *interfaces.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the changing values that this func has over time.
+func (obj *ReadFilesFunc) Stream(ctx context.Context) error {
+	return watchStream(ctx, obj.init, readFilesArgNamePattern, GlobRoot, ReadFiles)
+}
+
+// GlobRoot returns the path which must be watched to see all the changes to the
+// files that a glob pattern matches, and whether it must be watched recursively.
+// This is the deepest directory without any special characters in its path, or
+// the file itself if the pattern doesn't have any of them.
+func GlobRoot(pattern string) (string, bool, error) {
+	if !filepath.IsAbs(pattern) {
+		return "", false, fmt.Errorf("the pattern must be absolute, got: `%s`", pattern)
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return "", false, errwrap.Wrapf(err, "invalid pattern: `%s`", pattern)
+	}
+
+	parts := strings.Split(filepath.Clean(pattern), "/")
+	for i, part := range parts {
+		if !strings.ContainsAny(part, `*?[\`) {
+			continue
+		}
+		root := strings.Join(parts[:i], "/") + "/" // "" is the root dir
+		recurse := i < len(parts)-1                // special dirs too
+		return root, recurse, nil
+	}
+
+	return filepath.Clean(pattern), false, nil // just one file
+}
+
+// ReadFiles returns a map from each regular file which matches the pattern to
+// its contents.
+func ReadFiles(pattern string) (types.Value, error) {
+	result := types.NewMap(types.NewType("map{str: str}"))
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "invalid pattern: `%s`", pattern)
+	}
+	for _, filename := range matches {
+		fi, err := os.Stat(filename) // follow symlinks
+		if os.IsNotExist(err) {
+			continue // it went away
+		}
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "error reading file")
+		}
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+
+		content, err := os.ReadFile(filename)
+		if os.IsNotExist(err) {
+			continue // it went away
+		}
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "error reading file")
+		}
+
+		if err := result.Add(&types.StrValue{V: filename}, &types.StrValue{V: string(content)}); err != nil {
+			return nil, errwrap.Wrapf(err, "map could not add key `%s`", filename)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,195 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreos
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// StatFuncName is the name this function is registered as.
+	StatFuncName = "stat"
+
+	// arg names...
+	statArgNamePath = "path"
+)
+
+// statOutType is the type of the values that the stat function produces.
+var statOutType = types.NewType("struct{exists bool; is_dir bool; size int; mode str; owner str; group str; mtime int; sha256 str}")
+
+func init() {
+	funcs.ModuleRegister(ModuleName, StatFuncName, func() interfaces.Func { return &StatFunc{} }) // must register the func and name
+}
+
+// StatFunc is a function that returns the metadata of a local file. It produces
+// a struct which says if it exists, if it's a directory, its size in bytes, its
+// mode as an octal string such as "0644", the names of its owner and group, its
+// modification time in seconds since the epoch, and the hex sha256 sum of its
+// contents. The sum is empty for anything which isn't a regular file. Symlinks
+// are followed. If the file changes, or if the path changes, then a new struct
+// will be sent.
+type StatFunc struct {
+	init *interfaces.Init
+}
+
+// String returns a simple name for this function. This is needed so this struct
+// can satisfy the pgraph.Vertex interface.
+func (obj *StatFunc) String() string {
+	return StatFuncName
+}
+
+// ArgGen returns the Nth arg name for this function.
+func (obj *StatFunc) ArgGen(index int) (string, error) {
+	seq := []string{statArgNamePath}
+	if l := len(seq); index >= l {
+		return "", fmt.Errorf("index %d exceeds arg length of %d", index, l)
+	}
+	return seq[index], nil
+}
+
+// Validate makes sure we've built our struct properly. It is usually unused for
+// normal functions that users can use directly.
+func (obj *StatFunc) Validate() error {
+	return nil
+}
+
+// Info returns some static info about itself.
+func (obj *StatFunc) Info() *interfaces.Info {
+	return &interfaces.Info{
+		Pure: false, // the file can change
+		Memo: false,
+		Sig:  types.NewType(fmt.Sprintf("func(%s str) %s", statArgNamePath, statOutType)),
+	}
+}
+
+// Init runs some startup code for this function.
+func (obj *StatFunc) Init(init *interfaces.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the changing values that this func has over time.
+func (obj *StatFunc) Stream(ctx context.Context) error {
+	watch := func(path string) (string, bool, error) {
+		if !filepath.IsAbs(path) {
+			return "", false, fmt.Errorf("the path must be absolute, got: `%s`", path)
+		}
+		return filepath.Clean(path), false, nil
+	}
+	return watchStream(ctx, obj.init, statArgNamePath, watch, Stat)
+}
+
+// Stat returns the metadata of a file as a struct of the stat function's output
+// type. A file which doesn't exist has the zero value for every field.
+func Stat(path string) (types.Value, error) {
+	fields := map[string]types.Value{
+		"exists": &types.BoolValue{V: false},
+		"is_dir": &types.BoolValue{V: false},
+		"size":   &types.IntValue{V: 0},
+		"mode":   &types.StrValue{V: ""},
+		"owner":  &types.StrValue{V: ""},
+		"group":  &types.StrValue{V: ""},
+		"mtime":  &types.IntValue{V: 0},
+		"sha256": &types.StrValue{V: ""},
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errwrap.Wrapf(err, "error reading file")
+	}
+	if err == nil {
+		fields["exists"] = &types.BoolValue{V: true}
+		fields["is_dir"] = &types.BoolValue{V: fi.IsDir()}
+		fields["size"] = &types.IntValue{V: fi.Size()}
+		fields["mtime"] = &types.IntValue{V: fi.ModTime().Unix()}
+
+		mode := uint32(fi.Mode().Perm())
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			mode = st.Mode & 07777 // include the setuid, setgid and sticky bits
+			uid := strconv.FormatUint(uint64(st.Uid), 10)
+			gid := strconv.FormatUint(uint64(st.Gid), 10)
+			owner, group := uid, gid // if they have no names
+			if u, err := user.LookupId(uid); err == nil {
+				owner = u.Username
+			}
+			if g, err := user.LookupGroupId(gid); err == nil {
+				group = g.Name
+			}
+			fields["owner"] = &types.StrValue{V: owner}
+			fields["group"] = &types.StrValue{V: group}
+		}
+		fields["mode"] = &types.StrValue{V: fmt.Sprintf("%04o", mode)}
+
+		if fi.Mode().IsRegular() {
+			sum, err := sha256File(path)
+			if err != nil && !os.IsNotExist(err) { // it might have gone away
+				return nil, errwrap.Wrapf(err, "error reading file")
+			}
+			fields["sha256"] = &types.StrValue{V: sum}
+		}
+	}
+
+	result := types.NewStruct(statOutType)
+	for k, v := range fields {
+		if err := result.Set(k, v); err != nil {
+			return nil, errwrap.Wrapf(err, "struct could not set field `%s`", k)
+		}
+	}
+	return result, nil
+}
+
+// sha256File returns the hex sha256 sum of the contents of a file.
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -59,7 +59,8 @@ func init() {
 //
 // Note that in the likely case in which the process emits several lines one
 // after the other, the downstream resources might not run for every line unless
-// the "Meta:realize" metaparam is set to true.
+// the "Meta:realize" metaparam is set to true. The command function is a more
+// structured alternative which also gives you stderr and the exit code.
 type SystemFunc struct {
 	init   *interfaces.Init
 	cancel context.CancelFunc
@@ -0,0 +1,228 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreos
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+	"github.com/purpleidea/mgmt/util/recwatch"
+)
+
+// pathWatcher watches a path with recwatch. It sends one event when it starts,
+// and then one more each time that something changes. It can be moved to watch
+// a different path, and it must be closed when it's not needed anymore.
+type pathWatcher struct {
+	init *interfaces.Init
+
+	recWatcher *recwatch.RecWatcher
+	events     chan error // internal events
+	wg         *sync.WaitGroup
+	cancel     context.CancelFunc
+}
+
+// newPathWatcher returns a watcher which isn't watching anything yet.
+func newPathWatcher(init *interfaces.Init) *pathWatcher {
+	return &pathWatcher{
+		init:   init,
+		events: make(chan error),
+		wg:     &sync.WaitGroup{},
+	}
+}
+
+// Events returns the channel of events. A nil error is a change, and anything
+// else is a problem with the watcher.
+func (obj *pathWatcher) Events() <-chan error {
+	return obj.events
+}
+
+// Watch stops watching the previous path, if any, and starts watching this one.
+// A directory must have a trailing slash.
+func (obj *pathWatcher) Watch(ctx context.Context, path string, recurse bool) error {
+	obj.stop()
+
+	recWatcher := &recwatch.RecWatcher{
+		Path:    path,
+		Recurse: recurse,
+		Opts: []recwatch.Option{
+			recwatch.Logf(obj.init.Logf),
+			recwatch.Debug(obj.init.Debug),
+		},
+	}
+	if err := recWatcher.Init(); err != nil {
+		return errwrap.Wrapf(err, "could not watch `%s`", path)
+	}
+	obj.recWatcher = recWatcher
+
+	ctx, obj.cancel = context.WithCancel(ctx)
+
+	// watch recwatch events in a proxy goroutine, since changing the
+	// recwatch object would panic the main select when it's nil...
+	obj.wg.Add(1)
+	go func() {
+		defer obj.wg.Done()
+		startup := make(chan struct{})
+		close(startup)
+		for {
+			var err error
+			select {
+			case <-startup:
+				startup = nil
+				// send an initial event
+
+			case event, ok := <-recWatcher.Events():
+				if !ok {
+					return // file watcher shut down
+				}
+				if err = event.Error; err != nil {
+					err = errwrap.Wrapf(err, "error event received")
+				}
+
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case obj.events <- err:
+				// send event...
+
+			case <-ctx.Done():
+				// don't block here on shutdown
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// stop shuts down the current recwatch, if any, and waits for it to exit.
+func (obj *pathWatcher) stop() {
+	if obj.cancel != nil {
+		obj.cancel()
+		obj.cancel = nil
+	}
+	if obj.recWatcher != nil {
+		obj.recWatcher.Close()
+		obj.recWatcher = nil
+	}
+	obj.wg.Wait()
+}
+
+// Close stops watching. It must be called when you are done with the watcher.
+func (obj *pathWatcher) Close() {
+	obj.stop()
+}
+
+// watchStream is the common Stream of the functions which take a single str arg
+// and produce a value by reading something from the filesystem. The watch func
+// turns the arg into a path to watch, and whether to recurse into it, and the
+// read func produces the value. It is run again each time that the watched path
+// changes, and a new value is sent if the result is different.
+func watchStream(ctx context.Context, init *interfaces.Init, argName string, watch func(arg string) (string, bool, error), read func(arg string) (types.Value, error)) error {
+	defer close(init.Output) // the sender closes
+
+	watcher := newPathWatcher(init)
+	defer watcher.Close()
+
+	var arg *string        // the active arg
+	var result types.Value // last calculated output
+
+	for {
+		select {
+		case input, ok := <-init.Input:
+			if !ok {
+				init.Input = nil // don't infinite loop back
+				continue         // no more inputs, but don't return!
+			}
+
+			s := input.Struct()[argName].Str()
+			if arg != nil && *arg == s {
+				continue // nothing changed
+			}
+			arg = &s
+
+			path, recurse, err := watch(s)
+			if err != nil {
+				return err
+			}
+			if init.Debug {
+				init.Logf("watching: %s (recurse: %t)", path, recurse)
+			}
+			if err := watcher.Watch(ctx, path, recurse); err != nil {
+				return err
+			}
+			continue // wait for an actual event or we'd send empty!
+
+		case err := <-watcher.Events():
+			if err != nil {
+				return errwrap.Wrapf(err, "error event received")
+			}
+			if arg == nil {
+				continue // still waiting for input values
+			}
+
+			value, err := read(*arg)
+			if err != nil {
+				return err
+			}
+
+			// some values, such as maps, compare by pointer...
+			if result != nil && value.String() == result.String() {
+				continue // result didn't change
+			}
+			result = value // store new result
+
+		case <-ctx.Done():
+			return nil
+		}
+
+		select {
+		case init.Output <- result:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// dirPath returns the path with a trailing slash, which is how recwatch knows
+// that it is a directory.
+func dirPath(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("the path must not be empty")
+	}
+	if path[len(path)-1] != '/' {
+		path += "/"
+	}
+	return path, nil
+}
@@ -0,0 +1,90 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreregexp
+
+import (
+	"regexp"
+
+	"github.com/purpleidea/mgmt/lang/funcs/simple"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+func init() {
+	simple.ModuleRegister(ModuleName, "find_all", &types.FuncValue{
+		T: types.NewType("func(pattern str, s str) []str"),
+		V: FindAll,
+	})
+	simple.ModuleRegister(ModuleName, "find_named", &types.FuncValue{
+		T: types.NewType("func(pattern str, s str) map{str: str}"),
+		V: FindNamed,
+	})
+}
+
+// FindAll returns every match of the regexp pattern in the string, in order.
+func FindAll(input []types.Value) (types.Value, error) {
+	re, err := regexp.Compile(input[0].Str())
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "pattern did not compile")
+	}
+
+	result := types.NewList(types.NewType("[]str"))
+	for _, s := range re.FindAllString(input[1].Str(), -1) {
+		if err := result.Add(&types.StrValue{V: s}); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// FindNamed returns the named capture groups, such as `(?P<name>\w+)`, from the
+// first match of the regexp pattern in the string. Named groups which didn't
+// take part in the match are empty. If there is no match, the map is empty.
+func FindNamed(input []types.Value) (types.Value, error) {
+	re, err := regexp.Compile(input[0].Str())
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "pattern did not compile")
+	}
+
+	result := types.NewMap(types.NewType("map{str: str}"))
+	match := re.FindStringSubmatch(input[1].Str())
+	if match == nil {
+		return result, nil
+	}
+	for i, name := range re.SubexpNames() {
+		if name == "" {
+			continue // the whole match, or an unnamed group
+		}
+		if err := result.Add(&types.StrValue{V: name}, &types.StrValue{V: match[i]}); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,81 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreregexp
+
+import (
+	"testing"
+
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func TestFindAll0(t *testing.T) {
+	pattern := &types.StrValue{V: `\d+`}
+	s := &types.StrValue{V: "a1b22c333"}
+	val, err := FindAll([]types.Value{pattern, s})
+	if err != nil {
+		t.Errorf("find_all failed with: %+v", err)
+		return
+	}
+	if a, b := `["1", "22", "333"]`, val.String(); a != b {
+		t.Errorf("expected %s, got %s", a, b)
+	}
+}
+
+func TestFindNamed0(t *testing.T) {
+	pattern := &types.StrValue{V: `^(?P<host>[a-z]+)(\d+)(?:\.(?P<domain>[a-z.]+))?$`}
+
+	val, err := FindNamed([]types.Value{pattern, &types.StrValue{V: "db1.example.com"}})
+	if err != nil {
+		t.Errorf("find_named failed with: %+v", err)
+		return
+	}
+	if a, b := `{"domain": "example.com", "host": "db"}`, val.String(); a != b {
+		t.Errorf("expected %s, got %s", a, b)
+	}
+
+	// the domain group doesn't take part
+	val, err = FindNamed([]types.Value{pattern, &types.StrValue{V: "web2"}})
+	if err != nil {
+		t.Errorf("find_named failed with: %+v", err)
+		return
+	}
+	if a, b := `{"domain": "", "host": "web"}`, val.String(); a != b {
+		t.Errorf("expected %s, got %s", a, b)
+	}
+
+	val, err = FindNamed([]types.Value{pattern, &types.StrValue{V: "no match"}})
+	if err != nil {
+		t.Errorf("find_named failed with: %+v", err)
+		return
+	}
+	if l := len(val.Map()); l != 0 {
+		t.Errorf("expected an empty map, got %d entries", l)
+	}
+}
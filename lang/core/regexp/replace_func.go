@@ -0,0 +1,58 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreregexp
+
+import (
+	"regexp"
+
+	"github.com/purpleidea/mgmt/lang/funcs/simple"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+func init() {
+	simple.ModuleRegister(ModuleName, "replace", &types.FuncValue{
+		T: types.NewType("func(pattern str, s str, replacement str) str"),
+		V: Replace,
+	})
+}
+
+// Replace replaces every match of the regexp pattern in the string with the
+// replacement. Inside the replacement, `$1` or `${name}` is expanded to the
+// text of that capture group.
+func Replace(input []types.Value) (types.Value, error) {
+	re, err := regexp.Compile(input[0].Str())
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "pattern did not compile")
+	}
+	return &types.StrValue{
+		V: re.ReplaceAllString(input[1].Str(), input[2].Str()),
+	}, nil
+}
@@ -0,0 +1,71 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreregexp
+
+import (
+	"testing"
+
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func TestReplace0(t *testing.T) {
+	values := []struct {
+		pattern     string
+		s           string
+		replacement string
+		expected    string
+	}{
+		{`a+`, "caaat baat", "o", "cot bot"},
+		{`(\w+)@(\w+)`, "user@host", "$2 for $1", "host for user"},
+		{`(?P<k>\w+)=(?P<v>\w+)`, "a=1", "${v}=${k}", "1=a"},
+		{`x`, "abc", "y", "abc"},
+	}
+
+	for i, x := range values {
+		input := []types.Value{
+			&types.StrValue{V: x.pattern},
+			&types.StrValue{V: x.s},
+			&types.StrValue{V: x.replacement},
+		}
+		val, err := Replace(input)
+		if err != nil {
+			t.Errorf("test index %d failed with: %+v", i, err)
+			continue
+		}
+		if a, b := x.expected, val.Str(); a != b {
+			t.Errorf("test index %d expected %s, got %s", i, a, b)
+		}
+	}
+
+	input := []types.Value{&types.StrValue{V: `(`}, &types.StrValue{V: ""}, &types.StrValue{V: ""}}
+	if _, err := Replace(input); err == nil {
+		t.Errorf("expected an invalid pattern to error")
+	}
+}
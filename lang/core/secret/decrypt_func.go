@@ -0,0 +1,179 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coresecret
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/purpleidea/mgmt/engine/local"
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// DecryptFuncName is the name this function is registered as.
+	DecryptFuncName = "decrypt"
+
+	// arg names...
+	decryptArgNameCiphertext = "ciphertext"
+
+	// field names...
+	decryptFieldNameValue = "value"
+	decryptFieldNameOk    = "ok"
+)
+
+// decryptOutType is the type of the values that the decrypt function produces.
+var decryptOutType = types.NewType(fmt.Sprintf("struct{%s str; %s bool}", decryptFieldNameValue, decryptFieldNameOk))
+
+func init() {
+	funcs.ModuleRegister(ModuleName, DecryptFuncName, func() interfaces.Func { return &DecryptFunc{} }) // must register the func and name
+}
+
+// DecryptFunc decrypts a message which was encrypted to the pgp key of this
+// host, usually with the `mgmt secret encrypt` command. The value of the result
+// is a secret. On any host which the message wasn't encrypted to, or if pgp is
+// disabled, the value is empty and ok is false. This doesn't error, since a
+// program which is shared by many hosts usually only uses a secret on some of
+// them, such as behind an `if`, and both sides of that are always built. The
+// ciphertext itself isn't sensitive, so it can be stored in the code, or in the
+// World with the exchange functions.
+type DecryptFunc struct {
+	init *interfaces.Init
+	last types.Value // last value received to use for diff
+}
+
+// String returns a simple name for this function. This is needed so this struct
+// can satisfy the pgraph.Vertex interface.
+func (obj *DecryptFunc) String() string {
+	return DecryptFuncName
+}
+
+// ArgGen returns the Nth arg name for this function.
+func (obj *DecryptFunc) ArgGen(index int) (string, error) {
+	seq := []string{decryptArgNameCiphertext}
+	if l := len(seq); index >= l {
+		return "", fmt.Errorf("index %d exceeds arg length of %d", index, l)
+	}
+	return seq[index], nil
+}
+
+// Validate makes sure we've built our struct properly. It is usually unused for
+// normal functions that users can use directly.
+func (obj *DecryptFunc) Validate() error {
+	return nil
+}
+
+// Info returns some static info about itself.
+func (obj *DecryptFunc) Info() *interfaces.Info {
+	return &interfaces.Info{
+		Pure: false, // the result depends on the key of the host
+		Memo: false,
+		Sig:  types.NewType(fmt.Sprintf("func(%s str) %s", decryptArgNameCiphertext, decryptOutType)),
+	}
+}
+
+// Init runs some startup code for this function.
+func (obj *DecryptFunc) Init(init *interfaces.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the changing values that this func has over time.
+func (obj *DecryptFunc) Stream(ctx context.Context) error {
+	defer close(obj.init.Output) // the sender closes
+	for {
+		select {
+		case input, ok := <-obj.init.Input:
+			if !ok {
+				return nil // can't output any more
+			}
+
+			if obj.last != nil && input.Cmp(obj.last) == nil {
+				continue // value didn't change, skip it
+			}
+			obj.last = input // store for next
+
+			var decrypter local.Decrypter
+			if obj.init.Local != nil {
+				decrypter = obj.init.Local.Decrypter
+			}
+			ciphertext := input.Struct()[decryptArgNameCiphertext].Str()
+			value, err := decrypt(decrypter, ciphertext)
+			if err != nil && obj.init.Debug {
+				obj.init.Logf("decrypt: %+v", err)
+			}
+			result, err := decryptResult(value)
+			if err != nil { // programming error
+				return err
+			}
+
+			select {
+			case obj.init.Output <- result:
+			case <-ctx.Done():
+				return nil
+			}
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// decrypt decrypts the ciphertext with the keys of this host into a secret.
+func decrypt(decrypter local.Decrypter, ciphertext string) (*types.StrValue, error) {
+	if decrypter == nil {
+		return nil, fmt.Errorf("pgp is disabled on this host")
+	}
+	plaintext, err := decrypter.Decrypt(ciphertext)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "can't decrypt, it might not be encrypted to this host")
+	}
+	return types.NewSecret(plaintext), nil
+}
+
+// decryptResult builds the output of the decrypt function. The value is nil if
+// we couldn't decrypt it.
+func decryptResult(value *types.StrValue) (types.Value, error) {
+	ok := value != nil
+	if !ok {
+		value = types.NewSecret("")
+	}
+	st := types.NewStruct(decryptOutType)
+	if err := st.Set(decryptFieldNameValue, value); err != nil {
+		return nil, errwrap.Wrapf(err, "struct could not set field `%s`", decryptFieldNameValue)
+	}
+	if err := st.Set(decryptFieldNameOk, &types.BoolValue{V: ok}); err != nil {
+		return nil, errwrap.Wrapf(err, "struct could not set field `%s`", decryptFieldNameOk)
+	}
+	return st, nil
+}
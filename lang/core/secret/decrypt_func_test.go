@@ -0,0 +1,90 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package coresecret
+
+import (
+	"testing"
+
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/pgp"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestDecrypt0(t *testing.T) {
+	h1, err := pgp.Generate("h1", "test", "root@h1", nil)
+	if err != nil {
+		t.Fatalf("could not generate key: %+v", err)
+	}
+	h2, err := pgp.Generate("h2", "test", "root@h2", nil)
+	if err != nil {
+		t.Fatalf("could not generate key: %+v", err)
+	}
+
+	ciphertext, err := pgp.EncryptTo([]*openpgp.Entity{h1.Entity}, "hunter2")
+	if err != nil {
+		t.Fatalf("could not encrypt: %+v", err)
+	}
+
+	result, err := decrypt(h1, ciphertext)
+	if err != nil {
+		t.Fatalf("could not decrypt: %+v", err)
+	}
+	if !result.Secret {
+		t.Errorf("result is not a secret")
+	}
+	if result.V != "hunter2" {
+		t.Errorf("unexpected result: %s", result.V)
+	}
+
+	if _, err := decrypt(h2, ciphertext); err == nil {
+		t.Errorf("expected an error for a host which isn't a recipient")
+	}
+	if _, err := decrypt(nil, ciphertext); err == nil {
+		t.Errorf("expected an error when pgp is disabled")
+	}
+
+	// the hosts which can't decrypt it get an empty secret instead
+	for _, value := range []*types.StrValue{result, nil} {
+		out, err := decryptResult(value)
+		if err != nil {
+			t.Fatalf("could not build the result: %+v", err)
+		}
+		st := out.Struct()
+		if ok := st[decryptFieldNameOk].Bool(); ok != (value != nil) {
+			t.Errorf("unexpected ok: %t", ok)
+		}
+		if !types.IsSecret(st[decryptFieldNameValue]) {
+			t.Errorf("value is not a secret")
+		}
+	}
+}
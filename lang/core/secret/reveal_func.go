@@ -0,0 +1,128 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coresecret
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+const (
+	// RevealFuncName is the name this function is registered as.
+	RevealFuncName = "reveal"
+
+	// arg names...
+	revealArgNameS = "s"
+)
+
+func init() {
+	funcs.ModuleRegister(ModuleName, RevealFuncName, func() interfaces.Func { return &RevealFunc{} }) // must register the func and name
+}
+
+// RevealFunc turns a secret back into an ordinary str. This is needed to pass a
+// secret to a resource field which doesn't accept them, such as the content of
+// a file. Be careful, since the result can get printed, and anything derived
+// from it is no longer secret.
+type RevealFunc struct {
+	init *interfaces.Init
+	last types.Value // last value received to use for diff
+}
+
+// String returns a simple name for this function. This is needed so this struct
+// can satisfy the pgraph.Vertex interface.
+func (obj *RevealFunc) String() string {
+	return RevealFuncName
+}
+
+// ArgGen returns the Nth arg name for this function.
+func (obj *RevealFunc) ArgGen(index int) (string, error) {
+	seq := []string{revealArgNameS}
+	if l := len(seq); index >= l {
+		return "", fmt.Errorf("index %d exceeds arg length of %d", index, l)
+	}
+	return seq[index], nil
+}
+
+// Validate makes sure we've built our struct properly. It is usually unused for
+// normal functions that users can use directly.
+func (obj *RevealFunc) Validate() error {
+	return nil
+}
+
+// Info returns some static info about itself.
+func (obj *RevealFunc) Info() *interfaces.Info {
+	return &interfaces.Info{
+		Pure:   true,
+		Memo:   false,
+		Reveal: true, // this is the whole point
+		Sig:    types.NewType(fmt.Sprintf("func(%s str) str", revealArgNameS)),
+	}
+}
+
+// Init runs some startup code for this function.
+func (obj *RevealFunc) Init(init *interfaces.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the changing values that this func has over time.
+func (obj *RevealFunc) Stream(ctx context.Context) error {
+	defer close(obj.init.Output) // the sender closes
+	for {
+		select {
+		case input, ok := <-obj.init.Input:
+			if !ok {
+				return nil // can't output any more
+			}
+
+			if obj.last != nil && input.Cmp(obj.last) == nil {
+				continue // value didn't change, skip it
+			}
+			obj.last = input // store for next
+
+			result := &types.StrValue{
+				V: input.Struct()[revealArgNameS].Str(),
+			}
+
+			select {
+			case obj.init.Output <- result:
+			case <-ctx.Done():
+				return nil
+			}
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package corestrings
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/purpleidea/mgmt/lang/funcs/simple"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func init() {
+	simple.ModuleRegister(ModuleName, "pad_left", &types.FuncValue{
+		T: types.NewType("func(a str, length int, pad str) str"),
+		V: PadLeft,
+	})
+	simple.ModuleRegister(ModuleName, "pad_right", &types.FuncValue{
+		T: types.NewType("func(a str, length int, pad str) str"),
+		V: PadRight,
+	})
+}
+
+// PadLeft adds copies of the pad string to the start of the input until it is
+// at least length characters long. Strings which are already long enough are
+// returned unchanged.
+func PadLeft(input []types.Value) (types.Value, error) {
+	padding, err := pad(input[0].Str(), input[1].Int(), input[2].Str())
+	if err != nil {
+		return nil, err
+	}
+	return &types.StrValue{
+		V: padding + input[0].Str(),
+	}, nil
+}
+
+// PadRight adds copies of the pad string to the end of the input until it is at
+// least length characters long. Strings which are already long enough are
+// returned unchanged.
+func PadRight(input []types.Value) (types.Value, error) {
+	padding, err := pad(input[0].Str(), input[1].Int(), input[2].Str())
+	if err != nil {
+		return nil, err
+	}
+	return &types.StrValue{
+		V: input[0].Str() + padding,
+	}, nil
+}
+
+// pad returns the padding needed to make the string length characters long. If
+// the pad string has more than one character, the last copy may be cut short.
+func pad(s string, length int64, p string) (string, error) {
+	if p == "" {
+		return "", fmt.Errorf("the pad string can't be empty")
+	}
+	n := int(length) - utf8.RuneCountInString(s)
+	if n <= 0 {
+		return "", nil
+	}
+	runes := []rune(strings.Repeat(p, n)) // always long enough
+	return string(runes[:n]), nil
+}
@@ -0,0 +1,80 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package corestrings
+
+import (
+	"testing"
+
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func TestPad0(t *testing.T) {
+	values := []struct {
+		input  string
+		length int64
+		pad    string
+		left   string
+		right  string
+	}{
+		{"7", 3, "0", "007", "700"},
+		{"abc", 2, "0", "abc", "abc"},
+		{"x", 6, "ab", "ababax", "xababa"},
+		{"é", 3, "·", "··é", "é··"},
+	}
+
+	for i, x := range values {
+		input := []types.Value{
+			&types.StrValue{V: x.input},
+			&types.IntValue{V: x.length},
+			&types.StrValue{V: x.pad},
+		}
+		left, err := PadLeft(input)
+		if err != nil {
+			t.Errorf("test index %d failed with: %+v", i, err)
+			continue
+		}
+		right, err := PadRight(input)
+		if err != nil {
+			t.Errorf("test index %d failed with: %+v", i, err)
+			continue
+		}
+		if a, b := x.left, left.Str(); a != b {
+			t.Errorf("test index %d expected %s, got %s", i, a, b)
+		}
+		if a, b := x.right, right.Str(); a != b {
+			t.Errorf("test index %d expected %s, got %s", i, a, b)
+		}
+	}
+
+	input := []types.Value{&types.StrValue{V: "x"}, &types.IntValue{V: 3}, &types.StrValue{V: ""}}
+	if _, err := PadLeft(input); err == nil {
+		t.Errorf("expected an empty pad string to error")
+	}
+}
@@ -0,0 +1,66 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package corestrings
+
+import (
+	"github.com/purpleidea/mgmt/lang/funcs/simple"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func init() {
+	simple.ModuleRegister(ModuleName, "substring", &types.FuncValue{
+		T: types.NewType("func(a str, start int, end int) str"),
+		V: Substring,
+	})
+}
+
+// Substring returns the characters of the string from the start index, up to
+// but not including the end index. The indexes count characters, not bytes.
+// They are clamped to the length of the string, so this never errors, and if
+// start is not before end, then the result is empty.
+func Substring(input []types.Value) (types.Value, error) {
+	runes := []rune(input[0].Str())
+	clamp := func(i int64) int {
+		if i < 0 {
+			return 0
+		}
+		if i > int64(len(runes)) {
+			return len(runes)
+		}
+		return int(i)
+	}
+	start, end := clamp(input[1].Int()), clamp(input[2].Int())
+	if start >= end {
+		return &types.StrValue{}, nil
+	}
+	return &types.StrValue{
+		V: string(runes[start:end]),
+	}, nil
+}
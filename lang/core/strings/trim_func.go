@@ -0,0 +1,73 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package corestrings
+
+import (
+	"strings"
+
+	"github.com/purpleidea/mgmt/lang/funcs/simple"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func init() {
+	simple.ModuleRegister(ModuleName, "trim", &types.FuncValue{
+		T: types.NewType("func(a str) str"),
+		V: Trim,
+	})
+	simple.ModuleRegister(ModuleName, "trim_prefix", &types.FuncValue{
+		T: types.NewType("func(a str, prefix str) str"),
+		V: TrimPrefix,
+	})
+	simple.ModuleRegister(ModuleName, "trim_suffix", &types.FuncValue{
+		T: types.NewType("func(a str, suffix str) str"),
+		V: TrimSuffix,
+	})
+}
+
+// Trim removes the leading and trailing whitespace from a string.
+func Trim(input []types.Value) (types.Value, error) {
+	return &types.StrValue{
+		V: strings.TrimSpace(input[0].Str()),
+	}, nil
+}
+
+// TrimPrefix removes a prefix from a string if it has it.
+func TrimPrefix(input []types.Value) (types.Value, error) {
+	return &types.StrValue{
+		V: strings.TrimPrefix(input[0].Str(), input[1].Str()),
+	}, nil
+}
+
+// TrimSuffix removes a suffix from a string if it has it.
+func TrimSuffix(input []types.Value) (types.Value, error) {
+	return &types.StrValue{
+		V: strings.TrimSuffix(input[0].Str(), input[1].Str()),
+	}, nil
+}
@@ -0,0 +1,237 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !darwin
+
+package coresys
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/purpleidea/mgmt/engine/hostfacts"
+	"github.com/purpleidea/mgmt/lang/funcs/facts"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+	"github.com/purpleidea/mgmt/util/socketset"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// BlockDevicesFuncName is the name this fact is registered as. It's
+	// still a Func Name because this is the name space the fact is actually
+	// using.
+	BlockDevicesFuncName = "block_devices"
+
+	blockDevicesSignature = "map{str: struct{type str; parent str; size int; removable bool; rotational bool; model str}}"
+
+	// blockDir is where the kernel lists the block devices.
+	blockDir = "/sys/class/block/"
+
+	// blockSectorSize is the unit of the size file, which is always 512.
+	blockSectorSize = 512
+)
+
+func init() {
+	facts.ModuleRegister(ModuleName, BlockDevicesFuncName, func() facts.Fact { return &BlockDevicesFact{} }) // must register the fact and name
+}
+
+// BlockDevicesFact is a fact which returns the block devices, such as disks and
+// their partitions, keyed by their name. The type is `disk` or `part`, and the
+// parent is the name of the disk that a partition is on. The size is in bytes.
+// Devices with a size of zero, such as unused loop devices, are skipped. It
+// receives events from the kernel as devices are added and removed.
+type BlockDevicesFact struct {
+	init *facts.Init
+}
+
+// String returns a simple name for this fact. This is needed so this struct can
+// satisfy the pgraph.Vertex interface.
+func (obj *BlockDevicesFact) String() string {
+	return BlockDevicesFuncName
+}
+
+// Info returns some static info about itself.
+func (obj *BlockDevicesFact) Info() *facts.Info {
+	return &facts.Info{
+		Output: types.NewType(blockDevicesSignature),
+	}
+}
+
+// Init runs some startup code for this fact.
+func (obj *BlockDevicesFact) Init(init *facts.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the changing values that this fact has over time.
+func (obj *BlockDevicesFact) Stream(ctx context.Context) error {
+	defer close(obj.init.Output) // always signal when we're done
+
+	dir, err := os.MkdirTemp("", "mgmt-"+BlockDevicesFuncName+"-")
+	if err != nil {
+		return errwrap.Wrapf(err, "could not make a directory for the socket")
+	}
+	defer os.RemoveAll(dir)
+
+	ss, err := socketset.NewSocketSet(rtmGrps, path.Join(dir, socketFile), unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return errwrap.Wrapf(err, "error creating socket set")
+	}
+
+	// waitgroup for netlink receive goroutine
+	wg := &sync.WaitGroup{}
+	defer ss.Close()
+	// We must wait for the Shutdown() AND the select inside of SocketSet to
+	// complete before we Close, since the unblocking in SocketSet is not a
+	// synchronous operation.
+	defer wg.Wait()
+	defer ss.Shutdown() // close the netlink socket and unblock conn.receive()
+
+	eventChan := make(chan *nlChanEvent) // updated in goroutine when we receive uevent
+	closeChan := make(chan struct{})     // channel to unblock selects in goroutine
+	defer close(closeChan)
+
+	// wait for kernel to poke us about new device changes on the system
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(eventChan)
+		for {
+			uevent, err := ss.ReceiveUEvent() // calling Shutdown will stop this from blocking
+			select {
+			case eventChan <- &nlChanEvent{
+				uevent: uevent,
+				err:    err,
+			}:
+			case <-closeChan:
+				return
+			}
+		}
+	}()
+
+	// streams must generate an initial event on startup
+	startChan := make(chan struct{}) // start signal
+	close(startChan)                 // kick it off!
+	var last types.Value
+	for {
+		select {
+		case <-startChan: // kick the loop once at start
+			startChan = nil // disable
+
+		case event, ok := <-eventChan:
+			if !ok {
+				return nil
+			}
+			if event.err != nil {
+				return errwrap.Wrapf(event.err, "error receiving uevent")
+			}
+			if event.uevent.Subsystem != "block" {
+				continue
+			}
+			if obj.init.Debug {
+				obj.init.Logf("block device %s: %s", event.uevent.Action, event.uevent.Devpath)
+			}
+
+		case <-ctx.Done():
+			return nil
+		}
+
+		m, err := blockDevices(blockDir)
+		if err != nil {
+			return errwrap.Wrapf(err, "could not read block devices")
+		}
+		if last != nil && m.String() == last.String() { // maps cmp by pointer
+			continue // nothing changed
+		}
+		last = m
+
+		select {
+		case obj.init.Output <- m:
+			// send
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// blockDevices reads the block devices that are listed in this sysfs directory.
+func blockDevices(dir string) (*types.MapValue, error) {
+	typ := types.NewType(blockDevicesSignature)
+	m := types.NewMap(typ)
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		name := file.Name()
+		p := path.Join(dir, name)
+		size := readInt(path.Join(p, "size")) * blockSectorSize
+		if size == 0 {
+			continue // unused loop devices and the like
+		}
+
+		// partitions have this file, and live inside their disk's dir
+		kind, parent, disk := "disk", "", p
+		if _, err := os.Stat(path.Join(p, "partition")); err == nil {
+			real, err := filepath.EvalSymlinks(p)
+			if err != nil {
+				return nil, errwrap.Wrapf(err, "could not find the disk of %s", name)
+			}
+			kind, parent, disk = "part", path.Base(path.Dir(real)), path.Dir(real)
+		}
+
+		st := types.NewStruct(typ.Val)
+		st.Set("type", &types.StrValue{V: kind})
+		st.Set("parent", &types.StrValue{V: parent})
+		st.Set("size", &types.IntValue{V: size})
+		st.Set("removable", &types.BoolValue{V: readInt(path.Join(disk, "removable")) == 1})
+		st.Set("rotational", &types.BoolValue{V: readInt(path.Join(disk, "queue/rotational")) == 1})
+		st.Set("model", &types.StrValue{V: hostfacts.ReadString(path.Join(disk, "device/model"))})
+		if err := m.Add(&types.StrValue{V: name}, st); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// readInt returns the number in a sysfs file, or zero if it can't be read.
+func readInt(p string) int64 {
+	i, err := strconv.ParseInt(hostfacts.ReadString(p), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return i
+}
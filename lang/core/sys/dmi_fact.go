@@ -0,0 +1,111 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !darwin
+
+package coresys
+
+import (
+	"context"
+	"path"
+
+	"github.com/purpleidea/mgmt/engine/hostfacts"
+	"github.com/purpleidea/mgmt/lang/funcs/facts"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// DMIFuncName is the name this fact is registered as. It's still a Func
+	// Name because this is the name space the fact is actually using.
+	DMIFuncName = "dmi"
+
+	dmiSignature = "struct{vendor str; product str; version str; serial str; uuid str; bios_vendor str; bios_version str}"
+)
+
+// dmiFiles maps the fields of the fact to the files they're read from.
+var dmiFiles = map[string]string{
+	"vendor":       "sys_vendor",
+	"product":      "product_name",
+	"version":      "product_version",
+	"serial":       "product_serial",
+	"uuid":         "product_uuid",
+	"bios_vendor":  "bios_vendor",
+	"bios_version": "bios_version",
+}
+
+func init() {
+	facts.ModuleRegister(ModuleName, DMIFuncName, func() facts.Fact { return &DMIFact{} }) // must register the fact and name
+}
+
+// DMIFact is a fact which returns the DMI information of the machine, such as
+// the vendor, product name and serial number. Some fields, such as the serial
+// number, can usually only be read by root. Any fields which can't be read are
+// empty.
+type DMIFact struct {
+	init *facts.Init
+}
+
+// String returns a simple name for this fact. This is needed so this struct can
+// satisfy the pgraph.Vertex interface.
+func (obj *DMIFact) String() string {
+	return DMIFuncName
+}
+
+// Info returns some static info about itself.
+func (obj *DMIFact) Info() *facts.Info {
+	return &facts.Info{
+		Output: types.NewType(dmiSignature),
+	}
+}
+
+// Init runs some startup code for this fact.
+func (obj *DMIFact) Init(init *facts.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the single value that this fact has, and then closes.
+func (obj *DMIFact) Stream(ctx context.Context) error {
+	st := types.NewStruct(types.NewType(dmiSignature))
+	for k, name := range dmiFiles {
+		if err := st.Set(k, &types.StrValue{V: hostfacts.ReadString(path.Join(hostfacts.DMIDir, name))}); err != nil {
+			return errwrap.Wrapf(err, "struct could not set key: `%s`", k)
+		}
+	}
+
+	select {
+	case obj.init.Output <- st:
+		// pass
+	case <-ctx.Done():
+		return nil
+	}
+	close(obj.init.Output) // signal that we're done sending
+	return nil
+}
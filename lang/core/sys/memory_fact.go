@@ -0,0 +1,142 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !darwin
+
+package coresys
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/purpleidea/mgmt/engine/hostfacts"
+	"github.com/purpleidea/mgmt/lang/funcs/facts"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// MemoryFuncName is the name this fact is registered as. It's still a
+	// Func Name because this is the name space the fact is actually using.
+	MemoryFuncName = "memory"
+
+	memorySignature = "struct{total int; free int; available int; swap_total int; swap_free int}"
+
+	// memoryInterval is how often we read the memory values. The kernel
+	// doesn't tell us when they change, and they change all of the time.
+	memoryInterval = 5 * time.Second
+)
+
+func init() {
+	facts.ModuleRegister(ModuleName, MemoryFuncName, func() facts.Fact { return &MemoryFact{} }) // must register the fact and name
+}
+
+// MemoryFact is a fact which returns the total and free amounts of memory and
+// swap in bytes. The available memory is an estimate of how much could be used
+// without swapping, which includes memory that's used for caches.
+type MemoryFact struct {
+	init *facts.Init
+}
+
+// String returns a simple name for this fact. This is needed so this struct can
+// satisfy the pgraph.Vertex interface.
+func (obj *MemoryFact) String() string {
+	return MemoryFuncName
+}
+
+// Info returns some static info about itself.
+func (obj *MemoryFact) Info() *facts.Info {
+	return &facts.Info{
+		Output: types.NewType(memorySignature),
+	}
+}
+
+// Init runs some startup code for this fact.
+func (obj *MemoryFact) Init(init *facts.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the changing values that this fact has over time.
+func (obj *MemoryFact) Stream(ctx context.Context) error {
+	defer close(obj.init.Output) // always signal when we're done
+
+	ticker := time.NewTicker(memoryInterval)
+	defer ticker.Stop()
+
+	// streams must generate an initial event on startup
+	startChan := make(chan struct{}) // start signal
+	close(startChan)                 // kick it off!
+	var last types.Value
+	for {
+		select {
+		case <-startChan: // kick the loop once at start
+			startChan = nil // disable
+		case <-ticker.C: // received the timer event
+			// pass
+		case <-ctx.Done():
+			return nil
+		}
+
+		f, err := os.Open(hostfacts.MeminfoFile)
+		if err != nil {
+			return errwrap.Wrapf(err, "could not read memory values")
+		}
+		m, err := hostfacts.ParseMeminfo(f)
+		f.Close()
+		if err != nil {
+			return errwrap.Wrapf(err, "could not parse memory values")
+		}
+
+		st := types.NewStruct(types.NewType(memorySignature))
+		for k, field := range map[string]string{
+			"total":      "MemTotal",
+			"free":       "MemFree",
+			"available":  "MemAvailable",
+			"swap_total": "SwapTotal",
+			"swap_free":  "SwapFree",
+		} {
+			if err := st.Set(k, &types.IntValue{V: m[field]}); err != nil {
+				return errwrap.Wrapf(err, "struct could not set key: `%s`", k)
+			}
+		}
+		if last != nil && st.Cmp(last) == nil {
+			continue // nothing changed
+		}
+		last = st
+
+		select {
+		case obj.init.Output <- st:
+			// send
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
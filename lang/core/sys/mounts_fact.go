@@ -0,0 +1,235 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !darwin
+
+package coresys
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/purpleidea/mgmt/lang/funcs/facts"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// MountsFuncName is the name this fact is registered as. It's still a
+	// Func Name because this is the name space the fact is actually using.
+	MountsFuncName = "mounts"
+
+	mountsSignature = "map{str: struct{device str; type str; options []str}}"
+
+	// mountsFile lists the mounts, and can be polled for changes.
+	mountsFile = "/proc/self/mounts"
+)
+
+func init() {
+	facts.ModuleRegister(ModuleName, MountsFuncName, func() facts.Fact { return &MountsFact{} }) // must register the fact and name
+}
+
+// MountsFact is a fact which returns the mounted filesystems, keyed by where
+// they are mounted. If more than one filesystem is mounted in the same place,
+// then the one on top is used. The kernel tells us when this changes, so the
+// new value is sent right away.
+type MountsFact struct {
+	init *facts.Init
+}
+
+// String returns a simple name for this fact. This is needed so this struct can
+// satisfy the pgraph.Vertex interface.
+func (obj *MountsFact) String() string {
+	return MountsFuncName
+}
+
+// Info returns some static info about itself.
+func (obj *MountsFact) Info() *facts.Info {
+	return &facts.Info{
+		Output: types.NewType(mountsSignature),
+	}
+}
+
+// Init runs some startup code for this fact.
+func (obj *MountsFact) Init(init *facts.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the changing values that this fact has over time.
+func (obj *MountsFact) Stream(ctx context.Context) error {
+	defer close(obj.init.Output) // always signal when we're done
+
+	f, err := os.Open(mountsFile)
+	if err != nil {
+		return errwrap.Wrapf(err, "could not open %s", mountsFile)
+	}
+	defer f.Close()
+
+	// closing the write end of this pipe wakes up the poll so it can exit
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer pr.Close()
+
+	eventChan := make(chan error) // updated in goroutine when mounts change
+	closeChan := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	defer pw.Close()
+	defer close(closeChan)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		fds := []unix.PollFd{
+			{Fd: int32(f.Fd()), Events: unix.POLLPRI},
+			{Fd: int32(pr.Fd()), Events: unix.POLLIN},
+		}
+		for {
+			_, err := unix.Poll(fds, -1)
+			if err == unix.EINTR {
+				continue
+			}
+			if err == nil && fds[1].Revents != 0 {
+				return // we're shutting down
+			}
+			select {
+			case eventChan <- err:
+			case <-closeChan:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// streams must generate an initial event on startup
+	startChan := make(chan struct{}) // start signal
+	close(startChan)                 // kick it off!
+	var last types.Value
+	for {
+		select {
+		case <-startChan: // kick the loop once at start
+			startChan = nil // disable
+		case err := <-eventChan:
+			if err != nil {
+				return errwrap.Wrapf(err, "could not watch %s", mountsFile)
+			}
+			if obj.init.Debug {
+				obj.init.Logf("mounts changed")
+			}
+		case <-ctx.Done():
+			return nil
+		}
+
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return errwrap.Wrapf(err, "could not read %s", mountsFile)
+		}
+		m, err := parseMounts(f)
+		if err != nil {
+			return errwrap.Wrapf(err, "could not parse %s", mountsFile)
+		}
+		if last != nil && m.String() == last.String() { // maps cmp by pointer
+			continue // nothing changed
+		}
+		last = m
+
+		select {
+		case obj.init.Output <- m:
+			// send
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// parseMounts parses the contents of /proc/self/mounts into the type of value
+// that this fact returns.
+func parseMounts(r io.Reader) (*types.MapValue, error) {
+	typ := types.NewType(mountsSignature)
+	entries := make(map[string]*types.StructValue)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// lines look like: `/dev/sda1 /boot ext4 rw,relatime 0 0`
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		options := types.NewList(types.NewType("[]str"))
+		for _, x := range strings.Split(fields[3], ",") {
+			options.Add(&types.StrValue{V: x})
+		}
+		st := types.NewStruct(typ.Val)
+		st.Set("device", &types.StrValue{V: unescapeMount(fields[0])})
+		st.Set("type", &types.StrValue{V: fields[2]})
+		st.Set("options", options)
+		entries[unescapeMount(fields[1])] = st // later mounts are on top
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	m := types.NewMap(typ)
+	for k, st := range entries {
+		if err := m.Add(&types.StrValue{V: k}, st); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// unescapeMount undoes the octal escaping of spaces and other special
+// characters, such as `\040`, which the kernel uses in the mounts file.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
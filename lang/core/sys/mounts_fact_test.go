@@ -0,0 +1,69 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !darwin
+
+package coresys
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMounts(t *testing.T) {
+	data := `sysfs /sys sysfs rw,nosuid,nodev,noexec,relatime 0 0
+tmpfs /mnt tmpfs rw,relatime 0 0
+/dev/sda1 /mnt ext4 rw,relatime 0 0
+/dev/sdb1 /media/my\040disk vfat ro 0 0
+`
+	m, err := parseMounts(strings.NewReader(data))
+	if err != nil {
+		t.Errorf("could not parseMounts: %+v", err)
+		return
+	}
+	expected := `{"/media/my disk": struct{device: "/dev/sdb1"; type: "vfat"; options: ["ro"]}, "/mnt": struct{device: "/dev/sda1"; type: "ext4"; options: ["rw", "relatime"]}, "/sys": struct{device: "sysfs"; type: "sysfs"; options: ["rw", "nosuid", "nodev", "noexec", "relatime"]}}`
+	if s := m.String(); s != expected {
+		t.Errorf("unexpected mounts: %s", s)
+	}
+}
+
+func TestUnescapeMount(t *testing.T) {
+	values := map[string]string{
+		`/mnt`:            "/mnt",
+		`/my\040disk`:     "/my disk",
+		`/tab\011and\134`: "/tab\tand\\",
+		`/short\04`:       `/short\04`,
+		`/bad\999`:        `/bad\999`,
+	}
+	for input, expected := range values {
+		if s := unescapeMount(input); s != expected {
+			t.Errorf("expected %q for %q, got %q", expected, input, s)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !darwin
+
+package coresys
+
+import (
+	"context"
+
+	"github.com/purpleidea/mgmt/engine/hostfacts"
+	"github.com/purpleidea/mgmt/lang/funcs/facts"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+const (
+	// VirtualizationFuncName is the name this fact is registered as. It's
+	// still a Func Name because this is the name space the fact is actually
+	// using.
+	VirtualizationFuncName = "virtualization"
+)
+
+func init() {
+	facts.ModuleRegister(ModuleName, VirtualizationFuncName, func() facts.Fact { return &VirtualizationFact{} }) // must register the fact and name
+}
+
+// VirtualizationFact is a fact which returns the type of virtualization that we
+// are running in, or `none` on bare metal. Containers are checked first, so a
+// container inside of a virtual machine returns the container type. The names
+// are similar to what systemd-detect-virt uses, such as `kvm`, `vmware`,
+// `docker` or `lxc`.
+type VirtualizationFact struct {
+	init *facts.Init
+}
+
+// String returns a simple name for this fact. This is needed so this struct can
+// satisfy the pgraph.Vertex interface.
+func (obj *VirtualizationFact) String() string {
+	return VirtualizationFuncName
+}
+
+// Info returns some static info about itself.
+func (obj *VirtualizationFact) Info() *facts.Info {
+	return &facts.Info{
+		Output: types.NewType("str"),
+	}
+}
+
+// Init runs some startup code for this fact.
+func (obj *VirtualizationFact) Init(init *facts.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the single value that this fact has, and then closes.
+func (obj *VirtualizationFact) Stream(ctx context.Context) error {
+	select {
+	case obj.init.Output <- &types.StrValue{
+		V: hostfacts.Virtualization("/"),
+	}:
+		// pass
+	case <-ctx.Done():
+		return nil
+	}
+	close(obj.init.Output) // signal that we're done sending
+	return nil
+}
@@ -0,0 +1,129 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreworld
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/purpleidea/mgmt/engine/hostfacts"
+	"github.com/purpleidea/mgmt/lang/funcs/facts"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// AllFactsFuncName is the name this fact is registered as. It's still a
+	// Func Name because this is the name space the fact is actually using.
+	AllFactsFuncName = "all_facts"
+)
+
+func init() {
+	facts.ModuleRegister(ModuleName, AllFactsFuncName, func() facts.Fact { return &AllFactsFact{} }) // must register the fact and name
+}
+
+// AllFactsFact is a fact which returns the facts of every host that published
+// them into the world, keyed by hostname. A host only publishes them if it was
+// run with the `--publish-facts` option. This is useful for building something
+// like a load balancer config out of the real addresses of every backend.
+type AllFactsFact struct {
+	init *facts.Init
+}
+
+// String returns a simple name for this fact. This is needed so this struct can
+// satisfy the pgraph.Vertex interface.
+func (obj *AllFactsFact) String() string {
+	return AllFactsFuncName
+}
+
+// Info returns some static info about itself.
+func (obj *AllFactsFact) Info() *facts.Info {
+	return &facts.Info{
+		Output: types.NewType(fmt.Sprintf("map{str: %s}", factsType)),
+	}
+}
+
+// Init runs some startup code for this fact.
+func (obj *AllFactsFact) Init(init *facts.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the changing values that this fact has over time.
+func (obj *AllFactsFact) Stream(ctx context.Context) error {
+	defer close(obj.init.Output) // always signal when we're done
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // important so that we cleanup the watch when exiting
+
+	// the watch sends a startup event, so we get an initial value
+	watchChan, err := obj.init.World.StrMapWatch(ctx, hostfacts.Namespace)
+	if err != nil {
+		return err
+	}
+
+	var last types.Value
+	for {
+		select {
+		case err, ok := <-watchChan:
+			if !ok { // closed
+				return nil
+			}
+			if err != nil {
+				return errwrap.Wrapf(err, "channel watch failed on `%s`", hostfacts.Namespace)
+			}
+
+		case <-ctx.Done():
+			return nil
+		}
+
+		all, err := getFacts(ctx, obj.init.World)
+		if err != nil {
+			return err
+		}
+		m := types.NewMap(obj.Info().Output)
+		for hostname, v := range all {
+			if err := m.Add(&types.StrValue{V: hostname}, v); err != nil {
+				return errwrap.Wrapf(err, "map could not add the facts from `%s`", hostname)
+			}
+		}
+
+		if last != nil && m.String() == last.String() { // maps cmp by pointer
+			continue // nothing changed
+		}
+		last = m
+
+		select {
+		case obj.init.Output <- m:
+			// send
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
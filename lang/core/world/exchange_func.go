@@ -36,6 +36,7 @@ import (
 	"github.com/purpleidea/mgmt/lang/funcs"
 	"github.com/purpleidea/mgmt/lang/interfaces"
 	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/pgp"
 	"github.com/purpleidea/mgmt/util/errwrap"
 )
 
@@ -134,6 +135,9 @@ func (obj *ExchangeFunc) Stream(ctx context.Context) error {
 			if namespace == "" {
 				return fmt.Errorf("can't use an empty namespace")
 			}
+			if namespace == pgp.PublicKeyNamespace { // reserved
+				return fmt.Errorf("can't use the reserved namespace: %s", namespace)
+			}
 			if obj.init.Debug {
 				obj.init.Logf("namespace: %s", namespace)
 			}
@@ -152,7 +156,11 @@ func (obj *ExchangeFunc) Stream(ctx context.Context) error {
 				return fmt.Errorf("can't change namespace, previously: `%s`", obj.namespace)
 			}
 
-			value := input.Struct()[exchangeArgNameValue].Str()
+			v := input.Struct()[exchangeArgNameValue]
+			if types.IsSecret(v) { // it would be stored in plaintext
+				return fmt.Errorf("can't exchange a secret value")
+			}
+			value := v.Str()
 			if obj.init.Debug {
 				obj.init.Logf("value: %+v", value)
 			}
@@ -0,0 +1,217 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreworld
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/purpleidea/mgmt/engine"
+	"github.com/purpleidea/mgmt/engine/hostfacts"
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// FactsFuncName is the name this function is registered as.
+	FactsFuncName = "facts"
+
+	// arg names...
+	factsArgNameHostname = "hostname"
+)
+
+// factsType is the type of the facts that each host publishes.
+var factsType = mustTypeOf(hostfacts.Facts{})
+
+func init() {
+	funcs.ModuleRegister(ModuleName, FactsFuncName, func() interfaces.Func { return &FactsFunc{} })
+}
+
+// FactsFunc is a special function which returns the facts that a particular
+// host has published into the world. A host only publishes them if it was run
+// with the `--publish-facts` option. If that host has no facts, then the zero
+// value is returned, which has an empty hostname field.
+type FactsFunc struct {
+	init *interfaces.Init
+
+	hostname string
+
+	last   types.Value
+	result types.Value // last calculated output
+
+	watchChan chan error
+}
+
+// String returns a simple name for this function. This is needed so this struct
+// can satisfy the pgraph.Vertex interface.
+func (obj *FactsFunc) String() string {
+	return FactsFuncName
+}
+
+// ArgGen returns the Nth arg name for this function.
+func (obj *FactsFunc) ArgGen(index int) (string, error) {
+	seq := []string{factsArgNameHostname}
+	if l := len(seq); index >= l {
+		return "", fmt.Errorf("index %d exceeds arg length of %d", index, l)
+	}
+	return seq[index], nil
+}
+
+// Validate makes sure we've built our struct properly. It is usually unused for
+// normal functions that users can use directly.
+func (obj *FactsFunc) Validate() error {
+	return nil
+}
+
+// Info returns some static info about itself.
+func (obj *FactsFunc) Info() *interfaces.Info {
+	return &interfaces.Info{
+		Pure: false, // definitely false
+		Memo: false,
+		Sig:  types.NewType(fmt.Sprintf("func(%s str) %s", factsArgNameHostname, factsType)),
+		Err:  obj.Validate(),
+	}
+}
+
+// Init runs some startup code for this function.
+func (obj *FactsFunc) Init(init *interfaces.Init) error {
+	obj.init = init
+	obj.watchChan = make(chan error) // XXX: sender should close this, but did I implement that part yet???
+	return nil
+}
+
+// Stream returns the changing values that this func has over time.
+func (obj *FactsFunc) Stream(ctx context.Context) error {
+	defer close(obj.init.Output) // the sender closes
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // important so that we cleanup the watch when exiting
+	for {
+		select {
+		case input, ok := <-obj.init.Input:
+			if !ok {
+				obj.init.Input = nil // don't infinite loop back
+				continue             // no more inputs, but don't return!
+			}
+
+			if obj.last != nil && input.Cmp(obj.last) == nil {
+				continue // value didn't change, skip it
+			}
+			obj.last = input // store for next
+
+			hostname := input.Struct()[factsArgNameHostname].Str()
+			if hostname == "" {
+				return fmt.Errorf("can't use an empty hostname")
+			}
+			if obj.init.Debug {
+				obj.init.Logf("hostname: %s", hostname)
+			}
+
+			if obj.hostname == "" {
+				// Don't send a value right away, wait for the
+				// first watch startup event to get one!
+				var err error
+				obj.watchChan, err = obj.init.World.StrMapWatch(ctx, hostfacts.Namespace)
+				if err != nil {
+					return err
+				}
+				obj.hostname = hostname
+				continue
+			}
+			obj.hostname = hostname // it's okay to change hosts
+
+		case err, ok := <-obj.watchChan:
+			if !ok { // closed
+				return nil
+			}
+			if err != nil {
+				return errwrap.Wrapf(err, "channel watch failed on `%s`", hostfacts.Namespace)
+			}
+
+		case <-ctx.Done():
+			return nil
+		}
+
+		all, err := getFacts(ctx, obj.init.World)
+		if err != nil {
+			return err
+		}
+		result, exists := all[obj.hostname]
+		if !exists {
+			result = types.NewStruct(factsType)
+		}
+
+		// if the result is still the same, skip sending an update...
+		if obj.result != nil && result.Cmp(obj.result) == nil {
+			continue // result didn't change
+		}
+		obj.result = result // store new result
+
+		select {
+		case obj.init.Output <- obj.result: // send
+			// pass
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// getFacts returns the facts of every host that has published some, keyed by
+// their hostname.
+func getFacts(ctx context.Context, world engine.World) (map[string]types.Value, error) {
+	keyMap, err := world.StrMapGet(ctx, hostfacts.Namespace)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "channel read failed on `%s`", hostfacts.Namespace)
+	}
+	result := make(map[string]types.Value)
+	for hostname, data := range keyMap {
+		facts, err := hostfacts.Decode(data)
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "invalid facts from `%s`", hostname)
+		}
+		v, err := types.ValueOfGolang(*facts)
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "could not convert the facts from `%s`", hostname)
+		}
+		result[hostname] = v
+	}
+	return result, nil
+}
+
+// mustTypeOf returns the type of this golang value, and panics if it can't.
+func mustTypeOf(x interface{}) *types.Type {
+	typ, err := types.TypeOf(reflect.TypeOf(x))
+	if err != nil {
+		panic(fmt.Sprintf("could not determine type: %+v", err))
+	}
+	return typ
+}
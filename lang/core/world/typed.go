@@ -0,0 +1,116 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreworld
+
+import (
+	"fmt"
+
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+// storable checks that values of this type can be stored in the world. Only
+// functions can't be.
+func storable(vars map[string]*types.Type) error {
+	var check func(*types.Type) error
+	check = func(typ *types.Type) error {
+		if typ == nil {
+			return fmt.Errorf("type is not known")
+		}
+		switch typ.Kind {
+		case types.KindFunc:
+			return fmt.Errorf("can't store functions")
+		case types.KindList:
+			return check(typ.Val)
+		case types.KindMap:
+			if err := check(typ.Key); err != nil {
+				return err
+			}
+			return check(typ.Val)
+		case types.KindStruct:
+			for _, k := range typ.Ord {
+				if err := check(typ.Map[k]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return check(vars["?T"])
+}
+
+// encodeTyped returns the type and the string that a value is stored as. A str
+// is stored as itself, so that the untyped functions can still read it, and all
+// the other types are stored as json. Secrets are refused, since they would be
+// stored in plaintext.
+func encodeTyped(value types.Value) (string, string, error) {
+	if types.IsSecret(value) {
+		return "", "", fmt.Errorf("can't store a secret value")
+	}
+	typ := value.Type()
+	if typ.Kind == types.KindStr {
+		return typ.String(), value.Str(), nil
+	}
+	b, err := types.ValueToJSON(value)
+	if err != nil {
+		return "", "", err
+	}
+	return typ.String(), string(b), nil
+}
+
+// storedType parses the type that was stored beside a value. A string that was
+// stored without a type, such as with the untyped functions, is a str.
+func storedType(stored string) (*types.Type, error) {
+	if stored == "" {
+		return types.TypeStr, nil
+	}
+	t := types.NewType(stored)
+	if t == nil {
+		return nil, fmt.Errorf("invalid stored type: %s", stored)
+	}
+	return t, nil
+}
+
+// decodeTyped is the opposite of encodeTyped. It errors if the stored type is
+// not the type that we expect, since the values are set by other code, which
+// could be using a different type for them.
+func decodeTyped(typ *types.Type, stored, data string) (types.Value, error) {
+	t, err := storedType(stored)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Cmp(typ); err != nil {
+		return nil, errwrap.Wrapf(err, "stored type %s does not match the type %s", t, typ)
+	}
+	if typ.Kind == types.KindStr {
+		return &types.StrValue{V: data}, nil
+	}
+	return types.ValueFromJSON(typ, []byte(data))
+}
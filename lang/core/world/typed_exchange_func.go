@@ -0,0 +1,211 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreworld
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/funcs/templatepoly"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/pgp"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// TypedExchangeFuncName is the name this function is registered as.
+	TypedExchangeFuncName = "typed_exchange"
+)
+
+func init() {
+	funcs.ModuleRegister(ModuleName, TypedExchangeFuncName, func() interfaces.Func {
+		tmpl := templatepoly.NewTemplate(fmt.Sprintf("func(%s str, %s ?T) map{str: ?T}", exchangeArgNameNamespace, exchangeArgNameValue))
+		tmpl.Check = storable
+		return &TypedExchangeFunc{
+			Template: tmpl,
+		}
+	})
+}
+
+var _ interfaces.PolyFunc = &TypedExchangeFunc{} // ensure it meets this expectation
+
+// TypedExchangeFunc is like exchange, except that the value can be of any type
+// other than a function. The type is stored beside the value, and it is checked
+// against the type that we expect when reading the values of the other hosts.
+// It errors if any of them set a value of a different type in this namespace.
+// A str is stored as itself, so that it can still be read by exchange.
+type TypedExchangeFunc struct {
+	*templatepoly.Template // provides ArgGen, Unify, Build and Validate
+
+	init *interfaces.Init
+
+	namespace string
+
+	last   types.Value
+	result types.Value // last calculated output
+
+	watchChan chan error
+}
+
+// String returns a simple name for this function. This is needed so this struct
+// can satisfy the pgraph.Vertex interface.
+func (obj *TypedExchangeFunc) String() string {
+	return TypedExchangeFuncName
+}
+
+// Info returns some static info about itself. Build must be called before this
+// will return correct data.
+func (obj *TypedExchangeFunc) Info() *interfaces.Info {
+	return &interfaces.Info{
+		Pure: false, // definitely false
+		Memo: false,
+		// output is map of: hostname => value
+		Sig: obj.Type(),
+		Err: obj.Validate(),
+	}
+}
+
+// Init runs some startup code for this function.
+func (obj *TypedExchangeFunc) Init(init *interfaces.Init) error {
+	obj.init = init
+	obj.watchChan = make(chan error) // XXX: sender should close this, but did I implement that part yet???
+	return nil
+}
+
+// Stream returns the changing values that this func has over time.
+func (obj *TypedExchangeFunc) Stream(ctx context.Context) error {
+	defer close(obj.init.Output) // the sender closes
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for {
+		select {
+		case input, ok := <-obj.init.Input:
+			if !ok {
+				obj.init.Input = nil // don't infinite loop back
+				continue             // no more inputs, but don't return!
+			}
+
+			if obj.last != nil && input.Cmp(obj.last) == nil {
+				continue // value didn't change, skip it
+			}
+			obj.last = input // store for next
+
+			namespace := input.Struct()[exchangeArgNameNamespace].Str()
+			if namespace == "" {
+				return fmt.Errorf("can't use an empty namespace")
+			}
+			if namespace == pgp.PublicKeyNamespace { // reserved
+				return fmt.Errorf("can't use the reserved namespace: %s", namespace)
+			}
+			if obj.init.Debug {
+				obj.init.Logf("namespace: %s", namespace)
+			}
+
+			// TODO: support changing the namespace over time...
+			if obj.namespace == "" {
+				obj.namespace = namespace // store it
+				var err error
+				obj.watchChan, err = obj.init.World.StrMapWatch(ctx, obj.namespace) // watch for var changes
+				if err != nil {
+					return err
+				}
+
+			} else if obj.namespace != namespace {
+				return fmt.Errorf("can't change namespace, previously: `%s`", obj.namespace)
+			}
+
+			typ, value, err := encodeTyped(input.Struct()[exchangeArgNameValue])
+			if err != nil {
+				return errwrap.Wrapf(err, "could not encode the value")
+			}
+			if obj.init.Debug {
+				obj.init.Logf("value: %s", value)
+			}
+
+			if err := obj.init.World.TypedStrMapSet(ctx, obj.namespace, typ, value); err != nil {
+				return errwrap.Wrapf(err, "namespace write error of `%s` to `%s`", value, obj.namespace)
+			}
+
+			continue // we get values on the watch chan, not here!
+
+		case err, ok := <-obj.watchChan:
+			if !ok { // closed
+				return nil
+			}
+			if err != nil {
+				return errwrap.Wrapf(err, "channel watch failed on `%s`", obj.namespace)
+			}
+
+			result, err := typedStrMap(ctx, obj.init, obj.namespace, obj.Type().Out)
+			if err != nil {
+				return err
+			}
+
+			// if the result is still the same, skip sending an update...
+			if obj.result != nil && result.String() == obj.result.String() { // maps cmp by pointer
+				continue // result didn't change
+			}
+			obj.result = result // store new result
+
+		case <-ctx.Done():
+			return nil
+		}
+
+		select {
+		case obj.init.Output <- obj.result: // send
+			// pass
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// typedStrMap reads the values of every host in a namespace into a map of this
+// type, keyed by hostname.
+func typedStrMap(ctx context.Context, init *interfaces.Init, namespace string, typ *types.Type) (types.Value, error) {
+	values, stored, err := init.World.TypedStrMapGet(ctx, namespace)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "channel read failed on `%s`", namespace)
+	}
+
+	d := types.NewMap(typ)
+	for k, v := range values {
+		val, err := decodeTyped(typ.Val, stored[k], v)
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "invalid value from `%s` in `%s`", k, namespace)
+		}
+		if err := d.Add(&types.StrValue{V: k}, val); err != nil {
+			return nil, errwrap.Wrapf(err, "map could not add key `%s`, val: `%s`", k, v)
+		}
+	}
+	return d, nil
+}
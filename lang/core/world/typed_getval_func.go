@@ -0,0 +1,204 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreworld
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/funcs/templatepoly"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// TypedGetValFuncName is the name this function is registered as.
+	TypedGetValFuncName = "typed_getval"
+)
+
+func init() {
+	funcs.ModuleRegister(ModuleName, TypedGetValFuncName, func() interfaces.Func {
+		tmpl := templatepoly.NewTemplate(fmt.Sprintf("func(%s str) struct{%s ?T; %s bool}", getValArgNameKey, getValFieldNameValue, getValFieldNameExists))
+		tmpl.Check = storable
+		return &TypedGetValFunc{
+			Template: tmpl,
+		}
+	})
+}
+
+var _ interfaces.PolyFunc = &TypedGetValFunc{} // ensure it meets this expectation
+
+// TypedGetValFunc is like getval, except that the value can be of any type
+// other than a function. The type must be known from the way that the result is
+// used, and it errors if the type stored beside the value is a different one.
+// A value which was stored without a type, such as by the kv resource, is read
+// as a str. If the key doesn't exist, then the value is the zero value.
+type TypedGetValFunc struct {
+	*templatepoly.Template // provides ArgGen, Unify, Build and Validate
+
+	init *interfaces.Init
+
+	key string
+
+	last   types.Value
+	result types.Value // last calculated output
+
+	watchChan chan error
+}
+
+// String returns a simple name for this function. This is needed so this struct
+// can satisfy the pgraph.Vertex interface.
+func (obj *TypedGetValFunc) String() string {
+	return TypedGetValFuncName
+}
+
+// Info returns some static info about itself. Build must be called before this
+// will return correct data.
+func (obj *TypedGetValFunc) Info() *interfaces.Info {
+	return &interfaces.Info{
+		Pure: false, // definitely false
+		Memo: false,
+		Sig:  obj.Type(),
+		Err:  obj.Validate(),
+	}
+}
+
+// Init runs some startup code for this function.
+func (obj *TypedGetValFunc) Init(init *interfaces.Init) error {
+	obj.init = init
+	obj.watchChan = make(chan error) // XXX: sender should close this, but did I implement that part yet???
+	return nil
+}
+
+// Stream returns the changing values that this func has over time.
+func (obj *TypedGetValFunc) Stream(ctx context.Context) error {
+	defer close(obj.init.Output) // the sender closes
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // important so that we cleanup the watch when exiting
+	for {
+		select {
+		case input, ok := <-obj.init.Input:
+			if !ok {
+				obj.init.Input = nil // don't infinite loop back
+				continue             // no more inputs, but don't return!
+			}
+
+			if obj.last != nil && input.Cmp(obj.last) == nil {
+				continue // value didn't change, skip it
+			}
+			obj.last = input // store for next
+
+			key := input.Struct()[getValArgNameKey].Str()
+			if key == "" {
+				return fmt.Errorf("can't use an empty key")
+			}
+			if obj.init.Debug {
+				obj.init.Logf("key: %s", key)
+			}
+
+			// TODO: support changing the key over time...
+			if obj.key == "" {
+				obj.key = key // store it
+				var err error
+				// Don't send a value right away, wait for the
+				// first ValueWatch startup event to get one!
+				obj.watchChan, err = obj.init.World.StrWatch(ctx, obj.key) // watch for var changes
+				if err != nil {
+					return err
+				}
+
+			} else if obj.key != key {
+				return fmt.Errorf("can't change key, previously: `%s`", obj.key)
+			}
+
+			continue // we get values on the watch chan, not here!
+
+		case err, ok := <-obj.watchChan:
+			if !ok { // closed
+				return nil
+			}
+			if err != nil {
+				return errwrap.Wrapf(err, "channel watch failed on `%s`", obj.key)
+			}
+
+			result, err := obj.getValue(ctx) // get the value...
+			if err != nil {
+				return err
+			}
+
+			// if the result is still the same, skip sending an update...
+			if obj.result != nil && result.String() == obj.result.String() { // maps cmp by pointer
+				continue // result didn't change
+			}
+			obj.result = result // store new result
+
+		case <-ctx.Done():
+			return nil
+		}
+
+		select {
+		case obj.init.Output <- obj.result: // send
+			// pass
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// getValue gets the value we're looking for.
+func (obj *TypedGetValFunc) getValue(ctx context.Context) (types.Value, error) {
+	typ := obj.Type().Out
+	valueType := typ.Map[getValFieldNameValue]
+
+	exists := true // assume true
+	var val types.Value
+	stored, data, err := obj.init.World.TypedStrGet(ctx, obj.key)
+	if err != nil && obj.init.World.StrIsNotExist(err) {
+		exists = false // val doesn't exist
+		val = valueType.New()
+	} else if err != nil {
+		return nil, errwrap.Wrapf(err, "channel read failed on `%s`", obj.key)
+	} else if val, err = decodeTyped(valueType, stored, data); err != nil {
+		return nil, errwrap.Wrapf(err, "invalid value in `%s`", obj.key)
+	}
+
+	b := &types.BoolValue{V: exists}
+	st := types.NewStruct(typ)
+	if err := st.Set(getValFieldNameValue, val); err != nil {
+		return nil, errwrap.Wrapf(err, "struct could not add field `%s`, val: `%s`", getValFieldNameValue, val)
+	}
+	if err := st.Set(getValFieldNameExists, b); err != nil {
+		return nil, errwrap.Wrapf(err, "struct could not add field `%s`, val: `%s`", getValFieldNameExists, b)
+	}
+
+	return st, nil // put struct into interface type
+}
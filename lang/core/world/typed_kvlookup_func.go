@@ -0,0 +1,173 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package coreworld
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/funcs/templatepoly"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// TypedKVLookupFuncName is the name this function is registered as.
+	TypedKVLookupFuncName = "typed_kvlookup"
+)
+
+func init() {
+	funcs.ModuleRegister(ModuleName, TypedKVLookupFuncName, func() interfaces.Func {
+		tmpl := templatepoly.NewTemplate(fmt.Sprintf("func(%s str) map{str: ?T}", kvLookupArgNameNamespace))
+		tmpl.Check = storable
+		return &TypedKVLookupFunc{
+			Template: tmpl,
+		}
+	})
+}
+
+var _ interfaces.PolyFunc = &TypedKVLookupFunc{} // ensure it meets this expectation
+
+// TypedKVLookupFunc is like kvlookup, except that it reads the values that were
+// set with typed_exchange. The type of the values must be known from the way
+// that the result is used, such as with `$m map{str: int} = ...` and it errors
+// if any host set a value of a different type in this namespace.
+type TypedKVLookupFunc struct {
+	*templatepoly.Template // provides ArgGen, Unify, Build and Validate
+
+	init *interfaces.Init
+
+	namespace string
+
+	last   types.Value
+	result types.Value // last calculated output
+
+	watchChan chan error
+}
+
+// String returns a simple name for this function. This is needed so this struct
+// can satisfy the pgraph.Vertex interface.
+func (obj *TypedKVLookupFunc) String() string {
+	return TypedKVLookupFuncName
+}
+
+// Info returns some static info about itself. Build must be called before this
+// will return correct data.
+func (obj *TypedKVLookupFunc) Info() *interfaces.Info {
+	return &interfaces.Info{
+		Pure: false, // definitely false
+		Memo: false,
+		// output is map of: hostname => value
+		Sig: obj.Type(),
+		Err: obj.Validate(),
+	}
+}
+
+// Init runs some startup code for this function.
+func (obj *TypedKVLookupFunc) Init(init *interfaces.Init) error {
+	obj.init = init
+	obj.watchChan = make(chan error) // XXX: sender should close this, but did I implement that part yet???
+	return nil
+}
+
+// Stream returns the changing values that this func has over time.
+func (obj *TypedKVLookupFunc) Stream(ctx context.Context) error {
+	defer close(obj.init.Output) // the sender closes
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for {
+		select {
+		case input, ok := <-obj.init.Input:
+			if !ok {
+				obj.init.Input = nil // don't infinite loop back
+				continue             // no more inputs, but don't return!
+			}
+
+			if obj.last != nil && input.Cmp(obj.last) == nil {
+				continue // value didn't change, skip it
+			}
+			obj.last = input // store for next
+
+			namespace := input.Struct()[kvLookupArgNameNamespace].Str()
+			if namespace == "" {
+				return fmt.Errorf("can't use an empty namespace")
+			}
+			if obj.init.Debug {
+				obj.init.Logf("namespace: %s", namespace)
+			}
+
+			// TODO: support changing the namespace over time...
+			if obj.namespace == "" {
+				obj.namespace = namespace // store it
+				var err error
+				obj.watchChan, err = obj.init.World.StrMapWatch(ctx, obj.namespace) // watch for var changes
+				if err != nil {
+					return err
+				}
+
+			} else if obj.namespace != namespace {
+				return fmt.Errorf("can't change namespace, previously: `%s`", obj.namespace)
+			}
+
+			continue // we get values on the watch chan, not here!
+
+		case err, ok := <-obj.watchChan:
+			if !ok { // closed
+				return nil
+			}
+			if err != nil {
+				return errwrap.Wrapf(err, "channel watch failed on `%s`", obj.namespace)
+			}
+
+			result, err := typedStrMap(ctx, obj.init, obj.namespace, obj.Type().Out)
+			if err != nil {
+				return err
+			}
+
+			// if the result is still the same, skip sending an update...
+			if obj.result != nil && result.String() == obj.result.String() { // maps cmp by pointer
+				continue // result didn't change
+			}
+			obj.result = result // store new result
+
+		case <-ctx.Done():
+			return nil
+		}
+
+		select {
+		case obj.init.Output <- obj.result: // send
+			// pass
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
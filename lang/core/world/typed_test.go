@@ -0,0 +1,93 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package coreworld
+
+import (
+	"testing"
+
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func TestTyped0(t *testing.T) {
+	m := types.NewMap(types.NewType("map{int: []str}"))
+	list := types.NewList(types.NewType("[]str"))
+	list.Add(&types.StrValue{V: "a"})
+	m.Add(&types.IntValue{V: 42}, list)
+
+	for _, value := range []types.Value{&types.StrValue{V: "hello"}, m} {
+		typ, data, err := encodeTyped(value)
+		if err != nil {
+			t.Errorf("could not encode %s: %+v", value, err)
+			continue
+		}
+		if typ != value.Type().String() {
+			t.Errorf("unexpected type for %s: %s", value, typ)
+		}
+		out, err := decodeTyped(value.Type(), typ, data)
+		if err != nil {
+			t.Errorf("could not decode %s: %+v", data, err)
+			continue
+		}
+		if out.String() != value.String() {
+			t.Errorf("values differ: %s != %s", value, out)
+		}
+	}
+
+	// a str is stored as itself, so that the untyped functions can read it
+	if _, data, _ := encodeTyped(&types.StrValue{V: "hello"}); data != "hello" {
+		t.Errorf("unexpected encoding of a str: %s", data)
+	}
+
+	// a value without a stored type is a str
+	if out, err := decodeTyped(types.TypeStr, "", "hello"); err != nil || out.Str() != "hello" {
+		t.Errorf("could not decode an untyped str: %v, %+v", out, err)
+	}
+	if _, err := decodeTyped(types.NewType("int"), "", "42"); err == nil {
+		t.Errorf("expected an error when decoding an untyped int")
+	}
+	if _, err := decodeTyped(types.NewType("int"), "str", "42"); err == nil {
+		t.Errorf("expected an error when decoding a str as an int")
+	}
+
+	// secrets would be stored in plaintext
+	if _, _, err := encodeTyped(types.NewSecret("hunter2")); err == nil {
+		t.Errorf("expected an error when encoding a secret")
+	}
+
+	// a value that was stored without a type is a str
+	if typ, err := storedType(""); err != nil || typ.Cmp(types.TypeStr) != nil {
+		t.Errorf("unexpected type for an untyped value: %v, %+v", typ, err)
+	}
+	if _, err := storedType("struct{"); err == nil {
+		t.Errorf("expected an error for an invalid stored type")
+	}
+}
@@ -31,15 +31,30 @@
 package download
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/purpleidea/mgmt/lang/interfaces"
 	"github.com/purpleidea/mgmt/util/errwrap"
 
 	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	// DefaultBackoff is the delay before the first retry of a failed
+	// network operation, if none is specified. It doubles on each retry.
+	DefaultBackoff = 1 * time.Second
+
+	// MaxBackoff is the longest delay that we'll ever wait between retries.
+	MaxBackoff = 60 * time.Second
 )
 
 // Downloader implements the Downloader interface. It provides a mechanism to
@@ -56,16 +71,71 @@ type Downloader struct {
 	// usually zero.
 	Retry int
 
-	// TODO: add a retry backoff parameter
+	// Backoff is the delay before the first retry. It doubles after every
+	// failed attempt, up to MaxBackoff. If it is zero, then DefaultBackoff
+	// is used.
+	Backoff time.Duration
+
+	// Cache is an optional absolute directory path which stores a bare git
+	// mirror of each remote repo. Since git objects are content-addressed,
+	// the same mirror can safely be shared by many different prefixes and
+	// module paths. The module is cloned from its mirror, and the network
+	// is only used when the mirror doesn't have what we need. If this is
+	// empty, then we clone straight from the remote.
+	Cache string
+
+	// Lock is the previously written lock file. If it is specified, then
+	// each module is checked out at the locked commit, and its content is
+	// verified against the locked hash, unless the version constraint has
+	// changed, or we were asked to update. This can be nil.
+	Lock *Lock
+
+	mutex    *sync.Mutex
+	resolved map[string]*LockedModule // the modules we got in this run
+	dirs     map[string]string        // where each module was stored
 }
 
 // Init initializes the downloader with some core structures we'll need.
 func (obj *Downloader) Init(info *interfaces.DownloadInfo) error {
 	obj.info = info
+	obj.mutex = &sync.Mutex{}
+	obj.resolved = make(map[string]*LockedModule)
+	obj.dirs = make(map[string]string)
+
+	if obj.Cache != "" && (!strings.HasPrefix(obj.Cache, "/") || !strings.HasSuffix(obj.Cache, "/")) {
+		return fmt.Errorf("cache path (`%s`) must be an absolute dir", obj.Cache)
+	}
 	return nil
 }
 
-// Get runs a single download of an import and stores it on disk.
+// Locked returns a new lock which contains every module that was downloaded by
+// this downloader so far. This is what should be written to the lock file.
+func (obj *Downloader) Locked() *Lock {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	lock := NewLock()
+	for p, x := range obj.resolved {
+		lock.Modules[p] = x
+	}
+	return lock
+}
+
+// Dirs returns a map of every module that was downloaded by this downloader so
+// far, from its import path to the absolute directory which contains it.
+func (obj *Downloader) Dirs() map[string]string {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	dirs := make(map[string]string)
+	for p, dir := range obj.dirs {
+		dirs[p] = dir
+	}
+	return dirs
+}
+
+// Get runs a single download of an import and stores it on disk. If the import
+// has a version constraint, then the matching commit is checked out. If there
+// is a lock entry for this module, then the locked commit is used instead, and
+// the content hash of the module is verified.
 // XXX: this should only touch the filesystem via obj.info.Fs, but that is not
 // implemented at the moment, so we cheat and use the local fs directly. This is
 // not disastrous, since we only run Get on a local fs, since we don't download
@@ -85,7 +155,7 @@ func (obj *Downloader) Get(info *interfaces.ImportData, modulesPath string) erro
 		if err == nil {
 			return fmt.Errorf("module path (`%s`) must be a dir", modulesPath)
 		}
-		if err == os.ErrNotExist {
+		if os.IsNotExist(err) {
 			return fmt.Errorf("module path (`%s`) must exist", modulesPath)
 		}
 		return errwrap.Wrapf(err, "could not read module path (`%s`)", modulesPath)
@@ -98,16 +168,45 @@ func (obj *Downloader) Get(info *interfaces.ImportData, modulesPath string) erro
 	}
 	// TODO: error early if we're provided *ImportData that we can't act on
 
-	pull := false
+	c, err := parseConstraint(info.Version)
+	if err != nil {
+		return errwrap.Wrapf(err, "invalid version for `%s`", info.Name)
+	}
+
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+
+	// Every import of the same module shares a single directory, so they
+	// must all agree on the version. The ones without a constraint accept
+	// whatever is already there.
+	if x, exists := obj.resolved[info.Path]; exists {
+		if info.Version != "" && info.Version != x.Version {
+			return fmt.Errorf("module `%s` is required at both `%s` and `%s`", info.Name, x.Version, info.Version)
+		}
+		return nil // already done
+	}
+
+	var locked *LockedModule
+	if obj.Lock != nil && !obj.info.Update {
+		locked = obj.Lock.Modules[info.Path]
+	}
+	if locked != nil && (locked.URL != info.URL || locked.Version != info.Version) {
+		locked = nil // the requirement changed, so resolve it again
+	}
+
 	dir := modulesPath + info.Path // TODO: is this dir unique?
 	isBare := false
 	options := &git.CloneOptions{
-		URL: info.URL,
+		URL:  info.URL,
+		Tags: git.AllTags,
 		// TODO: do we want to add an option for infinite recursion here?
 		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
 	}
 
 	msg := fmt.Sprintf("downloading `%s` to: `%s`", info.URL, dir)
+	if info.Version != "" {
+		msg += fmt.Sprintf(" at: `%s`", info.Version)
+	}
 	if obj.info.Noop {
 		msg = "(noop) " + msg // add prefix
 	}
@@ -115,19 +214,44 @@ func (obj *Downloader) Get(info *interfaces.ImportData, modulesPath string) erro
 	if obj.info.Debug {
 		obj.info.Logf("info: `%+v`", info)
 		obj.info.Logf("options: `%+v`", options)
+		if locked != nil {
+			obj.info.Logf("locked: `%+v`", locked)
+		}
 	}
 	if obj.info.Noop {
 		return nil // done early
 	}
+
+	// clone from the local mirror instead if we have a cache
+	if obj.Cache != "" {
+		commit := ""
+		if locked != nil {
+			commit = locked.Commit
+		}
+		mirror, err := obj.mirror(info.URL, commit)
+		if err != nil {
+			return err
+		}
+		options.URL = mirror
+	}
+
 	// FIXME: replace with:
 	// `git.Clone(s storage.Storer, worktree billy.Filesystem, o *CloneOptions)`
 	// that uses an `fs engine.Fs` wrapped to the git Filesystem interface:
 	// `billyFs := desfacer.New(obj.info.Fs)`
 	// TODO: repo, err := git.Clone(??? storage.Storer, billyFs, options)
-	repo, err := git.PlainClone(path.Clean(dir), isBare, options)
+	fresh := true
+	var repo *git.Repository
+	err = obj.retry(func() error {
+		var err error
+		repo, err = git.PlainClone(path.Clean(dir), isBare, options)
+		return err
+	})
 	if err == git.ErrRepositoryAlreadyExists {
-		if obj.info.Update {
-			pull = true // make sure to pull latest...
+		fresh = false
+		repo, err = git.PlainOpen(path.Clean(dir))
+		if err != nil {
+			return errwrap.Wrapf(err, "can't open repo: `%s`", dir)
 		}
 	} else if err != nil {
 		return errwrap.Wrapf(err, "can't clone repo: `%s` to: `%s`", info.URL, dir)
@@ -142,25 +266,269 @@ func (obj *Downloader) Get(info *interfaces.ImportData, modulesPath string) erro
 		return errwrap.Wrapf(err, "can't work with nil work tree for: `%s`", dir)
 	}
 
-	// TODO: do we need to checkout master first, before pulling?
-	if pull {
-		options := &git.PullOptions{
-			// TODO: do we want to add an option for infinite recursion here?
-			RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+	// an existing repo gets the latest tags and branches if we're updating
+	if !fresh && obj.info.Update {
+		if err := obj.fetch(repo, options.URL); err != nil {
+			return err
 		}
-		err := worktree.Pull(options)
-		if err != nil && err != git.NoErrAlreadyUpToDate {
-			return errwrap.Wrapf(err, "can't pull latest from: `%s`", info.URL)
+	}
+
+	var hash plumbing.Hash
+	if locked != nil {
+		hash = plumbing.NewHash(locked.Commit)
+		if _, err := repo.CommitObject(hash); err != nil { // not here yet
+			if err := obj.fetch(repo, options.URL); err != nil {
+				return err
+			}
+		}
+	} else {
+		hash, err = obj.resolve(repo, c)
+		if err != nil && !fresh && !obj.info.Update { // try again
+			if err := obj.fetch(repo, options.URL); err != nil {
+				return err
+			}
+			hash, err = obj.resolve(repo, c)
+		}
+		if err != nil {
+			return errwrap.Wrapf(err, "can't find version `%s` of: `%s`", info.Version, info.URL)
 		}
 	}
 
-	// TODO: checkout requested sha1/tag if one was specified...
-	// if err := worktree.Checkout(opts *CheckoutOptions)
+	if err := obj.checkout(repo, worktree, hash); err != nil {
+		return errwrap.Wrapf(err, "can't checkout `%s` in: `%s`", hash, dir)
+	}
+
+	sum, err := HashDir(obj.info.Fs, dir)
+	if err != nil {
+		return errwrap.Wrapf(err, "can't hash module: `%s`", dir)
+	}
+	if locked != nil && sum != locked.Hash {
+		return fmt.Errorf("module `%s` does not match the lock file: got hash `%s`, expected `%s`", info.Name, sum, locked.Hash)
+	}
 
 	// does the repo have a metadata file present? (we'll validate it later)
 	if _, err := obj.info.Fs.Stat(dir + interfaces.MetadataFilename); err != nil {
 		return errwrap.Wrapf(err, "could not read repo metadata file `%s` in its root", interfaces.MetadataFilename)
 	}
 
+	obj.resolved[info.Path] = &LockedModule{
+		URL:     info.URL,
+		Version: info.Version,
+		Commit:  hash.String(),
+		Hash:    sum,
+	}
+	obj.dirs[info.Path] = dir
+	return nil
+}
+
+// mirror returns the path to the cached mirror of the remote repo. It creates
+// the mirror if it doesn't exist yet. If we have the locked commit, then we
+// don't touch the network at all. Otherwise we fetch the latest from the remote
+// so that the version can be resolved. If that fails, and we didn't need a
+// particular commit, then we carry on with whatever the mirror already has.
+func (obj *Downloader) mirror(url, commit string) (string, error) {
+	sum := sha256.Sum256([]byte(url))
+	dir := obj.Cache + hex.EncodeToString(sum[:]) + "/"
+
+	repo, err := git.PlainOpen(path.Clean(dir))
+	if err == git.ErrRepositoryNotExists {
+		obj.info.Logf("mirroring `%s` to: `%s`", url, dir)
+		options := &git.CloneOptions{
+			URL:    url,
+			Mirror: true, // this is a bare repo which has every ref
+		}
+		err := obj.retry(func() error {
+			_, err := git.PlainClone(path.Clean(dir), true, options)
+			return err
+		})
+		if err != nil {
+			return "", errwrap.Wrapf(err, "can't mirror repo: `%s` to: `%s`", url, dir)
+		}
+		return dir, nil
+	}
+	if err != nil {
+		return "", errwrap.Wrapf(err, "can't open mirror: `%s`", dir)
+	}
+
+	if commit != "" && !obj.info.Update {
+		if _, err := repo.CommitObject(plumbing.NewHash(commit)); err == nil {
+			return dir, nil // we already have it
+		}
+	}
+	if err := obj.fetch(repo, url); err != nil {
+		if commit != "" || obj.info.Update {
+			return "", err
+		}
+		obj.info.Logf("using the mirror of `%s` as is: %v", url, err)
+	}
+	return dir, nil
+}
+
+// fetch gets the latest branches and tags from the remote repo.
+func (obj *Downloader) fetch(repo *git.Repository, url string) error {
+	options := &git.FetchOptions{
+		Tags:  git.AllTags,
+		Force: true, // tags might have moved
+	}
+	err := obj.retry(func() error {
+		err := repo.Fetch(options)
+		if err == git.NoErrAlreadyUpToDate {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return errwrap.Wrapf(err, "can't fetch latest from: `%s`", url)
+	}
 	return nil
 }
+
+// retry runs the function until it succeeds, or until we run out of retries.
+// It waits a little bit longer between each attempt. A repo that already exists
+// is not a failure that can be fixed by retrying, so that error is returned.
+func (obj *Downloader) retry(fn func() error) error {
+	backoff := obj.Backoff
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+	for i := 0; ; i++ {
+		err := fn()
+		if err == nil || err == git.ErrRepositoryAlreadyExists {
+			return err
+		}
+		if obj.Retry >= 0 && i >= obj.Retry { // negative is infinite
+			return err
+		}
+		obj.info.Logf("retrying in %s: %v", backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > MaxBackoff {
+			backoff = MaxBackoff
+		}
+	}
+}
+
+// resolve returns the commit which matches the version constraint.
+func (obj *Downloader) resolve(repo *git.Repository, c *constraint) (plumbing.Hash, error) {
+	switch c.kind {
+	case constraintAny:
+		if !obj.info.Update { // whatever we have is fine
+			ref, err := repo.Head()
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			return ref.Hash(), nil
+		}
+		// we want the latest commit on the default remote branch
+		remote, err := repo.Remote(git.DefaultRemoteName)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		refs, err := remote.List(&git.ListOptions{})
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		for _, ref := range refs {
+			if ref.Name() != plumbing.HEAD {
+				continue
+			}
+			if ref.Type() == plumbing.HashReference {
+				return ref.Hash(), nil
+			}
+			branch := ref.Target().Short()
+			return resolveRevision(repo, "refs/remotes/"+git.DefaultRemoteName+"/"+branch)
+		}
+		return plumbing.ZeroHash, fmt.Errorf("remote has no default branch")
+
+	case constraintCommit:
+		if len(c.raw) == 40 {
+			hash := plumbing.NewHash(c.raw)
+			if _, err := repo.CommitObject(hash); err != nil {
+				return plumbing.ZeroHash, err
+			}
+			return hash, nil
+		}
+		iter, err := repo.CommitObjects()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		defer iter.Close()
+		found := []plumbing.Hash{}
+		err = iter.ForEach(func(commit *object.Commit) error {
+			if strings.HasPrefix(commit.Hash.String(), c.raw) {
+				found = append(found, commit.Hash)
+			}
+			return nil
+		})
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		if len(found) != 1 {
+			return plumbing.ZeroHash, fmt.Errorf("found %d commits matching `%s`", len(found), c.raw)
+		}
+		return found[0], nil
+
+	case constraintRef:
+		hash, err := resolveRevision(repo, "refs/tags/"+c.raw)
+		if err == nil {
+			return hash, nil
+		}
+		return resolveRevision(repo, "refs/remotes/"+git.DefaultRemoteName+"/"+c.raw)
+
+	case constraintRange:
+		iter, err := repo.Tags()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		defer iter.Close()
+		tags := []string{}
+		err = iter.ForEach(func(ref *plumbing.Reference) error {
+			tags = append(tags, ref.Name().Short())
+			return nil
+		})
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		best := c.Best(tags)
+		if best == "" {
+			return plumbing.ZeroHash, fmt.Errorf("no tag matches `%s`", c.raw)
+		}
+		return resolveRevision(repo, "refs/tags/"+best)
+	}
+
+	return plumbing.ZeroHash, fmt.Errorf("unknown constraint") // programming error
+}
+
+// checkout switches the working tree to the commit, if it's not already there.
+func (obj *Downloader) checkout(repo *git.Repository, worktree *git.Worktree, hash plumbing.Hash) error {
+	if ref, err := repo.Head(); err == nil && ref.Hash() == hash {
+		return nil // already there, so leave any local branch alone
+	}
+	options := &git.CheckoutOptions{
+		Hash:  hash,
+		Force: true,
+	}
+	if err := worktree.Checkout(options); err != nil {
+		return err
+	}
+
+	submodules, err := worktree.Submodules()
+	if err != nil {
+		return errwrap.Wrapf(err, "can't get submodules")
+	}
+	return submodules.Update(&git.SubmoduleUpdateOptions{
+		Init: true,
+		// TODO: do we want to add an option for infinite recursion here?
+		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+	})
+}
+
+// resolveRevision returns the commit that a revision points to. Annotated tags
+// are peeled down to the commit that they point to.
+func resolveRevision(repo *git.Repository, rev string) (plumbing.Hash, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return plumbing.ZeroHash, errwrap.Wrapf(err, "can't resolve `%s`", rev)
+	}
+	return *hash, nil
+}
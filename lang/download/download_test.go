@@ -0,0 +1,345 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package download
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/util"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/spf13/afero"
+)
+
+func TestConstraint0(t *testing.T) {
+	tags := []string{"v0.1.0", "v0.2.0", "v1.0.0", "v1.2.0", "1.3.1", "v1.4.0-rc1", "v2.0.0", "latest"}
+
+	testCases := []struct {
+		constraint string
+		kind       constraintKind
+		best       string
+	}{
+		{"", constraintAny, ""},
+		{"0123abcd", constraintCommit, ""},
+		{"latest", constraintRef, ""},
+		{"main", constraintRef, ""},
+		{"v1.2.0", constraintRange, "v1.2.0"},
+		{"1.2", constraintRange, "v1.2.0"},
+		{"^1.2", constraintRange, "1.3.1"},
+		{"^0.1", constraintRange, "v0.1.0"},
+		{"~1.2.0", constraintRange, "v1.2.0"},
+		{">=1.0.0, <2", constraintRange, "1.3.1"},
+		{">v2.0.0", constraintRange, ""},
+		{"v1.4.0-rc1", constraintRange, "v1.4.0-rc1"},
+	}
+
+	for index, tc := range testCases {
+		c, err := parseConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("test #%d: constraint `%s` failed with: %+v", index, tc.constraint, err)
+			continue
+		}
+		if c.kind != tc.kind {
+			t.Errorf("test #%d: constraint `%s` has kind %d, expected %d", index, tc.constraint, c.kind, tc.kind)
+			continue
+		}
+		if c.kind != constraintRange {
+			continue
+		}
+		if best := c.Best(tags); best != tc.best {
+			t.Errorf("test #%d: constraint `%s` picked `%s`, expected `%s`", index, tc.constraint, best, tc.best)
+		}
+	}
+
+	for _, x := range []string{">=", "^x", "1.0, <", ">=1.0,"} {
+		if _, err := parseConstraint(x); err == nil {
+			t.Errorf("constraint `%s` should have failed", x)
+		}
+	}
+}
+
+// commitTag writes some files into a repo and then commits and tags them.
+func commitTag(t *testing.T, repo *git.Repository, dir, tag string, files map[string]string) plumbing.Hash {
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("worktree failed: %+v", err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("write failed: %+v", err)
+		}
+		if _, err := worktree.Add(name); err != nil {
+			t.Fatalf("add failed: %+v", err)
+		}
+	}
+	hash, err := worktree.Commit(tag, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("commit failed: %+v", err)
+	}
+	if _, err := repo.CreateTag(tag, hash, nil); err != nil {
+		t.Fatalf("tag failed: %+v", err)
+	}
+	return hash
+}
+
+func TestDownloaderLock0(t *testing.T) {
+	if _, err := exec.LookPath("git-upload-pack"); err != nil {
+		t.Skip("git is needed to clone from a local repo")
+	}
+
+	src := t.TempDir()
+	repo, err := git.PlainInit(src, false)
+	if err != nil {
+		t.Fatalf("init failed: %+v", err)
+	}
+	v1 := commitTag(t, repo, src, "v1.0.0", map[string]string{
+		interfaces.MetadataFilename: "main: main.mcl\n",
+		"main.mcl":                  "$x = 1\n",
+	})
+	v11 := commitTag(t, repo, src, "v1.1.0", map[string]string{"main.mcl": "$x = 11\n"})
+	commitTag(t, repo, src, "v2.0.0", map[string]string{"main.mcl": "$x = 2\n"})
+
+	modules := t.TempDir() + "/" // must be a dir
+	fs := &util.AferoFs{Afero: &afero.Afero{Fs: afero.NewOsFs()}}
+	info := &interfaces.ImportData{
+		Name:    "example.com/mod1/",
+		Path:    "example.com/mod1/",
+		URL:     src,
+		Version: "^1.0",
+	}
+	get := func(lock *Lock, update bool, info *interfaces.ImportData) (*Downloader, error) {
+		d := &Downloader{Lock: lock}
+		err := d.Init(&interfaces.DownloadInfo{
+			Fs:     fs,
+			Update: update,
+			Logf: func(format string, v ...interface{}) {
+				t.Logf("get: "+format, v...)
+			},
+		})
+		if err != nil {
+			t.Fatalf("init failed: %+v", err)
+		}
+		return d, d.Get(info, modules)
+	}
+
+	// resolve the range and write the lock
+	d, err := get(nil, false, info)
+	if err != nil {
+		t.Fatalf("get failed: %+v", err)
+	}
+	lock := d.Locked()
+	locked, exists := lock.Modules[info.Path]
+	if !exists || locked.Commit != v11.String() || !strings.HasPrefix(locked.Hash, HashPrefix) {
+		t.Fatalf("unexpected lock: %+v", locked)
+	}
+
+	// the lock survives a round trip through the file
+	lockFile := modules + interfaces.LockFilename
+	if err := lock.Save(fs, lockFile); err != nil {
+		t.Fatalf("save failed: %+v", err)
+	}
+	if lock, err = ReadLock(fs, lockFile); err != nil {
+		t.Fatalf("read failed: %+v", err)
+	}
+
+	// a newer matching tag is ignored because the lock wins
+	v12 := commitTag(t, repo, src, "v1.2.0", map[string]string{"main.mcl": "$x = 12\n"})
+	if d, err = get(lock, false, info); err != nil {
+		t.Fatalf("get with lock failed: %+v", err)
+	}
+	if c := d.Locked().Modules[info.Path].Commit; c != v11.String() {
+		t.Errorf("lock was not honoured, got: %s", c)
+	}
+
+	// a conflicting constraint for the same module is an error
+	other := *info
+	other.Version = "v1.0.0"
+	if err := d.Get(&other, modules); err == nil {
+		t.Errorf("conflicting versions should fail")
+	}
+
+	// changing the constraint resolves it again
+	if d, err = get(lock, false, &other); err != nil {
+		t.Fatalf("get with new version failed: %+v", err)
+	}
+	if c := d.Locked().Modules[info.Path].Commit; c != v1.String() {
+		t.Errorf("new version was not used, got: %s", c)
+	}
+
+	// updating ignores the lock and gets the newest matching tag
+	if d, err = get(lock, true, info); err != nil {
+		t.Fatalf("get with update failed: %+v", err)
+	}
+	if c := d.Locked().Modules[info.Path].Commit; c != v12.String() {
+		t.Errorf("update did not get the latest, got: %s", c)
+	}
+
+	// a modified module fails the hash check
+	if _, err = get(lock, false, info); err != nil {
+		t.Fatalf("get with lock failed: %+v", err)
+	}
+	main := modules + info.Path + "main.mcl"
+	if err := os.WriteFile(main, []byte("$x = 42\n"), 0644); err != nil {
+		t.Fatalf("write failed: %+v", err)
+	}
+	if _, err = get(lock, false, info); err == nil {
+		t.Errorf("modified module should fail the hash check")
+	}
+}
+
+func TestDownloaderCache0(t *testing.T) {
+	if _, err := exec.LookPath("git-upload-pack"); err != nil {
+		t.Skip("git is needed to clone from a local repo")
+	}
+
+	src := t.TempDir()
+	repo, err := git.PlainInit(src, false)
+	if err != nil {
+		t.Fatalf("init failed: %+v", err)
+	}
+	commitTag(t, repo, src, "v1.0.0", map[string]string{
+		interfaces.MetadataFilename: "main: main.mcl\n",
+		"main.mcl":                  "$x = 1\n",
+	})
+	v11 := commitTag(t, repo, src, "v1.1.0", map[string]string{"main.mcl": "$x = 11\n"})
+
+	// this is the "remote" that we lose access to later on
+	bare := t.TempDir()
+	if _, err := git.PlainClone(bare, true, &git.CloneOptions{URL: src, Tags: git.AllTags}); err != nil {
+		t.Fatalf("bare clone failed: %+v", err)
+	}
+
+	cache := t.TempDir() + "/" // must be a dir
+	fs := &util.AferoFs{Afero: &afero.Afero{Fs: afero.NewOsFs()}}
+	info := &interfaces.ImportData{
+		Name:    "example.com/mod1/",
+		Path:    "example.com/mod1/",
+		URL:     bare,
+		Version: "^1.0",
+	}
+	get := func(lock *Lock, cache string, modules string) (*Downloader, error) {
+		d := &Downloader{Lock: lock, Cache: cache}
+		err := d.Init(&interfaces.DownloadInfo{
+			Fs: fs,
+			Logf: func(format string, v ...interface{}) {
+				t.Logf("get: "+format, v...)
+			},
+		})
+		if err != nil {
+			t.Fatalf("init failed: %+v", err)
+		}
+		return d, d.Get(info, modules)
+	}
+
+	// the first download goes through the cache
+	d, err := get(nil, cache, t.TempDir()+"/")
+	if err != nil {
+		t.Fatalf("get failed: %+v", err)
+	}
+	lock := d.Locked()
+	locked := lock.Modules[info.Path]
+	if locked == nil || locked.Commit != v11.String() || locked.URL != bare {
+		t.Fatalf("unexpected lock: %+v", locked)
+	}
+	entries, err := os.ReadDir(cache)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected a single mirror in the cache, got: %+v", entries)
+	}
+
+	// without the remote, a different prefix can still use the cache
+	if err := os.RemoveAll(bare); err != nil {
+		t.Fatalf("remove failed: %+v", err)
+	}
+	modules := t.TempDir() + "/"
+	if d, err = get(lock, cache, modules); err != nil {
+		t.Fatalf("get from cache failed: %+v", err)
+	}
+	if c := d.Locked().Modules[info.Path].Commit; c != v11.String() {
+		t.Errorf("cache did not give the locked commit, got: %s", c)
+	}
+	if _, err := get(lock, "", t.TempDir()+"/"); err == nil {
+		t.Errorf("get without the cache or the remote should fail")
+	}
+
+	// the vendored copy has the same content as the lock expects
+	vendorPath := t.TempDir() + "/" + interfaces.VendorDirectory
+	if err := Vendor(fs, d.Dirs(), vendorPath); err != nil {
+		t.Fatalf("vendor failed: %+v", err)
+	}
+	dir := vendorPath + info.Path
+	if _, err := os.Stat(dir + ".git"); !os.IsNotExist(err) {
+		t.Errorf("vendored module should not contain a .git dir")
+	}
+	if err := lock.Verify(fs, info.Path, dir); err != nil {
+		t.Errorf("vendored module does not match the lock: %+v", err)
+	}
+
+	// any change to the vendored copy is caught when it gets loaded
+	if err := os.WriteFile(dir+"main.mcl", []byte("$x = 42\n"), 0644); err != nil {
+		t.Fatalf("write failed: %+v", err)
+	}
+	if err := lock.Verify(fs, info.Path, dir); err == nil {
+		t.Errorf("modified vendored module should not match the lock")
+	}
+	if err := lock.Verify(fs, "example.com/mod2/", dir); err == nil {
+		t.Errorf("module which is not in the lock should not verify")
+	}
+}
+
+func TestHashDirSymlink0(t *testing.T) {
+	fs := &util.AferoFs{Afero: &afero.Afero{Fs: afero.NewOsFs()}}
+	dir := t.TempDir() + "/"
+	if err := os.WriteFile(dir+"main.mcl", []byte("$x = 1\n"), 0644); err != nil {
+		t.Fatalf("write failed: %+v", err)
+	}
+	if _, err := HashDir(fs, dir); err != nil {
+		t.Fatalf("hash failed: %+v", err)
+	}
+
+	// the target of a symlink could change without changing the hash
+	if err := os.Symlink("/etc/passwd", dir+"passwd"); err != nil {
+		t.Fatalf("symlink failed: %+v", err)
+	}
+	if _, err := HashDir(fs, dir); err == nil {
+		t.Errorf("hash of a dir with a symlink should fail")
+	}
+}
@@ -0,0 +1,217 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package download
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/purpleidea/mgmt/engine"
+	"github.com/purpleidea/mgmt/util"
+	"github.com/purpleidea/mgmt/util/errwrap"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// HashPrefix is the prefix of the content hashes that we store in the
+	// lock file. It names the algorithm so that we can change it later.
+	HashPrefix = "sha256:"
+)
+
+// Lock is the data structure representing the lock file. It records the exact
+// commit and the content hash of each downloaded module, so that every host
+// which downloads the same code ends up with an identical module tree.
+type Lock struct {
+	// Modules maps the path of each module, relative to the modules dir,
+	// to the version of it which was downloaded.
+	Modules map[string]*LockedModule `yaml:"modules"`
+}
+
+// LockedModule is the entry in the lock file for a single module.
+type LockedModule struct {
+	// URL is the URL that the module was cloned from.
+	URL string `yaml:"url"`
+
+	// Version is the version constraint that was resolved. If it changes
+	// in the metadata file, then the module gets resolved again.
+	Version string `yaml:"version,omitempty"`
+
+	// Commit is the sha1 of the commit which was checked out.
+	Commit string `yaml:"commit"`
+
+	// Hash is the content hash of the module tree, as built by HashDir.
+	Hash string `yaml:"hash"`
+}
+
+// NewLock returns a new, empty lock.
+func NewLock() *Lock {
+	return &Lock{
+		Modules: make(map[string]*LockedModule),
+	}
+}
+
+// ToBytes marshals the struct into a byte array and returns it.
+func (obj *Lock) ToBytes() ([]byte, error) {
+	return yaml.Marshal(obj)
+}
+
+// Save writes the lock file to the given path on the filesystem.
+func (obj *Lock) Save(fs engine.Fs, p string) error {
+	b, err := obj.ToBytes()
+	if err != nil {
+		return errwrap.Wrapf(err, "can't marshal lock")
+	}
+	return afero.WriteFile(fs, p, b, 0644)
+}
+
+// ParseLock reads from some input and returns a *Lock struct.
+func ParseLock(reader io.Reader) (*Lock, error) {
+	b, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "can't read lock")
+	}
+	lock := NewLock()
+	if err := yaml.Unmarshal(b, lock); err != nil {
+		return nil, errwrap.Wrapf(err, "can't parse lock")
+	}
+	if lock.Modules == nil { // empty document
+		lock.Modules = make(map[string]*LockedModule)
+	}
+
+	for p, x := range lock.Modules {
+		if x == nil || x.URL == "" || x.Commit == "" || x.Hash == "" {
+			return nil, fmt.Errorf("lock entry for `%s` is incomplete", p)
+		}
+		if !strings.HasPrefix(x.Hash, HashPrefix) {
+			return nil, fmt.Errorf("lock entry for `%s` has an unknown hash type", p)
+		}
+	}
+
+	return lock, nil
+}
+
+// ReadLock reads the lock file at the given path on the filesystem. If there is
+// no file there, then it returns nil, and no error.
+func ReadLock(fs engine.Fs, p string) (*Lock, error) {
+	f, err := fs.Open(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "can't open lock file `%s`", p)
+	}
+	defer f.Close()
+	lock, err := ParseLock(f)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "invalid lock file `%s`", p)
+	}
+	return lock, nil
+}
+
+// Verify checks that the module tree in dir has the content hash which this lock
+// expects for the module at path p, which is relative to the modules dir. It is
+// an error if the lock doesn't have an entry for this module.
+func (obj *Lock) Verify(fs engine.Fs, p, dir string) error {
+	locked, exists := obj.Modules[p]
+	if !exists {
+		return fmt.Errorf("module `%s` is not in the lock file", p)
+	}
+	sum, err := HashDir(fs, dir)
+	if err != nil {
+		return err
+	}
+	if sum != locked.Hash {
+		return fmt.Errorf("module `%s` does not match the lock file: got hash `%s`, expected `%s`", p, sum, locked.Hash)
+	}
+	return nil
+}
+
+// HashDir returns the content hash of a module tree. It covers the relative path
+// and the contents of every regular file, but skips any git metadata. The file
+// modes and times are ignored so that the hash is the same on every host. Since
+// a symlink could be pointed somewhere else without changing the hash, it errors
+// if it finds one, or any other special file.
+func HashDir(fs engine.Fs, dir string) (string, error) {
+	files := []string{}
+	fn := func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Name() == ".git" { // a dir, or a file in a submodule
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("module file `%s` is not a regular file", filepath.ToSlash(rel))
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	}
+	if err := afero.Walk(walkFs(fs), dir, fn); err != nil {
+		return "", errwrap.Wrapf(err, "can't walk `%s`", dir)
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, x := range files {
+		b, err := afero.ReadFile(fs, filepath.Join(dir, x))
+		if err != nil {
+			return "", errwrap.Wrapf(err, "can't read `%s`", x)
+		}
+		fmt.Fprintf(h, "%s\x00%x\n", x, sha256.Sum256(b))
+	}
+	return fmt.Sprintf("%s%x", HashPrefix, h.Sum(nil)), nil
+}
+
+// walkFs returns the fs that we should walk. The afero walk only uses lstat if
+// the fs supports it, and since our wrapper doesn't pass that through, we unwrap
+// it, or else any symlinks would be followed and look like regular files.
+func walkFs(fs engine.Fs) afero.Fs {
+	if x, ok := fs.(*util.AferoFs); ok && x.Afero != nil {
+		return x.Fs
+	}
+	return fs
+}
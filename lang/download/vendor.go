@@ -0,0 +1,108 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package download
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/purpleidea/mgmt/engine"
+	"github.com/purpleidea/mgmt/util/errwrap"
+
+	"github.com/spf13/afero"
+)
+
+// Vendor copies each of the downloaded modules into the vendor directory, so
+// that they can be imported from there without any network access. The dirs
+// map is what the Dirs method of the downloader returns. The vendor directory
+// is emptied first, so that it only ever contains the modules that are in use.
+// The .git directories are not copied, which means that a vendored module has
+// the same content hash as the one which is recorded in the lock file.
+func Vendor(fs engine.Fs, dirs map[string]string, vendorPath string) error {
+	if vendorPath == "" || !strings.HasSuffix(vendorPath, "/") || !strings.HasPrefix(vendorPath, "/") {
+		return fmt.Errorf("vendor path (`%s`) must be an absolute dir", vendorPath)
+	}
+	if err := fs.RemoveAll(vendorPath); err != nil {
+		return errwrap.Wrapf(err, "can't remove old vendor dir: `%s`", vendorPath)
+	}
+	if err := fs.MkdirAll(vendorPath, 0755); err != nil {
+		return errwrap.Wrapf(err, "can't make vendor dir: `%s`", vendorPath)
+	}
+
+	paths := []string{}
+	for p := range dirs {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths) // deterministic order for the logs and errors
+
+	for _, p := range paths {
+		if err := copyDir(fs, dirs[p], vendorPath+p); err != nil {
+			return errwrap.Wrapf(err, "can't vendor `%s`", p)
+		}
+	}
+	return nil
+}
+
+// copyDir copies the regular files of the src dir tree into the dst dir. Just
+// like HashDir, it skips over anything git related. Since the source was hashed
+// first, there shouldn't be any special files, but we skip them to be safe.
+func copyDir(fs engine.Fs, src, dst string) error {
+	fn := func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Name() == ".git" { // a dir, or a file in a submodule
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		out := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return fs.MkdirAll(out, info.Mode().Perm())
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		b, err := afero.ReadFile(fs, p)
+		if err != nil {
+			return err
+		}
+		return afero.WriteFile(fs, out, b, info.Mode().Perm())
+	}
+	return afero.Walk(walkFs(fs), src, fn)
+}
@@ -0,0 +1,212 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package download
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// constraintKind is the kind of version constraint which was requested.
+type constraintKind int
+
+const (
+	// constraintAny accepts whatever the default branch points to.
+	constraintAny constraintKind = iota
+
+	// constraintCommit pins a specific commit by its (maybe short) sha1.
+	constraintCommit
+
+	// constraintRef names a tag or a branch which is used verbatim.
+	constraintRef
+
+	// constraintRange picks the highest semver tag inside of a range.
+	constraintRange
+)
+
+// bound is a single comparison in a semver range, eg: `>=v1.2.0`.
+type bound struct {
+	op      string // one of: =, <, <=, >, >=
+	version string // canonical semver with a leading v
+}
+
+// constraint is a parsed version constraint from the Require field of the
+// metadata file.
+type constraint struct {
+	kind   constraintKind
+	raw    string
+	bounds []*bound // only used for a range
+}
+
+// parseConstraint parses a version constraint. The empty string accepts any
+// version. A string of hex digits is a commit. A version such as `v1.2.0`, or a
+// comma separated list of comparisons such as `>=1.2, <2` is a range of tags.
+// The `^1.2` and `~1.2.3` shorthands allow compatible and patch-level changes.
+// Anything else is the name of a tag or a branch.
+func parseConstraint(s string) (*constraint, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return &constraint{kind: constraintAny}, nil
+	}
+	if isCommit(s) {
+		return &constraint{kind: constraintCommit, raw: strings.ToLower(s)}, nil
+	}
+
+	isRange := strings.ContainsAny(s[:1], "<>=^~") || strings.Contains(s, ",")
+	if !isRange && canonical(s) == "" { // not a version, so it's a name
+		return &constraint{kind: constraintRef, raw: s}, nil
+	}
+
+	c := &constraint{kind: constraintRange, raw: s}
+	for _, x := range strings.Split(s, ",") {
+		bounds, err := parseBounds(strings.TrimSpace(x))
+		if err != nil {
+			return nil, err
+		}
+		c.bounds = append(c.bounds, bounds...)
+	}
+	return c, nil
+}
+
+// parseBounds parses a single comparison in a range. The shorthand operators
+// expand into two bounds.
+func parseBounds(s string) ([]*bound, error) {
+	op := ""
+	for _, x := range []string{"<=", ">=", "<", ">", "=", "^", "~"} {
+		if strings.HasPrefix(s, x) {
+			op = x
+			break
+		}
+	}
+	v := canonical(strings.TrimSpace(strings.TrimPrefix(s, op)))
+	if v == "" {
+		return nil, fmt.Errorf("invalid version in constraint `%s`", s)
+	}
+
+	switch op {
+	case "", "=":
+		return []*bound{{op: "=", version: v}}, nil
+
+	case "<", "<=", ">", ">=":
+		return []*bound{{op: op, version: v}}, nil
+
+	case "^": // compatible changes, which means up to the next major
+		upper := ""
+		var major, minor, patch int
+		fmt.Sscanf(v, "v%d.%d.%d", &major, &minor, &patch)
+		if major > 0 {
+			upper = fmt.Sprintf("v%d.0.0", major+1)
+		} else { // before v1, minor versions are allowed to break things
+			upper = fmt.Sprintf("v0.%d.0", minor+1)
+		}
+		return []*bound{{op: ">=", version: v}, {op: "<", version: upper}}, nil
+
+	case "~": // patch level changes
+		var major, minor, patch int
+		fmt.Sscanf(v, "v%d.%d.%d", &major, &minor, &patch)
+		upper := fmt.Sprintf("v%d.%d.0", major, minor+1)
+		return []*bound{{op: ">=", version: v}, {op: "<", version: upper}}, nil
+	}
+
+	return nil, fmt.Errorf("invalid operator in constraint `%s`", s) // unreachable
+}
+
+// Match returns true if the version is inside of this range constraint.
+func (obj *constraint) Match(version string) bool {
+	v := canonical(version)
+	if obj.kind != constraintRange || v == "" {
+		return false
+	}
+	if semver.Prerelease(v) != "" { // only match these explicitly
+		exact := len(obj.bounds) == 1 && obj.bounds[0].op == "="
+		if !exact {
+			return false
+		}
+	}
+	for _, b := range obj.bounds {
+		cmp := semver.Compare(v, b.version)
+		ok := false
+		switch b.op {
+		case "=":
+			ok = cmp == 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Best returns the tag with the highest version inside of this range, or the
+// empty string if none of them match.
+func (obj *constraint) Best(tags []string) string {
+	best := ""
+	for _, tag := range tags {
+		if !obj.Match(tag) {
+			continue
+		}
+		if best == "" || semver.Compare(canonical(tag), canonical(best)) > 0 {
+			best = tag
+		}
+	}
+	return best
+}
+
+// canonical returns the canonical semver form of a version or tag, adding the
+// leading v if it is missing. It returns the empty string if it isn't valid.
+func canonical(s string) string {
+	if !strings.HasPrefix(s, "v") {
+		s = "v" + s
+	}
+	return semver.Canonical(s)
+}
+
+// isCommit returns true if this looks like a full or an abbreviated sha1.
+func isCommit(s string) bool {
+	if len(s) < 7 || len(s) > 40 {
+		return false
+	}
+	for _, c := range strings.ToLower(s) {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
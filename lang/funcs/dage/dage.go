@@ -51,6 +51,12 @@ import (
 	"github.com/purpleidea/mgmt/util/errwrap"
 )
 
+const (
+	// GraphvizValueMax is the maximum length of a value that is shown when
+	// the graph is annotated with values. Longer values are truncated.
+	GraphvizValueMax = 64
+)
+
 // Engine implements a dag engine which lets us "run" a dag of functions, but
 // also allows us to modify it while we are running.
 type Engine struct {
@@ -73,6 +79,23 @@ type Engine struct {
 	// error has occurred, it will set that error property.
 	Callback func(context.Context, error)
 
+	// Record is the path to a file where we record every value sent by the
+	// functions which aren't pure, along with the new graph events that we
+	// send. If this is empty, then nothing is recorded.
+	Record string
+
+	// Replay is the path to a file which was made with Record. When this is
+	// set, the recorded functions aren't run, and instead their recorded
+	// values are sent in the same order as they were recorded in. Functions
+	// are matched by their name and by the functions upstream of them, and
+	// duplicates are matched in the order that they start. This can't be
+	// combined with Record.
+	Replay string
+
+	// Profiling enables the collection of the per function timing data
+	// which can be read with the Profile method.
+	Profiling bool
+
 	graph *pgraph.Graph                   // guarded by graphMutex
 	table map[interfaces.Func]types.Value // guarded by tableMutex
 	state map[interfaces.Func]*state
@@ -150,6 +173,15 @@ type Engine struct {
 	// statsMutex wraps access to the stats data.
 	statsMutex *sync.RWMutex
 
+	// recorder is used when we Record.
+	recorder *recorder
+
+	// replayer is used when we Replay.
+	replayer *replayer
+
+	// keys counts the functions which have been given a key for each hash.
+	keys map[string]int
+
 	// graphvizMutex wraps access to the Graphviz method.
 	graphvizMutex *sync.Mutex
 
@@ -209,9 +241,26 @@ func (obj *Engine) Setup() error {
 		runningList: make(map[*state]struct{}),
 		loadedList:  make(map[*state]bool),
 		inputList:   make(map[*state]int64),
+		profiles:    make(map[*state]*profile),
+		waiting:     make(map[*state]struct{}),
 	}
 	obj.statsMutex = &sync.RWMutex{}
 
+	obj.keys = make(map[string]int)
+	if obj.Record != "" && obj.Replay != "" {
+		return fmt.Errorf("can't record and replay at the same time")
+	}
+	if obj.Record != "" {
+		if obj.recorder, err = newRecorder(obj.Record); err != nil {
+			return errwrap.Wrapf(err, "can't start recording")
+		}
+	}
+	if obj.Replay != "" {
+		if obj.replayer, err = newReplayer(obj.Replay); err != nil {
+			return errwrap.Wrapf(err, "can't load recording")
+		}
+	}
+
 	obj.graphvizMutex = &sync.Mutex{}
 	return nil
 }
@@ -223,6 +272,9 @@ func (obj *Engine) Cleanup() error {
 	close(obj.pausedChan)
 	close(obj.resumeChan)
 	close(obj.resumedChan)
+	if obj.recorder != nil {
+		return obj.recorder.Close()
+	}
 	return nil
 }
 
@@ -287,8 +339,9 @@ func (obj *Engine) addVertex(f interfaces.Func) error {
 	// can read where we are locked must have a mutex around it or do the
 	// lookup when we're in an unlocked state.
 	node := &state{
-		Func: f,
-		name: f.String(), // cache a name to avoid locks
+		Func:   f,
+		name:   f.String(),      // cache a name to avoid locks
+		reveal: f.Info().Reveal, // cache this to avoid a call per send
 
 		input:  input,
 		output: output,
@@ -415,6 +468,9 @@ func (obj *Engine) deleteVertex(f interfaces.Func) error {
 			obj.stateMutex.Lock()
 			delete(obj.isClosed, node) // avoid memory leak
 			obj.stateMutex.Unlock()
+			obj.statsMutex.Lock()
+			delete(obj.stats.profiles, node) // avoid memory leak
+			obj.statsMutex.Unlock()
 		})
 		obj.nodeWaitMutex.Unlock()
 	}
@@ -741,6 +797,14 @@ func (obj *Engine) process(ctx context.Context) (reterr error) {
 			continue
 		}
 
+		// Once a func has seen a secret, everything that it produces
+		// is secret, even if the secret arg changes to a public one.
+		if !node.reveal && types.IsSecret(st) {
+			node.rwmutex.Lock()
+			node.secret = true
+			node.rwmutex.Unlock()
+		}
+
 		// XXX: respect the info.Pure and info.Memo fields somewhere...
 
 		// XXX: keep track of some state about who i sent to last before
@@ -755,11 +819,18 @@ func (obj *Engine) process(ctx context.Context) (reterr error) {
 		if obj.Debug {
 			obj.Logf("send to func `%s`", node)
 		}
+		sending := time.Now()
 		select {
 		case node.input <- st: // send to function
+			now := time.Now()
 			obj.statsMutex.Lock()
 			val, _ := obj.stats.inputList[node] // val is # or zero
 			obj.stats.inputList[node] = val + 1 // increment
+			if p, exists := obj.stats.profiles[node]; exists {
+				p.inputs++
+				p.lastInput = now
+				p.blocked += now.Sub(sending)
+			}
 			obj.statsMutex.Unlock()
 			// pass
 
@@ -903,6 +974,27 @@ func (obj *Engine) Run(ctx context.Context) (reterr error) {
 	once := &sync.Once{}
 	loadedSignal := func() { close(obj.loadedChan) } // only run once!
 
+	if obj.recorder != nil {
+		obj.recorder.start = time.Now() // offsets are from here
+	}
+	if obj.replayer != nil {
+		start := time.Now()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			obj.replayer.Run(ctx, start, obj.Logf)
+		}()
+	}
+
+	// name the slow funcs that are holding up the first graph
+	if SlowFuncInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			obj.slowFuncs(ctx)
+		}()
+	}
+
 	// aggregate events channel
 	wg.Add(1)
 	go func() {
@@ -926,6 +1018,15 @@ func (obj *Engine) Run(ctx context.Context) (reterr error) {
 			// TODO: check obj.loaded first?
 			once.Do(loadedSignal)
 
+			if err == nil && obj.recorder != nil {
+				if err := obj.recorder.graph(); err != nil {
+					obj.Logf("record: %+v", err)
+				}
+			}
+			if err == nil && obj.replayer != nil {
+				obj.replayer.graph()
+			}
+
 			// now send event...
 			if obj.Callback != nil {
 				// send stream signal (callback variant)
@@ -1214,9 +1315,24 @@ func (obj *Engine) Run(ctx context.Context) (reterr error) {
 			obj.loaded = false // reset this
 			node.running = true
 
+			if (obj.recorder != nil || obj.replayer != nil) && replayable(f) {
+				node.key = obj.key(node)
+			}
+			if node.key != "" && obj.recorder != nil {
+				node.exited = make(chan bool, 1)
+			}
+
 			obj.statsMutex.Lock()
 			val, _ := obj.stats.inputList[node] // val is # or zero
 			obj.stats.inputList[node] = val     // initialize to zero
+			obj.stats.waiting[node] = struct{}{}
+			if obj.Profiling {
+				obj.stats.profiles[node] = &profile{
+					name:    node.String(),
+					running: true,
+					started: time.Now(),
+				}
+			}
 			obj.statsMutex.Unlock()
 
 			innerCtx, innerCancel := context.WithCancel(ctx) // wrap parent (not mainCtx)
@@ -1247,9 +1363,22 @@ func (obj *Engine) Run(ctx context.Context) (reterr error) {
 							reterr = fmt.Errorf("panic in Stream of func `%s`: %+v", node, r)
 						}
 					}()
+					if node.key != "" && obj.replayer != nil {
+						return obj.replayer.stream(nodeCtx, node.key, node.input, node.output)
+					}
 					return f.Stream(nodeCtx)
 				}
 				runErr := fn(node.ctx) // wrap with recover()
+				if node.exited != nil {
+					// did it exit without being cancelled?
+					node.exited <- node.ctx.Err() == nil
+				}
+				obj.statsMutex.Lock()
+				delete(obj.stats.waiting, node)
+				if p, exists := obj.stats.profiles[node]; exists {
+					p.running = false
+				}
+				obj.statsMutex.Unlock()
 				if obj.Debug {
 					obj.Logf("Exiting func `%s`", node)
 					obj.statsMutex.Lock()
@@ -1293,6 +1422,19 @@ func (obj *Engine) Run(ctx context.Context) (reterr error) {
 						panic("got nil value")
 					}
 
+					node.rwmutex.RLock()
+					secret := node.secret
+					node.rwmutex.RUnlock()
+					if secret {
+						value = types.MakeSecret(value)
+					}
+
+					if node.key != "" && obj.recorder != nil {
+						if err := obj.recorder.value(node, value); err != nil {
+							obj.Logf("record: func `%s`: %+v", node, err)
+						}
+					}
+
 					obj.tableMutex.RLock()
 					cached, exists := obj.table[f]
 					obj.tableMutex.RUnlock()
@@ -1321,8 +1463,23 @@ func (obj *Engine) Run(ctx context.Context) (reterr error) {
 					//obj.Logf("func `%s` changed", node)
 					node.rwmutex.Unlock()
 
+					now := time.Now()
 					obj.statsMutex.Lock()
 					obj.stats.loadedList[node] = true
+					delete(obj.stats.waiting, node)
+					if p, exists := obj.stats.profiles[node]; exists {
+						p.values++
+						if p.firstValue.IsZero() {
+							p.firstValue = now
+						}
+						p.lastValue = now
+						if !p.lastInput.IsZero() {
+							p.lastLatency = now.Sub(p.lastInput)
+							if p.lastLatency > p.maxLatency {
+								p.maxLatency = p.lastLatency
+							}
+						}
+					}
 					obj.statsMutex.Unlock()
 
 					// Send a message to tell our ag channel
@@ -1341,6 +1498,13 @@ func (obj *Engine) Run(ctx context.Context) (reterr error) {
 				// no more output values are coming...
 				//obj.Logf("func `%s` stopped", node)
 
+				// record it if the func closed on its own
+				if node.exited != nil && <-node.exited {
+					if err := obj.recorder.closed(node); err != nil {
+						obj.Logf("record: func `%s`: %+v", node, err)
+					}
+				}
+
 				// nodes that never loaded will cause the engine to hang
 				if !node.loaded {
 					select {
@@ -1494,26 +1658,10 @@ func (obj *Engine) ExecGraphviz(dir string) error {
 		return err
 	}
 
-	dashedEdges, err := pgraph.NewGraph("dashedEdges")
+	dashedEdges, err := channelEdges(obj.graph)
 	if err != nil {
 		return err
 	}
-	for _, v1 := range obj.graph.Vertices() {
-		// if it's a ChannelBasedSinkFunc...
-		if cb, ok := v1.(*structs.ChannelBasedSinkFunc); ok {
-			// ...then add a dashed edge to its output
-			dashedEdges.AddEdge(v1, cb.Target, &pgraph.SimpleEdge{
-				Name: "channel", // secret channel
-			})
-		}
-		// if it's a ChannelBasedSourceFunc...
-		if cb, ok := v1.(*structs.ChannelBasedSourceFunc); ok {
-			// ...then add a dashed edge from its input
-			dashedEdges.AddEdge(cb.Source, v1, &pgraph.SimpleEdge{
-				Name: "channel", // secret channel
-			})
-		}
-	}
 
 	gv := &pgraph.Graphviz{
 		Name:     obj.graph.GetName(),
@@ -1532,10 +1680,94 @@ func (obj *Engine) ExecGraphviz(dir string) error {
 	return nil
 }
 
+// Graphviz returns the diagram of a copy of the current graph. The edges are
+// labelled with the argument names, and each vertex is annotated with its type
+// and its most recent value, if it has one. The engine is paused while we copy
+// the graph, so this must only be called while Run is running, and never while
+// holding the engine Lock.
+func (obj *Engine) Graphviz() (*pgraph.Graphviz, error) {
+	obj.Lock()
+	graph := obj.graph.Copy()
+	obj.Unlock()
+
+	dashedEdges, err := channelEdges(graph)
+	if err != nil {
+		return nil, err
+	}
+
+	table := obj.Table() // copy
+	labels := make(map[pgraph.Vertex]string)
+	for _, v := range graph.Vertices() {
+		f, ok := v.(interfaces.Func)
+		if !ok {
+			continue
+		}
+		lines := []string{}
+		if sig := f.Info().Sig; sig != nil && sig.Out != nil {
+			lines = append(lines, fmt.Sprintf("type: %s", sig.Out))
+		}
+		if value, exists := table[f]; exists {
+			lines = append(lines, fmt.Sprintf("value: %s", truncate(value.String(), GraphvizValueMax)))
+		}
+		if len(lines) > 0 {
+			labels[v] = strings.Join(lines, "\n")
+		}
+	}
+
+	return &pgraph.Graphviz{
+		Name: graph.GetName(),
+		Graphs: map[*pgraph.Graph]*pgraph.GraphvizOpts{
+			graph: {
+				Labels: labels,
+			},
+			dashedEdges: {
+				Style: "dashed",
+			},
+		},
+	}, nil
+}
+
+// truncate shortens a string to at most max characters, with an ellipsis at the
+// end if anything was removed.
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max-3]) + "..."
+}
+
+// channelEdges returns a graph of the secret channels between the channel based
+// sink and source funcs in a graph, so that they can be drawn as dashed edges.
+func channelEdges(graph *pgraph.Graph) (*pgraph.Graph, error) {
+	dashedEdges, err := pgraph.NewGraph("dashedEdges")
+	if err != nil {
+		return nil, err
+	}
+	for _, v1 := range graph.Vertices() {
+		// if it's a ChannelBasedSinkFunc...
+		if cb, ok := v1.(*structs.ChannelBasedSinkFunc); ok {
+			// ...then add a dashed edge to its output
+			dashedEdges.AddEdge(v1, cb.Target, &pgraph.SimpleEdge{
+				Name: "channel", // secret channel
+			})
+		}
+		// if it's a ChannelBasedSourceFunc...
+		if cb, ok := v1.(*structs.ChannelBasedSourceFunc); ok {
+			// ...then add a dashed edge from its input
+			dashedEdges.AddEdge(cb.Source, v1, &pgraph.SimpleEdge{
+				Name: "channel", // secret channel
+			})
+		}
+	}
+	return dashedEdges, nil
+}
+
 // state tracks some internal vertex-specific state information.
 type state struct {
-	Func interfaces.Func
-	name string // cache a name here for safer concurrency
+	Func   interfaces.Func
+	name   string // cache a name here for safer concurrency
+	reveal bool   // cache of the Reveal field from the func Info
 
 	input  chan types.Value // the top level type must be a struct
 	output chan types.Value
@@ -1544,11 +1776,17 @@ type state struct {
 	//init   bool // have we run Init on our func?
 	//ready  bool // has it received all the args it needs at least once?
 	loaded       bool // has the func run at least once ?
+	secret       bool // has it ever been sent a secret?
 	inputClosed  bool // is our input closed?
 	outputClosed bool // is our output closed?
 
 	isLeaf bool // is my out degree zero?
 
+	hash string // memoized hash of this func and its ancestors
+	key  string // name used to record and replay this func, if it is one
+
+	exited chan bool // when recording, true if Stream exited on its own
+
 	running bool
 	wg      *sync.WaitGroup
 	ctx     context.Context // per state ctx (inner ctx)
@@ -1577,6 +1815,14 @@ type stats struct {
 
 	// inputList keeps track of the number of inputs each node received.
 	inputList map[*state]int64
+
+	// profiles keeps track of the per node timing data for profiling. It
+	// is only used when profiling is enabled.
+	profiles map[*state]*profile
+
+	// waiting keeps track of the running nodes which haven't produced
+	// their first value yet.
+	waiting map[*state]struct{}
 }
 
 // String implements the fmt.Stringer interface for printing out our collected
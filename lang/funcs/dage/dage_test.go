@@ -250,6 +250,46 @@ func TestDageTable(t *testing.T) {
 			},
 		})
 	}
+	{
+		f1 := &testFunc{Name: "f1", Type: types.NewType("func() str")}
+		f2 := &testFunc{Name: "f2", Type: types.NewType("func(e1 str) str")}
+		e1 := testEdge("e1")
+
+		testCases = append(testCases, test{
+			name:     "profile",
+			vertices: []interfaces.Func{f1, f2},
+			actions: []dageTestOp{
+				func(engine *Engine, txn interfaces.Txn, meta *meta) error {
+					engine.Lock()
+					defer engine.Unlock()
+					return engine.AddEdge(f1, f2, e1)
+				},
+				func(engine *Engine, txn interfaces.Txn, meta *meta) error {
+					select {
+					case <-engine.Loaded():
+					case <-meta.ctx.Done():
+						return meta.ctx.Err()
+					}
+					profiles := engine.Profile()
+					if len(profiles) != 2 {
+						return fmt.Errorf("expected 2 profiles, got %d", len(profiles))
+					}
+					for _, p := range profiles {
+						if !p.Running || !p.Loaded || p.Values < 1 {
+							return fmt.Errorf("unexpected profile: %+v", p)
+						}
+						if p.Name == "f2" && p.Inputs < 1 {
+							return fmt.Errorf("expected inputs: %+v", p)
+						}
+					}
+					if names := engine.waiting(); len(names) != 0 {
+						return fmt.Errorf("still waiting on: %v", names)
+					}
+					return nil
+				},
+			},
+		})
+	}
 	{
 		// diamond
 		f1 := &testFunc{Name: "f1", Type: types.NewType("func() str")}
@@ -724,7 +764,8 @@ func TestDageTable(t *testing.T) {
 			}()
 
 			engine := &Engine{
-				Name: "dage",
+				Name:      "dage",
+				Profiling: true,
 
 				Debug: debug,
 				Logf:  t.Logf,
@@ -0,0 +1,311 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package dage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// SlowFuncInterval is how often we print the list of functions that are
+	// holding up the first graph. Set to zero to disable.
+	SlowFuncInterval = 5 * time.Second
+
+	// SlowFuncMax is the maximum number of function names that we list in
+	// each slow function warning. The remainder is summarized as a count.
+	SlowFuncMax = 10
+)
+
+// profile holds the running counters that we collect for a single function
+// vertex. It is guarded by the engine statsMutex.
+type profile struct {
+	name    string
+	running bool
+
+	values int64 // number of (non-duplicate) values streamed
+	inputs int64 // number of input structs sent to the function
+
+	started    time.Time // when the Stream of this function was started
+	firstValue time.Time // when the first value was received
+	lastValue  time.Time // when the most recent value was received
+	lastInput  time.Time // when the most recent input was sent
+
+	lastLatency time.Duration // input to output delay of the last value
+	maxLatency  time.Duration // biggest input to output delay we've seen
+	blocked     time.Duration // total time spent waiting to send inputs
+}
+
+// FuncProfile is a snapshot of the profiling data for a single function. All
+// the durations are in nanoseconds when encoded as JSON.
+type FuncProfile struct {
+	// Name is the printed name of the function vertex.
+	Name string `json:"name"`
+
+	// Running is true if the function Stream is still running.
+	Running bool `json:"running"`
+
+	// Loaded is true if the function has produced at least one value.
+	Loaded bool `json:"loaded"`
+
+	// Values is the number of distinct values that the function streamed.
+	// Values that are identical to the previous one are not counted.
+	Values int64 `json:"values"`
+
+	// Inputs is the number of input values that the engine sent to it.
+	Inputs int64 `json:"inputs"`
+
+	// Started is when the function Stream started running.
+	Started time.Time `json:"started"`
+
+	// FirstValue is the time between the function starting, and the first
+	// value arriving. It is zero if the function has not loaded yet.
+	FirstValue time.Duration `json:"first_value"`
+
+	// LastValue is when the most recent value arrived. It is the zero time
+	// if the function has not loaded yet.
+	LastValue time.Time `json:"last_value"`
+
+	// LastLatency is the time between the most recent input being sent to
+	// the function, and the value that followed it. Functions without any
+	// inputs always have a zero latency.
+	LastLatency time.Duration `json:"last_latency"`
+
+	// MaxLatency is the largest LastLatency that we have seen.
+	MaxLatency time.Duration `json:"max_latency"`
+
+	// Blocked is the total time that the engine spent blocked while sending
+	// new input values to this function.
+	Blocked time.Duration `json:"blocked"`
+}
+
+// Profile returns a snapshot of the profiling data for every function which is
+// in the graph of this engine. It is empty unless Profiling is enabled. The list
+// is sorted by time to first value, slowest first, and then by name.
+func (obj *Engine) Profile() []*FuncProfile {
+	obj.statsMutex.RLock()
+	defer obj.statsMutex.RUnlock()
+
+	result := []*FuncProfile{}
+	for _, p := range obj.stats.profiles {
+		fp := &FuncProfile{
+			Name:    p.name,
+			Running: p.running,
+			Loaded:  !p.firstValue.IsZero(),
+			Values:  p.values,
+			Inputs:  p.inputs,
+			Started: p.started,
+
+			LastValue:   p.lastValue,
+			LastLatency: p.lastLatency,
+			MaxLatency:  p.maxLatency,
+			Blocked:     p.blocked,
+		}
+		if fp.Loaded {
+			fp.FirstValue = p.firstValue.Sub(p.started)
+		}
+		result = append(result, fp)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].FirstValue != result[j].FirstValue {
+			return result[i].FirstValue > result[j].FirstValue
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// waiting returns the sorted names of the running functions which haven't sent
+// their first value yet.
+func (obj *Engine) waiting() []string {
+	obj.statsMutex.RLock()
+	defer obj.statsMutex.RUnlock()
+
+	names := []string{}
+	for node := range obj.stats.waiting {
+		names = append(names, node.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// slowFuncs prints a periodic warning which names the functions that we are
+// still waiting on, until the first graph is loaded or the context closes.
+func (obj *Engine) slowFuncs(ctx context.Context) {
+	ticker := time.NewTicker(SlowFuncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-obj.loadedChan: // funcs are now loaded!
+			return
+		case <-ctx.Done():
+			return
+		}
+
+		names := obj.waiting()
+		if len(names) == 0 {
+			continue
+		}
+		s := strings.Join(names, ", ")
+		if len(names) > SlowFuncMax {
+			s = strings.Join(names[:SlowFuncMax], ", ")
+			s += fmt.Sprintf(", and %d more", len(names)-SlowFuncMax)
+		}
+		obj.Logf("still waiting on %d func(s): %s", len(names), s)
+	}
+}
+
+// WriteProfileJSON writes out the current profiling data as a JSON list.
+func (obj *Engine) WriteProfileJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(obj.Profile())
+}
+
+// WriteProfilePprof writes out the current profiling data as a gzipped protobuf
+// which can be read by `go tool pprof`. Each function is a single sample with
+// a one frame stack, and the sample values are the values count, the time to
+// first value, the max latency, and the time spent blocked on input.
+func (obj *Engine) WriteProfilePprof(w io.Writer) error {
+	profiles := obj.Profile()
+
+	strs := []string{""} // the string table must start with an empty string
+	index := make(map[string]int64)
+	str := func(s string) int64 {
+		if i, exists := index[s]; exists {
+			return i
+		}
+		strs = append(strs, s)
+		index[s] = int64(len(strs) - 1)
+		return index[s]
+	}
+
+	p := &pbuf{}
+	for _, x := range [][2]string{
+		{"values", "count"},
+		{"first_value", "nanoseconds"},
+		{"max_latency", "nanoseconds"},
+		{"blocked", "nanoseconds"},
+	} {
+		vt := &pbuf{}
+		vt.varint(1, uint64(str(x[0]))) // type
+		vt.varint(2, uint64(str(x[1]))) // unit
+		p.bytes(1, vt.Bytes())          // sample_type
+	}
+
+	for i, fp := range profiles {
+		id := uint64(i + 1) // ids must be non-zero
+
+		sample := &pbuf{}
+		sample.packed(1, []uint64{id}) // location_id
+		sample.packed(2, []uint64{
+			uint64(fp.Values),
+			uint64(fp.FirstValue),
+			uint64(fp.MaxLatency),
+			uint64(fp.Blocked),
+		})
+		p.bytes(2, sample.Bytes()) // sample
+
+		line := &pbuf{}
+		line.varint(1, id) // function_id
+		location := &pbuf{}
+		location.varint(1, id)          // id
+		location.bytes(4, line.Bytes()) // line
+		p.bytes(4, location.Bytes())    // location
+
+		function := &pbuf{}
+		function.varint(1, id)                    // id
+		function.varint(2, uint64(str(fp.Name)))  // name
+		function.varint(3, uint64(str(fp.Name)))  // system_name
+		function.varint(4, uint64(str(obj.Name))) // filename
+		p.bytes(5, function.Bytes())              // function
+	}
+
+	defaultType := uint64(str("first_value"))
+	for _, s := range strs {
+		p.bytes(6, []byte(s)) // string_table
+	}
+	p.varint(9, uint64(time.Now().UnixNano())) // time_nanos
+	p.varint(14, defaultType)                  // default_sample_type
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(p.Bytes()); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// pbuf is a tiny protobuf encoder which supports the few wire types that are
+// needed to write out a pprof profile.
+type pbuf struct {
+	bytes.Buffer
+}
+
+// uvarint appends a raw varint.
+func (obj *pbuf) uvarint(x uint64) {
+	for x >= 0x80 {
+		obj.WriteByte(byte(x) | 0x80)
+		x >>= 7
+	}
+	obj.WriteByte(byte(x))
+}
+
+// varint appends a varint field. Zero values are skipped as is the default.
+func (obj *pbuf) varint(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	obj.uvarint(uint64(field)<<3 | 0) // wire type varint
+	obj.uvarint(x)
+}
+
+// bytes appends a length delimited field.
+func (obj *pbuf) bytes(field int, b []byte) {
+	obj.uvarint(uint64(field)<<3 | 2) // wire type length delimited
+	obj.uvarint(uint64(len(b)))
+	obj.Write(b)
+}
+
+// packed appends a packed repeated varint field.
+func (obj *pbuf) packed(field int, xs []uint64) {
+	p := &pbuf{}
+	for _, x := range xs {
+		p.uvarint(x)
+	}
+	obj.bytes(field, p.Bytes())
+}
@@ -0,0 +1,148 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package dage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestPbuf0(t *testing.T) {
+	p := &pbuf{}
+	p.varint(1, 150) // the classic example from the protobuf docs
+	if b := p.Bytes(); !bytes.Equal(b, []byte{0x08, 0x96, 0x01}) {
+		t.Errorf("unexpected varint encoding: %x", b)
+	}
+
+	p = &pbuf{}
+	p.bytes(2, []byte("testing"))
+	if b := p.Bytes(); !bytes.Equal(b, append([]byte{0x12, 0x07}, []byte("testing")...)) {
+		t.Errorf("unexpected bytes encoding: %x", b)
+	}
+
+	p = &pbuf{}
+	p.packed(4, []uint64{3, 270, 86942})
+	if b := p.Bytes(); !bytes.Equal(b, []byte{0x22, 0x06, 0x03, 0x8e, 0x02, 0x9e, 0xa7, 0x05}) {
+		t.Errorf("unexpected packed encoding: %x", b)
+	}
+}
+
+func TestProfileWrite0(t *testing.T) {
+	engine := &Engine{
+		Name:  "dage",
+		Logf:  t.Logf,
+		Debug: testing.Verbose(),
+	}
+	if err := engine.Setup(); err != nil {
+		t.Errorf("could not setup engine: %+v", err)
+		return
+	}
+
+	now := time.Now()
+	engine.stats.profiles[&state{}] = &profile{
+		name:       "fast",
+		running:    true,
+		values:     3,
+		started:    now,
+		firstValue: now.Add(10 * time.Millisecond),
+	}
+	engine.stats.profiles[&state{}] = &profile{
+		name:       "slow",
+		running:    true,
+		values:     1,
+		started:    now,
+		firstValue: now.Add(2 * time.Second),
+		blocked:    time.Second,
+	}
+	stuck := &state{name: "stuck"}
+	engine.stats.profiles[stuck] = &profile{
+		name:    "stuck",
+		running: true,
+		started: now,
+	}
+	engine.stats.waiting[stuck] = struct{}{}
+
+	profiles := engine.Profile()
+	names := []string{}
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+	if len(names) != 3 || names[0] != "slow" || names[1] != "fast" || names[2] != "stuck" {
+		t.Errorf("unexpected profile order: %v", names)
+	}
+	if d := profiles[0].FirstValue; d != 2*time.Second {
+		t.Errorf("unexpected first value duration: %s", d)
+	}
+
+	if waiting := engine.waiting(); len(waiting) != 1 || waiting[0] != "stuck" {
+		t.Errorf("unexpected waiting list: %v", waiting)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := engine.WriteProfileJSON(buf); err != nil {
+		t.Errorf("could not write json: %+v", err)
+		return
+	}
+	out := []*FuncProfile{}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Errorf("could not read json: %+v", err)
+		return
+	}
+	if len(out) != 3 || out[0].Name != "slow" || out[0].Blocked != time.Second {
+		t.Errorf("unexpected json profile: %s", buf.String())
+	}
+
+	buf = &bytes.Buffer{}
+	if err := engine.WriteProfilePprof(buf); err != nil {
+		t.Errorf("could not write pprof: %+v", err)
+		return
+	}
+	gz, err := gzip.NewReader(buf)
+	if err != nil {
+		t.Errorf("pprof is not gzipped: %+v", err)
+		return
+	}
+	b, err := io.ReadAll(gz)
+	if err != nil {
+		t.Errorf("could not read pprof: %+v", err)
+		return
+	}
+	for _, s := range []string{"slow", "fast", "stuck", "first_value", "nanoseconds"} {
+		if !bytes.Contains(b, []byte(s)) {
+			t.Errorf("pprof is missing string: %s", s)
+		}
+	}
+}
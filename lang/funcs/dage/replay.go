@@ -0,0 +1,417 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package dage
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/purpleidea/mgmt/lang/funcs/structs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// ReplayTimeout is how long the replay waits for a recorded event to
+	// become possible before it gives up on it and skips ahead. This only
+	// happens if the replayed run has diverged from the recorded one.
+	ReplayTimeout = 10 * time.Second
+)
+
+// event is a single entry in a recording. It is either a value that a function
+// sent, the close of the output of a function, or a new graph event from the
+// engine. The events are stored one per line in the order that they happened.
+type event struct {
+	// Seq is the position of this event in the recording.
+	Seq int64 `json:"seq"`
+
+	// Offset is the time between the engine starting and this event.
+	Offset time.Duration `json:"offset"`
+
+	// Key identifies the function that this event belongs to.
+	Key string `json:"key,omitempty"`
+
+	// Name is the printed name of the function. It is only informational.
+	Name string `json:"name,omitempty"`
+
+	// Type is the type of the value.
+	Type string `json:"type,omitempty"`
+
+	// Value is the encoded value that the function sent.
+	Value json.RawMessage `json:"value,omitempty"`
+
+	// Closed is true if the function closed its output.
+	Closed bool `json:"closed,omitempty"`
+
+	// Graph is the number of graph events that the engine has sent so far,
+	// if this is a graph event.
+	Graph int64 `json:"graph,omitempty"`
+
+	value types.Value // decoded value
+	sent  chan struct{}
+}
+
+// recorder writes out the events of a running engine.
+type recorder struct {
+	mutex  *sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	start  time.Time
+	seq    int64
+	graphs int64
+}
+
+// newRecorder creates a new recording file.
+func newRecorder(filename string) (*recorder, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &recorder{
+		mutex:  &sync.Mutex{},
+		file:   file,
+		writer: bufio.NewWriter(file),
+		start:  time.Now(),
+	}, nil
+}
+
+// write adds an event to the recording.
+func (obj *recorder) write(ev *event) error {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	obj.seq++
+	ev.Seq = obj.seq
+	ev.Offset = time.Since(obj.start)
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if _, err := obj.writer.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return obj.writer.Flush() // so that a crash still leaves a recording
+}
+
+// value records a value sent by a function.
+func (obj *recorder) value(node *state, value types.Value) error {
+	if types.IsSecret(value) {
+		return fmt.Errorf("not recording a secret value")
+	}
+	b, err := types.ValueToJSON(value)
+	if err != nil {
+		return err
+	}
+	return obj.write(&event{
+		Key:   node.key,
+		Name:  node.String(),
+		Type:  value.Type().String(),
+		Value: b,
+	})
+}
+
+// closed records the close of the output of a function.
+func (obj *recorder) closed(node *state) error {
+	return obj.write(&event{
+		Key:    node.key,
+		Name:   node.String(),
+		Closed: true,
+	})
+}
+
+// graph records a graph event from the engine.
+func (obj *recorder) graph() error {
+	obj.mutex.Lock()
+	obj.graphs++
+	graphs := obj.graphs
+	obj.mutex.Unlock()
+	return obj.write(&event{
+		Graph: graphs,
+	})
+}
+
+// Close finishes the recording.
+func (obj *recorder) Close() error {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	err := obj.writer.Flush()
+	return errwrap.Append(err, obj.file.Close())
+}
+
+// replayer sends the events of a recording back into a running engine in the
+// same order that they were recorded.
+type replayer struct {
+	events []*event
+
+	mutex   *sync.Mutex
+	chans   map[string]chan *event // one per function key
+	graphs  int64                  // number of graph events so far
+	changed chan struct{}          // closed when graphs changes
+}
+
+// newReplayer loads a recording.
+func newReplayer(filename string) (*replayer, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	events := []*event{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024) // allow big values
+	for scanner.Scan() {
+		ev := &event{}
+		if err := json.Unmarshal(scanner.Bytes(), ev); err != nil {
+			return nil, errwrap.Wrapf(err, "invalid event on line %d", len(events)+1)
+		}
+		if ev.Type != "" {
+			typ := types.NewType(ev.Type)
+			if typ == nil {
+				return nil, fmt.Errorf("invalid type `%s` on line %d", ev.Type, len(events)+1)
+			}
+			value, err := types.ValueFromJSON(typ, ev.Value)
+			if err != nil {
+				return nil, errwrap.Wrapf(err, "invalid value on line %d", len(events)+1)
+			}
+			ev.value = value
+		}
+		ev.sent = make(chan struct{})
+		events = append(events, ev)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+
+	return &replayer{
+		events:  events,
+		mutex:   &sync.Mutex{},
+		chans:   make(map[string]chan *event),
+		changed: make(chan struct{}),
+	}, nil
+}
+
+// channel returns the channel that the events for a function key are sent on.
+func (obj *replayer) channel(key string) chan *event {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	ch, exists := obj.chans[key]
+	if !exists {
+		ch = make(chan *event)
+		obj.chans[key] = ch
+	}
+	return ch
+}
+
+// graph tells the replayer that the engine sent a graph event.
+func (obj *replayer) graph() {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	obj.graphs++
+	close(obj.changed)
+	obj.changed = make(chan struct{})
+}
+
+// waitGraph blocks until the engine has sent at least this many graph events.
+func (obj *replayer) waitGraph(ctx context.Context, graphs int64, timeout <-chan time.Time) bool {
+	for {
+		obj.mutex.Lock()
+		count, changed := obj.graphs, obj.changed
+		obj.mutex.Unlock()
+		if count >= graphs {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// Run sends each recorded event to the function that it belongs to, once its
+// recorded offset has passed and every earlier event has been sent. Graph
+// events are a barrier which waits until the engine has caught up.
+func (obj *replayer) Run(ctx context.Context, start time.Time, logf func(format string, v ...interface{})) {
+	for _, ev := range obj.events {
+		select {
+		case <-time.After(time.Until(start.Add(ev.Offset))):
+		case <-ctx.Done():
+			return
+		}
+
+		timeout := time.After(ReplayTimeout)
+		if ev.Graph > 0 {
+			if !obj.waitGraph(ctx, ev.Graph, timeout) && ctx.Err() == nil {
+				logf("replay: gave up waiting for graph %d", ev.Graph)
+			}
+			continue
+		}
+
+		select {
+		case obj.channel(ev.Key) <- ev:
+		case <-timeout:
+			logf("replay: gave up waiting for func `%s` (%s)", ev.Name, ev.Key)
+			continue
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case <-ev.sent:
+		case <-ctx.Done():
+			return
+		}
+	}
+	logf("replay: done")
+}
+
+// stream runs in place of the Stream method of a function that is replayed. It
+// drains the input, and sends the recorded values for the function key.
+func (obj *replayer) stream(ctx context.Context, key string, input <-chan types.Value, output chan<- types.Value) error {
+	defer close(output) // the sender closes
+
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case _, ok := <-input:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ch := obj.channel(key)
+	for {
+		var ev *event
+		select {
+		case ev = <-ch:
+		case <-ctx.Done():
+			return nil
+		}
+		if ev.Closed {
+			close(ev.sent)
+			return nil
+		}
+		select {
+		case output <- ev.value:
+			close(ev.sent)
+		case <-ctx.Done():
+			close(ev.sent) // don't hold up the other funcs
+			return nil
+		}
+	}
+}
+
+// replayable returns true if we should record and replay this function.
+func replayable(f interfaces.Func) bool {
+	if _, ok := f.(interfaces.UnreplayableFunc); ok {
+		return false
+	}
+	return !f.Info().Pure
+}
+
+// hash returns a hash of this function and of every function upstream of it,
+// which is the same when we run the same code again. Constants include their
+// values, and every other function is identified by its name and its inputs.
+// This must be run when the graph isn't changing.
+func (obj *Engine) hash(f interfaces.Func) string {
+	node, exists := obj.state[f]
+	if exists && node.hash != "" {
+		return node.hash // memoized
+	}
+
+	s := f.String()
+	if cf, ok := f.(*structs.ConstFunc); ok {
+		s += "(" + hashValue(cf.Value) + ")"
+	}
+	args := []string{}
+	for _, v := range obj.graph.IncomingGraphVertices(f) {
+		ff, ok := v.(interfaces.Func)
+		if !ok {
+			panic("not a Func")
+		}
+		edge := obj.graph.Adjacency()[ff][f].(*interfaces.FuncEdge)
+		args = append(args, strings.Join(edge.Args, ",")+"="+obj.hash(ff))
+	}
+	sort.Strings(args)
+	s += "[" + strings.Join(args, ";") + "]"
+
+	sum := sha256.Sum256([]byte(s))
+	h := hex.EncodeToString(sum[:8])
+	if exists {
+		node.hash = h
+	}
+	return h
+}
+
+// hashValue returns an encoding of a constant value for the hash, which is the
+// same when we run the same code again. The printed form of a secret changes in
+// every process, and we don't want anything about it to end up in a recording,
+// so only the type of a secret is used.
+func hashValue(value types.Value) string {
+	typ := value.Type().String()
+	if types.IsSecret(value) {
+		return typ + ":secret"
+	}
+	b, err := types.ValueToJSON(value)
+	if err != nil { // eg: a func value
+		return typ + ":" + value.String()
+	}
+	return typ + ":" + string(b)
+}
+
+// key returns a name for this function which is the same when we run the same
+// code again. Functions with identical hashes are numbered in the order that
+// they start.
+func (obj *Engine) key(node *state) string {
+	h := obj.hash(node.Func)
+	obj.keys[h]++
+	return h + "#" + strconv.Itoa(obj.keys[h])
+}
@@ -0,0 +1,124 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package dage
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/purpleidea/mgmt/lang/funcs/structs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/pgraph"
+)
+
+func TestRecording0(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "recording.jsonl")
+	r, err := newRecorder(filename)
+	if err != nil {
+		t.Errorf("could not create recorder: %+v", err)
+		return
+	}
+	node := &state{name: "abc", key: "abc#1"}
+	if err := r.graph(); err != nil {
+		t.Errorf("could not record: %+v", err)
+	}
+	if err := r.value(node, &types.IntValue{V: 42}); err != nil {
+		t.Errorf("could not record: %+v", err)
+	}
+	if err := r.closed(node); err != nil {
+		t.Errorf("could not record: %+v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("could not close recorder: %+v", err)
+		return
+	}
+
+	p, err := newReplayer(filename)
+	if err != nil {
+		t.Errorf("could not load recording: %+v", err)
+		return
+	}
+	if len(p.events) != 3 {
+		t.Errorf("expected 3 events, got: %d", len(p.events))
+		return
+	}
+	if ev := p.events[0]; ev.Graph != 1 {
+		t.Errorf("expected a graph event, got: %+v", ev)
+	}
+	if ev := p.events[1]; ev.Key != "abc#1" || ev.value == nil || ev.value.Cmp(&types.IntValue{V: 42}) != nil {
+		t.Errorf("expected a value event, got: %+v", ev)
+	}
+	if ev := p.events[2]; ev.Key != "abc#1" || !ev.Closed {
+		t.Errorf("expected a closed event, got: %+v", ev)
+	}
+	for i, ev := range p.events {
+		if ev.Seq != int64(i+1) {
+			t.Errorf("unexpected seq %d for event #%d", ev.Seq, i)
+		}
+	}
+}
+
+func TestHash0(t *testing.T) {
+	// hash returns the hash of a func which has this constant as input
+	hash := func(value types.Value) string {
+		graph, err := pgraph.NewGraph("g")
+		if err != nil {
+			t.Fatalf("could not create graph: %+v", err)
+		}
+		c := &structs.ConstFunc{Value: value}
+		f := &testFunc{Name: "f", Type: types.NewType("func(a str) str")}
+		graph.AddEdge(c, f, testEdge("a"))
+		engine := &Engine{
+			graph: graph,
+			state: make(map[interfaces.Func]*state),
+		}
+		return engine.hash(f)
+	}
+
+	if h1, h2 := hash(&types.StrValue{V: "a"}), hash(&types.StrValue{V: "a"}); h1 != h2 {
+		t.Errorf("same constant has different hashes: %s != %s", h1, h2)
+	}
+	if h1, h2 := hash(&types.StrValue{V: "a"}), hash(&types.StrValue{V: "b"}); h1 == h2 {
+		t.Errorf("different constants have the same hash: %s", h1)
+	}
+
+	// secrets are printed differently in every process, so they only
+	// contribute their type, and nothing about the value
+	if h1, h2 := hash(types.NewSecret("hunter2")), hash(types.NewSecret("other")); h1 != h2 {
+		t.Errorf("secret constants have different hashes: %s != %s", h1, h2)
+	}
+	if s := hashValue(types.NewSecret("hunter2")); strings.Contains(s, "hunter2") || strings.Contains(s, "<secret:") {
+		t.Errorf("unexpected secret encoding: %s", s)
+	}
+}
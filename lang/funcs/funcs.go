@@ -68,6 +68,14 @@ const (
 // includes implementations which also satisfy PolyFunc as well.
 var registeredFuncs = make(map[string]func() interfaces.Func) // must initialize
 
+// deprecatedFuncs is a global map of function names to a deprecation message.
+// You should never touch this map directly. Use Deprecate instead.
+var deprecatedFuncs = make(map[string]string) // must initialize
+
+// registeredFuncsMutex guards both registeredFuncs and deprecatedFuncs, since
+// Stub can change the registered functions after the program has started up.
+var registeredFuncsMutex = &sync.RWMutex{}
+
 // Register takes a func and its name and makes it available for use. It is
 // commonly called in the init() method of the func at program startup. There is
 // no matching Unregister function. You may also register functions which
@@ -75,6 +83,15 @@ var registeredFuncs = make(map[string]func() interfaces.Func) // must initialize
 // module, you must join the module name to the function name with the ModuleSep
 // character. It is defined as a const and is probably the period character.
 func Register(name string, fn func() interfaces.Func) {
+	fnx := fn() // check that all functions have migrated to the new API!
+	if _, ok := fnx.(interfaces.OldPolyFunc); ok {
+		if _, ok := fnx.(interfaces.PolyFunc); !ok {
+			panic(fmt.Sprintf("a func named %s implements OldPolyFunc but not PolyFunc", name))
+		}
+	}
+
+	registeredFuncsMutex.Lock()
+	defer registeredFuncsMutex.Unlock()
 	if _, exists := registeredFuncs[name]; exists {
 		panic(fmt.Sprintf("a func named %s is already registered", name))
 	}
@@ -93,13 +110,6 @@ func Register(name string, fn func() interfaces.Func) {
 	//	panic(fmt.Sprintf("a func named %s is invalid", name))
 	//}
 
-	fnx := fn() // check that all functions have migrated to the new API!
-	if _, ok := fnx.(interfaces.OldPolyFunc); ok {
-		if _, ok := fnx.(interfaces.PolyFunc); !ok {
-			panic(fmt.Sprintf("a func named %s implements OldPolyFunc but not PolyFunc", name))
-		}
-	}
-
 	//gob.Register(fn())
 	registeredFuncs[name] = fn
 }
@@ -110,10 +120,59 @@ func ModuleRegister(module, name string, fn func() interfaces.Func) {
 	Register(module+ModuleSep+name, fn)
 }
 
+// Stub replaces an already registered function with a different implementation
+// and returns a function that restores the original one. This is used by the
+// `mgmt test` runner so that tests can fake out the functions which look at the
+// real system. Code that is compiled while the stub is in place will use it.
+func Stub(name string, fn func() interfaces.Func) (func(), error) {
+	registeredFuncsMutex.Lock()
+	defer registeredFuncsMutex.Unlock()
+	orig, exists := registeredFuncs[name]
+	if !exists {
+		return nil, fmt.Errorf("a func named %s is not registered", name)
+	}
+	registeredFuncs[name] = fn
+	return func() {
+		registeredFuncsMutex.Lock()
+		defer registeredFuncsMutex.Unlock()
+		registeredFuncs[name] = orig
+	}, nil
+}
+
+// Deprecate marks an already registered function as deprecated. The message
+// should tell the user what to use instead. It is commonly called in the init()
+// method right after the function was registered. This doesn't change how the
+// function runs, but tools such as the linter can warn about its use.
+func Deprecate(name, msg string) {
+	registeredFuncsMutex.Lock()
+	defer registeredFuncsMutex.Unlock()
+	if _, exists := registeredFuncs[name]; !exists {
+		panic(fmt.Sprintf("a func named %s is not registered", name))
+	}
+	deprecatedFuncs[name] = msg
+}
+
+// ModuleDeprecate is exactly like Deprecate, except that it operates within a
+// named module.
+func ModuleDeprecate(module, name, msg string) {
+	Deprecate(module+ModuleSep+name, msg)
+}
+
+// Deprecated returns the deprecation message for a function, and true if that
+// function has been marked as deprecated.
+func Deprecated(name string) (string, bool) {
+	registeredFuncsMutex.RLock()
+	defer registeredFuncsMutex.RUnlock()
+	msg, exists := deprecatedFuncs[name]
+	return msg, exists
+}
+
 // Lookup returns a pointer to the function's struct. It may be convertible to a
 // PolyFunc if the particular function implements those additional methods.
 func Lookup(name string) (interfaces.Func, error) {
+	registeredFuncsMutex.RLock()
 	f, exists := registeredFuncs[name]
+	registeredFuncsMutex.RUnlock()
 	if !exists {
 		return nil, fmt.Errorf("not found")
 	}
@@ -127,6 +186,8 @@ func Lookup(name string) (interfaces.Func, error) {
 // result in the map keys that it returns. If you search for an empty prefix,
 // then this will return all the top-level functions that aren't in a module.
 func LookupPrefix(prefix string) map[string]func() interfaces.Func {
+	registeredFuncsMutex.RLock()
+	defer registeredFuncsMutex.RUnlock()
 	result := make(map[string]func() interfaces.Func)
 	for name, f := range registeredFuncs {
 		// requested top-level functions, and no module separators...
@@ -153,6 +214,8 @@ func LookupPrefix(prefix string) map[string]func() interfaces.Func {
 // functions, and each one must have its own unique memory address to work
 // properly.
 func Map() map[string]func() interfaces.Func {
+	registeredFuncsMutex.RLock()
+	defer registeredFuncsMutex.RUnlock()
 	m := make(map[string]func() interfaces.Func)
 	for name, fn := range registeredFuncs { // copy
 		m[name] = fn
@@ -326,5 +389,13 @@ Loop:
 		// XXX: this could happen if we send zero input args, and Stream exits without error
 		return nil, fmt.Errorf("function exited with nil result and nil error")
 	}
+	if result != nil && !info.Reveal {
+		for _, arg := range args {
+			if types.IsSecret(arg) { // anything derived is secret
+				result = types.MakeSecret(result)
+				break
+			}
+		}
+	}
 	return result, reterr
 }
@@ -90,6 +90,10 @@ func (obj *ChannelBasedSinkFunc) Info() *interfaces.Info {
 	}
 }
 
+// Unreplayable tells the engine not to record or replay this function, because
+// it sends its values over a channel instead of a graph edge.
+func (obj *ChannelBasedSinkFunc) Unreplayable() {}
+
 // Init runs some startup code for this function.
 func (obj *ChannelBasedSinkFunc) Init(init *interfaces.Init) error {
 	obj.init = init
@@ -80,6 +80,10 @@ func (obj *ChannelBasedSourceFunc) Info() *interfaces.Info {
 	}
 }
 
+// Unreplayable tells the engine not to record or replay this function, because
+// it receives its values over a channel instead of a graph edge.
+func (obj *ChannelBasedSourceFunc) Unreplayable() {}
+
 // Init runs some startup code for this function.
 func (obj *ChannelBasedSourceFunc) Init(init *interfaces.Init) error {
 	obj.init = init
@@ -0,0 +1,782 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+// Package templatepoly contains a helper for building polymorphic functions
+// whose signature can be described by a template with type variables. A
+// signature such as `func(inputs []?T, needle ?T) int` means that every place
+// which uses `?T` must be of the same type, whatever that type turns out to be.
+package templatepoly
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// VarPrefix is the character which starts the name of a type variable
+	// in the string form of a signature template.
+	VarPrefix = "?"
+)
+
+// varRegexp matches the type variables in the string form of a template.
+var varRegexp = regexp.MustCompile(`\` + VarPrefix + `[A-Za-z][A-Za-z0-9]*`)
+
+// Sig is a signature template, or a part of one. It has the same shape as the
+// *types.Type that it describes, except that any part of it can also be a type
+// variable, which is something that the type system itself doesn't have.
+type Sig struct {
+	// Var is the name of the type variable, such as `?T`, if this is one.
+	// None of the other fields are used if it is set.
+	Var string
+
+	Kind types.Kind
+	Val  *Sig
+	Key  *Sig
+	Map  map[string]*Sig
+	Ord  []string
+	Out  *Sig
+}
+
+// Parse builds a signature template from its string form. It returns nil if
+// the string is not a valid signature.
+func Parse(s string) *Sig {
+	// The type parser doesn't know about type variables, so we replace
+	// each of them with a struct that has a single specially named field,
+	// and then we swap those back for the variables after parsing it.
+	s = varRegexp.ReplaceAllStringFunc(s, func(v string) string {
+		return fmt.Sprintf("struct{%s bool}", v)
+	})
+	typ := types.NewType(s)
+	if typ == nil {
+		return nil
+	}
+	return unmark(typ)
+}
+
+// unmark is a helper for Parse which builds the template from the parsed type,
+// and replaces the marker structs with type variables.
+func unmark(typ *types.Type) *Sig {
+	if typ == nil {
+		return nil
+	}
+	if typ.Kind == types.KindStruct && len(typ.Ord) == 1 && strings.HasPrefix(typ.Ord[0], VarPrefix) {
+		return &Sig{Var: typ.Ord[0]}
+	}
+	sig := &Sig{
+		Kind: typ.Kind,
+		Val:  unmark(typ.Val),
+		Key:  unmark(typ.Key),
+		Out:  unmark(typ.Out),
+	}
+	if typ.Map != nil {
+		sig.Map = make(map[string]*Sig)
+		for _, k := range typ.Ord {
+			sig.Map[k] = unmark(typ.Map[k])
+			sig.Ord = append(sig.Ord, k)
+		}
+	}
+	return sig
+}
+
+// Type returns the type that this template describes. It returns nil if there
+// are any type variables in it.
+func (obj *Sig) Type() *types.Type {
+	if obj.Var != "" {
+		return nil
+	}
+	typ := &types.Type{
+		Kind: obj.Kind,
+	}
+	if obj.Val != nil {
+		if typ.Val = obj.Val.Type(); typ.Val == nil {
+			return nil
+		}
+	}
+	if obj.Key != nil {
+		if typ.Key = obj.Key.Type(); typ.Key == nil {
+			return nil
+		}
+	}
+	if obj.Out != nil {
+		if typ.Out = obj.Out.Type(); typ.Out == nil {
+			return nil
+		}
+	}
+	if obj.Map != nil {
+		typ.Map = make(map[string]*types.Type)
+		for _, k := range obj.Ord {
+			t := obj.Map[k].Type()
+			if t == nil {
+				return nil
+			}
+			typ.Map[k] = t
+			typ.Ord = append(typ.Ord, k)
+		}
+	}
+	return typ
+}
+
+// String returns the string form of this template, which Parse can read back.
+func (obj *Sig) String() string {
+	if obj.Var != "" {
+		return obj.Var
+	}
+	if typ := obj.Type(); typ != nil {
+		return typ.String()
+	}
+	fields := []string{}
+	for _, k := range obj.Ord {
+		fields = append(fields, fmt.Sprintf("%s %s", k, obj.Map[k]))
+	}
+	switch obj.Kind {
+	case types.KindList:
+		return "[]" + obj.Val.String()
+	case types.KindMap:
+		return fmt.Sprintf("map{%s: %s}", obj.Key, obj.Val)
+	case types.KindStruct:
+		return fmt.Sprintf("struct{%s}", strings.Join(fields, "; "))
+	case types.KindFunc:
+		return fmt.Sprintf("func(%s) %s", strings.Join(fields, ", "), obj.Out)
+	}
+	return fmt.Sprintf("<%s>", obj.Kind) // can't have variables in this
+}
+
+// Template implements the unification and building of a polymorphic function
+// from a signature template. Functions which need a custom Stream can use it
+// to provide their ArgGen, Unify and Build methods.
+type Template struct {
+	// Sig is the signature template. It must be of kind func.
+	Sig *Sig
+
+	// Check is an optional function which is run on the solved type
+	// variables. It can be used to restrict what a variable can be.
+	Check func(vars map[string]*types.Type) error
+
+	// Hints maps the name of a str arg to a type variable. If that arg is
+	// known during unification, it is parsed as the type of the variable.
+	// This lets a user say what type they expect, such as when decoding.
+	Hints map[string]string
+
+	vars map[string]*types.Type // solved type variables
+	typ  *types.Type            // built signature
+}
+
+// NewTemplate returns a new template from the string form of a signature. It
+// panics if the signature is not valid, because it is a programming error.
+func NewTemplate(sig string) *Template {
+	tmpl := Parse(sig)
+	if tmpl == nil || tmpl.Var != "" || tmpl.Kind != types.KindFunc {
+		panic(fmt.Sprintf("invalid signature template: %s", sig))
+	}
+	return &Template{
+		Sig: tmpl,
+	}
+}
+
+// ArgGen returns the Nth arg name for this function.
+func (obj *Template) ArgGen(index int) (string, error) {
+	if l := len(obj.Sig.Ord); index >= l {
+		return "", fmt.Errorf("index %d exceeds arg length of %d", index, l)
+	}
+	return obj.Sig.Ord[index], nil
+}
+
+// Unify returns the list of invariants that this template produces. The type
+// variables each get a dummy expression, and every part of the signature is
+// related to those with the usual wrap invariants.
+func (obj *Template) Unify(expr interfaces.Expr) ([]interfaces.Invariant, error) {
+	var invariants []interfaces.Invariant
+	vars := make(map[string]interfaces.Expr)
+
+	mapped := make(map[string]interfaces.Expr)
+	ordered := []string{}
+	dummyArgs := []interfaces.Expr{}
+	for _, name := range obj.Sig.Ord {
+		dummy, invars, err := obj.expr(obj.Sig.Map[name], vars)
+		if err != nil {
+			return nil, err
+		}
+		invariants = append(invariants, invars...)
+		mapped[name] = dummy
+		ordered = append(ordered, name)
+		dummyArgs = append(dummyArgs, dummy)
+	}
+	dummyOut, invars, err := obj.expr(obj.Sig.Out, vars)
+	if err != nil {
+		return nil, err
+	}
+	invariants = append(invariants, invars...)
+
+	for name, v := range obj.Hints {
+		if _, exists := obj.Sig.Map[name]; !exists {
+			return nil, fmt.Errorf("type hint `%s` is not an arg", name)
+		}
+		if _, exists := vars[v]; !exists {
+			return nil, fmt.Errorf("type hint `%s` uses unknown variable %s", name, v)
+		}
+	}
+
+	// full function
+	invar := &interfaces.EqualityWrapFuncInvariant{
+		Expr1:    expr, // maps directly to us!
+		Expr2Map: mapped,
+		Expr2Ord: ordered,
+		Expr2Out: dummyOut,
+	}
+	invariants = append(invariants, invar)
+
+	// generator function to link this to the args of the call
+	fn := func(fnInvariants []interfaces.Invariant, solved map[interfaces.Expr]*types.Type) ([]interfaces.Invariant, error) {
+		for _, invariant := range fnInvariants {
+			// search for this special type of invariant
+			cfavInvar, ok := invariant.(*interfaces.CallFuncArgsValueInvariant)
+			if !ok {
+				continue
+			}
+			// did we find the mapping from us to ExprCall ?
+			if cfavInvar.Func != expr {
+				continue
+			}
+			// cfavInvar.Expr is the ExprCall! (the return pointer)
+			// cfavInvar.Args are the args that ExprCall uses!
+			if l, n := len(cfavInvar.Args), len(dummyArgs); l != n {
+				return nil, fmt.Errorf("unable to build function with %d args, expected %d", l, n)
+			}
+
+			var invariants []interfaces.Invariant
+			var invar interfaces.Invariant
+
+			// add the relationship to the returned value
+			invar = &interfaces.EqualityInvariant{
+				Expr1: dummyOut,
+				Expr2: cfavInvar.Expr,
+			}
+			invariants = append(invariants, invar)
+
+			// add the relationships to the called args
+			for i, arg := range cfavInvar.Args {
+				invar = &interfaces.EqualityInvariant{
+					Expr1: dummyArgs[i],
+					Expr2: arg,
+				}
+				invariants = append(invariants, invar)
+			}
+
+			// add what we know from any of the type hints
+			for i, name := range obj.Sig.Ord {
+				v, exists := obj.Hints[name]
+				if !exists {
+					continue
+				}
+				value, err := cfavInvar.Args[i].Value() // is it known?
+				if err != nil {
+					continue // we'll have to learn it some other way
+				}
+				typ := types.NewType(value.Str())
+				if typ == nil {
+					continue // this will error when it runs
+				}
+				invar = &interfaces.EqualsInvariant{
+					Expr: vars[v],
+					Type: typ,
+				}
+				invariants = append(invariants, invar)
+			}
+
+			invar = &interfaces.EqualityWrapCallInvariant{
+				Expr1:     cfavInvar.Expr,
+				Expr2Func: expr,
+			}
+			invariants = append(invariants, invar)
+
+			return invariants, nil // generator return
+		}
+		// We couldn't tell the solver anything it didn't already know!
+		return nil, fmt.Errorf("couldn't generate new invariants")
+	}
+	invar2 := &interfaces.GeneratorInvariant{
+		Func: fn,
+	}
+	invariants = append(invariants, invar2)
+
+	return invariants, nil
+}
+
+// expr returns a dummy expression for this part of the template, along with the
+// invariants which describe it.
+func (obj *Template) expr(tmpl *Sig, vars map[string]interfaces.Expr) (interfaces.Expr, []interfaces.Invariant, error) {
+	if tmpl == nil {
+		return nil, nil, fmt.Errorf("template is incomplete")
+	}
+	if tmpl.Var != "" {
+		dummy, exists := vars[tmpl.Var]
+		if !exists {
+			dummy = &interfaces.ExprAny{} // corresponds to the variable
+			vars[tmpl.Var] = dummy
+		}
+		return dummy, nil, nil
+	}
+
+	dummy := &interfaces.ExprAny{}
+	if typ := tmpl.Type(); typ != nil { // it's a known type
+		invar := &interfaces.EqualsInvariant{
+			Expr: dummy,
+			Type: typ,
+		}
+		return dummy, []interfaces.Invariant{invar}, nil
+	}
+
+	var invariants []interfaces.Invariant
+	switch tmpl.Kind {
+	case types.KindList:
+		val, invars, err := obj.expr(tmpl.Val, vars)
+		if err != nil {
+			return nil, nil, err
+		}
+		invariants = append(invariants, invars...)
+		invar := &interfaces.EqualityWrapListInvariant{
+			Expr1:    dummy,
+			Expr2Val: val,
+		}
+		invariants = append(invariants, invar)
+
+	case types.KindMap:
+		key, invars, err := obj.expr(tmpl.Key, vars)
+		if err != nil {
+			return nil, nil, err
+		}
+		invariants = append(invariants, invars...)
+		val, invars, err := obj.expr(tmpl.Val, vars)
+		if err != nil {
+			return nil, nil, err
+		}
+		invariants = append(invariants, invars...)
+		invar := &interfaces.EqualityWrapMapInvariant{
+			Expr1:    dummy,
+			Expr2Key: key,
+			Expr2Val: val,
+		}
+		invariants = append(invariants, invar)
+
+	case types.KindStruct, types.KindFunc:
+		mapped := make(map[string]interfaces.Expr)
+		ordered := []string{}
+		for _, k := range tmpl.Ord {
+			x, invars, err := obj.expr(tmpl.Map[k], vars)
+			if err != nil {
+				return nil, nil, err
+			}
+			invariants = append(invariants, invars...)
+			mapped[k] = x
+			ordered = append(ordered, k)
+		}
+		if tmpl.Kind == types.KindStruct {
+			invar := &interfaces.EqualityWrapStructInvariant{
+				Expr1:    dummy,
+				Expr2Map: mapped,
+				Expr2Ord: ordered,
+			}
+			invariants = append(invariants, invar)
+			break
+		}
+		out, invars, err := obj.expr(tmpl.Out, vars)
+		if err != nil {
+			return nil, nil, err
+		}
+		invariants = append(invariants, invars...)
+		invar := &interfaces.EqualityWrapFuncInvariant{
+			Expr1:    dummy,
+			Expr2Map: mapped,
+			Expr2Ord: ordered,
+			Expr2Out: out,
+		}
+		invariants = append(invariants, invar)
+
+	default:
+		return nil, nil, fmt.Errorf("can't use type variables in kind: %s", tmpl.Kind)
+	}
+
+	return dummy, invariants, nil
+}
+
+// Build is run to turn the polymorphic, undetermined function, into the
+// specific statically typed version. It learns each of the type variables from
+// the type that it gets, and returns the signature with those filled in.
+func (obj *Template) Build(typ *types.Type) (*types.Type, error) {
+	// typ is the KindFunc signature we're trying to build...
+	if typ.Kind != types.KindFunc {
+		return nil, fmt.Errorf("input type must be of kind func")
+	}
+	if l, n := len(typ.Ord), len(obj.Sig.Ord); l != n {
+		return nil, fmt.Errorf("the function needs exactly %d args, got %d", n, l)
+	}
+	if typ.Map == nil {
+		return nil, fmt.Errorf("invalid input type")
+	}
+	if typ.Out == nil {
+		return nil, fmt.Errorf("return type of function must be specified")
+	}
+
+	vars := make(map[string]*types.Type)
+	for i, name := range obj.Sig.Ord {
+		t, exists := typ.Map[typ.Ord[i]]
+		if !exists || t == nil {
+			return nil, fmt.Errorf("arg #%d must be specified", i)
+		}
+		if err := learn(obj.Sig.Map[name], t, vars); err != nil {
+			return nil, errwrap.Wrapf(err, "arg `%s` is inconsistent", name)
+		}
+	}
+	if err := learn(obj.Sig.Out, typ.Out, vars); err != nil {
+		return nil, errwrap.Wrapf(err, "return type is inconsistent")
+	}
+
+	sig, err := fill(obj.Sig, vars)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Check != nil {
+		if err := obj.Check(vars); err != nil {
+			return nil, err
+		}
+	}
+
+	obj.vars = vars
+	obj.typ = sig
+	return obj.typ, nil
+}
+
+// learn matches a template against a type, and stores the type variables it
+// finds. It errors if they don't match, or if a variable would have two types.
+func learn(tmpl *Sig, typ *types.Type, vars map[string]*types.Type) error {
+	if typ == nil {
+		return fmt.Errorf("type is not known")
+	}
+	if tmpl.Var != "" {
+		if t, exists := vars[tmpl.Var]; exists {
+			return errwrap.Wrapf(t.Cmp(typ), "type variable %s is inconsistent", tmpl.Var)
+		}
+		vars[tmpl.Var] = typ
+		return nil
+	}
+	if t := tmpl.Type(); t != nil {
+		return t.Cmp(typ)
+	}
+	if tmpl.Kind != typ.Kind {
+		return fmt.Errorf("expected kind %s, got %s", tmpl.Kind, typ.Kind)
+	}
+
+	switch tmpl.Kind {
+	case types.KindList:
+		return learn(tmpl.Val, typ.Val, vars)
+
+	case types.KindMap:
+		if err := learn(tmpl.Key, typ.Key, vars); err != nil {
+			return err
+		}
+		return learn(tmpl.Val, typ.Val, vars)
+
+	case types.KindStruct, types.KindFunc:
+		if len(tmpl.Ord) != len(typ.Ord) {
+			return fmt.Errorf("expected %d fields, got %d", len(tmpl.Ord), len(typ.Ord))
+		}
+		for i, k := range tmpl.Ord {
+			// func arg names don't matter, but struct ones do
+			if tmpl.Kind == types.KindStruct && k != typ.Ord[i] {
+				return fmt.Errorf("expected field `%s`, got `%s`", k, typ.Ord[i])
+			}
+			if err := learn(tmpl.Map[k], typ.Map[typ.Ord[i]], vars); err != nil {
+				return err
+			}
+		}
+		if tmpl.Kind == types.KindStruct {
+			return nil
+		}
+		return learn(tmpl.Out, typ.Out, vars)
+	}
+
+	return fmt.Errorf("can't use type variables in kind: %s", tmpl.Kind)
+}
+
+// fill returns a copy of the template with each type variable replaced by the
+// type that it was solved as.
+func fill(tmpl *Sig, vars map[string]*types.Type) (*types.Type, error) {
+	if tmpl == nil {
+		return nil, nil
+	}
+	if tmpl.Var != "" {
+		t, exists := vars[tmpl.Var]
+		if !exists {
+			return nil, fmt.Errorf("type variable %s is not known", tmpl.Var)
+		}
+		return t.Copy(), nil
+	}
+	if typ := tmpl.Type(); typ != nil {
+		return typ, nil
+	}
+
+	val, err := fill(tmpl.Val, vars)
+	if err != nil {
+		return nil, err
+	}
+	key, err := fill(tmpl.Key, vars)
+	if err != nil {
+		return nil, err
+	}
+	out, err := fill(tmpl.Out, vars)
+	if err != nil {
+		return nil, err
+	}
+	typ := &types.Type{
+		Kind: tmpl.Kind,
+		Val:  val,
+		Key:  key,
+		Out:  out,
+	}
+	if tmpl.Map != nil {
+		typ.Map = make(map[string]*types.Type)
+		for _, k := range tmpl.Ord {
+			t, err := fill(tmpl.Map[k], vars)
+			if err != nil {
+				return nil, err
+			}
+			typ.Map[k] = t
+			typ.Ord = append(typ.Ord, k)
+		}
+	}
+	return typ, nil
+}
+
+// Validate tells us if Build has been run successfully.
+func (obj *Template) Validate() error {
+	if obj.typ == nil { // build must be run first
+		return fmt.Errorf("type is still unspecified")
+	}
+	return nil
+}
+
+// CheckHints makes sure that the type hints among these args match the type
+// variables which were built. The args are in the same order as the signature.
+func (obj *Template) CheckHints(args []types.Value) error {
+	for i, name := range obj.Sig.Ord {
+		v, exists := obj.Hints[name]
+		if !exists {
+			continue
+		}
+		typ := types.NewType(args[i].Str())
+		if typ == nil {
+			return fmt.Errorf("arg `%s` is not a valid type: %s", name, args[i].Str())
+		}
+		if err := typ.Cmp(obj.vars[v]); err != nil {
+			return errwrap.Wrapf(err, "arg `%s` does not match the type %s", name, obj.vars[v])
+		}
+	}
+	return nil
+}
+
+// Type returns the signature that Build created, or nil if it hasn't been run.
+func (obj *Template) Type() *types.Type {
+	return obj.typ
+}
+
+// Var returns the type that a type variable was solved as. It includes the
+// prefix in the name, such as `?T`. It returns nil if Build hasn't been run.
+func (obj *Template) Var(name string) *types.Type {
+	return obj.vars[name]
+}
+
+// CheckOpts is a helper for the Check of a function which takes an opts struct
+// whose fields are all optional, such as `func(opts ?T) str`. It makes sure that
+// the type is a struct, that each of its fields is one of the valid ones with
+// the right type, and that any required fields are present.
+func CheckOpts(typ *types.Type, valid map[string]*types.Type, required ...string) error {
+	if typ == nil {
+		return fmt.Errorf("the type of the opts struct is not known")
+	}
+	if typ.Kind != types.KindStruct {
+		return fmt.Errorf("opts must be of kind struct")
+	}
+	for _, name := range typ.Ord {
+		t := typ.Map[name]
+		value, exists := valid[name]
+		if !exists {
+			return fmt.Errorf("unexpected opts field: `%s`", name)
+		}
+
+		if err := t.Cmp(value); err != nil {
+			return errwrap.Wrapf(err, "expected different type for opts field: `%s`", name)
+		}
+	}
+	for _, name := range required {
+		if _, exists := typ.Map[name]; !exists {
+			return fmt.Errorf("opts field `%s` is required", name)
+		}
+	}
+	return nil
+}
+
+// Register registers a pure polymorphic function which is described by this
+// signature template. The function receives the signature that was built, so
+// that it can make values of the right type, such as an empty list.
+func Register(name, sig string, fn func(typ *types.Type, args []types.Value) (types.Value, error)) {
+	NewTemplate(sig) // panic early if it's invalid
+	funcs.Register(name, func() interfaces.Func {
+		return &WrappedFunc{
+			Name:     name,
+			Template: NewTemplate(sig),
+			Fn:       fn,
+		}
+	})
+}
+
+// RegisterCheck is like Register, but it also takes a function to check the
+// solved type variables with, such as to only allow types which can be sorted.
+func RegisterCheck(name, sig string, check func(vars map[string]*types.Type) error, fn func(typ *types.Type, args []types.Value) (types.Value, error)) {
+	NewTemplate(sig) // panic early if it's invalid
+	funcs.Register(name, func() interfaces.Func {
+		tmpl := NewTemplate(sig)
+		tmpl.Check = check
+		return &WrappedFunc{
+			Name:     name,
+			Template: tmpl,
+			Fn:       fn,
+		}
+	})
+}
+
+// ModuleRegister is exactly like Register, except that it registers within a
+// named module. This is a helper function.
+func ModuleRegister(module, name, sig string, fn func(typ *types.Type, args []types.Value) (types.Value, error)) {
+	Register(module+funcs.ModuleSep+name, sig, fn)
+}
+
+// ModuleRegisterCheck is exactly like RegisterCheck, except that it registers
+// within a named module. This is a helper function.
+func ModuleRegisterCheck(module, name, sig string, check func(vars map[string]*types.Type) error, fn func(typ *types.Type, args []types.Value) (types.Value, error)) {
+	RegisterCheck(module+funcs.ModuleSep+name, sig, check, fn)
+}
+
+var _ interfaces.PolyFunc = &WrappedFunc{} // ensure it meets this expectation
+
+// WrappedFunc is a scaffolding function struct which fulfills the function API
+// for a pure function that is described by a signature template.
+type WrappedFunc struct {
+	*Template
+
+	Name string
+
+	Fn func(typ *types.Type, args []types.Value) (types.Value, error)
+
+	init   *interfaces.Init
+	last   types.Value // last value received to use for diff
+	result types.Value // last calculated output
+}
+
+// String returns a simple name for this function. This is needed so this struct
+// can satisfy the pgraph.Vertex interface.
+func (obj *WrappedFunc) String() string {
+	return obj.Name
+}
+
+// Info returns some static info about itself. Build must be called before this
+// will return correct data.
+func (obj *WrappedFunc) Info() *interfaces.Info {
+	return &interfaces.Info{
+		Pure: true,
+		Memo: false,
+		Sig:  obj.Type(), // nil if called speculatively
+		Err:  obj.Validate(),
+	}
+}
+
+// Init runs some startup code for this function.
+func (obj *WrappedFunc) Init(init *interfaces.Init) error {
+	obj.init = init
+	return nil
+}
+
+// Stream returns the changing values that this func has over time.
+func (obj *WrappedFunc) Stream(ctx context.Context) error {
+	defer close(obj.init.Output) // the sender closes
+	typ := obj.Type()
+	for {
+		select {
+		case input, ok := <-obj.init.Input:
+			if !ok {
+				if len(typ.Ord) > 0 {
+					return nil // can't output any more
+				}
+				// no inputs were expected, pass through once
+			}
+			if ok {
+				if obj.last != nil && input.Cmp(obj.last) == nil {
+					continue // value didn't change, skip it
+				}
+				obj.last = input // store for next
+			}
+
+			values := []types.Value{}
+			for _, name := range typ.Ord {
+				values = append(values, input.Struct()[name])
+			}
+			if err := obj.CheckHints(values); err != nil {
+				return errwrap.Wrapf(err, "function `%s` errored", obj.Name)
+			}
+
+			result, err := obj.Fn(typ, values)
+			if err != nil {
+				return errwrap.Wrapf(err, "function `%s` errored", obj.Name)
+			}
+
+			if obj.result != nil && result.Cmp(obj.result) == nil {
+				continue // result didn't change
+			}
+			obj.result = result // store new result
+
+		case <-ctx.Done():
+			return nil
+		}
+
+		select {
+		case obj.init.Output <- obj.result: // send
+			if len(typ.Ord) == 0 {
+				return nil // no more values, we're a pure func
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
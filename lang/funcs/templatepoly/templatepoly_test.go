@@ -0,0 +1,166 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package templatepoly
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func TestParse0(t *testing.T) {
+	for _, s := range []string{
+		"func(inputs []?T) []?T",
+		"func(a []?A, b []?B) []struct{a ?A; b ?B}",
+		"func(m map{?K: ?V}, key ?K) bool",
+		"func(inputs []?T, function func(x ?T) bool) []?T",
+		"func(a int) str", // no variables at all
+	} {
+		typ := Parse(s)
+		if typ == nil {
+			t.Errorf("could not parse: %s", s)
+			continue
+		}
+		// named types print as their name, so this should round trip
+		if out := typ.String(); out != s {
+			t.Errorf("parse of `%s` printed as: `%s`", s, out)
+		}
+	}
+
+	if sig := Parse("func(m map{?K: []?V}) ?K"); sig.Map["m"].Key.Var != "?K" || sig.Map["m"].Val.Val.Var != "?V" || sig.Out.Var != "?K" {
+		t.Errorf("expected type variables in: %s", sig)
+	}
+	if sig := Parse("func(a []int) ?T"); sig.Map["a"].Type() == nil || sig.Type() != nil {
+		t.Errorf("expected only the known part to have a type: %s", sig)
+	}
+
+	if typ := Parse("func(a ?) int"); typ != nil {
+		t.Errorf("expected an invalid template to fail")
+	}
+}
+
+func TestBuild0(t *testing.T) {
+	type test struct {
+		sig  string
+		typ  string
+		fail bool
+		exp  string
+	}
+	testCases := []test{
+		{
+			sig: "func(inputs []?T) []?T",
+			typ: "func(arg0 []str) []str",
+			exp: "func(inputs []str) []str",
+		},
+		{
+			sig: "func(a []?A, b []?B) []struct{a ?A; b ?B}",
+			typ: "func(a []int, b []bool) []struct{a int; b bool}",
+			exp: "func(a []int, b []bool) []struct{a int; b bool}",
+		},
+		{
+			sig: "func(inputs []?T, initial ?A, function func(?A, ?T) ?A) ?A",
+			typ: "func(inputs []int, initial str, function func(x str, y int) str) str",
+			exp: "func(inputs []int, initial str, function func(str, int) str) str",
+		},
+		{
+			sig:  "func(inputs []?T, needle ?T) int",
+			typ:  "func(inputs []int, needle str) int",
+			fail: true, // ?T can't be two different types
+		},
+		{
+			sig:  "func(m map{?K: ?V}, key ?K) bool",
+			typ:  "func(m []int, key int) bool",
+			fail: true, // the kind doesn't match
+		},
+		{
+			sig:  "func(inputs []?T) int",
+			typ:  "func(inputs []int) str",
+			fail: true, // the known part doesn't match
+		},
+		{
+			sig:  "func(inputs []?T) []?T",
+			typ:  "func(inputs []int, extra int) []int",
+			fail: true, // wrong number of args
+		},
+	}
+
+	for index, tc := range testCases {
+		t.Run(fmt.Sprintf("test #%d (%s)", index, tc.sig), func(t *testing.T) {
+			tmpl := NewTemplate(tc.sig)
+			typ, err := tmpl.Build(types.NewType(tc.typ))
+			if tc.fail {
+				if err == nil {
+					t.Errorf("test #%d: expected failure, got: %s", index, typ)
+				}
+				if tmpl.Validate() == nil {
+					t.Errorf("test #%d: expected validate to fail", index)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("test #%d: build failed: %+v", index, err)
+				return
+			}
+			if err := typ.Cmp(types.NewType(tc.exp)); err != nil {
+				t.Errorf("test #%d: got: %s, expected: %s", index, typ, tc.exp)
+			}
+			for i, name := range tmpl.Sig.Ord { // the arg names get fixed
+				if typ.Ord[i] != name {
+					t.Errorf("test #%d: arg #%d is named: %s", index, i, typ.Ord[i])
+				}
+			}
+			if err := tmpl.Validate(); err != nil {
+				t.Errorf("test #%d: validate failed: %+v", index, err)
+			}
+		})
+	}
+}
+
+func TestBuildCheck0(t *testing.T) {
+	tmpl := NewTemplate("func(inputs []?T) []?T")
+	tmpl.Check = func(vars map[string]*types.Type) error {
+		if vars["?T"].Kind != types.KindInt {
+			return fmt.Errorf("only ints are allowed")
+		}
+		return nil
+	}
+	if _, err := tmpl.Build(types.NewType("func(inputs []str) []str")); err == nil {
+		t.Errorf("expected the check to fail")
+	}
+	if _, err := tmpl.Build(types.NewType("func(inputs []int) []int")); err != nil {
+		t.Errorf("expected the check to pass: %+v", err)
+	}
+	if typ := tmpl.Var("?T"); typ == nil || typ.Kind != types.KindInt {
+		t.Errorf("unexpected type variable: %v", typ)
+	}
+}
@@ -119,6 +119,13 @@ func (obj *GAPI) Cli(info *gapi.Info) (*gapi.Deploy, error) {
 	if modules != "" && (!strings.HasPrefix(modules, "/") || !strings.HasSuffix(modules, "/")) {
 		return nil, fmt.Errorf("module path is not an absolute directory")
 	}
+	cache := args.ModuleCache
+	if cache != "" && (!strings.HasPrefix(cache, "/") || !strings.HasSuffix(cache, "/")) {
+		return nil, fmt.Errorf("module cache is not an absolute directory")
+	}
+	if args.Vendor && !args.Download {
+		return nil, fmt.Errorf("can't vendor without downloading")
+	}
 
 	// TODO: while reading through trees of metadata files, we could also
 	// check the license compatibility of deps...
@@ -153,6 +160,17 @@ func (obj *GAPI) Cli(info *gapi.Info) (*gapi.Deploy, error) {
 	// This runs the necessary downloads. It passes a downloader in, which
 	// can be used to pull down or update any missing imports.
 	var downloader interfaces.Downloader
+	lockFile := output.Base + interfaces.LockFilename // beside the metadata
+	// the lock file makes every host download identical modules, and we
+	// check it on every load, even when we don't download anything...
+	lock, err := download.ReadLock(downloadFs, lockFile) // can be nil
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "could not read lock file")
+	}
+	var verifyModule func(engine.Fs, string, string) error
+	if lock != nil {
+		verifyModule = lock.Verify
+	}
 	if args.Download {
 		downloadInfo := &interfaces.DownloadInfo{
 			Fs: downloadFs, // the local fs!
@@ -172,6 +190,8 @@ func (obj *GAPI) Cli(info *gapi.Info) (*gapi.Deploy, error) {
 		downloader = &download.Downloader{
 			Depth: args.Depth, // default of infinite is -1
 			Retry: args.Retry, // infinite is -1
+			Lock:  lock,       // can be nil
+			Cache: cache,      // empty to clone from the remote
 		}
 		if err := downloader.Init(downloadInfo); err != nil {
 			return nil, errwrap.Wrapf(err, "could not initialize downloader")
@@ -202,6 +222,7 @@ func (obj *GAPI) Cli(info *gapi.Info) (*gapi.Deploy, error) {
 
 		LexParser:       parser.LexParse,
 		Downloader:      downloader,
+		VerifyModule:    verifyModule,
 		StrInterpolater: interpolate.StrInterpolate,
 		//Local: obj.Local, // TODO: do we need this?
 		//World: obj.World, // TODO: do we need this?
@@ -259,6 +280,32 @@ func (obj *GAPI) Cli(info *gapi.Info) (*gapi.Deploy, error) {
 		return nil, errwrap.Wrapf(err, "could not set scope")
 	}
 
+	// Record exactly what we downloaded, so that it can be reproduced. We
+	// don't create a new lock file if there aren't any remote imports.
+	if d, ok := downloader.(*download.Downloader); ok && !info.Flags.Noop {
+		locked := d.Locked()
+		if lock != nil || len(locked.Modules) > 0 {
+			if err := locked.Save(downloadFs, lockFile); err != nil {
+				return nil, errwrap.Wrapf(err, "could not write lock file")
+			}
+			logf("wrote lock file: %s", lockFile)
+		}
+	}
+
+	// Copy everything we downloaded next to the top-level module, so that
+	// it can be run without downloading anything.
+	if d, ok := downloader.(*download.Downloader); ok && args.Vendor && !info.Flags.Noop {
+		vendorPath := interfaces.FindVendorPath(output.Metadata)
+		if vendorPath == "" {
+			return nil, fmt.Errorf("can't vendor without a top-level %s file", interfaces.MetadataFilename)
+		}
+		dirs := d.Dirs()
+		if err := download.Vendor(downloadFs, dirs, vendorPath); err != nil {
+			return nil, errwrap.Wrapf(err, "could not vendor modules")
+		}
+		logf("vendored %d module(s) to: %s", len(dirs), vendorPath)
+	}
+
 	// Previously the `get` command would stop here.
 	if args.OnlyDownload {
 		return nil, nil // success!
@@ -426,6 +473,12 @@ func (obj *GAPI) Cli(info *gapi.Info) (*gapi.Deploy, error) {
 			InputURI: fs.URI(),
 			Data: &lang.Data{
 				UnificationStrategy: unificationStrategy,
+				FuncProfile:         args.FuncProfile,
+				FuncGraphviz:        args.FuncGraphviz,
+				FuncGraphvizFilter:  args.FuncGraphvizFilter,
+				FuncInspect:         args.FuncInspect,
+				FuncRecord:          args.FuncRecord,
+				FuncReplay:          args.FuncReplay,
 				// TODO: add properties here...
 			},
 		},
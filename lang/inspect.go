@@ -0,0 +1,197 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package lang
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+
+	"github.com/purpleidea/mgmt/lang/ast"
+	"github.com/purpleidea/mgmt/lang/funcs/structs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+// InspectValue is the latest value of a single bind or function in a running
+// program.
+type InspectValue struct {
+	// Name is the name of the bind, or the printed name of the function.
+	Name string `json:"name"`
+
+	// Type is the type of the value. It is empty when not known yet.
+	Type string `json:"type,omitempty"`
+
+	// Loaded is true if a value has been produced.
+	Loaded bool `json:"loaded"`
+
+	// Value is the value in mcl syntax. It is empty when not loaded.
+	Value string `json:"value,omitempty"`
+}
+
+// Inspection is a snapshot of the latest values in a running program.
+type Inspection struct {
+	// Binds is the list of variables which are used in the program. The
+	// same name can appear more than once if it is used in more than one
+	// scope.
+	Binds []*InspectValue `json:"binds"`
+
+	// Funcs is the list of every function which has produced a value.
+	Funcs []*InspectValue `json:"funcs"`
+}
+
+// Inspect returns a snapshot of the latest value of every bind and function in
+// the running program.
+func (obj *Lang) Inspect() (*Inspection, error) {
+	binds, err := ast.BindFuncs(obj.ast)
+	if err != nil {
+		return nil, err
+	}
+	table := obj.funcs.Table() // copy
+
+	result := &Inspection{
+		Binds: []*InspectValue{},
+		Funcs: []*InspectValue{},
+	}
+	for name, fs := range binds {
+		for _, f := range fs {
+			result.Binds = append(result.Binds, inspectValue(table, "$"+name, f))
+		}
+	}
+	for f := range table {
+		result.Funcs = append(result.Funcs, inspectValue(table, f.String(), f))
+	}
+
+	for _, list := range [][]*InspectValue{result.Binds, result.Funcs} {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].Name != list[j].Name {
+				return list[i].Name < list[j].Name
+			}
+			return list[i].Value < list[j].Value
+		})
+	}
+	return result, nil
+}
+
+// inspectValue returns the latest value of a single function from the table. A
+// polymorphic function which hasn't been built yet has no signature, so it has
+// no type either.
+func inspectValue(table map[interfaces.Func]types.Value, name string, f interfaces.Func) *InspectValue {
+	iv := &InspectValue{
+		Name: name,
+	}
+	if sig := f.Info().Sig; sig != nil && sig.Out != nil {
+		iv.Type = sig.Out.String()
+	}
+	if value, exists := table[f]; exists {
+		iv.Loaded = true
+		iv.Value = value.String()
+	} else if cf, ok := f.(*structs.ConstFunc); ok {
+		// constants that were folded away aren't in the table
+		iv.Loaded = true
+		iv.Value = cf.Value.String()
+	}
+	return iv
+}
+
+// execFuncGraphviz writes out the function graph, annotated with the types and
+// latest values of each function, and runs the graphviz filter on it.
+func (obj *Lang) execFuncGraphviz() error {
+	gv, err := obj.funcs.Graphviz()
+	if err != nil {
+		return err
+	}
+	gv.Filter = obj.Data.FuncGraphvizFilter
+	gv.Filename = obj.Data.FuncGraphviz
+	gv.Hostname = obj.Hostname
+	return gv.Exec()
+}
+
+// serveInspect starts an http server on the listen address which shows the
+// latest values of the running program as JSON, and the function graph in the
+// graphviz format at /graphviz. It returns a function which stops the server.
+func (obj *Lang) serveInspect(listen string) (func(), error) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.NotFound(w, req)
+			return
+		}
+		inspection, err := obj.Inspect()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "\t")
+		if err := encoder.Encode(inspection); err != nil {
+			obj.Logf("func inspect: %+v", err)
+		}
+	})
+	mux.HandleFunc("/graphviz", func(w http.ResponseWriter, req *http.Request) {
+		gv, err := obj.funcs.Graphviz()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		w.Write([]byte(gv.Text()))
+	})
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{
+		Handler: mux,
+	}
+	obj.Logf("func inspect: listening on: %s", listener.Addr())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			obj.Logf("func inspect: %+v", err)
+		}
+	}()
+
+	return func() {
+		// wait for any running requests to finish
+		if err := server.Shutdown(context.Background()); err != nil {
+			obj.Logf("func inspect: %+v", err)
+		}
+		<-done
+	}, nil
+}
@@ -208,6 +208,14 @@ type Data struct {
 	// deploys, however that is not blocked at the level of this interface.
 	Downloader Downloader
 
+	// VerifyModule is a function which checks that the remote module tree
+	// at this path, which is relative to the modules dir, and which was
+	// found in this dir, matches what the lock file expects. It is run on
+	// every load of a remote module that the downloader didn't verify. It
+	// is nil if there's no lock file to check against. This is passed in
+	// this way to avoid dependency cycles.
+	VerifyModule func(fs engine.Fs, path, dir string) error
+
 	// LexParser is a function that needs to get passed in to run the lexer
 	// and parser to build the initial AST. This is passed in this way to
 	// avoid dependency cycles.
@@ -249,6 +257,7 @@ type Scope struct {
 	Variables map[string]Expr
 	Functions map[string]Expr // the Expr will usually be an *ExprFunc (actually it's usually (or always) an *ExprSingleton, which wraps an *ExprFunc now)
 	Classes   map[string]Stmt
+	Types     map[string]*types.Type // type aliases, which are compile time only
 
 	Chain []Node // chain of previously seen node's
 }
@@ -260,6 +269,7 @@ func EmptyScope() *Scope {
 		Variables: make(map[string]Expr),
 		Functions: make(map[string]Expr),
 		Classes:   make(map[string]Stmt),
+		Types:     make(map[string]*types.Type),
 		Chain:     []Node{},
 	}
 }
@@ -276,6 +286,9 @@ func (obj *Scope) InitScope() {
 	if obj.Classes == nil {
 		obj.Classes = make(map[string]Stmt)
 	}
+	if obj.Types == nil {
+		obj.Types = make(map[string]*types.Type)
+	}
 	if obj.Chain == nil {
 		obj.Chain = []Node{}
 	}
@@ -289,6 +302,7 @@ func (obj *Scope) Copy() *Scope {
 	variables := make(map[string]Expr)
 	functions := make(map[string]Expr)
 	classes := make(map[string]Stmt)
+	typs := make(map[string]*types.Type)
 	chain := []Node{}
 	if obj != nil { // allow copying nil scopes
 		obj.InitScope()                   // safety
@@ -301,6 +315,9 @@ func (obj *Scope) Copy() *Scope {
 		for k, v := range obj.Classes { // copy
 			classes[k] = v // we don't copy the StmtClass!
 		}
+		for k, v := range obj.Types { // copy
+			typs[k] = v // we don't copy the types, they're never modified
+		}
 		for _, x := range obj.Chain { // copy
 			chain = append(chain, x) // we don't copy the Stmt pointer!
 		}
@@ -309,6 +326,7 @@ func (obj *Scope) Copy() *Scope {
 		Variables: variables,
 		Functions: functions,
 		Classes:   classes,
+		Types:     typs,
 		Chain:     chain,
 	}
 }
@@ -325,6 +343,7 @@ func (obj *Scope) Merge(scope *Scope) error {
 	namedVariables := []string{}
 	namedFunctions := []string{}
 	namedClasses := []string{}
+	namedTypes := []string{}
 	for name := range scope.Variables {
 		namedVariables = append(namedVariables, name)
 	}
@@ -334,9 +353,13 @@ func (obj *Scope) Merge(scope *Scope) error {
 	for name := range scope.Classes {
 		namedClasses = append(namedClasses, name)
 	}
+	for name := range scope.Types {
+		namedTypes = append(namedTypes, name)
+	}
 	sort.Strings(namedVariables)
 	sort.Strings(namedFunctions)
 	sort.Strings(namedClasses)
+	sort.Strings(namedTypes)
 
 	obj.InitScope() // safety
 
@@ -361,6 +384,13 @@ func (obj *Scope) Merge(scope *Scope) error {
 		}
 		obj.Classes[name] = scope.Classes[name]
 	}
+	for _, name := range namedTypes {
+		if _, exists := obj.Types[name]; exists {
+			e := fmt.Errorf("type `%s` was overwritten", name)
+			err = errwrap.Append(err, e)
+		}
+		obj.Types[name] = scope.Types[name]
+	}
 
 	return err
 }
@@ -380,22 +410,45 @@ func (obj *Scope) IsEmpty() bool {
 	if len(obj.Classes) > 0 {
 		return false
 	}
+	if len(obj.Types) > 0 {
+		return false
+	}
 	return true
 }
 
 // Arg represents a name identifier for a func or class argument declaration and
-// is sometimes accompanied by a type. This does not satisfy the Expr interface.
+// is sometimes accompanied by a type. It can also have a default value which is
+// used when the caller doesn't pass in this arg. This does not satisfy the Expr
+// interface.
 type Arg struct {
 	Name string
 	Type *types.Type // nil if unspecified (needs to be solved for)
+
+	// Default is the expression which is used if this arg is omitted. It
+	// is evaluated in the scope of the definition, not of the caller. This
+	// is nil if the arg is required.
+	Default Expr
+
+	pos *Pos // where this arg was defined, if known
 }
 
+// Locate stores the position of this arg. It is called by the parser.
+func (obj *Arg) Locate(line, column int) {
+	obj.pos = &Pos{Line: line, Column: column}
+}
+
+// Pos returns the position of this arg, or nil if it is not known.
+func (obj *Arg) Pos() *Pos { return obj.pos }
+
 // String returns a short representation of this arg.
 func (obj *Arg) String() string {
 	s := obj.Name
 	if obj.Type != nil {
 		s += fmt.Sprintf(" %s", obj.Type.String())
 	}
+	if obj.Default != nil {
+		s += fmt.Sprintf(" = %s", obj.Default.String())
+	}
 	return s
 }
 
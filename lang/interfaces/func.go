@@ -47,11 +47,12 @@ type FuncSig = func([]types.Value) (types.Value, error)
 // used for static analysis and type checking. If you break this contract, you
 // might cause a panic.
 type Info struct {
-	Pure bool        // is the function pure? (can it be memoized?)
-	Memo bool        // should the function be memoized? (false if too much output)
-	Slow bool        // is the function slow? (avoid speculative execution)
-	Sig  *types.Type // the signature of the function, must be KindFunc
-	Err  error       // is this a valid function, or was it created improperly?
+	Pure   bool        // is the function pure? (can it be memoized?)
+	Memo   bool        // should the function be memoized? (false if too much output)
+	Slow   bool        // is the function slow? (avoid speculative execution)
+	Reveal bool        // does it unwrap secrets? (if not, its output from a secret is secret)
+	Sig    *types.Type // the signature of the function, must be KindFunc
+	Err    error       // is this a valid function, or was it created improperly?
 }
 
 // Init is the structure of values and references which is passed into all
@@ -248,6 +249,19 @@ type DataFunc interface {
 	SetData(*FuncData)
 }
 
+// UnreplayableFunc is a function which isn't pure, but which the engine must not
+// record and replay. This is the case for functions that modify the function
+// graph with the Txn API, or that get their values over a channel from another
+// function, because their output comes from the rest of the function graph and
+// not from the outside world. If you don't implement this, then any function
+// which isn't pure can be recorded and replayed.
+type UnreplayableFunc interface {
+	Func // implement everything in Func but add the additional requirements
+
+	// Unreplayable is a marker method which does nothing.
+	Unreplayable()
+}
+
 // FuncEdge links an output vertex (value) to an input vertex with a named
 // argument.
 type FuncEdge struct {
@@ -67,6 +67,12 @@ type ImportData struct {
 	// URL is the path that a `git clone` operation should use as the URL.
 	// If it is a local import, then this is the empty value.
 	URL string
+
+	// Version is the version constraint that the downloader should use for
+	// a remote import. It is not part of the import string, and is instead
+	// looked up in the Require field of the metadata of the importing
+	// module. If it is empty, then any version is acceptable.
+	Version string
 }
 
 // DownloadInfo is the set of input values passed into the Init method of the
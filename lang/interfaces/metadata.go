@@ -44,6 +44,11 @@ const (
 	// the ideal entry point for any running code.
 	MetadataFilename = "metadata.yaml"
 
+	// LockFilename is the filename for the lock file which records the
+	// exact versions of the downloaded modules. It is stored alongside the
+	// top-level metadata file.
+	LockFilename = "lock.yaml"
+
 	// FileNameExtension is the filename extension used for languages files.
 	FileNameExtension = "mcl" // alternate suggestions welcome!
 
@@ -60,6 +65,12 @@ const (
 	// modules. It can store any useful files that we'd like.
 	FilesDirectory = "files/"
 
+	// VendorDirectory is the directory name which the `mod vendor` command
+	// copies all of the remote imports into. It lives beside the top-level
+	// metadata file, and it is searched before anything else, when we are
+	// not downloading.
+	VendorDirectory = "vendor/"
+
 	// ModuleDirectory is the default module directory name. It gets
 	// appended to whatever the running prefix is or relative to the base
 	// dir being used for deploys.
@@ -112,6 +123,13 @@ type Metadata struct {
 	// they wish to override it higher up in the module search locations.
 	ParentPathBlock bool `yaml:"parentpathblock"`
 
+	// Require maps the name of a remote import, exactly as it is written
+	// in the import statement, to a version constraint. The constraint can
+	// be a tag or branch name, a commit sha, or a semver range of tags such
+	// as `>=1.2.0, <2.0.0` or `^1.2`. Imports which are not listed here use
+	// the default branch of the repository.
+	Require map[string]string `yaml:"require"`
+
 	// Metadata stores a link to the parent metadata structure if it exists.
 	Metadata *Metadata // this does *NOT* get a yaml struct tag
 
@@ -231,6 +249,11 @@ func ParseMetadata(reader io.Reader) (*Metadata, error) {
 	if metadata.Files != "" && (strings.HasPrefix(metadata.Files, "/") || !strings.HasSuffix(metadata.Files, "/")) {
 		return nil, fmt.Errorf("the Files field must be undefined or be a relative dir path")
 	}
+	for name, version := range metadata.Require {
+		if name == "" || strings.TrimSpace(version) == "" {
+			return nil, fmt.Errorf("the Require field must map import names to versions")
+		}
+	}
 	// TODO: add more validation
 
 	return metadata, nil
@@ -334,3 +357,21 @@ func FindModulesPathList(metadata *Metadata, base, modules string) ([]string, er
 	found = append(found, modules) // often comes from an ENV or a default
 	return ret(found)
 }
+
+// FindVendorPath returns an absolute path to the vendor directory of the
+// top-level module. It walks up through the parent metadata to find it. If the
+// top-level module has no metadata file, then there is no vendor directory, and
+// the empty string is returned. It does not do any filesystem operations.
+func FindVendorPath(metadata *Metadata) string {
+	if metadata == nil {
+		return ""
+	}
+	m := metadata // start
+	for m.Metadata != nil {
+		m = m.Metadata // search upwards to the root
+	}
+	if m.metadataPath == "" { // a top-level module might be empty!
+		return ""
+	}
+	return m.metadataPath + VendorDirectory // join w/o cleaning trailing slash
+}
@@ -116,6 +116,34 @@ func TestMetadataParse0(t *testing.T) {
 			meta: meta,
 		})
 	}
+	{
+		meta := DefaultMetadata()
+		meta.Require = map[string]string{
+			"git://github.com/purpleidea/mgmt-example1/": "^1.2",
+			"git://github.com/purpleidea/mgmt-example2/": "v0.1.0",
+		}
+		testCases = append(testCases, test{
+			name: "require versions",
+			yaml: util.Code(`
+			require:
+			  "git://github.com/purpleidea/mgmt-example1/": "^1.2"
+			  "git://github.com/purpleidea/mgmt-example2/": "v0.1.0"
+			`),
+			fail: false,
+			meta: meta,
+		})
+	}
+	{
+		testCases = append(testCases, test{
+			name: "require empty version",
+			yaml: util.Code(`
+			require:
+			  "git://github.com/purpleidea/mgmt-example1/": ""
+			`),
+			fail: true,
+			meta: DefaultMetadata(), // unused
+		})
+	}
 
 	names := []string{}
 	for index, tc := range testCases { // run all the tests
@@ -208,3 +236,20 @@ func TestMetadataSave0(t *testing.T) {
 	}
 	t.Logf("got:\n%s", s)
 }
+
+func TestFindVendorPath0(t *testing.T) {
+	top := &Metadata{metadataPath: "/top/"}
+	mid := &Metadata{metadataPath: "/top/path/example.com/mod1/", Metadata: top}
+	low := &Metadata{metadataPath: "/top/path/example.com/mod2/", Metadata: mid}
+	if s := FindVendorPath(low); s != "/top/vendor/" {
+		t.Errorf("unexpected vendor path: %s", s)
+	}
+
+	// a top-level module without a metadata file has no vendor dir
+	if s := FindVendorPath(&Metadata{Metadata: &Metadata{}}); s != "" {
+		t.Errorf("unexpected vendor path: %s", s)
+	}
+	if s := FindVendorPath(nil); s != "" {
+		t.Errorf("unexpected vendor path: %s", s)
+	}
+}
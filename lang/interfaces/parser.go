@@ -29,6 +29,10 @@
 
 package interfaces
 
+import (
+	"errors"
+)
+
 // Pos represents a position in the code. This is used by the parser and string
 // interpolation.
 // TODO: consider expanding with range characteristics.
@@ -37,3 +41,44 @@ type Pos struct {
 	Column   int    // column number starting at 1
 	Filename string // optional source filename, if known
 }
+
+// Locatable is something which knows where it was defined in the code. The
+// parser locates what it builds, and any copies keep that same position.
+type Locatable interface {
+	// Locate stores the position of this. It is called by the parser.
+	Locate(line, column int)
+
+	// Pos returns the position of this, or nil if it is not known.
+	Pos() *Pos
+}
+
+// PosError is an error which happened at a known position in the code. It
+// prints the same as the error that it wraps, so that it can be added without
+// changing any messages.
+type PosError struct {
+	Err error
+	Pos *Pos
+}
+
+// Error returns the message of the wrapped error.
+func (obj *PosError) Error() string { return obj.Err.Error() }
+
+// Unwrap returns the wrapped error.
+func (obj *PosError) Unwrap() error { return obj.Err }
+
+// WrapPos adds this position to the error. If the error already has a position
+// further down the chain, then that more precise one is kept, and the error is
+// returned unchanged. It also does nothing if the error or position are nil.
+func WrapPos(err error, pos *Pos) error {
+	if err == nil || pos == nil {
+		return err
+	}
+	var e *PosError
+	if errors.As(err, &e) {
+		return err
+	}
+	return &PosError{
+		Err: err,
+		Pos: pos,
+	}
+}
@@ -63,7 +63,6 @@ import (
 	"github.com/purpleidea/mgmt/lang/unification"
 	"github.com/purpleidea/mgmt/pgraph"
 	"github.com/purpleidea/mgmt/util"
-	"github.com/purpleidea/mgmt/util/errwrap"
 
 	"github.com/kylelemons/godebug/pretty"
 	"github.com/spf13/afero"
@@ -2248,7 +2247,7 @@ func TestAstFunc3(t *testing.T) {
 					return
 				}
 
-				s, err := stringResFields(res)
+				s, err := engineUtil.ResToFieldsString(res)
 				if err != nil {
 					t.Errorf("test #%d: FAIL\n\n", index)
 					t.Logf("test #%d: can't read resource: %+v", index, err)
@@ -2301,42 +2300,3 @@ func TestAstFunc3(t *testing.T) {
 		t.Skip("skipping all tests...")
 	}
 }
-
-// stringResFields is a helper function to store a resource graph as a text
-// format for test comparisons. This also adds a line for each vertex as well!
-func stringResFields(res engine.Res) (string, error) {
-	m, err := engineUtil.ResToParamValues(res)
-	if err != nil {
-		return "", errwrap.Wrapf(err, "can't read resource %s", res)
-	}
-	str := ""
-	keys := []string{}
-	for k := range m {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys) // sort for determinism
-	for _, field := range keys {
-		v := m[field]
-		str += fmt.Sprintf("Field: %s[%s].%s = %s\n", res.Kind(), res.Name(), field, v)
-	}
-
-	groupableRes, ok := res.(engine.GroupableRes)
-	if !ok {
-		return str, nil
-	}
-	for _, x := range groupableRes.GetGroup() { // grouped elements
-		s, err := stringResFields(x) // recurse
-		if err != nil {
-			return "", err
-		}
-		s += fmt.Sprintf("Vertex: %s\n", x) // add one for the res itself!
-
-		// add a prefix to each line?
-		s = strings.Trim(s, "\n") // trim trailing newlines
-		for _, f := range strings.Split(s, "\n") {
-			str += fmt.Sprintf("Group: %s: ", res) + f + "\n"
-		}
-		//str += s
-	}
-	return str, nil
-}
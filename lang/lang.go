@@ -35,6 +35,8 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -62,6 +64,10 @@ const (
 	// debugging stats. This is useful for finding bugs in the function
 	// engine. Set to zero to disable.
 	EngineStartupStatsTimeout = 10
+
+	// FuncProfileReportSize is the number of slowest functions to list once
+	// the function engine has loaded, when function profiling is enabled.
+	FuncProfileReportSize = 5
 )
 
 // Data is some data that is passed into the Lang struct. It is presented here
@@ -73,6 +79,35 @@ type Data struct {
 	// we have an overall cleaner unification algorithm in place.
 	UnificationStrategy map[string]string
 
+	// FuncProfile is the path to a local file where we write the function
+	// engine profile when we shut down. If it ends with .pprof or .pb.gz it
+	// is written in the pprof format, otherwise we write JSON. If this is
+	// empty, then no profile is written.
+	FuncProfile string
+
+	// FuncGraphviz is the path to a local file where we write the function
+	// graph each time we interpret it. If this is empty, then nothing is
+	// written.
+	FuncGraphviz string
+
+	// FuncGraphvizFilter is the graphviz filter to use, such as `dot` or
+	// `neato`.
+	FuncGraphvizFilter string
+
+	// FuncInspect is the listen address for an http server which shows the
+	// latest value of every bind and function in the running program. If
+	// this is empty, then no server is started.
+	FuncInspect string
+
+	// FuncRecord is the path to a local file where the function engine
+	// records the values of the functions which aren't pure.
+	FuncRecord string
+
+	// FuncReplay is the path to a local file which was made by FuncRecord.
+	// The function engine replays those values instead of running the
+	// functions which made them.
+	FuncReplay string
+
 	// TODO: Add other fields here if necessary.
 }
 
@@ -311,6 +346,11 @@ func (obj *Lang) Init(ctx context.Context) error {
 		Local:    obj.Local,
 		World:    obj.World,
 		//Prefix:   fmt.Sprintf("%s/", path.Join(obj.Prefix, "funcs")),
+		Record: obj.Data.FuncRecord,
+		Replay: obj.Data.FuncReplay,
+
+		Profiling: obj.Data.FuncProfile != "",
+
 		Debug: obj.Debug,
 		Logf: func(format string, v ...interface{}) {
 			obj.Logf("funcs: "+format, v...)
@@ -396,6 +436,37 @@ func (obj *Lang) Run(ctx context.Context) (reterr error) {
 		}()
 	}
 
+	if obj.Data.FuncInspect != "" {
+		stop, err := obj.serveInspect(obj.Data.FuncInspect)
+		if err != nil {
+			return errwrap.Wrapf(err, "could not start func inspect server")
+		}
+		// this runs before the above Reverse so that any request
+		// which pauses the function engine can finish first
+		defer stop()
+	}
+
+	// print the slowest funcs once we've loaded if we are profiling
+	if obj.Data.FuncProfile != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-obj.funcs.Loaded(): // funcs are now loaded!
+			case <-ctx.Done():
+				return
+			}
+			profiles := obj.funcs.Profile() // slowest first
+			if len(profiles) > FuncProfileReportSize {
+				profiles = profiles[:FuncProfileReportSize]
+			}
+			obj.Logf("slowest funcs to load:")
+			for _, p := range profiles {
+				obj.Logf("%s: first value after %s (values: %d, blocked: %s)", p.Name, p.FirstValue, p.Values, p.Blocked)
+			}
+		}()
+	}
+
 	select {
 	case <-ctx.Done():
 	}
@@ -429,10 +500,42 @@ func (obj *Lang) Interpret() (*pgraph.Graph, error) {
 		return nil, errwrap.Wrapf(err, "could not interpret")
 	}
 
+	if obj.Data.FuncGraphviz != "" {
+		if err := obj.execFuncGraphviz(); err != nil {
+			obj.Logf("func graphviz: %+v", err)
+		} else {
+			obj.Logf("func graphviz: successfully generated graph!")
+		}
+	}
+
 	return graph, nil // return a graph
 }
 
 // Cleanup cleans up and frees memory and resources after everything is done.
 func (obj *Lang) Cleanup() error {
-	return obj.funcs.Cleanup()
+	var reterr error
+	if obj.Data.FuncProfile != "" {
+		if err := obj.writeFuncProfile(obj.Data.FuncProfile); err != nil {
+			reterr = errwrap.Wrapf(err, "could not write func profile")
+		}
+	}
+	return errwrap.Append(reterr, obj.funcs.Cleanup())
+}
+
+// writeFuncProfile writes the function engine profile to a local file. The file
+// format is chosen by the file extension.
+func (obj *Lang) writeFuncProfile(filename string) error {
+	buf := &bytes.Buffer{}
+	if strings.HasSuffix(filename, ".pprof") || strings.HasSuffix(filename, ".pb.gz") {
+		if err := obj.funcs.WriteProfilePprof(buf); err != nil {
+			return err
+		}
+	} else if err := obj.funcs.WriteProfileJSON(buf); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filename, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	obj.Logf("wrote func profile to: %s", filename)
+	return nil
 }
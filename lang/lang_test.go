@@ -34,14 +34,21 @@ package lang
 import (
 	"context"
 	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/purpleidea/mgmt/engine"
 	"github.com/purpleidea/mgmt/engine/resources"
+	"github.com/purpleidea/mgmt/lang/ast"
 	_ "github.com/purpleidea/mgmt/lang/core" // import so the funcs register
+	"github.com/purpleidea/mgmt/lang/funcs"
 	"github.com/purpleidea/mgmt/lang/inputs"
 	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
 	"github.com/purpleidea/mgmt/pgraph"
 	"github.com/purpleidea/mgmt/util"
 	"github.com/purpleidea/mgmt/util/errwrap"
@@ -1117,3 +1124,319 @@ func TestInterpretMany(t *testing.T) {
 		})
 	}
 }
+
+func TestInspect0(t *testing.T) {
+	code := `
+		import "fmt"
+
+		$x = "hello"
+		$y = fmt.printf("%s world", $x)
+		$unused = "nope"
+
+		test "t1" {
+			anotherstr => $y,
+		}
+	`
+	logf := func(format string, v ...interface{}) {
+		t.Logf("test: lang: "+format, v...)
+	}
+	mmFs := afero.NewMemMapFs()
+	afs := &afero.Afero{Fs: mmFs} // wrap so that we're implementing ioutil
+	fs := &util.AferoFs{Afero: afs}
+
+	output, err := inputs.ParseInput(code, fs) // raw code can be passed in
+	if err != nil {
+		t.Errorf("ParseInput failed: %+v", err)
+		return
+	}
+	for _, fn := range output.Workers {
+		if err := fn(fs); err != nil {
+			t.Errorf("worker failed: %+v", err)
+			return
+		}
+	}
+
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lang := &Lang{
+		Fs:    fs,
+		Input: "/" + interfaces.MetadataFilename, // start path in fs
+		Data: &Data{
+			UnificationStrategy: make(map[string]string), // empty
+		},
+		Debug: testing.Verbose(), // set via the -test.v flag to `go test`
+		Logf:  logf,
+	}
+	if err := lang.Init(ctx); err != nil {
+		t.Errorf("init failed: %+v", err)
+		return
+	}
+	defer lang.Cleanup()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := lang.Run(ctx); err != nil {
+			t.Errorf("run failed: %+v", err)
+		}
+	}()
+	defer cancel() // shutdown the Run
+
+	select {
+	case err, ok := <-lang.Stream():
+		if !ok {
+			t.Errorf("stream closed without event")
+			return
+		}
+		if err != nil {
+			t.Errorf("stream failed: %+v", err)
+			return
+		}
+	}
+
+	inspection, err := lang.Inspect()
+	if err != nil {
+		t.Errorf("inspect failed: %+v", err)
+		return
+	}
+	binds := make(map[string]string)
+	for _, x := range inspection.Binds {
+		if !x.Loaded {
+			t.Errorf("bind `%s` is not loaded", x.Name)
+		}
+		binds[x.Name] = x.Value
+	}
+	expected := map[string]string{
+		"$x": `"hello"`,
+		"$y": `"hello world"`,
+	}
+	if !reflect.DeepEqual(binds, expected) {
+		t.Errorf("unexpected binds: %+v", binds)
+	}
+	if len(inspection.Funcs) == 0 {
+		t.Errorf("expected some funcs")
+	}
+
+	gv, err := lang.funcs.Graphviz()
+	if err != nil {
+		t.Errorf("graphviz failed: %+v", err)
+		return
+	}
+	if text := gv.Text(); !strings.Contains(text, "value: &#34;hello world&#34;") {
+		t.Errorf("graphviz is missing values:\n%s", text)
+	}
+}
+
+// runRecordReplay runs the code with this function engine data until it gets n
+// different resource graphs, and returns the field of the test resource in each
+// one. The same graph can be sent more than once, so repeats are skipped.
+func runRecordReplay(t *testing.T, code string, data *Data, n int) []string {
+	logf := func(format string, v ...interface{}) {
+		t.Logf("test: lang: "+format, v...)
+	}
+	mmFs := afero.NewMemMapFs()
+	afs := &afero.Afero{Fs: mmFs} // wrap so that we're implementing ioutil
+	fs := &util.AferoFs{Afero: afs}
+
+	output, err := inputs.ParseInput(code, fs) // raw code can be passed in
+	if err != nil {
+		t.Fatalf("ParseInput failed: %+v", err)
+	}
+	for _, fn := range output.Workers {
+		if err := fn(fs); err != nil {
+			t.Fatalf("worker failed: %+v", err)
+		}
+	}
+
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	data.UnificationStrategy = make(map[string]string) // empty
+	lang := &Lang{
+		Fs:    fs,
+		Input: "/" + interfaces.MetadataFilename, // start path in fs
+		Data:  data,
+		Debug: testing.Verbose(), // set via the -test.v flag to `go test`
+		Logf:  logf,
+	}
+	if err := lang.Init(ctx); err != nil {
+		t.Fatalf("init failed: %+v", err)
+	}
+	defer lang.Cleanup()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := lang.Run(ctx); err != nil {
+			t.Errorf("run failed: %+v", err)
+		}
+	}()
+	defer cancel() // shutdown the Run
+
+	result := []string{}
+	for len(result) < n {
+		select {
+		case err, ok := <-lang.Stream():
+			if !ok {
+				t.Fatalf("stream closed early")
+			}
+			if err != nil {
+				t.Fatalf("stream failed: %+v", err)
+			}
+		case <-time.After(30 * time.Second):
+			t.Fatalf("timeout waiting for graph #%d", len(result)+1)
+		}
+
+		graph, err := lang.Interpret()
+		if err != nil {
+			t.Fatalf("interpret failed: %+v", err)
+		}
+		for _, v := range graph.Vertices() {
+			res, ok := v.(*resources.TestRes)
+			if !ok {
+				continue
+			}
+			if l := len(result); l > 0 && result[l-1] == res.AnotherStr {
+				continue
+			}
+			result = append(result, res.AnotherStr)
+		}
+	}
+	return result
+}
+
+func TestRecordReplay0(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping slow test")
+	}
+	// now is impure and it sends a different value every second
+	code := `
+		import "datetime"
+		import "fmt"
+
+		test "t1" {
+			anotherstr => fmt.printf("now: %d", datetime.now()),
+		}
+	`
+	filename := filepath.Join(t.TempDir(), "recording.jsonl")
+	recorded := runRecordReplay(t, code, &Data{FuncRecord: filename}, 3)
+	replayed := runRecordReplay(t, code, &Data{FuncReplay: filename}, 3)
+	if !reflect.DeepEqual(recorded, replayed) {
+		t.Errorf("replay does not match the recording")
+		t.Logf("recorded: %+v", recorded)
+		t.Logf("replayed: %+v", replayed)
+	}
+}
+
+func TestInspectValue0(t *testing.T) {
+	// a polymorphic function has no signature until it's built
+	f := &funcs.LookupFunc{}
+	iv := inspectValue(map[interfaces.Func]types.Value{}, "lookup", f)
+	if iv.Type != "" || iv.Loaded {
+		t.Errorf("unexpected inspect value: %+v", iv)
+	}
+
+	// once it is built, the type is known
+	typ := types.NewType("func(listormap []str, indexorkey int) str")
+	if _, err := f.Build(typ); err != nil {
+		t.Errorf("build failed: %+v", err)
+		return
+	}
+	table := map[interfaces.Func]types.Value{
+		f: &types.StrValue{V: "hello"},
+	}
+	iv = inspectValue(table, "lookup", f)
+	if iv.Type != "str" || !iv.Loaded || iv.Value != `"hello"` {
+		t.Errorf("unexpected inspect value: %+v", iv)
+	}
+}
+
+// BenchmarkConstantFolding compares how long it takes to get the first graph
+// out of some code which calls many pure functions with constant args, with and
+// without the folding of those calls. The number of vertices in the function
+// graph is reported as well.
+func BenchmarkConstantFolding(b *testing.B) {
+	code := "import \"fmt\"\nimport \"strings\"\n"
+	for i := 0; i < 50; i++ {
+		code += fmt.Sprintf("test \"t%d\" {\n", i)
+		code += fmt.Sprintf("\tanotherstr => fmt.printf(\"%%d: %%s\", %d, strings.to_lower(\"HELLO\")),\n", i)
+		code += fmt.Sprintf("\tint64ptr => %d + 13,\n", i)
+		code += "}\n"
+	}
+
+	run := func(b *testing.B) int {
+		mmFs := afero.NewMemMapFs()
+		afs := &afero.Afero{Fs: mmFs} // wrap so that we're implementing ioutil
+		fs := &util.AferoFs{Afero: afs}
+
+		output, err := inputs.ParseInput(code, fs) // raw code can be passed in
+		if err != nil {
+			b.Fatalf("ParseInput failed: %+v", err)
+		}
+		for _, fn := range output.Workers {
+			if err := fn(fs); err != nil {
+				b.Fatalf("worker failed: %+v", err)
+			}
+		}
+
+		wg := &sync.WaitGroup{}
+		defer wg.Wait()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		lang := &Lang{
+			Fs:    fs,
+			Input: "/" + interfaces.MetadataFilename, // start path in fs
+			Data: &Data{
+				UnificationStrategy: make(map[string]string), // empty
+			},
+			Logf: func(format string, v ...interface{}) {},
+		}
+		if err := lang.Init(ctx); err != nil {
+			b.Fatalf("init failed: %+v", err)
+		}
+		defer lang.Cleanup()
+		count := lang.graph.NumVertices()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := lang.Run(ctx); err != nil {
+				b.Errorf("run failed: %+v", err)
+			}
+		}()
+		defer cancel() // shutdown the Run
+
+		// we only wait for the first event, since that is the startup
+		if err, ok := <-lang.Stream(); !ok || err != nil {
+			b.Fatalf("stream failed: %+v", err)
+		}
+		if _, err := lang.Interpret(); err != nil {
+			b.Fatalf("interpret failed: %+v", err)
+		}
+		return count
+	}
+
+	for _, folding := range []bool{false, true} {
+		name := "unfolded"
+		if folding {
+			name = "folded"
+		}
+		b.Run(name, func(b *testing.B) {
+			defer func(v bool) { ast.AllowConstantFolding = v }(ast.AllowConstantFolding)
+			ast.AllowConstantFolding = folding
+			count := 0
+			for i := 0; i < b.N; i++ {
+				count = run(b)
+			}
+			b.ReportMetric(float64(count), "vertices")
+		})
+	}
+}
@@ -0,0 +1,653 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+// Package lint contains a static analysis pass for mcl code. It looks at the
+// parsed AST and flags suspicious code without running any of it.
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/purpleidea/mgmt/engine"
+	engineUtil "github.com/purpleidea/mgmt/engine/util"
+	"github.com/purpleidea/mgmt/lang/ast"
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+	langUtil "github.com/purpleidea/mgmt/lang/util"
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// SeverityWarning is used for problems that are probably a mistake, but
+	// which don't stop the code from running.
+	SeverityWarning = "warning"
+
+	// SeverityError is used for problems that will cause the code to fail
+	// at some later stage.
+	SeverityError = "error"
+)
+
+const (
+	// CheckUnusedBind is the name of the check for binds that are never
+	// referenced.
+	CheckUnusedBind = "unused-bind"
+
+	// CheckUnusedImport is the name of the check for imports that are never
+	// referenced.
+	CheckUnusedImport = "unused-import"
+
+	// CheckShadowedVar is the name of the check for variables that hide a
+	// variable of the same name from an outer scope.
+	CheckShadowedVar = "shadowed-variable"
+
+	// CheckBranchRes is the name of the check for resources which have the
+	// same kind and name in both branches of an if statement.
+	CheckBranchRes = "duplicate-branch-resource"
+
+	// CheckUnknownKind is the name of the check for resources whose kind
+	// does not exist.
+	CheckUnknownKind = "unknown-kind"
+
+	// CheckUnknownField is the name of the check for resource fields which
+	// do not exist on that kind.
+	CheckUnknownField = "unknown-field"
+
+	// CheckUndefinedEdge is the name of the check for edges which point to
+	// a resource that is never defined.
+	CheckUndefinedEdge = "undefined-edge"
+
+	// CheckDeprecatedFunc is the name of the check for calls to functions
+	// which have been marked as deprecated.
+	CheckDeprecatedFunc = "deprecated-function"
+)
+
+// Problem is a single issue found by the linter. The position is that of the
+// offending node, and the Node field contains its short string representation
+// too, since some nodes, such as those built by the interpolation, don't have a
+// position.
+type Problem struct {
+	// Check is the name of the check that found this problem.
+	Check string `json:"check"`
+
+	// Severity is either SeverityWarning or SeverityError.
+	Severity string `json:"severity"`
+
+	// Message is a human readable description of the problem.
+	Message string `json:"message"`
+
+	// Node is the String() of the AST node that caused the problem.
+	Node string `json:"node"`
+
+	// File is the name of the file with the problem, if known.
+	File string `json:"file,omitempty"`
+
+	// Line is the line of the node, starting at 1. It is zero if unknown.
+	Line int `json:"line,omitempty"`
+
+	// Column is the column of the node, starting at 1. It is zero if
+	// unknown.
+	Column int `json:"column,omitempty"`
+}
+
+// String returns a human readable representation of this problem. It starts
+// with the position in the usual file:line:column format, if it is known.
+func (obj *Problem) String() string {
+	s := fmt.Sprintf("%s: %s: %s", obj.Severity, obj.Check, obj.Message)
+	if obj.Line > 0 {
+		s = fmt.Sprintf("%d:%d: %s", obj.Line, obj.Column, s)
+		if obj.File != "" {
+			s = obj.File + ":" + s
+		}
+	} else if obj.File != "" {
+		s = obj.File + ": " + s
+	}
+	return s
+}
+
+// Linter runs all of the lint checks over an AST. The AST should already have
+// been initialized and interpolated. The checks which only need to look at the
+// code run first, and then the linter runs SetScope with the supplied scope, in
+// the same way that the interpreter does, so that it can see what each of the
+// variables refers to. As a result, the AST shouldn't be used again afterwards.
+type Linter struct {
+	// AST is the input program to check.
+	AST interfaces.Stmt
+
+	// Scope is the top-level, built-in scope to run SetScope with.
+	Scope *interfaces.Scope
+
+	// Filename is the name of the file that the AST came from. It is used
+	// in each problem when the position of the node doesn't have one.
+	Filename string
+
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	problems []*Problem
+}
+
+// imported is an import statement in some scope.
+type imported struct {
+	stmt  *ast.StmtImport
+	alias string
+}
+
+// Lint runs all the checks and returns the list of problems found. An error is
+// only returned if the linter itself could not run, which includes the case of
+// code that fails to scope-check.
+func (obj *Linter) Lint() ([]*Problem, error) {
+	if obj.AST == nil {
+		return nil, fmt.Errorf("the AST is nil")
+	}
+	if obj.Scope == nil {
+		obj.Scope = interfaces.EmptyScope()
+	}
+	if obj.Logf == nil {
+		obj.Logf = func(format string, v ...interface{}) {}
+	}
+	obj.problems = []*Problem{}
+
+	// This must happen before SetScope, because that adds copies of the
+	// default args to each call, and replaces the type aliases in place.
+	nodes := []interfaces.Node{}
+	if err := obj.AST.Apply(func(node interfaces.Node) error {
+		nodes = append(nodes, node)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	defined, dynamic, edges := obj.lexical(nodes)
+
+	if err := obj.AST.SetScope(obj.Scope); err != nil {
+		return nil, errwrap.Wrapf(err, "could not set scope")
+	}
+	obj.unused(nodes)
+
+	// These can only be checked once the whole program has been seen.
+	for _, x := range edges {
+		name, ok := x.Name.(*ast.ExprStr)
+		if !ok {
+			continue
+		}
+		if _, exists := dynamic[x.Kind]; exists {
+			continue // it might be one of these
+		}
+		if _, exists := defined[resKey(x.Kind, name.V)]; exists {
+			continue
+		}
+		obj.report(CheckUndefinedEdge, SeverityWarning, x, "edge to `%s` which is never defined", resKey(x.Kind, name.V))
+	}
+
+	return obj.problems, nil
+}
+
+// report adds a new problem to the list.
+func (obj *Linter) report(check, severity string, node interfaces.Node, format string, v ...interface{}) {
+	p := &Problem{
+		Check:    check,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, v...),
+		Node:     node.String(),
+		File:     obj.Filename,
+	}
+	if x, ok := node.(interfaces.Locatable); ok && x.Pos() != nil {
+		pos := x.Pos()
+		p.Line = pos.Line
+		p.Column = pos.Column
+		if pos.Filename != "" {
+			p.File = pos.Filename
+		}
+	}
+	if obj.Debug {
+		obj.Logf("problem: %s", p)
+	}
+	obj.problems = append(obj.problems, p)
+}
+
+// lexical runs the checks which only need to look at the code as it was
+// written. It returns the kind and name of all the static resources, the kinds
+// with a name we can't compute, and all of the edge halves, so that the edges
+// can be checked at the end.
+func (obj *Linter) lexical(nodes []interfaces.Node) (map[string]struct{}, map[string]struct{}, []*ast.StmtEdgeHalf) {
+	defined := make(map[string]struct{})
+	dynamic := make(map[string]struct{})
+	edges := []*ast.StmtEdgeHalf{}
+
+	enclosing := enclosingScopes(nodes)
+	imports := []*imported{}
+	system := make(map[string]string) // alias -> module name
+	names := []string{}               // anything that might use an import
+
+	// A prog comes after its children, so look for the imports first.
+	for _, node := range nodes {
+		x, ok := node.(*ast.StmtProg)
+		if !ok {
+			continue
+		}
+		for _, stmt := range x.Body {
+			imp, ok := stmt.(*ast.StmtImport)
+			if !ok {
+				continue
+			}
+			result, err := langUtil.ParseImportName(imp.Name)
+			if err != nil {
+				continue // this is caught elsewhere
+			}
+			alias := result.Alias
+			if imp.Alias != "" {
+				alias = imp.Alias
+			}
+			imports = append(imports, &imported{
+				stmt:  imp,
+				alias: alias,
+			})
+			if result.IsSystem {
+				system[alias] = result.Name
+			}
+		}
+	}
+
+	for _, node := range nodes {
+		switch x := node.(type) {
+		case *ast.StmtProg:
+			for _, stmt := range x.Body {
+				if bind, ok := stmt.(*ast.StmtBind); ok {
+					obj.shadows(bind, bind.Ident, x, enclosing[bind])
+				}
+			}
+
+		case *ast.StmtBind:
+			names = append(names, typeNames(x.Type)...)
+
+		case *ast.StmtRes:
+			obj.res(x, defined, dynamic)
+			for _, c := range x.Contents {
+				if e, ok := c.(*ast.StmtResEdge); ok {
+					edges = append(edges, e.EdgeHalf)
+				}
+			}
+
+		case *ast.StmtEdge:
+			edges = append(edges, x.EdgeHalfList...)
+
+		case *ast.StmtIf:
+			if x.ThenBranch == nil || x.ElseBranch == nil {
+				break
+			}
+			then := staticRes(x.ThenBranch)
+			for _, key := range staticResList(x.ElseBranch) {
+				if _, exists := then[key]; !exists {
+					continue
+				}
+				obj.report(CheckBranchRes, SeverityWarning, x, "resource `%s` is defined in both branches, consider using conditional fields instead", key)
+			}
+
+		case *ast.StmtClass:
+			for _, arg := range x.Args {
+				names = append(names, typeNames(arg.Type)...)
+				obj.shadows(x, arg.Name, x, enclosing[x])
+			}
+
+		case *ast.StmtInclude:
+			names = append(names, x.Name) // classes can come from imports too
+
+		case *ast.StmtType:
+			names = append(names, typeNames(x.Type)...)
+
+		case *ast.ExprFunc:
+			if x.Body == nil {
+				break // a built-in function
+			}
+			for _, arg := range x.Args {
+				names = append(names, typeNames(arg.Type)...)
+				obj.shadows(x, arg.Name, x, enclosing[x])
+			}
+			names = append(names, typeNames(x.Return)...)
+
+		case *ast.ExprVar:
+			names = append(names, x.Name)
+
+		case *ast.ExprCall:
+			names = append(names, x.Name)
+			if x.Var {
+				break
+			}
+			name := x.Name
+			if ix := strings.Index(name, interfaces.ModuleSep); ix >= 0 {
+				if module, exists := system[name[:ix]]; exists {
+					name = module + name[ix:]
+				}
+			}
+			if msg, deprecated := funcs.Deprecated(name); deprecated {
+				obj.report(CheckDeprecatedFunc, SeverityWarning, x, "function `%s` is deprecated: %s", name, msg)
+			}
+		}
+	}
+
+	for _, x := range imports {
+		if x.alias == interfaces.BareSymbol {
+			continue // we can't tell what comes from these
+		}
+		used := false
+		for _, name := range names {
+			if strings.HasPrefix(name, x.alias+interfaces.ModuleSep) {
+				used = true
+				break
+			}
+		}
+		if !used {
+			obj.report(CheckUnusedImport, SeverityWarning, x.stmt, "import `%s` is never used", x.stmt.Name)
+		}
+	}
+
+	return defined, dynamic, edges
+}
+
+// shadows reports if a name which is declared by the node in the scope, also
+// exists in one of the enclosing scopes.
+func (obj *Linter) shadows(node interfaces.Node, name string, scope interfaces.Node, enclosing []interfaces.Node) {
+	for _, x := range enclosing {
+		if x == scope {
+			continue
+		}
+		if _, exists := declared(x)[name]; exists {
+			obj.report(CheckShadowedVar, SeverityWarning, node, "variable `$%s` shadows a variable from an outer scope", name)
+			return
+		}
+	}
+}
+
+// unused reports all the binds which no variable was resolved to. It must run
+// after SetScope. Each include and each call of a polymorphic function has its
+// own copy of the definition, so we look inside of those too. Every copy keeps
+// the position of the original node, so that's what we use to match them up.
+func (obj *Linter) unused(nodes []interfaces.Node) {
+	// The body of a class is only scope-checked through its includes, so
+	// we don't know anything about the binds of a class that isn't used.
+	inClass := make(map[interfaces.Node]struct{})
+	for _, node := range nodes {
+		if x, ok := node.(*ast.StmtClass); ok {
+			_ = x.Body.Apply(func(node interfaces.Node) error {
+				inClass[node] = struct{}{}
+				return nil
+			})
+		}
+	}
+
+	scoped := []interfaces.Node{}
+	included := make(map[interfaces.Node]struct{})
+	seen := make(map[interfaces.Node]struct{})
+	depth := 0 // how many includes deep we are
+	var apply func(interfaces.Node) error
+	apply = func(node interfaces.Node) error {
+		if depth > 0 {
+			included[node] = struct{}{}
+		}
+		if _, exists := seen[node]; exists {
+			return nil
+		}
+		seen[node] = struct{}{}
+		scoped = append(scoped, node)
+
+		target := ast.ScopeTarget(node)
+		switch node.(type) {
+		case *ast.ExprCall:
+		case *ast.StmtInclude:
+			depth++
+			defer func() { depth-- }()
+		default:
+			return nil
+		}
+		if target == nil {
+			return nil
+		}
+		return target.Apply(apply)
+	}
+	_ = obj.AST.Apply(apply)
+
+	binds := []*ast.StmtBind{}
+	values := make(map[interface{}]*ast.StmtBind)
+	found := make(map[interface{}]struct{})
+	for _, node := range scoped {
+		x, ok := node.(*ast.StmtBind)
+		if !ok {
+			continue
+		}
+		values[x.Value] = x
+		values[key(x.Value)] = x
+		_, ok1 := inClass[x]
+		_, ok2 := included[x]
+		if ok1 && !ok2 {
+			continue // never scope-checked
+		}
+		if _, exists := found[key(x)]; exists {
+			continue // another copy of the same bind
+		}
+		found[key(x)] = struct{}{}
+		binds = append(binds, x)
+	}
+
+	used := make(map[interface{}]struct{})
+	for _, node := range scoped {
+		switch x := node.(type) {
+		case *ast.ExprVar:
+		case *ast.ExprCall:
+			if !x.Var {
+				continue
+			}
+		default:
+			continue
+		}
+		target := unwrap(ast.ScopeTarget(node))
+		if target == nil {
+			continue
+		}
+		if x, exists := values[target]; exists {
+			used[key(x)] = struct{}{}
+		} else if x, exists := values[key(target)]; exists {
+			used[key(x)] = struct{}{}
+		}
+	}
+
+	for _, x := range binds {
+		if _, exists := used[key(x)]; exists {
+			continue
+		}
+		obj.report(CheckUnusedBind, SeverityWarning, x, "bind `$%s` is never used", x.Ident)
+	}
+}
+
+// res checks a resource statement, and adds it to the defined or dynamic maps.
+func (obj *Linter) res(res *ast.StmtRes, defined, dynamic map[string]struct{}) {
+	switch name := res.Name.(type) {
+	case *ast.ExprStr:
+		defined[resKey(res.Kind, name.V)] = struct{}{}
+	case *ast.ExprList:
+		for _, x := range name.Elements {
+			s, ok := x.(*ast.ExprStr)
+			if !ok {
+				dynamic[res.Kind] = struct{}{}
+				continue
+			}
+			defined[resKey(res.Kind, s.V)] = struct{}{}
+		}
+	default:
+		dynamic[res.Kind] = struct{}{}
+	}
+
+	var fields map[string]string // lang field name -> struct field name
+	if _, err := engine.NewResource(res.Kind); err != nil {
+		obj.report(CheckUnknownKind, SeverityError, res, "resource kind `%s` does not exist", res.Kind)
+	} else if m, err := engineUtil.LangFieldNameToStructFieldName(res.Kind); err == nil {
+		fields = m // if this errors, then we don't check the fields
+	}
+
+	for _, x := range res.Contents {
+		c, ok := x.(*ast.StmtResField)
+		if !ok {
+			continue
+		}
+		if _, exists := fields[c.Field]; fields != nil && !exists {
+			obj.report(CheckUnknownField, SeverityError, c, "resource kind `%s` has no field named `%s`", res.Kind, c.Field)
+		}
+	}
+}
+
+// enclosingScopes returns the list of nodes which start a new scope around each
+// node. Each node that starts a scope is included in its own list.
+func enclosingScopes(nodes []interfaces.Node) map[interfaces.Node][]interfaces.Node {
+	result := make(map[interfaces.Node][]interfaces.Node)
+	for _, node := range nodes {
+		if declared(node) == nil {
+			continue
+		}
+		_ = node.Apply(func(n interfaces.Node) error {
+			result[n] = append(result[n], node)
+			return nil
+		})
+	}
+	return result
+}
+
+// declared returns the names of the variables which this node adds to the scope
+// of its children. It returns nil if the node doesn't start a new scope.
+func declared(node interfaces.Node) map[string]struct{} {
+	result := make(map[string]struct{})
+	switch x := node.(type) {
+	case *ast.StmtProg:
+		for _, stmt := range x.Body {
+			if bind, ok := stmt.(*ast.StmtBind); ok {
+				result[bind.Ident] = struct{}{}
+			}
+		}
+	case *ast.StmtClass:
+		for _, arg := range x.Args {
+			result[arg.Name] = struct{}{}
+		}
+	case *ast.ExprFunc:
+		if x.Body == nil {
+			return nil
+		}
+		for _, arg := range x.Args {
+			result[arg.Name] = struct{}{}
+		}
+	default:
+		return nil
+	}
+	return result
+}
+
+// unwrap returns the definition inside of the wrappers which SetScope adds to
+// each bind and function.
+func unwrap(node interfaces.Node) interfaces.Node {
+	for {
+		switch x := node.(type) {
+		case *ast.ExprTopLevel:
+			node = x.Definition
+		case *ast.ExprSingleton:
+			node = x.Definition
+		case *ast.ExprPoly:
+			node = x.Definition
+		default:
+			return node
+		}
+	}
+}
+
+// key returns the position of a node if it has one, since that is the same for
+// all of its copies, and otherwise it returns the node itself.
+func key(node interfaces.Node) interface{} {
+	if x, ok := node.(interfaces.Locatable); ok && x.Pos() != nil {
+		return x.Pos()
+	}
+	return node
+}
+
+// typeNames returns the names of the type aliases which this type refers to.
+func typeNames(typ *types.Type) []string {
+	if typ == nil {
+		return nil
+	}
+	if typ.Kind == types.KindNil && typ.Name != "" { // unresolved alias
+		return []string{typ.Name}
+	}
+	result := []string{}
+	result = append(result, typeNames(typ.Key)...)
+	result = append(result, typeNames(typ.Val)...)
+	for _, t := range typ.Map {
+		result = append(result, typeNames(t)...)
+	}
+	result = append(result, typeNames(typ.Out)...)
+	result = append(result, typeNames(typ.Var)...)
+	return result
+}
+
+// staticResList returns the list of resources with a constant name that are
+// defined somewhere in this statement, without looking inside of classes.
+func staticResList(node interfaces.Stmt) []string {
+	result := []string{}
+	var fn func(interfaces.Stmt)
+	fn = func(node interfaces.Stmt) {
+		switch x := node.(type) {
+		case *ast.StmtProg:
+			for _, stmt := range x.Body {
+				fn(stmt)
+			}
+		case *ast.StmtIf:
+			if x.ThenBranch != nil {
+				fn(x.ThenBranch)
+			}
+			if x.ElseBranch != nil {
+				fn(x.ElseBranch)
+			}
+		case *ast.StmtRes:
+			if name, ok := x.Name.(*ast.ExprStr); ok {
+				result = append(result, resKey(x.Kind, name.V))
+			}
+		}
+	}
+	fn(node)
+	return result
+}
+
+// staticRes is like staticResList, except it returns a set.
+func staticRes(node interfaces.Stmt) map[string]struct{} {
+	result := make(map[string]struct{})
+	for _, key := range staticResList(node) {
+		result[key] = struct{}{}
+	}
+	return result
+}
+
+// resKey returns the unique identifier for a resource.
+func resKey(kind, name string) string {
+	return fmt.Sprintf("%s[%s]", kind, name)
+}
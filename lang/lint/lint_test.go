@@ -0,0 +1,380 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	_ "github.com/purpleidea/mgmt/engine/resources" // import so the resources register
+	"github.com/purpleidea/mgmt/lang/ast"
+	_ "github.com/purpleidea/mgmt/lang/core/net" // import so the funcs register
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/interpolate"
+	"github.com/purpleidea/mgmt/lang/parser"
+	"github.com/purpleidea/mgmt/util"
+)
+
+func TestLint0(t *testing.T) {
+	type test struct { // an individual test
+		name string
+		code string
+		fail bool
+		exp  []string // check names of the expected problems, in order
+	}
+	testCases := []test{}
+
+	testCases = append(testCases, test{
+		name: "clean",
+		code: `
+			import "fmt"
+			$x = "hello"
+			test "t1" {
+				stringptr => fmt.printf("%s", $x),
+			}
+		`,
+		exp: []string{},
+	})
+	testCases = append(testCases, test{
+		name: "unused bind and import",
+		code: `
+			import "fmt"
+			import "math"
+			$x = "hello"
+			$y = fmt.printf("%s", "hi")
+			test "t1" {
+				stringptr => $y,
+			}
+		`,
+		exp: []string{
+			CheckUnusedImport, // math
+			CheckUnusedBind,   // $x
+		},
+	})
+	testCases = append(testCases, test{
+		name: "interpolated var is used",
+		code: `
+			$x = "hello"
+			test "t1" {
+				stringptr => "${x} world",
+			}
+		`,
+		exp: []string{},
+	})
+	testCases = append(testCases, test{
+		name: "shadowed variable",
+		code: `
+			$x = "hello"
+			if true {
+				$x = "world"
+				test "t1" {
+					stringptr => $x,
+				}
+			}
+			$f = func($x) {
+				$x
+			}
+			test "t2" {
+				stringptr => $f("hi"),
+			}
+		`,
+		exp: []string{
+			CheckShadowedVar, // in the if
+			CheckShadowedVar, // in the func
+			CheckUnusedBind,  // the outer $x
+		},
+	})
+	testCases = append(testCases, test{
+		name: "bind only used by an unused bind",
+		code: `
+			$x = "hello"
+			$y = $x
+		`,
+		exp: []string{
+			CheckUnusedBind, // $y
+		},
+	})
+	testCases = append(testCases, test{
+		name: "same name in different scopes",
+		code: `
+			class c1 {
+				$x = "hello"
+				test $x {}
+			}
+			class c2 {
+				$x = "world"
+			}
+			include c1
+			include c1 as i1
+			$f = func($y) {
+				$y
+			}
+			test "t1" {
+				stringptr => $f("hi"),
+			}
+		`,
+		exp: []string{},
+	})
+	testCases = append(testCases, test{
+		name: "unknown variable",
+		code: `
+			test "t1" {
+				stringptr => $nope,
+			}
+		`,
+		fail: true,
+	})
+	testCases = append(testCases, test{
+		name: "same resource in both branches",
+		code: `
+			if true {
+				test "t1" {
+					stringptr => "a",
+				}
+			} else {
+				test "t1" {
+					stringptr => "b",
+				}
+				test "t2" {}
+			}
+		`,
+		exp: []string{
+			CheckBranchRes,
+		},
+	})
+	testCases = append(testCases, test{
+		name: "unknown kind and field",
+		code: `
+			test "t1" {
+				nosuchfield => "a",
+			}
+			nosuchkind "t2" {
+				whatever => "a",
+			}
+		`,
+		exp: []string{
+			CheckUnknownField,
+			CheckUnknownKind,
+		},
+	})
+	testCases = append(testCases, test{
+		name: "undefined edges",
+		code: `
+			test "t1" {
+				Before => Test["t3"],
+			}
+			test "t2" {}
+			Test["t1"] -> Test["t2"] -> Test["t4"]
+		`,
+		exp: []string{
+			CheckUndefinedEdge, // t3
+			CheckUndefinedEdge, // t4
+		},
+	})
+	testCases = append(testCases, test{
+		name: "edges to dynamic names",
+		code: `
+			$names = ["t1", "t2",]
+			test $names {}
+			Test["t1"] -> Test["t9"]
+		`,
+		exp: []string{},
+	})
+	testCases = append(testCases, test{
+		name: "deprecated function",
+		code: `
+			import "net" as n
+			test "t1" {
+				stringptr => n.oldmacfmt("00:11:22:33:44:55"),
+			}
+		`,
+		exp: []string{
+			CheckDeprecatedFunc,
+		},
+	})
+	testCases = append(testCases, test{
+		name: "class binds used through include alias",
+		code: `
+			class c1($a) {
+				$b = "hello"
+				$c = "world"
+			}
+			include c1("x") as i1
+			test "t1" {
+				stringptr => $i1.b,
+			}
+		`,
+		exp: []string{
+			CheckUnusedBind, // $c
+		},
+	})
+	testCases = append(testCases, test{
+		name: "default and named args are used",
+		code: `
+			$p = "hello"
+			$q = "world"
+			class c1($a, $b = $p) {
+				test "${a}${b}" {}
+			}
+			include c1(a => $q)
+		`,
+		exp: []string{},
+	})
+
+	names := []string{}
+	for index, tc := range testCases { // run all the tests
+		if tc.name == "" {
+			t.Errorf("test #%d: not named", index)
+			continue
+		}
+		if util.StrInList(tc.name, names) {
+			t.Errorf("test #%d: duplicate sub test name of: %s", index, tc.name)
+			continue
+		}
+		names = append(names, tc.name)
+
+		t.Run(fmt.Sprintf("test #%d (%s)", index, tc.name), func(t *testing.T) {
+			xast, err := parser.LexParse(strings.NewReader(tc.code))
+			if err != nil {
+				t.Errorf("test #%d: FAIL", index)
+				t.Errorf("test #%d: lex/parse failed with: %+v", index, err)
+				return
+			}
+			data := &interfaces.Data{
+				StrInterpolater: interpolate.StrInterpolate,
+				Debug:           testing.Verbose(),
+				Logf: func(format string, v ...interface{}) {
+					t.Logf("ast: "+format, v...)
+				},
+			}
+			if err := xast.Init(data); err != nil {
+				t.Errorf("test #%d: FAIL", index)
+				t.Errorf("test #%d: init failed with: %+v", index, err)
+				return
+			}
+			iast, err := xast.Interpolate()
+			if err != nil {
+				t.Errorf("test #%d: FAIL", index)
+				t.Errorf("test #%d: interpolate failed with: %+v", index, err)
+				return
+			}
+
+			scope := interfaces.EmptyScope()
+			scope.Functions = ast.FuncPrefixToFunctionsScope("") // runs funcs.LookupPrefix
+
+			linter := &Linter{
+				AST:   iast,
+				Scope: scope,
+				Debug: testing.Verbose(),
+				Logf: func(format string, v ...interface{}) {
+					t.Logf(fmt.Sprintf("test #%d", index)+": lint: "+format, v...)
+				},
+			}
+			problems, err := linter.Lint()
+			if !tc.fail && err != nil {
+				t.Errorf("test #%d: FAIL", index)
+				t.Errorf("test #%d: lint failed with: %+v", index, err)
+				return
+			}
+			if tc.fail && err == nil {
+				t.Errorf("test #%d: FAIL", index)
+				t.Errorf("test #%d: lint passed, expected fail", index)
+				return
+			}
+			if tc.fail {
+				return // nothing else to check
+			}
+
+			checks := []string{}
+			for _, p := range problems {
+				checks = append(checks, p.Check)
+				t.Logf("test #%d: problem: %s (%s)", index, p, p.Node)
+			}
+			if !reflect.DeepEqual(checks, tc.exp) {
+				t.Errorf("test #%d: FAIL", index)
+				t.Errorf("test #%d: got: %v", index, checks)
+				t.Errorf("test #%d: exp: %v", index, tc.exp)
+			}
+		})
+	}
+}
+
+func TestLintPos0(t *testing.T) {
+	code := "import \"fmt\"\n\n$x = \"hello\"\n\ntest \"t1\" {\n\tnope => 42,\n}\n"
+	xast, err := parser.LexParse(strings.NewReader(code))
+	if err != nil {
+		t.Errorf("lex/parse failed with: %+v", err)
+		return
+	}
+	data := &interfaces.Data{
+		StrInterpolater: interpolate.StrInterpolate,
+		Logf: func(format string, v ...interface{}) {
+			t.Logf("ast: "+format, v...)
+		},
+	}
+	if err := xast.Init(data); err != nil {
+		t.Errorf("init failed with: %+v", err)
+		return
+	}
+	iast, err := xast.Interpolate()
+	if err != nil {
+		t.Errorf("interpolate failed with: %+v", err)
+		return
+	}
+	linter := &Linter{
+		AST:      iast,
+		Filename: "/tmp/main.mcl",
+		Logf: func(format string, v ...interface{}) {
+			t.Logf("lint: "+format, v...)
+		},
+	}
+	problems, err := linter.Lint()
+	if err != nil {
+		t.Errorf("lint failed with: %+v", err)
+		return
+	}
+	s := []string{}
+	for _, p := range problems {
+		s = append(s, p.String())
+	}
+	exp := []string{
+		"/tmp/main.mcl:6:2: error: unknown-field: resource kind `test` has no field named `nope`",
+		"/tmp/main.mcl:1:1: warning: unused-import: import `fmt` is never used",
+		"/tmp/main.mcl:3:1: warning: unused-bind: bind `$x` is never used",
+	}
+	if !reflect.DeepEqual(s, exp) {
+		t.Errorf("got: %+v", s)
+		t.Errorf("exp: %+v", exp)
+	}
+}
@@ -0,0 +1,769 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/purpleidea/mgmt/engine"
+	engineUtil "github.com/purpleidea/mgmt/engine/util"
+	"github.com/purpleidea/mgmt/lang/ast"
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/funcs/vars"
+	"github.com/purpleidea/mgmt/lang/inputs"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/interpolate"
+	"github.com/purpleidea/mgmt/lang/parser"
+	"github.com/purpleidea/mgmt/lang/unification"
+	_ "github.com/purpleidea/mgmt/lang/unification/solvers" // import so the solvers register
+	"github.com/purpleidea/mgmt/pgraph"
+	"github.com/purpleidea/mgmt/util"
+	"github.com/purpleidea/mgmt/util/errwrap"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	// diagnosticSource is the source name shown next to our diagnostics.
+	diagnosticSource = "mgmt"
+
+	// identChars are the chars that can be part of an identifier that we
+	// might hover on. We include the dollar sign and module separator.
+	identChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_.$"
+)
+
+var (
+	// keywords are offered as completions in a statement position.
+	keywords = []string{
+		"if",
+		"else",
+		"func",
+		"class",
+		"include",
+		"import",
+		"type",
+		"as",
+		"true",
+		"false",
+		"panic",
+	}
+
+	// resHeaderRegexp matches the start of a resource, eg: `file "/tmp/a" {`
+	resHeaderRegexp = regexp.MustCompile(`^\s*([a-z][a-z0-9_]*(:[a-z][a-z0-9_]*)*)\s+\S.*$`)
+)
+
+// document is an open text document and the result of its latest compilation.
+// The positions in here count the characters of a line in runes, just like the
+// lexer does. The protocol counts them in UTF-16 code units, so the methods the
+// server calls convert them when they come in and when they go out.
+type document struct {
+	uri     string
+	version int
+	text    string
+	lines   []string
+
+	modules string // modules path
+	debug   bool
+	logf    func(format string, v ...interface{})
+
+	// filename is the path that the text was last compiled as. Only the
+	// nodes with a position in this file are part of this document.
+	filename string
+
+	// ast is the compiled AST. It is only set if SetScope succeeded, which
+	// means the scope graph can be used. If unified is true, then the type
+	// unification succeeded too, and the expressions have known types.
+	ast     interfaces.Stmt
+	unified bool
+
+	diagnostics []Diagnostic
+}
+
+// update stores the new text of this document and recompiles it.
+func (obj *document) update(version int, text string) {
+	obj.version = version
+	obj.text = text
+	obj.lines = strings.Split(text, "\n")
+	obj.ast = nil
+	obj.unified = false
+	obj.diagnostics = nil
+
+	if err := obj.compile(); err != nil {
+		obj.diagnostics = append(obj.diagnostics, obj.diagnostic(err))
+	}
+}
+
+// path returns the local file path of this document, or the empty string if
+// the document does not live on the local filesystem.
+func (obj *document) path() string {
+	u, err := url.Parse(obj.uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return u.Path
+}
+
+// compile runs the same compilation stages that `mgmt run lang` would, up to
+// and including type unification. It stores the AST as soon as it is usable.
+func (obj *document) compile() error {
+	// Overlay the unsaved text on top of the real filesystem, so that any
+	// local imports can be found, but without writing anything to disk.
+	var fs engine.Fs
+	path := obj.path()
+	if path == "" {
+		path = "/" + interfaces.MainFilename // anonymous buffer
+		afs := &afero.Afero{Fs: afero.NewMemMapFs()}
+		fs = &util.AferoFs{Afero: afs}
+	} else {
+		base := afero.NewReadOnlyFs(afero.NewOsFs())
+		overlay := afero.NewCopyOnWriteFs(base, afero.NewMemMapFs())
+		afs := &afero.Afero{Fs: overlay}
+		fs = &util.AferoFs{Afero: afs}
+	}
+	obj.filename = path
+	afs := fs.(*util.AferoFs)
+	if err := afs.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errwrap.Wrapf(err, "could not make dir")
+	}
+	if err := afs.WriteFile(path, []byte(obj.text), 0600); err != nil {
+		return errwrap.Wrapf(err, "could not write file")
+	}
+
+	output, err := inputs.ParseInput(path, fs)
+	if err != nil {
+		return errwrap.Wrapf(err, "could not activate an input parser")
+	}
+
+	xast, err := parser.LexParse(bytes.NewReader(output.Main))
+	if err != nil {
+		return err // this error has a position, so don't wrap it
+	}
+	setFilename(xast, path) // before any imports are parsed
+
+	importGraph, err := pgraph.NewGraph("importGraph")
+	if err != nil {
+		return err
+	}
+	importVertex := &pgraph.SelfVertex{
+		Name:  "",          // first node is the empty string
+		Graph: importGraph, // store a reference to ourself
+	}
+	importGraph.AddVertex(importVertex)
+
+	data := &interfaces.Data{
+		Fs:       output.FS,
+		FsURI:    output.FS.URI(),
+		Base:     output.Base, // base dir (absolute path) that this is rooted in
+		Files:    output.Files,
+		Imports:  importVertex,
+		Metadata: output.Metadata,
+		Modules:  obj.modules,
+
+		LexParser:       parser.LexParse,
+		Downloader:      nil, // we never download from the editor
+		StrInterpolater: interpolate.StrInterpolate,
+
+		Debug: obj.debug,
+		Logf: func(format string, v ...interface{}) {
+			obj.logf("ast: "+format, v...)
+		},
+	}
+	if err := xast.Init(data); err != nil {
+		return errwrap.Wrapf(err, "could not init and validate AST")
+	}
+
+	iast, err := xast.Interpolate()
+	if err != nil {
+		return errwrap.Wrapf(err, "could not interpolate AST")
+	}
+
+	variables := map[string]interfaces.Expr{
+		"purpleidea": &ast.ExprStr{V: "hello world!"}, // james says hi
+		"hostname":   &ast.ExprStr{V: ""},             // NOTE: empty b/c not used
+	}
+	consts := ast.VarPrefixToVariablesScope(vars.ConstNamespace) // strips prefix!
+	addback := vars.ConstNamespace + interfaces.ModuleSep        // add it back...
+	variables, err = ast.MergeExprMaps(variables, consts, addback)
+	if err != nil {
+		return errwrap.Wrapf(err, "couldn't merge in consts")
+	}
+	scope := &interfaces.Scope{
+		Variables: variables,
+		Functions: ast.FuncPrefixToFunctionsScope(""), // runs funcs.LookupPrefix
+	}
+	if err := iast.SetScope(scope); err != nil {
+		return errwrap.Wrapf(err, "could not set scope")
+	}
+	obj.ast = iast // the scope graph is now usable
+
+	solver, err := unification.LookupDefault()
+	if err != nil {
+		return errwrap.Wrapf(err, "could not get default solver")
+	}
+	unifier := &unification.Unifier{
+		AST:    iast,
+		Solver: solver,
+		Debug:  obj.debug,
+		Logf: func(format string, v ...interface{}) {
+			if obj.debug { // unification only has debug messages...
+				obj.logf("unification: "+format, v...)
+			}
+		},
+	}
+	if err := unifier.Unify(context.TODO()); err != nil {
+		return errwrap.Wrapf(err, "could not unify types")
+	}
+	obj.unified = true
+
+	return nil
+}
+
+// diagnostic converts a compilation error into a diagnostic. The lexer and
+// parser errors contain a position, as do the errors of any located node, such
+// as a variable which is not in scope. The others are shown on the first line
+// of the document.
+func (obj *document) diagnostic(err error) Diagnostic {
+	if e, ok := err.(*parser.LexParseErr); ok {
+		return Diagnostic{
+			Range: obj.toProtocolRange(Range{
+				Start: Position{Line: e.Row, Character: e.Col},
+				End:   Position{Line: e.Row, Character: e.Col + utf8.RuneCountInString(e.Str)},
+			}),
+			Severity: DiagnosticSeverityError,
+			Source:   diagnosticSource,
+			Message:  e.Error(),
+		}
+	}
+	r := Range{}
+	if len(obj.lines) > 0 {
+		r.End.Character = utf8.RuneCountInString(obj.lines[0])
+	}
+	var e *interfaces.PosError
+	if errors.As(err, &e) && obj.isOurs(e.Pos) {
+		start := toPosition(e.Pos)
+		r = Range{Start: start, End: start}
+		if w, wr := obj.word(start); w != "" {
+			r.End = wr.End
+		}
+	}
+	return Diagnostic{
+		Range:    obj.toProtocolRange(r),
+		Severity: DiagnosticSeverityError,
+		Source:   diagnosticSource,
+		Message:  err.Error(),
+	}
+}
+
+// word returns the identifier which surrounds the position, and its range. It
+// returns the empty string if there is nothing there.
+func (obj *document) word(pos Position) (string, Range) {
+	if pos.Line < 0 || pos.Line >= len(obj.lines) {
+		return "", Range{}
+	}
+	line := []rune(obj.lines[pos.Line])
+	if pos.Character < 0 || pos.Character > len(line) {
+		return "", Range{}
+	}
+	start, end := pos.Character, pos.Character
+	for start > 0 && strings.ContainsRune(identChars, line[start-1]) {
+		start--
+	}
+	for end < len(line) && strings.ContainsRune(identChars, line[end]) {
+		end++
+	}
+	// a dollar sign can only be the first character of a variable
+	if i := strings.LastIndex(string(line[start:end]), "$"); i > 0 {
+		start += len([]rune(string(line[start:end])[:i]))
+	}
+	w := strings.TrimSuffix(string(line[start:end]), interfaces.ModuleSep)
+	r := Range{
+		Start: Position{Line: pos.Line, Character: start},
+		End:   Position{Line: pos.Line, Character: start + len([]rune(w))},
+	}
+	return w, r
+}
+
+// hover returns the inferred type of the variable or function call under the
+// cursor. It returns nil if there is nothing useful to say.
+func (obj *document) hover(pos Position) *Hover {
+	if obj.ast == nil || !obj.unified {
+		return nil
+	}
+	w, r := obj.word(obj.fromProtocol(pos))
+	if w == "" {
+		return nil
+	}
+
+	s := ""
+	for _, node := range obj.nodesAt(r.Start) {
+		if s = hoverText(node); s != "" {
+			break
+		}
+	}
+	if s == "" {
+		return nil
+	}
+	r = obj.toProtocolRange(r)
+
+	return &Hover{
+		Contents: MarkupContent{
+			Kind:  MarkupKindPlainText,
+			Value: s,
+		},
+		Range: &r,
+	}
+}
+
+// hoverText returns the description of the type of this node, or the empty
+// string if it has no known type. The original body of a class is never scope
+// checked, only its copies are, so we skip the nodes which have no scope target.
+func hoverText(node interfaces.Node) string {
+	switch x := node.(type) {
+	case *ast.ExprVar:
+		if ast.ScopeTarget(x) == nil {
+			return ""
+		}
+		typ, err := x.Type()
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("$%s %s", x.Name, typ)
+
+	case *ast.StmtBind:
+		typ, err := x.Value.Type()
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("$%s %s", x.Ident, typ)
+
+	case *ast.ExprCall:
+		if ast.ScopeTarget(x) == nil {
+			return ""
+		}
+		out, err := x.Type()
+		if err != nil {
+			return ""
+		}
+		args := []string{}
+		for _, arg := range x.Args {
+			typ, err := arg.Type()
+			if err != nil {
+				return ""
+			}
+			args = append(args, typ.String())
+		}
+		name := x.Name
+		if x.Var {
+			name = "$" + name
+		}
+		return fmt.Sprintf("%s(%s) %s", name, strings.Join(args, ", "), out)
+	}
+	return ""
+}
+
+// definition returns the location where the variable, function or class under
+// the cursor is defined. We find the node at the cursor, follow its edge in the
+// scope graph to the definition that it points to, and return the position that
+// the parser stored on that definition.
+func (obj *document) definition(pos Position) *Location {
+	if obj.ast == nil {
+		return nil
+	}
+	w, r := obj.word(obj.fromProtocol(pos))
+	if w == "" {
+		return nil
+	}
+
+	// A class body or a polymorphic function has one copy for each use, and
+	// any copy which was scope-checked will lead us to the same definition.
+	for _, node := range obj.nodesAt(r.Start) {
+		def := obj.resolve(node)
+		if def == nil {
+			continue
+		}
+		start := obj.toProtocol(toPosition(def.Pos()))
+		return &Location{
+			URI: obj.uri,
+			Range: Range{
+				Start: start,
+				End:   start,
+			},
+		}
+	}
+	return nil
+}
+
+// nodesAt returns the located nodes of this document which start at exactly
+// this position. There can be more than one, because each include of a class
+// and each call of a polymorphic function scope-checks its own copy.
+func (obj *document) nodesAt(pos Position) []interfaces.Node {
+	nodes := []interfaces.Node{}
+	obj.walk(func(node interfaces.Node) error {
+		x, ok := node.(interfaces.Locatable)
+		if !ok || !obj.isOurs(x.Pos()) {
+			return nil
+		}
+		if toPosition(x.Pos()) == pos {
+			nodes = append(nodes, node)
+		}
+		return nil
+	})
+	return nodes
+}
+
+// walk runs the function on every node of the AST like Apply does, but it also
+// descends into the copy of the function which each call has, since it's those
+// copies of a polymorphic function that unification gives a type to.
+func (obj *document) walk(fn func(interfaces.Node) error) {
+	seen := make(map[interfaces.Node]struct{})
+	var apply func(interfaces.Node) error
+	apply = func(node interfaces.Node) error {
+		if _, exists := seen[node]; exists {
+			return nil
+		}
+		seen[node] = struct{}{}
+		if err := fn(node); err != nil {
+			return err
+		}
+		call, ok := node.(*ast.ExprCall)
+		if !ok {
+			return nil
+		}
+		if callee := ast.ScopeTarget(call); callee != nil {
+			return callee.Apply(apply)
+		}
+		return nil
+	}
+	_ = obj.ast.Apply(apply)
+}
+
+// resolve follows the scope graph from this node to the bind, param, function
+// or class that defines it. It returns nil if that definition isn't located in
+// this document, which is the case for builtins and for anything imported.
+func (obj *document) resolve(node interfaces.Node) interfaces.Locatable {
+	var target interfaces.Node
+	switch x := node.(type) {
+	case *ast.StmtBind, *ast.StmtFunc, *ast.StmtClass:
+		target = x // we're already on the definition
+	case *ast.ExprVar, *ast.ExprCall, *ast.StmtInclude:
+		target = ast.ScopeTarget(x) // nil if it was never scope-checked
+	}
+
+	// the scope wraps the definitions, so look inside of those wrappers
+	for {
+		switch x := target.(type) {
+		case *ast.ExprTopLevel:
+			target = x.Definition
+			continue
+		case *ast.ExprSingleton:
+			target = x.Definition
+			continue
+		case *ast.ExprPoly:
+			target = x.Definition
+			continue
+		}
+		break
+	}
+	if target == nil {
+		return nil
+	}
+
+	// A variable points to the value of its bind, so find that bind.
+	var def interfaces.Locatable
+	obj.walk(func(node interfaces.Node) error {
+		if x, ok := node.(*ast.StmtBind); ok && def == nil && x.Value == target {
+			def = x
+		}
+		return nil
+	})
+	if def == nil { // a param, a function or a class
+		x, ok := target.(interfaces.Locatable)
+		if !ok {
+			return nil
+		}
+		def = x
+	}
+	if !obj.isOurs(def.Pos()) {
+		return nil
+	}
+	return def
+}
+
+// isOurs returns true if this position is known, and is in this document.
+func (obj *document) isOurs(pos *interfaces.Pos) bool {
+	return pos != nil && pos.Filename == obj.filename
+}
+
+// toPosition converts a position in the code, whose lines and columns count
+// from one, into a protocol position, which counts from zero.
+func toPosition(pos *interfaces.Pos) Position {
+	return Position{
+		Line:      pos.Line - 1,
+		Character: pos.Column - 1,
+	}
+}
+
+// fromProtocol converts a protocol position, whose characters are counted in
+// UTF-16 code units, into one whose characters are counted in runes. A position
+// which is past the end of the line stays past the end by the same amount.
+func (obj *document) fromProtocol(pos Position) Position {
+	if pos.Line < 0 || pos.Line >= len(obj.lines) {
+		return pos
+	}
+	line := []rune(obj.lines[pos.Line])
+	n := 0 // utf-16 code units so far
+	for i, r := range line {
+		if n >= pos.Character {
+			return Position{Line: pos.Line, Character: i}
+		}
+		n += utf16Len(r)
+	}
+	return Position{Line: pos.Line, Character: len(line) + pos.Character - n}
+}
+
+// toProtocol is the opposite of fromProtocol. It converts a position whose
+// characters are counted in runes, into one which counts UTF-16 code units.
+func (obj *document) toProtocol(pos Position) Position {
+	if pos.Line < 0 || pos.Line >= len(obj.lines) {
+		return pos
+	}
+	line := []rune(obj.lines[pos.Line])
+	n := 0 // utf-16 code units so far
+	for i := 0; i < pos.Character; i++ {
+		if i >= len(line) {
+			n += pos.Character - i
+			break
+		}
+		n += utf16Len(line[i])
+	}
+	return Position{Line: pos.Line, Character: n}
+}
+
+// utf16Len returns the number of UTF-16 code units which encode this rune. The
+// runes outside of the basic multilingual plane need a surrogate pair.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// toProtocolRange converts both ends of this range with toProtocol.
+func (obj *document) toProtocolRange(r Range) Range {
+	return Range{
+		Start: obj.toProtocol(r.Start),
+		End:   obj.toProtocol(r.End),
+	}
+}
+
+// setFilename stores the filename on the positions of all the located nodes
+// and args in this AST, so that we can tell them apart from the ones which come
+// from an imported file. The copies that are made later share these positions.
+func setFilename(node interfaces.Node, filename string) {
+	set := func(x interfaces.Locatable) {
+		if pos := x.Pos(); pos != nil {
+			pos.Filename = filename
+		}
+	}
+	_ = node.Apply(func(node interfaces.Node) error {
+		if x, ok := node.(interfaces.Locatable); ok {
+			set(x)
+		}
+		var args []*interfaces.Arg
+		switch x := node.(type) {
+		case *ast.ExprFunc:
+			args = x.Args
+		case *ast.StmtClass:
+			args = x.Args
+		}
+		for _, arg := range args {
+			set(arg)
+		}
+		return nil
+	})
+}
+
+// completion returns the list of possible completions at the cursor. Inside of
+// a resource body, this is the list of fields for that kind, otherwise it is
+// the list of functions, resource kinds, keywords and variables.
+func (obj *document) completion(pos Position) *CompletionList {
+	pos = obj.fromProtocol(pos)
+	prefix := ""
+	if pos.Line >= 0 && pos.Line < len(obj.lines) {
+		line := []rune(obj.lines[pos.Line])
+		end := pos.Character
+		if end > len(line) {
+			end = len(line)
+		}
+		start := end
+		for start > 0 && strings.ContainsRune(identChars, line[start-1]) {
+			start--
+		}
+		prefix = string(line[start:end])
+	}
+
+	items := []CompletionItem{}
+	if kind := obj.resourceKind(pos); kind != "" {
+		fields, err := engineUtil.LangFieldNameToStructType(kind)
+		if err != nil {
+			return &CompletionList{Items: items}
+		}
+		for name, typ := range fields {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			items = append(items, CompletionItem{
+				Label:      name,
+				Kind:       CompletionItemKindField,
+				Detail:     typ.String(),
+				InsertText: name + " => ",
+			})
+		}
+		sortItems(items)
+		return &CompletionList{Items: items}
+	}
+
+	if strings.HasPrefix(prefix, "$") {
+		seen := make(map[string]struct{})
+		if obj.ast != nil {
+			_ = obj.ast.Apply(func(node interfaces.Node) error {
+				if x, ok := node.(*ast.StmtBind); ok {
+					seen[x.Ident] = struct{}{}
+				}
+				return nil
+			})
+		}
+		for name := range seen {
+			if !strings.HasPrefix("$"+name, prefix) {
+				continue
+			}
+			items = append(items, CompletionItem{
+				Label:      "$" + name,
+				Kind:       CompletionItemKindVariable,
+				InsertText: name, // the dollar sign was already typed
+			})
+		}
+		sortItems(items)
+		return &CompletionList{Items: items}
+	}
+
+	for name, fn := range funcs.Map() {
+		if strings.HasPrefix(name, funcs.ReplaceChar) { // internal funcs
+			continue
+		}
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		item := CompletionItem{
+			Label: name,
+			Kind:  CompletionItemKindFunction,
+		}
+		if info := fn().Info(); info != nil && info.Sig != nil && !info.Sig.HasVariant() {
+			item.Detail = info.Sig.String()
+		}
+		items = append(items, item)
+	}
+	for _, kind := range engine.RegisteredResourcesNames() {
+		if !strings.HasPrefix(kind, prefix) {
+			continue
+		}
+		items = append(items, CompletionItem{
+			Label: kind,
+			Kind:  CompletionItemKindClass,
+		})
+	}
+	if !strings.Contains(prefix, interfaces.ModuleSep) {
+		for _, x := range keywords {
+			if !strings.HasPrefix(x, prefix) {
+				continue
+			}
+			items = append(items, CompletionItem{
+				Label: x,
+				Kind:  CompletionItemKindKeyword,
+			})
+		}
+	}
+	sortItems(items)
+	return &CompletionList{Items: items}
+}
+
+// resourceKind returns the kind of the resource whose body the position is in.
+// It returns the empty string if we're not in a resource body.
+func (obj *document) resourceKind(pos Position) string {
+	if pos.Line < 0 || pos.Line >= len(obj.lines) {
+		return ""
+	}
+	// walk backwards to find the innermost unmatched open curly brace
+	depth := 0
+	for i := pos.Line; i >= 0; i-- {
+		line := []rune(obj.lines[i])
+		end := len(line)
+		if i == pos.Line && pos.Character < end {
+			end = pos.Character
+		}
+		for j := end - 1; j >= 0; j-- {
+			switch line[j] {
+			case '}':
+				depth++
+			case '{':
+				if depth > 0 {
+					depth--
+					continue
+				}
+				m := resHeaderRegexp.FindStringSubmatch(string(line[:j]))
+				if m == nil {
+					return ""
+				}
+				kind := m[1]
+				if !util.StrInList(kind, engine.RegisteredResourcesNames()) {
+					return ""
+				}
+				return kind
+			}
+		}
+	}
+	return ""
+}
+
+// sortItems sorts the completion items by label so the output is stable.
+func sortItems(items []CompletionItem) {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Label < items[j].Label
+	})
+}
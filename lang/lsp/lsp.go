@@ -0,0 +1,425 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+// Package lsp implements a language server for the mcl language. It speaks the
+// Language Server Protocol (LSP) over a pair of streams, which are usually the
+// stdin and stdout of the `mgmt lsp` command. It offers diagnostics from the
+// lexer/parser and the later compilation stages, hover with the types that
+// were found by type unification, go-to-definition which is resolved with the
+// scope graph, and completion of function names, resource kinds and fields.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/purpleidea/mgmt/util/errwrap"
+)
+
+const (
+	// Name is the name of this language server, as sent to the client.
+	Name = "mgmt-lsp"
+
+	// LanguageID is the language identifier that editors use for mcl.
+	LanguageID = "mcl"
+
+	// headerContentLength is the header that specifies the message length.
+	headerContentLength = "Content-Length"
+)
+
+// Server is the mcl language server. It reads JSON-RPC messages from In, and
+// writes the responses and notifications to Out. Build it with the public
+// fields and then call Run.
+type Server struct {
+	// In is where we read client messages from. This is usually stdin.
+	In io.Reader
+
+	// Out is where we write our messages to. This is usually stdout.
+	Out io.Writer
+
+	// Version is the version string that is reported to the client.
+	Version string
+
+	// Modules is an absolute path to a modules directory which is used to
+	// look for remote imports. It has the same meaning as the --module-path
+	// flag of `mgmt run lang`.
+	Modules string
+
+	Debug bool
+	Logf  func(format string, v ...interface{})
+
+	mutex *sync.Mutex // guards writes to Out
+
+	docs        map[string]*document // open documents keyed by URI
+	initialized bool                 // did we receive initialize?
+	shutdown    bool                 // did we receive shutdown?
+}
+
+// Run reads and handles messages until the client asks us to exit, the input
+// stream closes, or the context is cancelled. It returns nil on a clean exit.
+func (obj *Server) Run(ctx context.Context) error {
+	if obj.In == nil || obj.Out == nil {
+		return fmt.Errorf("the In and Out streams must be specified")
+	}
+	obj.mutex = &sync.Mutex{}
+	obj.docs = make(map[string]*document)
+
+	reader := bufio.NewReader(obj.In)
+	msgs := make(chan *Message)
+	errs := make(chan error, 1)
+	// NOTE: We can't unblock a pending read of stdin, so this goroutine is
+	// allowed to outlive Run. It exits once the input stream is closed.
+	go func() {
+		defer close(msgs)
+		for {
+			msg, err := readMessage(reader)
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				err := <-errs
+				if err == io.EOF {
+					return nil // client went away
+				}
+				return errwrap.Wrapf(err, "could not read message")
+			}
+			exit, err := obj.handle(msg)
+			if err != nil {
+				return err
+			}
+			if exit {
+				if !obj.shutdown {
+					return fmt.Errorf("exit before shutdown")
+				}
+				return nil
+			}
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// handle processes a single message. It returns true if we should exit.
+func (obj *Server) handle(msg *Message) (bool, error) {
+	if msg.Method == "" { // a response to a request we never send
+		return false, nil
+	}
+	if obj.Debug {
+		obj.Logf("method: %s", msg.Method)
+	}
+	if msg.Method == "exit" {
+		return true, nil
+	}
+
+	if msg.ID == nil { // notification
+		if !obj.initialized || obj.shutdown {
+			return false, nil // drop it
+		}
+		if err := obj.notification(msg.Method, msg.Params); err != nil {
+			// errors in notifications can't be sent back to the
+			// client, and shouldn't kill the server, so log them...
+			obj.Logf("notification %s: %+v", msg.Method, err)
+		}
+		return false, nil
+	}
+
+	result, err := obj.request(msg.Method, msg.Params)
+	resp := &Message{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  result,
+	}
+	if err != nil {
+		e, ok := err.(*ResponseError)
+		if !ok {
+			e = &ResponseError{
+				Code:    ErrCodeInternalError,
+				Message: err.Error(),
+			}
+		}
+		resp.Result = nil
+		resp.Error = e
+	} else if result == nil {
+		resp.Result = json.RawMessage("null") // a result is mandatory
+	}
+	return false, obj.send(resp)
+}
+
+// request handles the methods which expect a response.
+func (obj *Server) request(method string, params json.RawMessage) (interface{}, error) {
+	if method == "initialize" {
+		obj.initialized = true
+		return &InitializeResult{
+			Capabilities: ServerCapabilities{
+				PositionEncoding:   PositionEncodingUTF16,
+				TextDocumentSync:   TextDocumentSyncKindFull,
+				HoverProvider:      true,
+				DefinitionProvider: true,
+				CompletionProvider: &CompletionOptions{
+					TriggerCharacters: []string{".", "$"},
+				},
+			},
+			ServerInfo: &ServerInfo{
+				Name:    Name,
+				Version: obj.Version,
+			},
+		}, nil
+	}
+	if !obj.initialized {
+		return nil, &ResponseError{
+			Code:    ErrCodeServerNotInitialized,
+			Message: "server is not initialized",
+		}
+	}
+	if obj.shutdown {
+		return nil, &ResponseError{
+			Code:    ErrCodeInvalidRequest,
+			Message: "server is shutting down",
+		}
+	}
+
+	switch method {
+	case "shutdown":
+		obj.shutdown = true
+		return nil, nil
+
+	case "textDocument/hover":
+		p := &TextDocumentPositionParams{}
+		if err := unmarshalParams(params, p); err != nil {
+			return nil, err
+		}
+		doc, exists := obj.docs[p.TextDocument.URI]
+		if !exists {
+			return nil, nil
+		}
+		return doc.hover(p.Position), nil
+
+	case "textDocument/definition":
+		p := &TextDocumentPositionParams{}
+		if err := unmarshalParams(params, p); err != nil {
+			return nil, err
+		}
+		doc, exists := obj.docs[p.TextDocument.URI]
+		if !exists {
+			return nil, nil
+		}
+		return doc.definition(p.Position), nil
+
+	case "textDocument/completion":
+		p := &TextDocumentPositionParams{}
+		if err := unmarshalParams(params, p); err != nil {
+			return nil, err
+		}
+		doc, exists := obj.docs[p.TextDocument.URI]
+		if !exists {
+			return nil, nil
+		}
+		return doc.completion(p.Position), nil
+	}
+
+	return nil, &ResponseError{
+		Code:    ErrCodeMethodNotFound,
+		Message: fmt.Sprintf("method not found: %s", method),
+	}
+}
+
+// notification handles the methods which don't expect a response.
+func (obj *Server) notification(method string, params json.RawMessage) error {
+	switch method {
+	case "textDocument/didOpen":
+		p := &DidOpenTextDocumentParams{}
+		if err := unmarshalParams(params, p); err != nil {
+			return err
+		}
+		doc := obj.newDocument(p.TextDocument.URI)
+		doc.update(p.TextDocument.Version, p.TextDocument.Text)
+		obj.docs[doc.uri] = doc
+		return obj.publish(doc)
+
+	case "textDocument/didChange":
+		p := &DidChangeTextDocumentParams{}
+		if err := unmarshalParams(params, p); err != nil {
+			return err
+		}
+		doc, exists := obj.docs[p.TextDocument.URI]
+		if !exists {
+			return fmt.Errorf("document is not open: %s", p.TextDocument.URI)
+		}
+		if len(p.ContentChanges) == 0 {
+			return nil
+		}
+		// we only support full sync, so the last change is the text
+		text := p.ContentChanges[len(p.ContentChanges)-1].Text
+		doc.update(p.TextDocument.Version, text)
+		return obj.publish(doc)
+
+	case "textDocument/didSave":
+		p := &DidSaveTextDocumentParams{}
+		if err := unmarshalParams(params, p); err != nil {
+			return err
+		}
+		doc, exists := obj.docs[p.TextDocument.URI]
+		if !exists {
+			return nil
+		}
+		// imported files on disk might have changed, so recompile
+		doc.update(doc.version, doc.text)
+		return obj.publish(doc)
+
+	case "textDocument/didClose":
+		p := &DidCloseTextDocumentParams{}
+		if err := unmarshalParams(params, p); err != nil {
+			return err
+		}
+		delete(obj.docs, p.TextDocument.URI)
+		// clear any diagnostics that the client might still show
+		return obj.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+			URI:         p.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+	}
+
+	return nil // ignore the ones we don't know about
+}
+
+// newDocument builds a new document which compiles with our settings.
+func (obj *Server) newDocument(uri string) *document {
+	return &document{
+		uri:     uri,
+		modules: obj.Modules,
+		debug:   obj.Debug,
+		logf: func(format string, v ...interface{}) {
+			obj.Logf("compile: "+format, v...)
+		},
+	}
+}
+
+// publish sends the current diagnostics of a document to the client.
+func (obj *Server) publish(doc *document) error {
+	diagnostics := doc.diagnostics
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{} // must not be null
+	}
+	return obj.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+		URI:         doc.uri,
+		Diagnostics: diagnostics,
+	})
+}
+
+// notify sends a notification to the client.
+func (obj *Server) notify(method string, params interface{}) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return obj.send(&Message{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  b,
+	})
+}
+
+// send writes a message to the client with the required header.
+func (obj *Server) send(msg *Message) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return errwrap.Wrapf(err, "could not encode message")
+	}
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	if _, err := fmt.Fprintf(obj.Out, "%s: %d\r\n\r\n", headerContentLength, len(b)); err != nil {
+		return errwrap.Wrapf(err, "could not write header")
+	}
+	if _, err := obj.Out.Write(b); err != nil {
+		return errwrap.Wrapf(err, "could not write message")
+	}
+	return nil
+}
+
+// readMessage reads a single message from the stream. It returns io.EOF if the
+// stream closed cleanly between two messages.
+func readMessage(reader *bufio.Reader) (*Message, error) {
+	tp := textproto.NewReader(reader)
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, errwrap.Wrapf(err, "could not read header")
+	}
+	s := header.Get(headerContentLength)
+	if s == "" {
+		return nil, fmt.Errorf("missing %s header", headerContentLength)
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid %s header: %s", headerContentLength, s)
+	}
+	b := make([]byte, length)
+	if _, err := io.ReadFull(reader, b); err != nil {
+		return nil, errwrap.Wrapf(err, "could not read body")
+	}
+	msg := &Message{}
+	if err := json.Unmarshal(b, msg); err != nil {
+		return nil, errwrap.Wrapf(err, "could not decode message")
+	}
+	return msg, nil
+}
+
+// unmarshalParams decodes the params into the struct pointer, and returns an
+// error that can be sent back to the client if it fails.
+func unmarshalParams(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &ResponseError{
+			Code:    ErrCodeInvalidParams,
+			Message: err.Error(),
+		}
+	}
+	return nil
+}
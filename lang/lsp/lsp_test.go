@@ -0,0 +1,402 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	_ "github.com/purpleidea/mgmt/engine/resources" // import so the resources register
+)
+
+// client is a tiny test client which talks to the server over pipes.
+type client struct {
+	t      *testing.T
+	w      io.Writer
+	r      *bufio.Reader
+	nextID int
+}
+
+func (obj *client) write(msg interface{}) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		obj.t.Fatalf("could not encode: %+v", err)
+	}
+	if _, err := fmt.Fprintf(obj.w, "Content-Length: %d\r\n\r\n%s", len(b), b); err != nil {
+		obj.t.Fatalf("could not write: %+v", err)
+	}
+}
+
+func (obj *client) notify(method string, params interface{}) {
+	obj.write(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	})
+}
+
+// call sends a request and returns the raw result of the response. Any
+// notifications that arrive first are returned in the list.
+func (obj *client) call(method string, params interface{}) (json.RawMessage, []*Message) {
+	obj.nextID++
+	obj.write(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      obj.nextID,
+		"method":  method,
+		"params":  params,
+	})
+	notifications := []*Message{}
+	for {
+		msg := obj.read()
+		if msg.ID == nil {
+			notifications = append(notifications, msg)
+			continue
+		}
+		if msg.Error != nil {
+			obj.t.Fatalf("%s failed: %+v", method, msg.Error)
+		}
+		b, err := json.Marshal(msg.Result)
+		if err != nil {
+			obj.t.Fatalf("could not encode: %+v", err)
+		}
+		return b, notifications
+	}
+}
+
+func (obj *client) read() *Message {
+	msg, err := readMessage(obj.r)
+	if err != nil {
+		obj.t.Fatalf("could not read: %+v", err)
+	}
+	return msg
+}
+
+func runServer(t *testing.T) (*client, func()) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	server := &Server{
+		In:      inR,
+		Out:     outW,
+		Version: "test",
+		Logf: func(format string, v ...interface{}) {
+			t.Logf("lsp: "+format, v...)
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- server.Run(ctx)
+	}()
+	c := &client{
+		t: t,
+		w: inW,
+		r: bufio.NewReader(outR),
+	}
+	c.call("initialize", map[string]interface{}{})
+	c.notify("initialized", map[string]interface{}{})
+
+	return c, func() {
+		c.call("shutdown", nil)
+		c.notify("exit", nil)
+		if err := <-done; err != nil {
+			t.Errorf("server exited with: %+v", err)
+		}
+		cancel()
+		inW.Close()
+		outR.Close()
+	}
+}
+
+func open(c *client, uri, text string) *PublishDiagnosticsParams {
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{
+			"uri":        uri,
+			"languageId": LanguageID,
+			"version":    1,
+			"text":       text,
+		},
+	})
+	msg := c.read()
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("unexpected message: %s", msg.Method)
+	}
+	p := &PublishDiagnosticsParams{}
+	if err := json.Unmarshal(msg.Params, p); err != nil {
+		c.t.Fatalf("could not decode: %+v", err)
+	}
+	return p
+}
+
+func position(uri string, line, char int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     map[string]interface{}{"line": line, "character": char},
+	}
+}
+
+func TestDiagnostics0(t *testing.T) {
+	c, cleanup := runServer(t)
+	defer cleanup()
+
+	uri := "untitled:bad"
+	p := open(c, uri, "$x = \"hello\"\n$y = $x $x\n")
+	if l := len(p.Diagnostics); l != 1 {
+		t.Fatalf("expected one diagnostic, got: %d", l)
+	}
+	d := p.Diagnostics[0]
+	if d.Range.Start.Line != 1 {
+		t.Errorf("expected an error on line 1, got: %+v", d.Range)
+	}
+	if d.Severity != DiagnosticSeverityError {
+		t.Errorf("unexpected severity: %d", d.Severity)
+	}
+
+	// fix the error and the diagnostic should go away
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []interface{}{
+			map[string]interface{}{"text": "$x = \"hello\"\n$y = $x\n"},
+		},
+	})
+	msg := c.read()
+	p = &PublishDiagnosticsParams{}
+	if err := json.Unmarshal(msg.Params, p); err != nil {
+		t.Fatalf("could not decode: %+v", err)
+	}
+	if l := len(p.Diagnostics); l != 0 {
+		t.Errorf("expected no diagnostics, got: %+v", p.Diagnostics)
+	}
+}
+
+func TestHoverAndDefinition0(t *testing.T) {
+	c, cleanup := runServer(t)
+	defer cleanup()
+
+	uri := "untitled:good"
+	code := strings.Join([]string{
+		`import "strings"`,
+		`$x = "hello"`,
+		`$y = strings.to_lower($x)`,
+		`test "t1" {`,
+		`	stringptr => $y,`,
+		`}`,
+	}, "\n")
+	if p := open(c, uri, code); len(p.Diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics: %+v", p.Diagnostics)
+	}
+
+	b, _ := c.call("textDocument/hover", position(uri, 4, 16))
+	hover := &Hover{}
+	if err := json.Unmarshal(b, hover); err != nil {
+		t.Fatalf("could not decode: %+v", err)
+	}
+	if s := hover.Contents.Value; s != "$y str" {
+		t.Errorf("unexpected hover: %s", s)
+	}
+
+	b, _ = c.call("textDocument/hover", position(uri, 2, 10))
+	hover = &Hover{}
+	if err := json.Unmarshal(b, hover); err != nil {
+		t.Fatalf("could not decode: %+v", err)
+	}
+	if s := hover.Contents.Value; s != "strings.to_lower(str) str" {
+		t.Errorf("unexpected hover: %s", s)
+	}
+
+	b, _ = c.call("textDocument/definition", position(uri, 2, 24))
+	loc := &Location{}
+	if err := json.Unmarshal(b, loc); err != nil {
+		t.Fatalf("could not decode: %+v", err)
+	}
+	if loc.Range.Start.Line != 1 || loc.URI != uri {
+		t.Errorf("unexpected definition: %+v", loc)
+	}
+}
+
+func TestDiagnostics1(t *testing.T) {
+	c, cleanup := runServer(t)
+	defer cleanup()
+
+	uri := "untitled:scope"
+	p := open(c, uri, "$x = \"hello\"\n$y = $z\n")
+	if l := len(p.Diagnostics); l != 1 {
+		t.Fatalf("expected one diagnostic, got: %d", l)
+	}
+	r := p.Diagnostics[0].Range
+	if r.Start.Line != 1 || r.Start.Character != 5 || r.End.Character != 7 {
+		t.Errorf("expected the error on `$z`, got: %+v", r)
+	}
+}
+
+func TestHoverAndDefinition1(t *testing.T) {
+	c, cleanup := runServer(t)
+	defer cleanup()
+
+	// the $x inside of the class and the func shadow the one outside
+	uri := "untitled:shadow"
+	code := strings.Join([]string{
+		`$x = "outer"`, // 0
+		`class c1 {`,
+		`	$x = 42`,
+		`	test "t1" {`,
+		`		int64ptr => $x,`,
+		`	}`, // 5
+		`}`,
+		`include c1`,
+		`test "t2" {`,
+		`	stringptr => $x,`,
+		`}`, // 10
+		`func add1($x) {`,
+		`	$x + 1`,
+		`}`,
+		`test "t3" {`,
+		`	int64ptr => add1(13),`, // 15
+		`}`,
+	}, "\n")
+	if p := open(c, uri, code); len(p.Diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics: %+v", p.Diagnostics)
+	}
+
+	hovers := []struct {
+		line, char int
+		expected   string
+	}{
+		{4, 15, "$x int"},
+		{9, 15, "$x str"},
+		{12, 2, "$x int"},
+		{15, 14, "add1(int) int"},
+	}
+	for _, x := range hovers {
+		b, _ := c.call("textDocument/hover", position(uri, x.line, x.char))
+		hover := &Hover{}
+		if err := json.Unmarshal(b, hover); err != nil {
+			t.Fatalf("could not decode: %+v", err)
+		}
+		if s := hover.Contents.Value; s != x.expected {
+			t.Errorf("unexpected hover at %d:%d: %s", x.line, x.char, s)
+		}
+	}
+
+	definitions := []struct {
+		line, char int
+		defLine    int
+		defChar    int
+	}{
+		{4, 15, 2, 1},   // the bind inside of the class
+		{9, 15, 0, 0},   // the bind at the top
+		{12, 2, 11, 10}, // the func param
+		{15, 14, 11, 0}, // the func
+		{7, 9, 1, 0},    // the class
+	}
+	for _, x := range definitions {
+		b, _ := c.call("textDocument/definition", position(uri, x.line, x.char))
+		loc := &Location{}
+		if err := json.Unmarshal(b, loc); err != nil {
+			t.Fatalf("could not decode: %+v", err)
+		}
+		if s := loc.Range.Start; s.Line != x.defLine || s.Character != x.defChar {
+			t.Errorf("unexpected definition at %d:%d: %+v", x.line, x.char, s)
+		}
+	}
+}
+
+func TestCompletion0(t *testing.T) {
+	c, cleanup := runServer(t)
+	defer cleanup()
+
+	uri := "untitled:complete"
+	open(c, uri, "import \"strings\"\n$x = strings.to\n")
+
+	b, _ := c.call("textDocument/completion", position(uri, 1, 15))
+	list := &CompletionList{}
+	if err := json.Unmarshal(b, list); err != nil {
+		t.Fatalf("could not decode: %+v", err)
+	}
+	found := false
+	for _, item := range list.Items {
+		if !strings.HasPrefix(item.Label, "strings.to") {
+			t.Errorf("unexpected completion: %s", item.Label)
+		}
+		if item.Label == "strings.to_lower" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("missing completion: %+v", list.Items)
+	}
+}
+
+func TestPositionEncoding0(t *testing.T) {
+	c, cleanup := runServer(t)
+	defer cleanup()
+
+	// the emoji needs two utf-16 code units, and the accent needs one, so
+	// the protocol positions are further along than the rune positions
+	uri := "untitled:utf16"
+	code := strings.Join([]string{
+		`$x = "héllo 🎉"`,
+		`$y = "🎉🎉" + $x`,
+		`test "t1" {`,
+		`	stringptr => $y,`,
+		`}`,
+	}, "\n")
+	if p := open(c, uri, code); len(p.Diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics: %+v", p.Diagnostics)
+	}
+
+	b, _ := c.call("textDocument/hover", position(uri, 1, 15))
+	hover := &Hover{}
+	if err := json.Unmarshal(b, hover); err != nil {
+		t.Fatalf("could not decode: %+v", err)
+	}
+	if s := hover.Contents.Value; s != "$x str" {
+		t.Errorf("unexpected hover: %s", s)
+	}
+	if r := hover.Range; r == nil || r.Start.Character != 14 || r.End.Character != 16 {
+		t.Errorf("unexpected hover range: %+v", r)
+	}
+
+	uri = "untitled:utf16bad"
+	p := open(c, uri, "$x = \"hello\"\n$y = \"é🎉\" + $z\n")
+	if l := len(p.Diagnostics); l != 1 {
+		t.Fatalf("expected one diagnostic, got: %d", l)
+	}
+	r := p.Diagnostics[0].Range
+	if r.Start.Line != 1 || r.Start.Character != 13 || r.End.Character != 15 {
+		t.Errorf("expected the error on `$z`, got: %+v", r)
+	}
+}
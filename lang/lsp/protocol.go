@@ -0,0 +1,259 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package lsp
+
+import (
+	"encoding/json"
+)
+
+// These are the standard JSON-RPC error codes that we use.
+const (
+	// ErrCodeParseError means invalid JSON was received by the server.
+	ErrCodeParseError = -32700
+
+	// ErrCodeInvalidRequest means the JSON sent is not a valid request.
+	ErrCodeInvalidRequest = -32600
+
+	// ErrCodeMethodNotFound means the method does not exist or is not
+	// available.
+	ErrCodeMethodNotFound = -32601
+
+	// ErrCodeInvalidParams means the method parameters were invalid.
+	ErrCodeInvalidParams = -32602
+
+	// ErrCodeInternalError means an internal JSON-RPC error occurred.
+	ErrCodeInternalError = -32603
+
+	// ErrCodeServerNotInitialized means a request was received before the
+	// initialize request.
+	ErrCodeServerNotInitialized = -32002
+)
+
+// These are the subset of the LSP constants that we use.
+const (
+	// TextDocumentSyncKindFull means documents are synced by always
+	// sending the full content of the document.
+	TextDocumentSyncKindFull = 1
+
+	// PositionEncodingUTF16 means that the characters of a position are
+	// counted in UTF-16 code units. This is the default, and the only one
+	// that every client must support, so it is the only one we offer.
+	PositionEncodingUTF16 = "utf-16"
+
+	// DiagnosticSeverityError reports an error.
+	DiagnosticSeverityError = 1
+
+	// DiagnosticSeverityWarning reports a warning.
+	DiagnosticSeverityWarning = 2
+
+	// MarkupKindPlainText is plain text, which is what we send on hover.
+	MarkupKindPlainText = "plaintext"
+
+	// CompletionItemKindFunction is a completion item for a function.
+	CompletionItemKindFunction = 3
+
+	// CompletionItemKindField is a completion item for a resource field.
+	CompletionItemKindField = 5
+
+	// CompletionItemKindVariable is a completion item for a variable.
+	CompletionItemKindVariable = 6
+
+	// CompletionItemKindClass is a completion item for a resource kind.
+	CompletionItemKindClass = 7
+
+	// CompletionItemKindKeyword is a completion item for a keyword.
+	CompletionItemKindKeyword = 14
+)
+
+// Message is the union of the request, response and notification messages in
+// the JSON-RPC protocol. A request has both an ID and a Method, a notification
+// has only a Method, and a response has only an ID.
+type Message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *ResponseError   `json:"error,omitempty"`
+}
+
+// ResponseError is the error object that is sent in a failed response.
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error returns the error string of this error. It implements the error
+// interface.
+func (obj *ResponseError) Error() string {
+	return obj.Message
+}
+
+// Position is a zero-indexed position in a text document. The Character is
+// counted in UTF-16 code units, and the document converts it to and from the
+// rune offsets that the lexer uses.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a range in a text document. The End position is exclusive.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location represents a location inside a resource, such as a line inside a
+// text file.
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// TextDocumentIdentifier identifies a text document by URI.
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+// TextDocumentItem is an item to transfer a text document from the client to
+// the server.
+type TextDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+// VersionedTextDocumentIdentifier identifies a specific version of a document.
+type VersionedTextDocumentIdentifier struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+}
+
+// TextDocumentContentChangeEvent is an event describing a change to a text
+// document. Since we only support full sync, the Range is always absent.
+type TextDocumentContentChangeEvent struct {
+	Text string `json:"text"`
+}
+
+// TextDocumentPositionParams is a parameter literal used in requests to pass a
+// text document and a position inside that document.
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// DidOpenTextDocumentParams are the params of the didOpen notification.
+type DidOpenTextDocumentParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+// DidChangeTextDocumentParams are the params of the didChange notification.
+type DidChangeTextDocumentParams struct {
+	TextDocument   VersionedTextDocumentIdentifier  `json:"textDocument"`
+	ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
+}
+
+// DidCloseTextDocumentParams are the params of the didClose notification.
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// DidSaveTextDocumentParams are the params of the didSave notification.
+type DidSaveTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// InitializeResult is the result of the initialize request.
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+	ServerInfo   *ServerInfo        `json:"serverInfo,omitempty"`
+}
+
+// ServerInfo describes the server to the client.
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// ServerCapabilities are the capabilities that this server provides.
+type ServerCapabilities struct {
+	PositionEncoding   string             `json:"positionEncoding,omitempty"`
+	TextDocumentSync   int                `json:"textDocumentSync"`
+	HoverProvider      bool               `json:"hoverProvider"`
+	DefinitionProvider bool               `json:"definitionProvider"`
+	CompletionProvider *CompletionOptions `json:"completionProvider,omitempty"`
+}
+
+// CompletionOptions describe the completion support of the server.
+type CompletionOptions struct {
+	TriggerCharacters []string `json:"triggerCharacters,omitempty"`
+}
+
+// Diagnostic represents a compiler error or warning in a document.
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Message  string `json:"message"`
+}
+
+// PublishDiagnosticsParams are the params of the publishDiagnostics
+// notification that the server sends to the client.
+type PublishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// MarkupContent is a string value with a specific content kind.
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Hover is the result of a hover request.
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+// CompletionItem is a single completion suggestion.
+type CompletionItem struct {
+	Label      string `json:"label"`
+	Kind       int    `json:"kind,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	InsertText string `json:"insertText,omitempty"`
+}
+
+// CompletionList is the result of a completion request.
+type CompletionList struct {
+	IsIncomplete bool             `json:"isIncomplete"`
+	Items        []CompletionItem `json:"items"`
+}
@@ -1654,6 +1654,100 @@ func TestLexParse0(t *testing.T) {
 			exp:  exp,
 		})
 	}
+	{
+		exp := &ast.StmtProg{
+			Body: []interfaces.Stmt{
+				&ast.StmtType{
+					Name: "endpoint",
+					Type: &types.Type{
+						Kind: types.KindStruct,
+						Map: map[string]*types.Type{
+							"host": types.TypeStr,
+							"type": types.TypeStr,
+							"opts": types.NewNamedType("mod.opts"),
+						},
+						Ord: []string{"host", "type", "opts"},
+					},
+				},
+			},
+		}
+		testCases = append(testCases, test{
+			name: "simple type alias 1",
+			code: `
+			type endpoint = struct{host str; type str; opts mod.opts}
+			`,
+			fail: false,
+			exp:  exp,
+		})
+	}
+	{
+		testCases = append(testCases, test{
+			name: "type alias name can't be dotted",
+			code: `
+			type foo.bar = str
+			`,
+			fail: true,
+		})
+	}
+	{
+		exp := &ast.StmtProg{
+			Body: []interfaces.Stmt{
+				&ast.StmtClass{
+					Name: "c1",
+					Args: []*interfaces.Arg{
+						{
+							Name: "a",
+						},
+						{
+							Name: "b",
+							Type: types.TypeInt,
+							Default: &ast.ExprInt{
+								V: 42,
+							},
+						},
+					},
+					Body: &ast.StmtProg{
+						Body: []interfaces.Stmt{},
+					},
+				},
+				&ast.StmtInclude{
+					Name: "c1",
+					Args: []interfaces.Expr{
+						&ast.ExprStr{
+							V: "hello",
+						},
+					},
+					Named: []*ast.ExprNamedArg{
+						{
+							Name: "b",
+							Value: &ast.ExprInt{
+								V: 13,
+							},
+						},
+					},
+				},
+			},
+		}
+		testCases = append(testCases, test{
+			name: "class with default and named args",
+			code: `
+			class c1($a, $b int = 42) {
+			}
+			include c1("hello", b => 13)
+			`,
+			fail: false,
+			exp:  exp,
+		})
+	}
+	{
+		testCases = append(testCases, test{
+			name: "positional arg can't follow named arg",
+			code: `
+			$x = foo.bar(a => 42, "hello")
+			`,
+			fail: true,
+		})
+	}
 	{
 		exp := &ast.StmtProg{
 			Body: []interfaces.Stmt{
@@ -79,6 +79,9 @@ func init() {
 	args []*interfaces.Arg
 	arg  *interfaces.Arg
 
+	namedArgs []*ast.ExprNamedArg
+	namedArg  *ast.ExprNamedArg
+
 	resContents []ast.StmtResContents // interface
 	resField    *ast.StmtResField
 	resEdge     *ast.StmtResEdge
@@ -102,6 +105,7 @@ func init() {
 %token FUNC_IDENTIFIER
 %token CLASS_IDENTIFIER INCLUDE_IDENTIFIER
 %token IMPORT_IDENTIFIER AS_IDENTIFIER
+%token TYPE_IDENTIFIER
 %token COMMENT ERROR
 %token PANIC_IDENTIFIER
 
@@ -204,6 +208,7 @@ stmt:
 			ThenBranch: $4.stmt,
 			//ElseBranch: nil,
 		}
+		locate($$.stmt, $1)
 	}
 |	IF expr OPEN_CURLY prog CLOSE_CURLY ELSE OPEN_CURLY prog CLOSE_CURLY
 	{
@@ -213,6 +218,7 @@ stmt:
 			ThenBranch: $4.stmt,
 			ElseBranch: $8.stmt,
 		}
+		locate($$.stmt, $1)
 	}
 	// this is the named version, iow, a user-defined function (statement)
 	// `func name() { <expr> }`
@@ -221,14 +227,17 @@ stmt:
 |	FUNC_IDENTIFIER IDENTIFIER OPEN_PAREN args CLOSE_PAREN OPEN_CURLY expr CLOSE_CURLY
 	{
 		posLast(yylex, yyDollar) // our pos
+		fn := &ast.ExprFunc{
+			Args: $4.args,
+			//Return: nil,
+			Body: $7.expr,
+		}
+		locate(fn, $1)
 		$$.stmt = &ast.StmtFunc{
 			Name: $2.str,
-			Func: &ast.ExprFunc{
-				Args: $4.args,
-				//Return: nil,
-				Body: $7.expr,
-			},
+			Func: fn,
 		}
+		locate($$.stmt, $1)
 	}
 	// `func name(...) <type> { <expr> }`
 |	FUNC_IDENTIFIER IDENTIFIER OPEN_PAREN args CLOSE_PAREN type OPEN_CURLY expr CLOSE_CURLY
@@ -258,15 +267,20 @@ stmt:
 				Ord:  ord,
 				Out:  $6.typ,
 			}
-			if err := fn.SetType(typ); err != nil {
-				// this will ultimately cause a parser error to occur...
-				yylex.Error(fmt.Sprintf("%s: %+v", ErrParseSetType, err))
+			// type aliases get resolved (and set) in SetScope
+			if !typ.HasUnresolved() {
+				if err := fn.SetType(typ); err != nil {
+					// this will ultimately cause a parser error to occur...
+					yylex.Error(fmt.Sprintf("%s: %+v", ErrParseSetType, err))
+				}
 			}
 		}
+		locate(fn, $1)
 		$$.stmt = &ast.StmtFunc{
 			Name: $2.str,
 			Func: fn,
 		}
+		locate($$.stmt, $1)
 	}
 	// `class name { <prog> }`
 |	CLASS_IDENTIFIER colon_identifier OPEN_CURLY prog CLOSE_CURLY
@@ -277,6 +291,7 @@ stmt:
 			Args: nil,
 			Body: $4.stmt,
 		}
+		locate($$.stmt, $1)
 	}
 	// `class name(<arg>) { <prog> }`
 	// `class name(<arg>, <arg>) { <prog> }`
@@ -288,6 +303,7 @@ stmt:
 			Args: $4.args,
 			Body: $7.stmt,
 		}
+		locate($$.stmt, $1)
 	}
 	// `include name`
 |	INCLUDE_IDENTIFIER dotted_identifier
@@ -296,15 +312,18 @@ stmt:
 		$$.stmt = &ast.StmtInclude{
 			Name: $2.str,
 		}
+		locate($$.stmt, $2) // the class name
 	}
 	// `include name(...)`
 |	INCLUDE_IDENTIFIER dotted_identifier OPEN_PAREN call_args CLOSE_PAREN
 	{
 		posLast(yylex, yyDollar) // our pos
 		$$.stmt = &ast.StmtInclude{
-			Name: $2.str,
-			Args: $4.exprs,
+			Name:  $2.str,
+			Args:  $4.exprs,
+			Named: $4.namedArgs,
 		}
+		locate($$.stmt, $2) // the class name
 	}
 	// `include name as foo`
 	// TODO: should we support: `include name as *`
@@ -315,6 +334,7 @@ stmt:
 			Name:  $2.str,
 			Alias: $4.str,
 		}
+		locate($$.stmt, $2) // the class name
 	}
 	// `include name(...) as foo`
 	// TODO: should we support: `include name(...) as *`
@@ -324,8 +344,10 @@ stmt:
 		$$.stmt = &ast.StmtInclude{
 			Name:  $2.str,
 			Args:  $4.exprs,
+			Named: $4.namedArgs,
 			Alias: $7.str,
 		}
+		locate($$.stmt, $2) // the class name
 	}
 	// `import "name"`
 |	IMPORT_IDENTIFIER STRING
@@ -335,6 +357,7 @@ stmt:
 			Name: $2.str,
 			//Alias: "",
 		}
+		locate($$.stmt, $1)
 	}
 	// `import "name" as alias`
 |	IMPORT_IDENTIFIER STRING AS_IDENTIFIER IDENTIFIER
@@ -344,6 +367,7 @@ stmt:
 			Name:  $2.str,
 			Alias: $4.str,
 		}
+		locate($$.stmt, $1)
 	}
 	// `import "name" as *`
 |	IMPORT_IDENTIFIER STRING AS_IDENTIFIER MULTIPLY
@@ -353,6 +377,16 @@ stmt:
 			Name:  $2.str,
 			Alias: $4.str,
 		}
+		locate($$.stmt, $1)
+	}
+	// `type name = <type>`
+|	TYPE_IDENTIFIER IDENTIFIER EQUALS type
+	{
+		posLast(yylex, yyDollar) // our pos
+		$$.stmt = &ast.StmtType{
+			Name: $2.str,
+			Type: $4.typ,
+		}
 	}
 /*
 	// resource bind
@@ -528,7 +562,7 @@ struct_fields:
 	}
 ;
 struct_field:
-	IDENTIFIER ROCKET expr COMMA
+	field_identifier ROCKET expr COMMA
 	{
 		posLast(yylex, yyDollar) // our pos
 		$$.structField = &ast.ExprStructField{
@@ -544,10 +578,12 @@ call:
 	{
 		posLast(yylex, yyDollar) // our pos
 		$$.expr = &ast.ExprCall{
-			Name: $1.str,
-			Args: $3.exprs,
+			Name:  $1.str,
+			Args:  $3.exprs,
+			Named: $3.namedArgs,
 			//Var: false, // default
 		}
+		locate($$.expr, $1)
 	}
 	// calling a function that's stored in a variable (a lambda)
 	// `$foo(4, "hey")` # call function value
@@ -555,12 +591,14 @@ call:
 	{
 		posLast(yylex, yyDollar) // our pos
 		$$.expr = &ast.ExprCall{
-			Name: $1.str,
-			Args: $3.exprs,
+			Name:  $1.str,
+			Args:  $3.exprs,
+			Named: $3.namedArgs,
 			// Instead of `Var: true`, we could have added a `$`
 			// prefix to the Name, but I felt this was more elegant.
 			Var: true, // lambda
 		}
+		locate($$.expr, $1)
 	}
 |	expr PLUS expr
 	{
@@ -776,7 +814,7 @@ call:
 	// lookup a field in a struct
 	// _struct_lookup($foo, "field")
 	// $foo->field
-|	expr ARROW IDENTIFIER
+|	expr ARROW field_identifier
 	{
 		posLast(yylex, yyDollar) // our pos
 		$$.expr = &ast.ExprCall{
@@ -793,7 +831,7 @@ call:
 	// lookup a field in a struct with a default
 	// _struct_lookup_optional($foo, "field", "default")
 	// $foo->field || "default"
-|	expr ARROW IDENTIFIER DEFAULT expr
+|	expr ARROW field_identifier DEFAULT expr
 	{
 		posLast(yylex, yyDollar) // our pos
 		$$.expr = &ast.ExprCall{
@@ -819,24 +857,57 @@ call:
 		}
 	}
 ;
-// list order gets us the position of the arg, but named params would work too!
+// list order gets us the position of the arg, but named params work too! they
+// must come after all of the positional args, and they use the `=>` operator.
 // this is also used by the include statement when the called class uses args!
 call_args:
 	/* end of list */
 	{
 		posLast(yylex, yyDollar) // our pos
 		$$.exprs = []interfaces.Expr{}
+		$$.namedArgs = nil
 	}
 	// seems that "left recursion" works here... thanks parser generator!
 |	call_args COMMA expr
 	{
 		posLast(yylex, yyDollar) // our pos
+		if len($1.namedArgs) > 0 {
+			// this will ultimately cause a parser error to occur...
+			yylex.Error(fmt.Sprintf("%s: %s", ErrParseNamedArgOrder, $3.expr.String()))
+		}
 		$$.exprs = append($1.exprs, $3.expr)
+		$$.namedArgs = $1.namedArgs
 	}
 |	expr
 	{
 		posLast(yylex, yyDollar) // our pos
 		$$.exprs = append([]interfaces.Expr{}, $1.expr)
+		$$.namedArgs = nil
+	}
+	// `foo(42, bar => true)`
+|	call_args COMMA named_arg
+	{
+		posLast(yylex, yyDollar) // our pos
+		$$.exprs = $1.exprs
+		$$.namedArgs = append($1.namedArgs, $3.namedArg)
+	}
+	// `foo(bar => true)`
+|	named_arg
+	{
+		posLast(yylex, yyDollar) // our pos
+		$$.exprs = []interfaces.Expr{}
+		$$.namedArgs = append([]*ast.ExprNamedArg{}, $1.namedArg)
+	}
+;
+named_arg:
+	// `port => 8080`
+	field_identifier ROCKET expr
+	{
+		posLast(yylex, yyDollar) // our pos
+		$$.namedArg = &ast.ExprNamedArg{
+			Name:  $1.str,
+			Value: $3.expr,
+		}
 	}
 ;
 var:
@@ -846,6 +917,7 @@ var:
 		$$.expr = &ast.ExprVar{
 			Name: $1.str,
 		}
+		locate($$.expr, $1)
 	}
 ;
 func:
@@ -861,6 +933,7 @@ func:
 			//Return: nil,
 			Body: $6.expr,
 		}
+		locate($$.expr, $1)
 	}
 	// `func(...) <type> { <expr> }`
 |	FUNC_IDENTIFIER OPEN_PAREN args CLOSE_PAREN type OPEN_CURLY expr CLOSE_CURLY
@@ -871,6 +944,7 @@ func:
 			Return: $5.typ, // return type is known
 			Body:   $7.expr,
 		}
+		locate($$.expr, $1)
 		isFullyTyped := $5.typ != nil // true if set
 		m := make(map[string]*types.Type)
 		ord := []string{}
@@ -890,9 +964,12 @@ func:
 				Ord:  ord,
 				Out:  $5.typ,
 			}
-			if err := $$.expr.SetType(typ); err != nil {
-				// this will ultimately cause a parser error to occur...
-				yylex.Error(fmt.Sprintf("%s: %+v", ErrParseSetType, err))
+			// type aliases get resolved (and set) in SetScope
+			if !typ.HasUnresolved() {
+				if err := $$.expr.SetType(typ); err != nil {
+					// this will ultimately cause a parser error to occur...
+					yylex.Error(fmt.Sprintf("%s: %+v", ErrParseSetType, err))
+				}
 			}
 		}
 	}
@@ -921,6 +998,7 @@ arg:
 		$$.arg = &interfaces.Arg{
 			Name: $1.str,
 		}
+		locate($$.arg, $1)
 	}
 	// `$x <type>`
 |	var_identifier type
@@ -929,6 +1007,26 @@ arg:
 			Name: $1.str,
 			Type: $2.typ,
 		}
+		locate($$.arg, $1)
+	}
+	// `$x = <expr>`
+|	var_identifier EQUALS expr
+	{
+		$$.arg = &interfaces.Arg{
+			Name:    $1.str,
+			Default: $3.expr,
+		}
+		locate($$.arg, $1)
+	}
+	// `$x <type> = <expr>`
+|	var_identifier type EQUALS expr
+	{
+		$$.arg = &interfaces.Arg{
+			Name:    $1.str,
+			Type:    $2.typ,
+			Default: $4.expr,
+		}
+		locate($$.arg, $1)
 	}
 ;
 bind:
@@ -940,6 +1038,7 @@ bind:
 			Ident: $1.str,
 			Value: $3.expr,
 		}
+		locate($$.stmt, $1)
 	}
 	// `$x bool = true`
 	// `$x int = if true { 42 } else { 13 }`
@@ -947,14 +1046,20 @@ bind:
 	{
 		posLast(yylex, yyDollar) // our pos
 		var expr interfaces.Expr = $4.expr
-		if err := expr.SetType($2.typ); err != nil {
+		var typ *types.Type
+		// type aliases get resolved (and set) in SetScope
+		if $2.typ.HasUnresolved() {
+			typ = $2.typ
+		} else if err := expr.SetType($2.typ); err != nil {
 			// this will ultimately cause a parser error to occur...
 			yylex.Error(fmt.Sprintf("%s: %+v", ErrParseSetType, err))
 		}
 		$$.stmt = &ast.StmtBind{
 			Ident: $1.str,
 			Value: expr,
+			Type:  typ,
 		}
+		locate($$.stmt, $1)
 	}
 ;
 panic:
@@ -1012,6 +1117,7 @@ resource:
 			Name:     $2.expr,
 			Contents: $4.resContents,
 		}
+		locate($$.stmt, $1)
 	}
 ;
 resource_body:
@@ -1062,18 +1168,19 @@ resource_body:
 	}
 ;
 resource_field:
-	IDENTIFIER ROCKET expr COMMA
+	field_identifier ROCKET expr COMMA
 	{
 		posLast(yylex, yyDollar) // our pos
 		$$.resField = &ast.StmtResField{
 			Field: $1.str,
 			Value: $3.expr,
 		}
+		locate($$.resField, $1)
 	}
 ;
 conditional_resource_field:
 	// content => $present ?: "hello",
-	IDENTIFIER ROCKET expr ELVIS expr COMMA
+	field_identifier ROCKET expr ELVIS expr COMMA
 	{
 		posLast(yylex, yyDollar) // our pos
 		$$.resField = &ast.StmtResField{
@@ -1081,6 +1188,7 @@ conditional_resource_field:
 			Value:     $5.expr,
 			Condition: $3.expr,
 		}
+		locate($$.resField, $1)
 	}
 ;
 resource_edge:
@@ -1215,6 +1323,7 @@ edge_half:
 			Name: $3.expr,
 			//SendRecv: "", // unused
 		}
+		locate($$.edgeHalf, $1)
 	}
 ;
 edge_half_sendrecv:
@@ -1227,6 +1336,7 @@ edge_half_sendrecv:
 			Name: $3.expr,
 			SendRecv: $6.str,
 		}
+		locate($$.edgeHalf, $1)
 	}
 ;
 type:
@@ -1254,35 +1364,47 @@ type:
 	// list: []int or [][]str (with recursion)
 	{
 		posLast(yylex, yyDollar) // our pos
-		$$.typ = types.NewType("[]" + $3.typ.String())
+		// build these directly so that the type alias references work
+		$$.typ = &types.Type{
+			Kind: types.KindList,
+			Val:  $3.typ,
+		}
 	}
 |	MAP_IDENTIFIER OPEN_CURLY type COLON type CLOSE_CURLY
 	// map: map{str: int} or map{str: []int}
 	{
 		posLast(yylex, yyDollar) // our pos
-		$$.typ = types.NewType(fmt.Sprintf("map{%s: %s}", $3.typ.String(), $5.typ.String()))
+		$$.typ = &types.Type{
+			Kind: types.KindMap,
+			Key:  $3.typ,
+			Val:  $5.typ,
+		}
 	}
 |	STRUCT_IDENTIFIER OPEN_CURLY type_struct_fields CLOSE_CURLY
 	// struct: struct{} or struct{a bool} or struct{a bool; bb int}
 	{
 		posLast(yylex, yyDollar) // our pos
 
-		names := make(map[string]struct{})
-		strs := []string{}
+		m := make(map[string]*types.Type)
+		ord := []string{}
 		for _, arg := range $3.args {
-			s := fmt.Sprintf("%s %s", arg.Name, arg.Type.String())
-			if _, exists := names[arg.Name]; exists {
+			if _, exists := m[arg.Name]; exists {
 				// duplicate field name used
+				s := fmt.Sprintf("%s %s", arg.Name, arg.Type.String())
 				err := fmt.Errorf("duplicate struct field of `%s`", s)
 				// this will ultimately cause a parser error to occur...
 				yylex.Error(fmt.Sprintf("%s: %+v", ErrParseSetType, err))
 				break // we must skip, because code continues!
 			}
-			names[arg.Name] = struct{}{}
-			strs = append(strs, s)
+			m[arg.Name] = arg.Type
+			ord = append(ord, arg.Name)
 		}
 
-		$$.typ = types.NewType(fmt.Sprintf("%s{%s}", $1.str, strings.Join(strs, "; ")))
+		$$.typ = &types.Type{
+			Kind: types.KindStruct,
+			Map:  m,
+			Ord:  ord,
+		}
 	}
 |	FUNC_IDENTIFIER OPEN_PAREN type_func_args CLOSE_PAREN type
 	// XXX: should we allow named args in the type signature?
@@ -1328,6 +1450,24 @@ type:
 		posLast(yylex, yyDollar) // our pos
 		$$.typ = types.NewType($1.str) // "variant"
 	}
+	// type alias: foo or mod.foo (resolved later in SetScope)
+|	type_identifier
+	{
+		posLast(yylex, yyDollar) // our pos
+		$$.typ = types.NewNamedType($1.str)
+	}
+;
+type_identifier:
+	IDENTIFIER
+	{
+		posLast(yylex, yyDollar) // our pos
+		$$.str = $1.str
+	}
+|	type_identifier DOT IDENTIFIER
+	{
+		posLast(yylex, yyDollar) // our pos
+		$$.str = $1.str + interfaces.ModuleSep + $3.str
+	}
 ;
 type_struct_fields:
 	/* end of list */
@@ -1347,7 +1487,7 @@ type_struct_fields:
 	}
 ;
 type_struct_field:
-	IDENTIFIER type
+	field_identifier type
 	{
 		posLast(yylex, yyDollar) // our pos
 		$$.arg = &interfaces.Arg{ // re-use the Arg struct
@@ -1392,6 +1532,19 @@ type_func_arg:
 		}
 	}
 ;
+// a field name can be called type, even though it's also a keyword
+field_identifier:
+	IDENTIFIER
+	{
+		posLast(yylex, yyDollar) // our pos
+		$$.str = $1.str
+	}
+|	TYPE_IDENTIFIER
+	{
+		posLast(yylex, yyDollar) // our pos
+		$$.str = $1.str
+	}
+;
 undotted_identifier:
 	IDENTIFIER
 	{
@@ -1473,6 +1626,15 @@ func pos(y yyLexer, dollar yySymType) {
 	return
 }
 
+// locate stores the position of this symbol on the node that we built, if that
+// node is one which can be located. The lexer counts from zero, but we count the
+// lines and columns of a position from one.
+func locate(x interface{}, dollar yySymType) {
+	if l, ok := x.(interfaces.Locatable); ok {
+		l.Locate(dollar.row+1, dollar.col+1)
+	}
+}
+
 // cast is used to pull out the parser run-specific struct we store our AST in.
 // this is usually called in the parser.
 func cast(y yyLexer) *lexParseAST {
@@ -1514,6 +1676,8 @@ func (yylex *Lexer) Error(str string) {
 			err = ErrParseExpectingComma
 		} else if strings.HasPrefix(str, ErrParseSetType.Error()) {
 			err = ErrParseSetType
+		} else if strings.HasPrefix(str, ErrParseNamedArgOrder.Error()) {
+			err = ErrParseNamedArgOrder
 		}
 		lp.parseErr = &LexParseErr{
 			Err: err,
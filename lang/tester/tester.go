@@ -0,0 +1,585 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+// Package tester runs user written unit tests for mcl code. Each test is an
+// mcl file whose name ends in _test.mcl and which contains some special
+// comments. The `# stub: <func> = <value>` comments replace a function with one
+// that always returns a constant value, and the `# assert: <line>` and
+// `# refute: <line>` comments check that a line is (or is not) present in the
+// text representation of the resource graph that the code produces. That text
+// representation uses the same Vertex, Edge and Field lines that the txtar
+// tests for the language use.
+package tester
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/purpleidea/mgmt/engine"
+	"github.com/purpleidea/mgmt/engine/graph/autoedge"
+	"github.com/purpleidea/mgmt/engine/local"
+	engineUtil "github.com/purpleidea/mgmt/engine/util"
+	"github.com/purpleidea/mgmt/etcd"
+	"github.com/purpleidea/mgmt/lang"
+	"github.com/purpleidea/mgmt/lang/ast"
+	"github.com/purpleidea/mgmt/lang/funcs"
+	"github.com/purpleidea/mgmt/lang/funcs/simple"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/parser"
+	"github.com/purpleidea/mgmt/lang/types"
+	"github.com/purpleidea/mgmt/pgraph"
+	"github.com/purpleidea/mgmt/util"
+	"github.com/purpleidea/mgmt/util/errwrap"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	// FileSuffix is the suffix that all test files must have.
+	FileSuffix = "_test" + interfaces.DotFileNameExtension
+
+	// DirectiveStub is the comment prefix which replaces a function.
+	DirectiveStub = "stub:"
+
+	// DirectiveAssert is the comment prefix for a line which must be found
+	// in the output graph.
+	DirectiveAssert = "assert:"
+
+	// DirectiveRefute is the comment prefix for a line which must not be
+	// found in the output graph.
+	DirectiveRefute = "refute:"
+
+	// DefaultTimeout is the default number of seconds to wait for the
+	// function engine to produce the first graph.
+	DefaultTimeout = 60
+)
+
+// Stub replaces the function named Func with one that returns Value.
+type Stub struct {
+	Func  string
+	Value string // the mcl expression
+}
+
+// Spec contains all of the directives that were found in a test file.
+type Spec struct {
+	Stubs   []*Stub
+	Asserts []string
+	Refutes []string
+}
+
+// Result is the outcome of running a single test file.
+type Result struct {
+	// File is the path of the test file.
+	File string
+
+	// Failures is the list of assertions which did not hold.
+	Failures []string
+
+	// Err is set if the test could not run at all.
+	Err error
+
+	// Duration is how long this test took.
+	Duration time.Duration
+}
+
+// Passed returns true if this test ran and all of its assertions held.
+func (obj *Result) Passed() bool {
+	return obj.Err == nil && len(obj.Failures) == 0
+}
+
+// Tester finds and runs the test files.
+type Tester struct {
+	// Path is a test file or a directory to search for test files in.
+	Path string
+
+	// Hostname is the hostname that the code will see, unless this is
+	// stubbed out.
+	Hostname string
+
+	// Timeout is the number of seconds to wait for each graph.
+	Timeout int
+
+	Debug bool
+	Logf  func(format string, v ...interface{})
+}
+
+// Discover returns the sorted list of test files that should be run.
+func (obj *Tester) Discover() ([]string, error) {
+	fi, err := os.Stat(obj.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		if !strings.HasSuffix(obj.Path, FileSuffix) {
+			return nil, fmt.Errorf("file `%s` is not a test file", obj.Path)
+		}
+		return []string{obj.Path}, nil
+	}
+
+	files := []string{}
+	fn := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, FileSuffix) {
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	}
+	if err := filepath.Walk(obj.Path, fn); err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Run discovers and then runs each test file one after another. It only errors
+// if the tests could not be found. Individual test errors are in the results.
+func (obj *Tester) Run(ctx context.Context) ([]*Result, error) {
+	if obj.Logf == nil {
+		obj.Logf = func(format string, v ...interface{}) {}
+	}
+	files, err := obj.Discover()
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "could not find tests")
+	}
+
+	results := []*Result{}
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		timing := time.Now()
+		result := &Result{
+			File: file,
+		}
+		result.Failures, result.Err = obj.runFile(ctx, file)
+		result.Duration = time.Since(timing)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// runFile runs a single test file and returns the list of failed assertions.
+func (obj *Tester) runFile(ctx context.Context, file string) ([]string, error) {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := ParseSpec(b)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "could not parse test directives")
+	}
+
+	for _, stub := range spec.Stubs {
+		restore, err := StubFunc(stub.Func, stub.Value)
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "could not stub `%s`", stub.Func)
+		}
+		defer restore()
+	}
+
+	graph, err := obj.interpret(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := GraphLines(graph)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Debug {
+		for _, x := range lines {
+			obj.Logf("%s: %s", file, x)
+		}
+	}
+
+	return Check(spec, lines), nil
+}
+
+// interpret runs the test code until the first graph is produced. The test file
+// is run as if it was the main file of the directory it is in, so that it can
+// import the other local files that it is testing.
+func (obj *Tester) interpret(ctx context.Context, path string) (_ *pgraph.Graph, reterr error) {
+	logf := func(format string, v ...interface{}) {
+		if obj.Debug {
+			obj.Logf("lang: "+format, v...)
+		}
+	}
+
+	// Read from disk, but write the metadata file to memory so that we
+	// can point it at the test file without changing anything on disk.
+	base := afero.NewReadOnlyFs(afero.NewOsFs())
+	overlay := afero.NewCopyOnWriteFs(base, afero.NewMemMapFs())
+	afs := &afero.Afero{Fs: overlay} // wrap so that we're implementing ioutil
+	fs := &util.AferoFs{Afero: afs}
+
+	metadataPath := filepath.Join(filepath.Dir(path), interfaces.MetadataFilename)
+	metadata := interfaces.DefaultMetadata()
+	if f, err := fs.Open(metadataPath); err == nil {
+		metadata, err = interfaces.ParseMetadata(f)
+		f.Close()
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "could not parse existing metadata")
+		}
+	}
+	metadata.Main = filepath.Base(path)
+	byt, err := metadata.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	if err := afs.WriteFile(metadataPath, byt, 0600); err != nil {
+		return nil, errwrap.Wrapf(err, "could not write metadata")
+	}
+
+	tmpdir, err := os.MkdirTemp("", "mgmt-test-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	localAPI := (&local.API{
+		Prefix: fmt.Sprintf("%s/", filepath.Join(tmpdir, "local")),
+		Debug:  obj.Debug,
+		Logf: func(format string, v ...interface{}) {
+			logf("local: api: "+format, v...)
+		},
+	}).Init()
+
+	// There is no cluster, so any world functions must be stubbed out.
+	world := &etcd.World{
+		Hostname:     obj.Hostname,
+		StandaloneFs: fs,
+		Debug:        obj.Debug,
+		Logf: func(format string, v ...interface{}) {
+			logf("world: etcd: "+format, v...)
+		},
+	}
+
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	l := &lang.Lang{
+		Fs:    fs,
+		FsURI: fs.URI(),
+		Input: metadataPath,
+		Data: &lang.Data{
+			UnificationStrategy: make(map[string]string), // empty
+		},
+		Hostname: obj.Hostname,
+		Local:    localAPI,
+		World:    world,
+		Debug:    obj.Debug,
+		Logf:     logf,
+	}
+	if err := l.Init(ctx); err != nil {
+		return nil, errwrap.Wrapf(err, "could not init the code")
+	}
+	defer l.Cleanup()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := l.Run(ctx); err != nil {
+			reterr = errwrap.Append(reterr, err)
+		}
+	}()
+	defer cancel() // shutdown the Run before we wait for it
+
+	timeout := obj.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	// we only wait for the first event, instead of the continuous stream
+	select {
+	case err, ok := <-l.Stream():
+		if !ok {
+			return nil, fmt.Errorf("stream closed without event")
+		}
+		if err != nil {
+			return nil, errwrap.Wrapf(err, "stream failed")
+		}
+
+	case <-time.After(time.Duration(timeout) * time.Second):
+		return nil, fmt.Errorf("timeout waiting for the graph")
+
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	graph, err := l.Interpret()
+	if err != nil {
+		return nil, err
+	}
+	if err := autoedge.AutoEdge(graph, obj.Debug, logf); err != nil {
+		return nil, errwrap.Wrapf(err, "automatic edges failed")
+	}
+
+	return graph, nil
+}
+
+// ParseSpec finds all the test directives in the comments of some mcl code.
+// Each directive must be on its own line.
+func ParseSpec(code []byte) (*Spec, error) {
+	spec := &Spec{
+		Stubs:   []*Stub{},
+		Asserts: []string{},
+		Refutes: []string{},
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(code))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+
+		if s := strings.TrimPrefix(line, DirectiveStub); s != line {
+			name, value, ok := strings.Cut(s, "=")
+			if !ok {
+				return nil, fmt.Errorf("stub is missing an equals sign: %s", line)
+			}
+			spec.Stubs = append(spec.Stubs, &Stub{
+				Func:  strings.TrimSpace(name),
+				Value: strings.TrimSpace(value),
+			})
+			continue
+		}
+		if s := strings.TrimPrefix(line, DirectiveAssert); s != line {
+			spec.Asserts = append(spec.Asserts, strings.TrimSpace(s))
+			continue
+		}
+		if s := strings.TrimPrefix(line, DirectiveRefute); s != line {
+			spec.Refutes = append(spec.Refutes, strings.TrimSpace(s))
+			continue
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(spec.Asserts) == 0 && len(spec.Refutes) == 0 {
+		return nil, fmt.Errorf("test contains no assertions")
+	}
+	return spec, nil
+}
+
+// Check returns the list of assertions from the spec which don't hold against
+// the lines of the graph.
+func Check(spec *Spec, lines []string) []string {
+	m := make(map[string]struct{})
+	for _, x := range lines {
+		m[x] = struct{}{}
+	}
+	failures := []string{}
+	for _, x := range spec.Asserts {
+		if _, exists := m[x]; !exists {
+			failures = append(failures, fmt.Sprintf("missing: %s", x))
+		}
+	}
+	for _, x := range spec.Refutes {
+		if _, exists := m[x]; exists {
+			failures = append(failures, fmt.Sprintf("unexpected: %s", x))
+		}
+	}
+	return failures
+}
+
+// GraphLines returns the sorted text representation of a resource graph. Edge
+// lines omit the trailing edge name comment, since it's not useful to match on.
+func GraphLines(graph *pgraph.Graph) ([]string, error) {
+	lines := []string{}
+	for _, x := range strings.Split(graph.Sprint(), "\n") {
+		if x == "" {
+			continue
+		}
+		if strings.HasPrefix(x, "Edge: ") {
+			x, _, _ = strings.Cut(x, " # ")
+		}
+		lines = append(lines, x)
+	}
+	for _, v := range graph.Vertices() {
+		res, ok := v.(engine.Res)
+		if !ok {
+			return nil, fmt.Errorf("unexpected non-resource: %+v", v)
+		}
+		s, err := engineUtil.ResToFieldsString(res)
+		if err != nil {
+			return nil, err
+		}
+		for _, x := range strings.Split(strings.TrimSpace(s), "\n") {
+			if x == "" {
+				continue
+			}
+			lines = append(lines, x)
+		}
+	}
+	sort.Strings(lines)
+	return lines, nil
+}
+
+// StubFunc replaces the registered function with one that always returns the
+// constant value in the mcl expression. The signature of the function stays the
+// same, so polymorphic functions can't be stubbed. It returns a function which
+// restores the original.
+func StubFunc(name, expr string) (func(), error) {
+	fn, err := funcs.Lookup(name)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "could not find function")
+	}
+	sig := fn.Info().Sig
+	if sig == nil || sig.Kind != types.KindFunc || sig.HasVariant() {
+		return nil, fmt.Errorf("function does not have a static signature")
+	}
+
+	// Parse the value by wrapping it in a bind statement.
+	xast, err := parser.LexParse(strings.NewReader("$stub = " + expr))
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "could not parse value")
+	}
+	prog, ok := xast.(*ast.StmtProg)
+	if !ok || len(prog.Body) != 1 {
+		return nil, fmt.Errorf("unexpected value")
+	}
+	bind, ok := prog.Body[0].(*ast.StmtBind)
+	if !ok {
+		return nil, fmt.Errorf("unexpected value")
+	}
+	value, err := ExprToValue(bind.Value, sig.Out)
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "invalid value")
+	}
+
+	fv := &types.FuncValue{
+		T: sig,
+		V: func([]types.Value) (types.Value, error) {
+			return value, nil
+		},
+	}
+	return funcs.Stub(name, func() interfaces.Func {
+		return &simple.WrappedFunc{Name: name, Fn: fv}
+	})
+}
+
+// ExprToValue converts a constant expression into a value of the given type.
+func ExprToValue(expr interfaces.Expr, typ *types.Type) (types.Value, error) {
+	switch typ.Kind {
+	case types.KindBool:
+		if x, ok := expr.(*ast.ExprBool); ok {
+			return &types.BoolValue{V: x.V}, nil
+		}
+
+	case types.KindStr:
+		if x, ok := expr.(*ast.ExprStr); ok {
+			return &types.StrValue{V: x.V}, nil
+		}
+
+	case types.KindInt:
+		if x, ok := expr.(*ast.ExprInt); ok {
+			return &types.IntValue{V: x.V}, nil
+		}
+
+	case types.KindFloat:
+		if x, ok := expr.(*ast.ExprFloat); ok {
+			return &types.FloatValue{V: x.V}, nil
+		}
+
+	case types.KindList:
+		if x, ok := expr.(*ast.ExprList); ok {
+			l := types.NewList(typ)
+			for i, e := range x.Elements {
+				v, err := ExprToValue(e, typ.Val)
+				if err != nil {
+					return nil, errwrap.Wrapf(err, "list index `%d`", i)
+				}
+				if err := l.Add(v); err != nil {
+					return nil, err
+				}
+			}
+			return l, nil
+		}
+
+	case types.KindMap:
+		if x, ok := expr.(*ast.ExprMap); ok {
+			m := types.NewMap(typ)
+			for _, kv := range x.KVs {
+				k, err := ExprToValue(kv.Key, typ.Key)
+				if err != nil {
+					return nil, errwrap.Wrapf(err, "map key")
+				}
+				v, err := ExprToValue(kv.Val, typ.Val)
+				if err != nil {
+					return nil, errwrap.Wrapf(err, "map value")
+				}
+				if err := m.Add(k, v); err != nil {
+					return nil, err
+				}
+			}
+			return m, nil
+		}
+
+	case types.KindStruct:
+		if x, ok := expr.(*ast.ExprStruct); ok {
+			st := types.NewStruct(typ)
+			for _, field := range x.Fields {
+				t, exists := typ.Map[field.Name]
+				if !exists {
+					return nil, fmt.Errorf("struct has no field named `%s`", field.Name)
+				}
+				v, err := ExprToValue(field.Value, t)
+				if err != nil {
+					return nil, errwrap.Wrapf(err, "struct field `%s`", field.Name)
+				}
+				if err := st.Set(field.Name, v); err != nil {
+					return nil, err
+				}
+			}
+			return st, nil
+		}
+
+	default:
+		return nil, fmt.Errorf("values of type %s can't be stubbed", typ)
+	}
+
+	return nil, fmt.Errorf("expected a constant of type %s, got: %s", typ, expr)
+}
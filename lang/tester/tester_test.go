@@ -0,0 +1,185 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package tester
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	_ "github.com/purpleidea/mgmt/engine/resources" // import so the resources register
+	"github.com/purpleidea/mgmt/lang/ast"
+	"github.com/purpleidea/mgmt/lang/interfaces"
+	"github.com/purpleidea/mgmt/lang/types"
+)
+
+func TestParseSpec0(t *testing.T) {
+	code := `
+		# stub: sys.hostname = "h1"
+		# stub: os.readfile = "x = y"
+		import "lib.mcl"
+		# this is just a comment
+		# assert: Vertex: test[t1]
+		# refute: Vertex: test[t2]
+	`
+	spec, err := ParseSpec([]byte(code))
+	if err != nil {
+		t.Errorf("could not parse: %+v", err)
+		return
+	}
+	exp := &Spec{
+		Stubs: []*Stub{
+			{Func: "sys.hostname", Value: `"h1"`},
+			{Func: "os.readfile", Value: `"x = y"`},
+		},
+		Asserts: []string{"Vertex: test[t1]"},
+		Refutes: []string{"Vertex: test[t2]"},
+	}
+	if !reflect.DeepEqual(spec, exp) {
+		t.Errorf("got: %+v", spec)
+		t.Errorf("exp: %+v", exp)
+	}
+
+	if _, err := ParseSpec([]byte(`test "t1" {}`)); err == nil {
+		t.Errorf("expected an error when there are no assertions")
+	}
+}
+
+func TestCheck0(t *testing.T) {
+	spec := &Spec{
+		Asserts: []string{"Vertex: test[t1]", "Vertex: test[t3]"},
+		Refutes: []string{"Vertex: test[t2]", "Vertex: test[t4]"},
+	}
+	lines := []string{"Vertex: test[t1]", "Vertex: test[t2]"}
+	exp := []string{
+		"missing: Vertex: test[t3]",
+		"unexpected: Vertex: test[t2]",
+	}
+	if failures := Check(spec, lines); !reflect.DeepEqual(failures, exp) {
+		t.Errorf("got: %+v", failures)
+		t.Errorf("exp: %+v", exp)
+	}
+}
+
+func TestExprToValue0(t *testing.T) {
+	expr := &ast.ExprMap{
+		KVs: []*ast.ExprMapKV{
+			{
+				Key: &ast.ExprStr{V: "a"},
+				Val: &ast.ExprList{Elements: []interfaces.Expr{&ast.ExprInt{V: 42}}},
+			},
+			{
+				Key: &ast.ExprStr{V: "b"},
+				Val: &ast.ExprList{Elements: []interfaces.Expr{}},
+			},
+		},
+	}
+	typ := types.NewType("map{str: []int}")
+	value, err := ExprToValue(expr, typ)
+	if err != nil {
+		t.Errorf("could not convert: %+v", err)
+		return
+	}
+	if s, exp := value.String(), `{"a": [42], "b": []}`; s != exp {
+		t.Errorf("got: %s", s)
+		t.Errorf("exp: %s", exp)
+	}
+
+	if _, err := ExprToValue(&ast.ExprStr{V: "a"}, types.NewType("int")); err == nil {
+		t.Errorf("expected an error for the wrong type")
+	}
+}
+
+func TestRun0(t *testing.T) {
+	dir := t.TempDir()
+	lib := `
+		import "sys"
+		class hello() {
+			$name = sys.hostname()
+			test "hello-${name}" {
+				anotherstr => "bye",
+			}
+		}
+	`
+	test := `
+		# stub: sys.hostname = "h1"
+		import "lib.mcl"
+		include lib.hello()
+		# assert: Vertex: test[hello-h1]
+		# assert: Field: test[hello-h1].AnotherStr = "bye"
+		# refute: Vertex: test[hello-localhost]
+	`
+	bad := `
+		import "lib.mcl"
+		include lib.hello()
+		# assert: Vertex: test[hello-h1]
+	`
+	files := map[string]string{
+		"lib.mcl":      lib,
+		"lib_test.mcl": test,
+		"bad_test.mcl": bad,
+	}
+	for name, code := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(code), 0600); err != nil {
+			t.Errorf("could not write file: %+v", err)
+			return
+		}
+	}
+
+	tester := &Tester{
+		Path:     dir,
+		Hostname: "localhost",
+		Debug:    testing.Verbose(),
+		Logf: func(format string, v ...interface{}) {
+			t.Logf("tester: "+format, v...)
+		},
+	}
+	results, err := tester.Run(context.Background())
+	if err != nil {
+		t.Errorf("could not run: %+v", err)
+		return
+	}
+	if len(results) != 2 {
+		t.Errorf("expected two results, got: %d", len(results))
+		return
+	}
+
+	// The results are sorted by file name.
+	if r := results[0]; r.Passed() || r.Err != nil || len(r.Failures) != 1 {
+		t.Errorf("expected the bad test to fail: %+v", r)
+	}
+	if r := results[1]; !r.Passed() {
+		t.Errorf("expected the test to pass: %+v (%v)", r, r.Err)
+	}
+}
@@ -0,0 +1,247 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// ValueToJSON returns a JSON encoding of a value of any type, except for
+// functions. The type isn't part of the encoding, so it must be stored beside
+// it, and given to ValueFromJSON to decode the value again. Ints and floats are
+// encoded as strings so that they don't lose any precision, and map entries are
+// encoded as a list of key and value pairs since the keys might not be strings.
+func ValueToJSON(value Value) ([]byte, error) {
+	x, err := encodeValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(x)
+}
+
+// ValueFromJSON is the opposite of ValueToJSON. It decodes a value of this type.
+func ValueFromJSON(typ *Type, data []byte) (Value, error) {
+	var x interface{}
+	if err := json.Unmarshal(data, &x); err != nil {
+		return nil, err
+	}
+	return decodeValue(typ, x)
+}
+
+// encodeValue converts a value into something which can be encoded as JSON.
+func encodeValue(value Value) (interface{}, error) {
+	switch x := value.(type) {
+	case *BoolValue:
+		return x.V, nil
+	case *StrValue:
+		return x.V, nil
+	case *IntValue:
+		return strconv.FormatInt(x.V, 10), nil
+	case *FloatValue:
+		return strconv.FormatFloat(x.V, 'g', -1, 64), nil
+	case *ListValue:
+		l := []interface{}{}
+		for _, v := range x.V {
+			y, err := encodeValue(v)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, y)
+		}
+		return l, nil
+	case *MapValue:
+		keys := []Value{}
+		for k := range x.V {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		l := []interface{}{}
+		for _, k := range keys {
+			y, err := encodeValue(k)
+			if err != nil {
+				return nil, err
+			}
+			z, err := encodeValue(x.V[k])
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, []interface{}{y, z})
+		}
+		return l, nil
+	case *StructValue:
+		m := make(map[string]interface{})
+		for k, v := range x.V {
+			y, err := encodeValue(v)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = y
+		}
+		return m, nil
+	case *VariantValue:
+		y, err := encodeValue(x.V)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"type":  x.V.Type().String(),
+			"value": y,
+		}, nil
+	}
+	return nil, fmt.Errorf("can't encode a value of type: %s", value.Type())
+}
+
+// decodeValue is the opposite of encodeValue. It builds a value of this type.
+func decodeValue(typ *Type, x interface{}) (Value, error) {
+	switch typ.Kind {
+	case KindBool:
+		b, ok := x.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected a bool")
+		}
+		return &BoolValue{V: b}, nil
+
+	case KindStr:
+		s, ok := x.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a str")
+		}
+		return &StrValue{V: s}, nil
+
+	case KindInt:
+		s, ok := x.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected an int")
+		}
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &IntValue{V: i}, nil
+
+	case KindFloat:
+		s, ok := x.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a float")
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &FloatValue{V: f}, nil
+
+	case KindList:
+		l, ok := x.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected a list")
+		}
+		list := NewList(typ)
+		for _, y := range l {
+			v, err := decodeValue(typ.Val, y)
+			if err != nil {
+				return nil, err
+			}
+			if err := list.Add(v); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+
+	case KindMap:
+		l, ok := x.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected a map")
+		}
+		m := NewMap(typ)
+		for _, y := range l {
+			kv, ok := y.([]interface{})
+			if !ok || len(kv) != 2 {
+				return nil, fmt.Errorf("expected a map entry")
+			}
+			k, err := decodeValue(typ.Key, kv[0])
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeValue(typ.Val, kv[1])
+			if err != nil {
+				return nil, err
+			}
+			if err := m.Add(k, v); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+
+	case KindStruct:
+		m, ok := x.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected a struct")
+		}
+		st := NewStruct(typ)
+		for _, k := range typ.Ord {
+			y, exists := m[k]
+			if !exists {
+				return nil, fmt.Errorf("missing struct field `%s`", k)
+			}
+			v, err := decodeValue(typ.Map[k], y)
+			if err != nil {
+				return nil, err
+			}
+			if err := st.Set(k, v); err != nil {
+				return nil, err
+			}
+		}
+		return st, nil
+
+	case KindVariant:
+		m, ok := x.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected a variant")
+		}
+		s, ok := m["type"].(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a variant type")
+		}
+		t := NewType(s)
+		if t == nil {
+			return nil, fmt.Errorf("invalid variant type `%s`", s)
+		}
+		v, err := decodeValue(t, m["value"])
+		if err != nil {
+			return nil, err
+		}
+		return &VariantValue{V: v, T: typ}, nil
+	}
+
+	return nil, fmt.Errorf("can't decode a value of type: %s", typ)
+}
@@ -0,0 +1,77 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package types
+
+import (
+	"testing"
+)
+
+func TestValueCodec0(t *testing.T) {
+	values := []Value{
+		&BoolValue{V: true},
+		&StrValue{V: "hello\nworld"},
+		&IntValue{V: -9007199254740993}, // not exact as a json float
+		&FloatValue{V: 0.1},
+	}
+
+	list := NewList(NewType("[]int"))
+	list.Add(&IntValue{V: 13})
+	list.Add(&IntValue{V: 42})
+	values = append(values, list)
+
+	m := NewMap(NewType("map{int: str}"))
+	m.Add(&IntValue{V: 1}, &StrValue{V: "one"})
+	m.Add(&IntValue{V: 2}, &StrValue{V: "two"})
+	values = append(values, m)
+
+	st := NewStruct(NewType("struct{a bool; b []str}"))
+	st.Set("a", &BoolValue{V: true})
+	st.Set("b", NewList(NewType("[]str")))
+	values = append(values, st)
+
+	for i, value := range values {
+		b, err := ValueToJSON(value)
+		if err != nil {
+			t.Errorf("test #%d: could not encode %s: %+v", i, value, err)
+			continue
+		}
+		out, err := ValueFromJSON(value.Type(), b)
+		if err != nil {
+			t.Errorf("test #%d: could not decode %s: %+v", i, b, err)
+			continue
+		}
+		// map values compare their keys by pointer, so use the strings
+		if err := value.Type().Cmp(out.Type()); err != nil || value.String() != out.String() {
+			t.Errorf("test #%d: values differ: %s != %s", i, value, out)
+		}
+	}
+}
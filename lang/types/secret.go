@@ -0,0 +1,174 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+package types
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var (
+	// secretKey is the random key used to identify secrets when they get
+	// printed. It is different each time that we run.
+	secretKey = func() []byte {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			panic(fmt.Sprintf("could not generate a secret key: %v", err))
+		}
+		return b
+	}()
+
+	// secretType is the golang type of a Secret.
+	secretType = reflect.TypeOf(Secret(""))
+)
+
+// Redact returns what a secret is printed as. It doesn't contain the secret, but
+// it does contain a keyed hash of it, so that the same secret is always printed
+// the same way and two different ones differ. Since the key is only known to
+// this process, the hash can't be used to guess the secret.
+func Redact(s string) string {
+	h := hmac.New(sha256.New, secretKey)
+	h.Write([]byte(s))
+	return fmt.Sprintf("<secret:%s>", hex.EncodeToString(h.Sum(nil))[:16])
+}
+
+// Secret is a string which holds sensitive data, such as a password. It can be
+// used as the type of a resource field which accepts secret values from mcl. It
+// has the mcl type of str. It is redacted whenever it gets printed, so that the
+// resource must call Reveal to get the real value, usually in CheckApply.
+type Secret string
+
+// Reveal returns the real value of this secret.
+func (obj Secret) Reveal() string {
+	return string(obj)
+}
+
+// ContainsAny returns true if this secret contains any of the chars. It lets a
+// resource validate a secret without needing to reveal it.
+func (obj Secret) ContainsAny(chars string) bool {
+	return strings.ContainsAny(string(obj), chars)
+}
+
+// String returns a redacted representation of this secret. This is what gets
+// printed by the %v and %s verbs.
+func (obj Secret) String() string {
+	return Redact(string(obj))
+}
+
+// GoString returns a redacted representation of this secret. This is what gets
+// printed by the %#v verb.
+func (obj Secret) GoString() string {
+	return Redact(string(obj))
+}
+
+// NewSecret returns a new str value which is a secret.
+func NewSecret(s string) *StrValue {
+	return &StrValue{V: s, Secret: true}
+}
+
+// IsSecret returns true if this value is a secret, or if it contains one.
+func IsSecret(v Value) bool {
+	switch x := v.(type) {
+	case *StrValue:
+		return x.Secret
+
+	case *ListValue:
+		for _, v := range x.V {
+			if IsSecret(v) {
+				return true
+			}
+		}
+
+	case *MapValue:
+		for k, v := range x.V {
+			if IsSecret(k) || IsSecret(v) {
+				return true
+			}
+		}
+
+	case *StructValue:
+		for _, v := range x.V {
+			if IsSecret(v) {
+				return true
+			}
+		}
+
+	case *VariantValue:
+		return x.V != nil && IsSecret(x.V)
+	}
+	return false
+}
+
+// MakeSecret returns a copy of this value with every str inside of it turned
+// into a secret. The other kinds of values, such as the length of a secret, are
+// left as they are. Function values are also left as they are, because the
+// values that they produce at runtime get their own secrecy from their args.
+func MakeSecret(v Value) Value {
+	switch x := v.(type) {
+	case *StrValue:
+		if x.Secret {
+			return x
+		}
+		return NewSecret(x.V)
+
+	case *ListValue:
+		values := []Value{}
+		for _, v := range x.V {
+			values = append(values, MakeSecret(v))
+		}
+		return &ListValue{T: x.T, V: values}
+
+	case *MapValue:
+		m := make(map[Value]Value)
+		for k, v := range x.V {
+			m[MakeSecret(k)] = MakeSecret(v)
+		}
+		return &MapValue{T: x.T, V: m}
+
+	case *StructValue:
+		m := make(map[string]Value)
+		for k, v := range x.V {
+			m[k] = MakeSecret(v)
+		}
+		return &StructValue{T: x.T, V: m}
+
+	case *VariantValue:
+		if x.V == nil {
+			return x
+		}
+		return &VariantValue{T: x.T, V: MakeSecret(x.V)}
+	}
+	return v
+}
@@ -0,0 +1,143 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSecretRedact0(t *testing.T) {
+	s := Secret("hunter2")
+	for _, str := range []string{s.String(), fmt.Sprintf("%v", s), fmt.Sprintf("%#v", s), NewSecret("hunter2").String()} {
+		if strings.Contains(str, "hunter2") {
+			t.Errorf("secret was printed: %s", str)
+		}
+		if str != Redact("hunter2") {
+			t.Errorf("expected %s, got: %s", Redact("hunter2"), str)
+		}
+	}
+	if Redact("hunter2") == Redact("hunter3") {
+		t.Errorf("different secrets were printed the same way")
+	}
+	if s.Reveal() != "hunter2" {
+		t.Errorf("unexpected reveal: %s", s.Reveal())
+	}
+	if !s.ContainsAny("2:") || s.ContainsAny(":\n") {
+		t.Errorf("unexpected chars in the secret")
+	}
+}
+
+func TestSecretCmp0(t *testing.T) {
+	if err := NewSecret("a").Cmp(NewSecret("a")); err != nil {
+		t.Errorf("expected equal secrets: %+v", err)
+	}
+	if err := NewSecret("a").Cmp(&StrValue{V: "a"}); err == nil {
+		t.Errorf("expected a secret to differ from a plain str")
+	}
+	if c := NewSecret("a").Copy().(*StrValue); !c.Secret {
+		t.Errorf("copy lost the secret")
+	}
+}
+
+func TestMakeSecret0(t *testing.T) {
+	v, err := ValueOf(reflect.ValueOf(map[string][]string{"alpha": {"bravo", "charlie"}}))
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if IsSecret(v) {
+		t.Errorf("value should not be a secret")
+	}
+	s := MakeSecret(v)
+	if !IsSecret(s) {
+		t.Errorf("value should be a secret")
+	}
+	if IsSecret(v) {
+		t.Errorf("original value was modified")
+	}
+	if str := s.String(); strings.Contains(str, "alpha") || strings.Contains(str, "bravo") {
+		t.Errorf("secret was printed: %s", str)
+	}
+	if x := MakeSecret(&IntValue{V: 42}); IsSecret(x) {
+		t.Errorf("an int can't be a secret")
+	}
+}
+
+func TestSecretInto0(t *testing.T) {
+	type foo struct {
+		A string      `lang:"a"`
+		B Secret      `lang:"b"`
+		C *Secret     `lang:"c"`
+		D interface{} `lang:"d"`
+	}
+	st := &StructValue{
+		T: NewType("struct{a str; b str; c str; d str}"),
+		V: map[string]Value{
+			"a": &StrValue{V: "x"},
+			"b": NewSecret("y"),
+			"c": NewSecret("z"),
+			"d": &StrValue{V: "w"},
+		},
+	}
+	f := &foo{}
+	if err := Into(st, reflect.ValueOf(f)); err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	if f.A != "x" || f.B.Reveal() != "y" || f.C == nil || f.C.Reveal() != "z" || f.D != "w" {
+		t.Errorf("unexpected result: %+v", f)
+	}
+
+	// a secret must not end up somewhere that would print it
+	for _, field := range []string{"a", "d"} {
+		st.V[field] = NewSecret("oops")
+		if err := Into(st, reflect.ValueOf(&foo{})); err == nil {
+			t.Errorf("expected an error for secret field: %s", field)
+		}
+		st.V[field] = &StrValue{V: "ok"}
+	}
+
+	type bar struct {
+		A string  `lang:"a"`
+		B Secret  `lang:"b"`
+		C *Secret `lang:"c"`
+	}
+	v, err := ValueOf(reflect.ValueOf(&bar{A: f.A, B: f.B, C: f.C}))
+	if err != nil {
+		t.Fatalf("error: %+v", err)
+	}
+	x := v.(*StructValue)
+	if IsSecret(x.V["a"]) || !IsSecret(x.V["b"]) || !IsSecret(x.V["c"]) {
+		t.Errorf("unexpected secrecy: %+v", x)
+	}
+}
@@ -84,6 +84,12 @@ type Type struct {
 	Ord []string
 	Out *Type // if Kind == Func, use Map and Ord for Input, Out for Output
 	Var *Type // if Kind == Variant, use Var only
+
+	// Name is the name of the type alias that this type was declared with.
+	// It is only used to print friendlier messages, and it is not compared
+	// in Cmp. If the Kind is KindNil, then this is an unresolved reference
+	// to the alias of that name, and it must be resolved before any use.
+	Name string
 }
 
 // TypeOf takes a reflect.Type and returns an equivalent *Type. It removes any
@@ -591,6 +597,15 @@ func NewType(s string) *Type {
 	return nil // error (this also matches the empty string as input)
 }
 
+// NewNamedType returns an unresolved reference to the type alias with this
+// name. It has no kind until it is replaced with the real type using Resolve.
+func NewNamedType(name string) *Type {
+	return &Type{
+		Kind: KindNil,
+		Name: name,
+	}
+}
+
 // New creates a new Value of this type. It will represent the "zero" value. It
 // panics if you give it a malformed type.
 func (obj *Type) New() Value {
@@ -623,6 +638,11 @@ func (obj *Type) New() Value {
 // String returns the textual representation for this type.
 func (obj *Type) String() string {
 	switch obj.Kind {
+	case KindNil:
+		if obj.Name != "" { // unresolved reference to a type alias
+			return obj.Name
+		}
+
 	case KindBool:
 		return "bool"
 	case KindStr:
@@ -699,11 +719,33 @@ func (obj *Type) String() string {
 	panic("malformed type")
 }
 
-// Cmp compares this type to another.
+// Cmp compares this type to another. If either type was declared with a type
+// alias, then the error message will mention the name of that alias.
 func (obj *Type) Cmp(typ *Type) error {
 	if obj == nil || typ == nil {
 		return fmt.Errorf("cannot compare to nil")
 	}
+	err := obj.cmp(typ)
+	if err == nil || (obj.Name == "" && typ.Name == "") {
+		return err
+	}
+	return errwrap.Wrapf(err, "type `%s` does not match `%s`", obj.named(), typ.named())
+}
+
+// named returns the alias name of this type if it has one, and the textual
+// representation otherwise.
+func (obj *Type) named() string {
+	if obj.Name != "" {
+		return obj.Name
+	}
+	return obj.String()
+}
+
+// cmp is the implementation of Cmp without the alias name error wrapping.
+func (obj *Type) cmp(typ *Type) error {
+	if obj == nil || typ == nil {
+		return fmt.Errorf("cannot compare to nil")
+	}
 
 	// TODO: is this correct?
 	// recurse into variants if we want base type comparisons
@@ -852,9 +894,17 @@ func (obj *Type) Cmp(typ *Type) error {
 }
 
 // Copy copies this type so that inplace modification won't affect the original.
+// The name of a type alias is kept for the outermost type only.
 func (obj *Type) Copy() *Type {
+	if obj.Kind == KindNil && obj.Name != "" { // unresolved
+		return NewNamedType(obj.Name)
+	}
 	// String() needs to print function arg names or they'd get erased here!
-	return NewType(obj.String()) // hack to do this easily
+	typ := NewType(obj.String()) // hack to do this easily
+	if typ != nil {
+		typ.Name = obj.Name
+	}
+	return typ
 }
 
 // Reflect returns a representative type satisfying the golang Type Interface.
@@ -1044,6 +1094,104 @@ func (obj *Type) HasVariant() bool {
 	panic("malformed type")
 }
 
+// HasUnresolved tells us if the type contains any unresolved references to a
+// type alias. These come from NewNamedType and are replaced using Resolve.
+func (obj *Type) HasUnresolved() bool {
+	if obj == nil {
+		return false
+	}
+	switch obj.Kind {
+	case KindNil:
+		return obj.Name != ""
+
+	case KindList:
+		return obj.Val.HasUnresolved()
+
+	case KindMap:
+		return obj.Key.HasUnresolved() || obj.Val.HasUnresolved()
+
+	case KindStruct, KindFunc:
+		for _, k := range obj.Ord {
+			if obj.Map[k].HasUnresolved() {
+				return true
+			}
+		}
+		return obj.Out.HasUnresolved()
+
+	case KindVariant:
+		return obj.Var.HasUnresolved()
+	}
+
+	return false
+}
+
+// Resolve returns a copy of this type where every unresolved reference to a
+// type alias has been replaced by the type that the lookup function returns for
+// that name. The replacements remember the alias name so that messages can use
+// it. If there is nothing to resolve, then this returns the original pointer.
+func (obj *Type) Resolve(lookup func(name string) (*Type, error)) (*Type, error) {
+	if obj == nil || !obj.HasUnresolved() {
+		return obj, nil
+	}
+	switch obj.Kind {
+	case KindNil:
+		typ, err := lookup(obj.Name)
+		if err != nil {
+			return nil, err
+		}
+		if typ == nil || typ.HasUnresolved() {
+			return nil, fmt.Errorf("type alias `%s` is not resolved", obj.Name)
+		}
+		typ = typ.Copy()
+		typ.Name = obj.Name
+		return typ, nil
+
+	case KindList:
+		val, err := obj.Val.Resolve(lookup)
+		if err != nil {
+			return nil, err
+		}
+		return &Type{Kind: KindList, Val: val, Name: obj.Name}, nil
+
+	case KindMap:
+		key, err := obj.Key.Resolve(lookup)
+		if err != nil {
+			return nil, err
+		}
+		val, err := obj.Val.Resolve(lookup)
+		if err != nil {
+			return nil, err
+		}
+		return &Type{Kind: KindMap, Key: key, Val: val, Name: obj.Name}, nil
+
+	case KindStruct, KindFunc:
+		m := make(map[string]*Type)
+		ord := []string{}
+		for _, k := range obj.Ord {
+			t, err := obj.Map[k].Resolve(lookup)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = t
+			ord = append(ord, k)
+		}
+		out, err := obj.Out.Resolve(lookup)
+		if err != nil {
+			return nil, err
+		}
+		return &Type{Kind: obj.Kind, Map: m, Ord: ord, Out: out, Name: obj.Name}, nil
+
+	case KindVariant:
+		v, err := obj.Var.Resolve(lookup)
+		if err != nil {
+			return nil, err
+		}
+		return &Type{Kind: KindVariant, Var: v, Name: obj.Name}, nil
+	}
+
+	return nil, fmt.Errorf("malformed type")
+}
+
 // ComplexCmp tells us if the input type is compatible with the concrete one. It
 // can match against types containing variants, or against partial types. If the
 // two types are equivalent, it will return nil. If the input type is identical,
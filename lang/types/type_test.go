@@ -33,6 +33,7 @@ package types
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/purpleidea/mgmt/util"
@@ -1498,6 +1499,62 @@ func TestTypeCopy0(t *testing.T) {
 	}
 }
 
+func TestTypeResolve0(t *testing.T) {
+	aliases := map[string]*Type{
+		"port": NewType("int"),
+		"addr": NewType("struct{host str; port int}"),
+	}
+	lookup := func(name string) (*Type, error) {
+		if typ, exists := aliases[name]; exists {
+			return typ, nil
+		}
+		return nil, fmt.Errorf("type `%s` does not exist", name)
+	}
+
+	typ := &Type{
+		Kind: KindMap,
+		Key:  NewType("str"),
+		Val: &Type{
+			Kind: KindList,
+			Val:  NewNamedType("addr"),
+		},
+	}
+	if !typ.HasUnresolved() {
+		t.Errorf("expected unresolved type")
+	}
+	if s := typ.String(); s != "map{str: []addr}" {
+		t.Errorf("unexpected unresolved string: %s", s)
+	}
+	resolved, err := typ.Resolve(lookup)
+	if err != nil {
+		t.Errorf("resolve failed: %+v", err)
+		return
+	}
+	if resolved.HasUnresolved() {
+		t.Errorf("expected resolved type")
+	}
+	if err := resolved.Cmp(NewType("map{str: []struct{host str; port int}}")); err != nil {
+		t.Errorf("resolved type is wrong: %+v", err)
+	}
+	if name := resolved.Val.Val.Name; name != "addr" {
+		t.Errorf("expected alias name to be kept, got: %s", name)
+	}
+
+	if _, err := NewNamedType("nope").Resolve(lookup); err == nil {
+		t.Errorf("expected resolve of missing alias to fail")
+	}
+
+	port, err := NewNamedType("port").Resolve(lookup)
+	if err != nil {
+		t.Errorf("resolve failed: %+v", err)
+		return
+	}
+	err = port.Cmp(NewType("str"))
+	if err == nil || !strings.Contains(err.Error(), "type `port` does not match `str`") {
+		t.Errorf("expected error with alias name, got: %v", err)
+	}
+}
+
 func TestTypeOf0(t *testing.T) {
 	// TODO: implement testing of the TypeOf function
 	// TODO: implement testing TypeOf for struct field name mappings
@@ -117,6 +117,9 @@ func ValueOf(v reflect.Value) (Value, error) {
 		if v, ok := (value.Interface()).(net.HardwareAddr); ok {
 			return &StrValue{V: v.String()}, nil
 		}
+		if v, ok := (value.Interface()).(Secret); ok {
+			return NewSecret(v.Reveal()), nil
+		}
 	}
 	// TODO: net/url.URL, time.Duration, etc. Note: avoid net/mail.Address
 
@@ -332,6 +335,9 @@ func Into(v Value, rv reflect.Value) error {
 	// This is used when we are setting a resource field which has type of
 	// interface{} instead of a string, bool, list, etc...
 	if isInterface := typ.Kind() == reflect.Interface; isInterface {
+		if IsSecret(v) { // we'd lose track of it
+			return fmt.Errorf("cannot Into() a secret into %s", typ)
+		}
 		//x := reflect.ValueOf(v) // no!
 		// use the value with type interface{}, not types.Value
 		x := reflect.ValueOf(v.Value())
@@ -352,6 +358,10 @@ func Into(v Value, rv reflect.Value) error {
 		if err := mustInto(reflect.String); err != nil {
 			return err
 		}
+		// Only a Secret can hold one, so that it doesn't get printed.
+		if v.Secret && typ != secretType {
+			return fmt.Errorf("cannot Into() a secret into %s, only into a %s", typ, secretType)
+		}
 
 		rv.SetString(v.V)
 		return nil
@@ -654,10 +664,12 @@ func (obj *BoolValue) Bool() bool {
 	return obj.V
 }
 
-// StrValue represents a string value.
+// StrValue represents a string value. If Secret is true, then it holds
+// sensitive data, and it is redacted when it gets printed. See Secret.
 type StrValue struct {
 	Base
-	V string
+	V      string
+	Secret bool
 }
 
 // NewStr creates a new string value.
@@ -665,6 +677,9 @@ func NewStr() *StrValue { return &StrValue{} }
 
 // String returns a visual representation of this value.
 func (obj *StrValue) String() string {
+	if obj.Secret {
+		return Redact(obj.V)
+	}
 	return strconv.Quote(obj.V) // wraps in quotes, turns tabs into \t etc...
 	//return fmt.Sprintf(`"%s"`, obj.V)
 }
@@ -690,12 +705,15 @@ func (obj *StrValue) Cmp(val Value) error {
 	if obj.V != val.(*StrValue).V {
 		return fmt.Errorf("values are different")
 	}
+	if obj.Secret != val.(*StrValue).Secret {
+		return fmt.Errorf("values differ in secrecy")
+	}
 	return nil
 }
 
 // Copy returns a copy of this value.
 func (obj *StrValue) Copy() Value {
-	return &StrValue{V: obj.V}
+	return &StrValue{V: obj.V, Secret: obj.Secret}
 }
 
 // Value returns the raw value of this type.
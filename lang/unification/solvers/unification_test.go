@@ -775,6 +775,251 @@ func TestUnification1(t *testing.T) {
 		})
 	}
 
+	{
+		//import "list"
+		//test "t1" {
+		//	slicestring => list.reverse(["a", "b",]),
+		//}
+		expr := &ast.ExprCall{
+			Name: "list.reverse",
+			Args: []interfaces.Expr{
+				&ast.ExprList{
+					Elements: []interfaces.Expr{
+						&ast.ExprStr{V: "a"},
+						&ast.ExprStr{V: "b"},
+					},
+				},
+			},
+		}
+		stmt := &ast.StmtProg{
+			Body: []interfaces.Stmt{
+				&ast.StmtImport{
+					Name: "list",
+				},
+				&ast.StmtRes{
+					Kind: "test",
+					Name: &ast.ExprStr{V: "t1"},
+					Contents: []ast.StmtResContents{
+						&ast.StmtResField{
+							Field: "slicestring",
+							Value: expr,
+						},
+					},
+				},
+			},
+		}
+		testCases = append(testCases, test{
+			name: "template polyfunc, list reverse",
+			ast:  stmt,
+			fail: false,
+			expect: map[interfaces.Expr]*types.Type{
+				expr: types.NewType("[]str"),
+			},
+		})
+	}
+	{
+		//import "list"
+		//$z = list.zip([13,], [true,])
+		//test "t1" {
+		//	int64ptr => len($z),
+		//}
+		expr := &ast.ExprCall{
+			Name: "list.zip",
+			Args: []interfaces.Expr{
+				&ast.ExprList{
+					Elements: []interfaces.Expr{
+						&ast.ExprInt{V: 13},
+					},
+				},
+				&ast.ExprList{
+					Elements: []interfaces.Expr{
+						&ast.ExprBool{V: true},
+					},
+				},
+			},
+		}
+		stmt := &ast.StmtProg{
+			Body: []interfaces.Stmt{
+				&ast.StmtImport{
+					Name: "list",
+				},
+				&ast.StmtBind{
+					Ident: "z",
+					Value: expr,
+				},
+				&ast.StmtRes{
+					Kind: "test",
+					Name: &ast.ExprStr{V: "t1"},
+					Contents: []ast.StmtResContents{
+						&ast.StmtResField{
+							Field: "int64ptr",
+							Value: &ast.ExprCall{
+								Name: "len",
+								Args: []interfaces.Expr{
+									&ast.ExprVar{Name: "z"},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		testCases = append(testCases, test{
+			name: "template polyfunc, list zip",
+			ast:  stmt,
+			fail: false,
+			expect: map[interfaces.Expr]*types.Type{
+				expr: types.NewType("[]struct{a int; b bool}"),
+			},
+		})
+	}
+	{
+		//import "maps"
+		//test "t1" {
+		//	slicestring => maps.keys({"a" => 1.0,}),
+		//}
+		expr := &ast.ExprCall{
+			Name: "maps.keys",
+			Args: []interfaces.Expr{
+				&ast.ExprMap{
+					KVs: []*ast.ExprMapKV{
+						{
+							Key: &ast.ExprStr{V: "a"},
+							Val: &ast.ExprFloat{V: 1.0},
+						},
+					},
+				},
+			},
+		}
+		stmt := &ast.StmtProg{
+			Body: []interfaces.Stmt{
+				&ast.StmtImport{
+					Name: "maps",
+				},
+				&ast.StmtRes{
+					Kind: "test",
+					Name: &ast.ExprStr{V: "t1"},
+					Contents: []ast.StmtResContents{
+						&ast.StmtResField{
+							Field: "slicestring",
+							Value: expr,
+						},
+					},
+				},
+			},
+		}
+		testCases = append(testCases, test{
+			name: "template polyfunc, maps keys",
+			ast:  stmt,
+			fail: false,
+			expect: map[interfaces.Expr]*types.Type{
+				expr: types.NewType("[]str"),
+			},
+		})
+	}
+	{
+		//import "list"
+		//test "t1" {
+		//	int64ptr => list.index_of([13,], "hello"),
+		//}
+		expr := &ast.ExprCall{
+			Name: "list.index_of",
+			Args: []interfaces.Expr{
+				&ast.ExprList{
+					Elements: []interfaces.Expr{
+						&ast.ExprInt{V: 13},
+					},
+				},
+				&ast.ExprStr{V: "hello"},
+			},
+		}
+		stmt := &ast.StmtProg{
+			Body: []interfaces.Stmt{
+				&ast.StmtImport{
+					Name: "list",
+				},
+				&ast.StmtRes{
+					Kind: "test",
+					Name: &ast.ExprStr{V: "t1"},
+					Contents: []ast.StmtResContents{
+						&ast.StmtResField{
+							Field: "int64ptr",
+							Value: expr,
+						},
+					},
+				},
+			},
+		}
+		testCases = append(testCases, test{
+			name: "template polyfunc, inconsistent type variable",
+			ast:  stmt,
+			fail: true,
+		})
+	}
+	{
+		//import "list"
+		//test "t1" {
+		//	int64ptr => list.fold([1, 2,], 0, func($acc, $x) { $acc + $x }),
+		//}
+		fn := &ast.ExprFunc{
+			Args: []*interfaces.Arg{
+				{
+					Name: "acc",
+				},
+				{
+					Name: "x",
+				},
+			},
+			Body: &ast.ExprCall{
+				Name: funcs.OperatorFuncName,
+				Args: []interfaces.Expr{
+					&ast.ExprStr{V: "+"},
+					&ast.ExprVar{Name: "acc"},
+					&ast.ExprVar{Name: "x"},
+				},
+			},
+		}
+		expr := &ast.ExprCall{
+			Name: "list.fold",
+			Args: []interfaces.Expr{
+				&ast.ExprList{
+					Elements: []interfaces.Expr{
+						&ast.ExprInt{V: 1},
+						&ast.ExprInt{V: 2},
+					},
+				},
+				&ast.ExprInt{V: 0},
+				fn,
+			},
+		}
+		stmt := &ast.StmtProg{
+			Body: []interfaces.Stmt{
+				&ast.StmtImport{
+					Name: "list",
+				},
+				&ast.StmtRes{
+					Kind: "test",
+					Name: &ast.ExprStr{V: "t1"},
+					Contents: []ast.StmtResContents{
+						&ast.StmtResField{
+							Field: "int64ptr",
+							Value: expr,
+						},
+					},
+				},
+			},
+		}
+		testCases = append(testCases, test{
+			name: "template polyfunc, list fold with lambda",
+			ast:  stmt,
+			fail: false,
+			expect: map[interfaces.Expr]*types.Type{
+				expr: types.TypeInt,
+				fn:   types.NewType("func(acc int, x int) int"),
+			},
+		})
+	}
+
 	names := []string{}
 	for index, tc := range testCases { // run all the tests
 		if tc.name == "" {
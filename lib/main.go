@@ -46,6 +46,7 @@ import (
 	"github.com/purpleidea/mgmt/engine"
 	"github.com/purpleidea/mgmt/engine/graph"
 	"github.com/purpleidea/mgmt/engine/graph/autogroup"
+	"github.com/purpleidea/mgmt/engine/hostfacts"
 	"github.com/purpleidea/mgmt/engine/local"
 	_ "github.com/purpleidea/mgmt/engine/resources" // let register's run
 	"github.com/purpleidea/mgmt/etcd"
@@ -190,6 +191,10 @@ type Config struct {
 
 	// PrometheusListen is the prometheus instance bind specification.
 	PrometheusListen string `arg:"--prometheus-listen" help:"specify prometheus instance binding"`
+
+	// PublishFacts publishes a set of facts about this host into the World
+	// so that the other hosts can read them with the world.facts functions.
+	PublishFacts bool `arg:"--publish-facts" help:"publish a set of facts about this host for the world.facts functions"`
 }
 
 // Main is the main struct for running the mgmt logic.
@@ -585,6 +590,9 @@ func (obj *Main) Run() error {
 			obj.Logf("local: api: "+format, v...)
 		},
 	}).Init()
+	if obj.pgpKeys != nil { // don't store a nil pointer in the interface
+		localAPI.Decrypter = obj.pgpKeys
+	}
 
 	// implementation of the World API (alternatives can be substituted in)
 	// XXX: The "implementation of the World API" should have more than just
@@ -602,6 +610,62 @@ func (obj *Main) Run() error {
 		},
 	}
 
+	if obj.PublishFacts {
+		publisher := &hostfacts.Publisher{
+			Hostname: hostname,
+			World:    world,
+			Debug:    obj.Debug,
+			Logf: func(format string, v ...interface{}) {
+				obj.Logf("facts: "+format, v...)
+			},
+		}
+		ctx, cancel := context.WithCancel(exitCtx)
+		publisherWg := &sync.WaitGroup{}
+		// This must finish before etcd shuts down, so that it can
+		// remove our facts. It can't use the main waitgroup, because
+		// that might wait on the exitchan which hasn't closed yet.
+		defer publisherWg.Wait()
+		defer cancel()
+		publisherWg.Add(1)
+		go func() {
+			defer publisherWg.Done()
+			if err := publisher.Run(ctx); err != nil {
+				Logf("facts: publisher failed: %+v", err)
+			}
+		}()
+	}
+
+	if obj.pgpKeys != nil {
+		publicKey, err := obj.pgpKeys.PublicKey()
+		if err != nil {
+			return errwrap.Wrapf(err, "can't export pgp public key")
+		}
+		// Publish our public key so that secrets can be encrypted to us
+		// with `mgmt secret encrypt`. It is left behind when we exit, so
+		// that this can still happen while we're not running.
+		ctx, cancel := context.WithCancel(exitCtx)
+		publishWg := &sync.WaitGroup{}
+		defer publishWg.Wait() // before etcd shuts down, like the facts
+		defer cancel()
+		publishWg.Add(1)
+		go func() {
+			defer publishWg.Done()
+			for {
+				err := world.StrMapSet(ctx, pgp.PublicKeyNamespace, publicKey)
+				if err == nil {
+					obj.Logf("pgp: published public key")
+					return
+				}
+				obj.Logf("pgp: could not publish public key: %+v", err)
+				select {
+				case <-time.After(5 * time.Second): // retry
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
 	obj.ge = &graph.Engine{
 		Program:   obj.Program,
 		Version:   obj.Version,
@@ -36,6 +36,7 @@ import (
 	"bytes"
 	"crypto"
 	"encoding/base64"
+	"fmt"
 	"io"
 	"os"
 	"strings"
@@ -43,11 +44,22 @@ import (
 	"github.com/purpleidea/mgmt/util/errwrap"
 
 	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
 	"golang.org/x/crypto/openpgp/packet"
+	// Keys made by openpgp.NewEntity don't list any preferred hashes, so
+	// encrypting to them falls back to this one, which must be linked in.
+	_ "golang.org/x/crypto/ripemd160"
 )
 
-// DefaultKeyringFile is the default file name for keyrings.
-const DefaultKeyringFile = "keyring.pgp"
+const (
+	// DefaultKeyringFile is the default file name for keyrings.
+	DefaultKeyringFile = "keyring.pgp"
+
+	// PublicKeyNamespace is the World string map namespace that each host
+	// publishes its public key into, so that secrets can be encrypted to
+	// it. It is reserved, so the exchange functions refuse to write to it.
+	PublicKeyNamespace = "_pgp"
+)
 
 // CONFIG set default Hash.
 var CONFIG packet.Config
@@ -192,6 +204,58 @@ func (obj *PGP) EncryptMsg(to *openpgp.Entity, msg string) (*bytes.Buffer, error
 	return buf, nil
 }
 
+// PublicKey returns the public part of the entity as an armored key. This is
+// safe to share, and can be parsed with ParsePublicKey.
+func (obj *PGP) PublicKey() (string, error) {
+	buf := new(bytes.Buffer)
+	w, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return "", errwrap.Wrapf(err, "can't create armor encoder")
+	}
+	if err := obj.Entity.Serialize(w); err != nil {
+		return "", errwrap.Wrapf(err, "can't serialize public key")
+	}
+	if err := w.Close(); err != nil {
+		return "", errwrap.Wrapf(err, "can't close armor encoder")
+	}
+	return buf.String(), nil
+}
+
+// ParsePublicKey parses an armored public key such as the one returned by the
+// PublicKey method.
+func ParsePublicKey(key string) (*openpgp.Entity, error) {
+	entityList, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+	if err != nil {
+		return nil, errwrap.Wrapf(err, "can't read public key")
+	}
+	if l := len(entityList); l != 1 {
+		return nil, fmt.Errorf("expected one public key, got %d", l)
+	}
+	return entityList[0], nil
+}
+
+// EncryptTo encrypts a message so that any one of the recipients can decrypt it
+// with Decrypt, and nobody else can. It is not signed, so it can be done without
+// a private key. The result is base64 encoded just like Encrypt.
+func EncryptTo(recipients []*openpgp.Entity, msg string) (string, error) {
+	if len(recipients) == 0 {
+		return "", fmt.Errorf("no recipients")
+	}
+
+	buf := new(bytes.Buffer)
+	w, err := openpgp.Encrypt(buf, recipients, nil, nil, &CONFIG)
+	if err != nil {
+		return "", errwrap.Wrapf(err, "can't encrypt message")
+	}
+	if _, err := w.Write([]byte(msg)); err != nil {
+		return "", errwrap.Wrapf(err, "can't write to buffer")
+	}
+	if err := w.Close(); err != nil {
+		return "", errwrap.Wrapf(err, "can't close writer")
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
 // Decrypt an encrypted msg.
 func (obj *PGP) Decrypt(encString string) (string, error) {
 	entityList := openpgp.EntityList{obj.Entity}
@@ -0,0 +1,91 @@
+// Mgmt
+// Copyright (C) 2013-2024+ James Shubin and the project contributors
+// Written by James Shubin <james@shubin.ca> and the project contributors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+// Additional permission under GNU GPL version 3 section 7
+//
+// If you modify this program, or any covered work, by linking or combining it
+// with embedded mcl code and modules (and that the embedded mcl code and
+// modules which link with this program, contain a copy of their source code in
+// the authoritative form) containing parts covered by the terms of any other
+// license, the licensors of this program grant you additional permission to
+// convey the resulting work. Furthermore, the licensors of this program grant
+// the original author, James Shubin, additional permission to update this
+// additional permission if he deems it necessary to achieve the goals of this
+// additional permission.
+
+//go:build !root
+
+package pgp
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestEncryptTo(t *testing.T) {
+	hosts := []string{"h1", "h2", "h3"}
+	keys := make(map[string]*PGP)
+	for _, host := range hosts {
+		p, err := Generate(host, "test", "root@"+host, nil)
+		if err != nil {
+			t.Fatalf("could not generate key: %+v", err)
+		}
+		keys[host] = p
+	}
+
+	recipients := []*openpgp.Entity{}
+	for _, host := range []string{"h1", "h2"} {
+		publicKey, err := keys[host].PublicKey()
+		if err != nil {
+			t.Fatalf("could not export public key: %+v", err)
+		}
+		entity, err := ParsePublicKey(publicKey)
+		if err != nil {
+			t.Fatalf("could not parse public key: %+v", err)
+		}
+		if entity.PrivateKey != nil {
+			t.Fatalf("private key was exported")
+		}
+		recipients = append(recipients, entity)
+	}
+
+	msg := "hello secret world"
+	enc, err := EncryptTo(recipients, msg)
+	if err != nil {
+		t.Fatalf("could not encrypt: %+v", err)
+	}
+
+	for _, host := range []string{"h1", "h2"} {
+		dec, err := keys[host].Decrypt(enc)
+		if err != nil {
+			t.Errorf("host %s could not decrypt: %+v", host, err)
+			continue
+		}
+		if dec != msg {
+			t.Errorf("host %s decrypted: %s", host, dec)
+		}
+	}
+
+	if dec, err := keys["h3"].Decrypt(enc); err == nil {
+		t.Errorf("host h3 was not a recipient, but it decrypted: %s", dec)
+	}
+
+	if _, err := EncryptTo(nil, msg); err == nil {
+		t.Errorf("expected an error with no recipients")
+	}
+}
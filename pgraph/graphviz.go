@@ -220,18 +220,31 @@ type GraphvizOpts struct {
 	// Font represents the node font to use.
 	// TODO: implement me
 	Font string
+
+	// Labels holds some optional extra text to display under the name of
+	// each vertex. Each line of the text is shown on a separate line.
+	Labels map[Vertex]string
 }
 
 func (obj *Graph) graphvizBody(opts *GraphvizOpts) string {
 	str := ""
 	style := ""
+	labels := make(map[Vertex]string)
 	if opts != nil {
 		style = opts.Style
+		if opts.Labels != nil {
+			labels = opts.Labels
+		}
 	}
 
 	// all in deterministic order
 	for _, i := range obj.VerticesSorted() { // reverse paths
 		v1 := html.EscapeString(i.String()) // 1st vertex
+		if label, exists := labels[i]; exists && label != "" {
+			for _, line := range strings.Split(label, "\n") {
+				v1 += "<BR />" + html.EscapeString(line)
+			}
+		}
 		if ptrLabels {
 			text := fmt.Sprintf("%p", i)
 			small := fmt.Sprintf("<FONT POINT-SIZE=\"%d\">%s</FONT>", ptrLabelsSize, text)
@@ -94,15 +94,22 @@ func (obj *GAPI) Cli(info *gapi.Info) (*gapi.Deploy, error) {
 
 	langInfo := &gapi.Info{
 		Args: &cliUtil.LangArgs{
-			Input:        args.LangInput,
-			Download:     args.Download,
-			OnlyDownload: args.OnlyDownload,
-			Update:       args.Update,
-			OnlyUnify:    args.OnlyUnify,
-			SkipUnify:    args.SkipUnify,
-			Depth:        args.Depth,
-			Retry:        args.Retry,
-			ModulePath:   args.ModulePath,
+			Input:              args.LangInput,
+			Download:           args.Download,
+			OnlyDownload:       args.OnlyDownload,
+			Update:             args.Update,
+			OnlyUnify:          args.OnlyUnify,
+			SkipUnify:          args.SkipUnify,
+			Depth:              args.Depth,
+			Retry:              args.Retry,
+			ModulePath:         args.ModulePath,
+			ModuleCache:        args.ModuleCache,
+			FuncProfile:        args.FuncProfile,
+			FuncGraphviz:       args.FuncGraphviz,
+			FuncGraphvizFilter: args.FuncGraphvizFilter,
+			FuncInspect:        args.FuncInspect,
+			FuncRecord:         args.FuncRecord,
+			FuncReplay:         args.FuncReplay,
 		},
 		Flags: flags,
 		Fs:    fs,
@@ -66,7 +66,7 @@ func NewSocketSet(groups uint32, name string, proto int) (*SocketSet, error) {
 	if err := unix.Bind(fdEvents, &unix.SockaddrNetlink{
 		Family: unix.AF_NETLINK,
 		Groups: groups,
-		Pid:    uint32(os.Getpid()), // set PID to our process
+		Pid:    0, // let the kernel pick a unique id, so we can have many
 	}); err != nil {
 		return nil, errwrap.Wrapf(err, "error binding netlink socket")
 	}